@@ -5,8 +5,12 @@
 package actions
 
 import (
+	"errors"
+	"github.com/jinzhu/gorm"
 	admin_middleware "github.com/orivil/morgine/bundles/admin/middleware"
 	admin_model "github.com/orivil/morgine/bundles/admin/model"
+	"github.com/orivil/morgine/utils/sql"
+	"github.com/orivil/morgine/utils/token"
 	"github.com/orivil/morgine/xx"
 )
 
@@ -17,6 +21,7 @@ var Login xx.Action = func(method, route string, controller *xx.Condition) {
 	}
 	doc := &xx.Doc {
 		Title: "获得登录授权",
+		Desc: "连续登录失败后用户名或 IP 将被锁定, 锁定期间返回剩余等待秒数",
 		Params: xx.Params {
 			{
 				Type:   xx.Form,
@@ -24,9 +29,24 @@ var Login xx.Action = func(method, route string, controller *xx.Condition) {
 			},
 		},
 		Responses: xx.Responses{
-			xx.MessageResponse(xx.MsgTypeWarning),
 			{
-				Body: xx.MAP{"token": "token string"},
+				Body: xx.MessageData(xx.MsgWarning, admin_model.ErrPasswordIncorrect.Error()),
+			},
+			{
+				Description: "登录被锁定",
+				Body: xx.StatusJsonData(StatusLoginLocked, xx.MAP{"wait_seconds": 60, "wait_at": 1577808000}),
+			},
+			{
+				Description: "已启用两步验证, 以 mfa_token 调用两步验证登录接口",
+				Body: xx.StatusJsonData(StatusTwoFactorRequired, xx.MAP{"mfa_token": "mfa token", "expires_in": 300}),
+			},
+			{
+				Description: "所属角色要求两步验证但尚未设置, 以 mfa_token 设置并启用两步验证",
+				Body: xx.StatusJsonData(StatusTwoFactorSetupRequired, xx.MAP{"mfa_token": "mfa token", "expires_in": 300}),
+			},
+			{
+				Description: "access_token 用于 Authorization 请求头, 过期后使用 refresh_token 换取新的令牌",
+				Body: &token.Pair{AccessToken: "access token", TokenType: "Bearer", ExpiresIn: 900, RefreshToken: "refresh token"},
 			},
 		},
 	}
@@ -34,18 +54,33 @@ var Login xx.Action = func(method, route string, controller *xx.Condition) {
 		p := &params{}
 		err := ctx.Unmarshal(p)
 		if err != nil {
-			xx.HandleUnmarshalError(err, ctx)
+			ctx.SendJsonMessage(xx.MsgWarning, err.Error())
+			return
+		}
+		if sendLoginLocked(ctx, p.Username) {
+			return
+		}
+		admin, err := admin_model.SignIn(p.Username, p.Password)
+		if err != nil {
+			if err == admin_model.ErrUsernameIncorrect || err == admin_model.ErrPasswordIncorrect {
+				loginFailed(ctx, p.Username)
+				ctx.SendJsonMessage(xx.MsgWarning, err.Error())
+			} else if err == admin_model.ErrAdminDisabled {
+				ctx.SendJsonMessage(xx.MsgWarning, err.Error())
+			} else {
+				ctx.Error(err)
+			}
 		} else {
-			admin, err := admin_model.SignIn(p.Username, p.Password)
+			// 需要两步验证时, 验证通过后才清除失败记录
+			if challengeTwoFactor(ctx, admin) {
+				return
+			}
+			pair, err := admin_middleware.NewToken(ctx, admin)
 			if err != nil {
-				ctx.MsgWarning(err.Error())
+				ctx.Error(err)
 			} else {
-				token, err := admin_middleware.NewToken(admin)
-				if err != nil {
-					ctx.Error(err)
-				} else {
-					ctx.SendJSON(xx.MAP{"token": token})
-				}
+				loginSuccess(p.Username)
+				ctx.SendJSON(pair)
 			}
 		}
 	})
@@ -65,23 +100,29 @@ var ChangePassword xx.Action = func(method, route string, controller *xx.Conditi
 				Schema:&param{},
 			},
 		},
+		Responses: xx.Responses {
+			{
+				Body: xx.MessageData(xx.MsgSuccess, "修改成功"),
+			},
+		},
 	}
 	controller.Handle(method, route, doc, func(ctx *xx.Context) {
 		p := &param{}
 		err := ctx.Unmarshal(p)
 		if err != nil {
-			xx.HandleUnmarshalError(err, ctx)
-		} else {
-			if id, ok := admin_middleware.GetUserIDFromContext(ctx); ok {
-				err := admin_model.UpdatePassword(id, p.Username, p.NewPassword)
-				if err != nil {
-					ctx.MsgWarning(err.Error())
-				} else {
-					ctx.MsgSuccess("修改成功")
-				}
+			ctx.SendJsonMessage(xx.MsgWarning, err.Error())
+			return
+		}
+		id, _ := admin_middleware.GetUserIDFromContext(ctx)
+		err = admin_model.UpdatePassword(id, p.Username, p.NewPassword)
+		if err != nil {
+			if err == admin_model.ErrUserNotRegistered {
+				ctx.SendJsonMessage(xx.MsgWarning, err.Error())
 			} else {
-				ctx.MsgWarning("用户未登录")
+				ctx.Error(err)
 			}
+		} else {
+			ctx.SendJsonMessage(xx.MsgSuccess, "修改成功")
 		}
 	})
 }
@@ -99,19 +140,272 @@ var GetHashedPassword xx.Action = func(method, route string, controller *xx.Cond
 				Schema:&param{},
 			},
 		},
+		Responses: xx.Responses {
+			{
+				Body: xx.MAP{"password": "$2a$10$..."},
+			},
+		},
 	}
 	controller.Handle(method, route, doc, func(ctx *xx.Context) {
 		p := &param{}
 		err := ctx.Unmarshal(p)
 		if err != nil {
-			xx.HandleUnmarshalError(err, ctx)
+			ctx.SendJsonMessage(xx.MsgWarning, err.Error())
+			return
+		}
+		passwd, err := admin_model.HashPassword(p.Password)
+		if err != nil {
+			ctx.Error(err)
 		} else {
-			passwd, err := admin_model.HashPassword(p.Password)
-			if err != nil {
-				ctx.MsgWarning(err.Error())
-			} else {
-				ctx.SendJSON(xx.MAP{"password": passwd})
-			}
+			ctx.SendJSON(xx.MAP{"password": passwd})
+		}
+	})
+}
+var errManageSelf = errors.New("不能管理自己的账号")
+
+// 获得当前管理员可管理的账号, 超级管理员可管理除自己外的所有账号, 其他管理员只能管理自己的后代账号
+func manageableAdmin(operator *admin_model.Admin, adminID int) (*admin_model.Admin, error) {
+	if adminID == operator.ID {
+		return nil, errManageSelf
+	}
+	if !operator.Super {
+		return manageableSubAdmin(operator, adminID)
+	}
+	admin, err := admin_model.GetAdmin(adminID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, admin_model.ErrUserNotRegistered
+		}
+		return nil, err
+	}
+	return admin, nil
+}
+
+var GetAdmins xx.Action = func(method, route string, controller *xx.Condition) {
+	type params struct {
+		Username string `param:"username" desc:"用户名包含的字符"`
+		Disabled *bool  `param:"disabled" desc:"是否禁用, 为空时不限"`
+		Order    string `param:"order" enum:"id username created_at" desc:"排序字段, 默认为 id"`
+		Desc     bool   `param:"desc" desc:"是否倒序"`
+		Limit    int    `param:"limit" required:"" num:"1-100"`
+		Offset   int    `param:"offset"`
+	}
+	doc := &xx.Doc {
+		Title: "获得管理员列表",
+		Desc:  "非超级管理员只能查询自己的后代账号",
+		Params: xx.Params {
+			{
+				Type:   xx.Query,
+				Schema: &params{},
+			},
+		},
+		Responses: xx.Responses {
+			{
+				Body: xx.MAP{"total": 1, "admins": []*admin_model.Admin{{ID: 1, Username: "root", Forefather: "|", Super: true}}},
+			},
+		},
+	}
+	controller.Handle(method, route, doc, func(ctx *xx.Context) {
+		p := &params{}
+		err := ctx.Unmarshal(p)
+		if err != nil {
+			ctx.SendJsonMessage(xx.MsgWarning, err.Error())
+			return
+		}
+		operator, err := loginAdmin(ctx)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		filter := &admin_model.AdminFilter{Username: p.Username, Disabled: p.Disabled}
+		if !operator.Super {
+			filter.AncestorID = operator.ID
+		}
+		total, err := admin_model.CountAdminList(filter)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		admins, err := admin_model.GetAdminList(filter, sql.InitOrder(p.Order, p.Desc), p.Limit, p.Offset)
+		if err != nil {
+			ctx.Error(err)
+		} else {
+			ctx.SendJSON(xx.MAP{"total": total, "admins": admins})
+		}
+	})
+}
+
+var CreateAdmin xx.Action = func(method, route string, controller *xx.Condition) {
+	type params struct {
+		Username string `param:"username" required:"用户名不能为空"`
+		Password string `param:"password" required:"密码不能为空" len:"6-12" len-msg:"密码必须在6-12个字符之间" reg:"^[\\w|\\_]+$" reg-msg:"密码只能是字母数字或下划线"`
+		Super    bool   `param:"super" desc:"是否为超级管理员"`
+		RoleIDs  []int  `param:"role_ids" desc:"授予的角色ID, 只能授予当前管理员拥有的角色"`
+	}
+	doc := &xx.Doc {
+		Title: "创建管理员",
+		Desc:  "只有超级管理员可创建顶级账号, 创建子账号请使用子账号接口",
+		Params: xx.Params {
+			{
+				Type:   xx.Form,
+				Schema: &params{},
+			},
+		},
+		Responses: xx.Responses {
+			{
+				Body: xx.MessageData(xx.MsgWarning, admin_model.ErrNeedSuperAdmin.Error()),
+			},
+			{
+				Body: xx.MessageData(xx.MsgWarning, admin_model.ErrUsernameRegistered.Error()),
+			},
+			{
+				Body: xx.MAP{"admin": &admin_model.Admin{ID: 2, Username: "admin", Forefather: "|"}},
+			},
+		},
+	}
+	controller.Handle(method, route, doc, func(ctx *xx.Context) {
+		p := &params{}
+		err := ctx.Unmarshal(p)
+		if err != nil {
+			ctx.SendJsonMessage(xx.MsgWarning, err.Error())
+			return
+		}
+		operator, err := loginAdmin(ctx)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		if !operator.Super {
+			sendManageError(ctx, admin_model.ErrNeedSuperAdmin)
+			return
+		}
+		err = admin_model.CheckGrantableRoles(operator, p.RoleIDs)
+		if err != nil {
+			sendManageError(ctx, err)
+			return
+		}
+		admin, err := admin_model.CreateSubAdmin(nil, p.Username, p.Password, p.Super)
+		if err != nil {
+			sendManageError(ctx, err)
+			return
+		}
+		err = admin_model.ReplaceAdminRoles(admin.ID, p.RoleIDs)
+		if err != nil {
+			ctx.Error(err)
+		} else {
+			ctx.SendJSON(xx.MAP{"admin": admin})
+		}
+	})
+}
+
+var DisableAdmin xx.Action = func(method, route string, controller *xx.Condition) {
+	type params struct {
+		AdminID  int  `param:"admin_id" required:"账号ID不能为空"`
+		Disabled bool `param:"disabled" desc:"true 为禁用, false 为启用"`
+	}
+	doc := &xx.Doc {
+		Title: "禁用或启用管理员",
+		Desc:  "禁用的账号不能登录, 已登录的设备将退出登录, 账号及其数据仍然保留",
+		Params: xx.Params {
+			{
+				Type:   xx.Form,
+				Schema: &params{},
+			},
+		},
+		Responses: xx.Responses {
+			{
+				Body: xx.MessageData(xx.MsgWarning, admin_model.ErrNeedSuperAdmin.Error()),
+			},
+			{
+				Body: xx.MessageData(xx.MsgSuccess, "已禁用"),
+			},
+		},
+	}
+	controller.Handle(method, route, doc, func(ctx *xx.Context) {
+		p := &params{}
+		err := ctx.Unmarshal(p)
+		if err != nil {
+			ctx.SendJsonMessage(xx.MsgWarning, err.Error())
+			return
+		}
+		operator, err := loginAdmin(ctx)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		_, err = manageableAdmin(operator, p.AdminID)
+		if err != nil {
+			sendManageError(ctx, err)
+			return
+		}
+		err = admin_model.SetAdminDisabled(p.AdminID, p.Disabled)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		if !p.Disabled {
+			ctx.SendJsonMessage(xx.MsgSuccess, "已启用")
+			return
+		}
+		err = RevokeAdminTokens(p.AdminID)
+		if err != nil {
+			ctx.Error(err)
+		} else {
+			ctx.SendJsonMessage(xx.MsgSuccess, "已禁用")
 		}
 	})
-}
\ No newline at end of file
+}
+
+var ResetAdminPassword xx.Action = func(method, route string, controller *xx.Condition) {
+	type params struct {
+		AdminID  int    `param:"admin_id" required:"账号ID不能为空"`
+		Password string `param:"password" required:"密码不能为空" len:"6-12" len-msg:"密码必须在6-12个字符之间" reg:"^[\\w|\\_]+$" reg-msg:"密码只能是字母数字或下划线"`
+	}
+	doc := &xx.Doc {
+		Title: "重置管理员密码",
+		Desc:  "重置后该管理员已登录的设备将退出登录",
+		Params: xx.Params {
+			{
+				Type:   xx.Form,
+				Schema: &params{},
+			},
+		},
+		Responses: xx.Responses {
+			{
+				Body: xx.MessageData(xx.MsgWarning, admin_model.ErrNeedSuperAdmin.Error()),
+			},
+			{
+				Body: xx.MessageData(xx.MsgSuccess, "已重置"),
+			},
+		},
+	}
+	controller.Handle(method, route, doc, func(ctx *xx.Context) {
+		p := &params{}
+		err := ctx.Unmarshal(p)
+		if err != nil {
+			ctx.SendJsonMessage(xx.MsgWarning, err.Error())
+			return
+		}
+		operator, err := loginAdmin(ctx)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		_, err = manageableAdmin(operator, p.AdminID)
+		if err != nil {
+			sendManageError(ctx, err)
+			return
+		}
+		err = admin_model.ResetPassword(p.AdminID, p.Password)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		err = RevokeAdminTokens(p.AdminID)
+		if err != nil {
+			ctx.Error(err)
+		} else {
+			ctx.SendJsonMessage(xx.MsgSuccess, "已重置")
+		}
+	})
+}
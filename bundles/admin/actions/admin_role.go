@@ -9,139 +9,163 @@ import (
 	"github.com/orivil/morgine/xx"
 )
 
-var CountAdminRoles xx.Action = func(method, route string, controller *xx.Condition) {
+var GetAdminRoles xx.Action = func(method, route string, controller *xx.Condition) {
 	type params struct {
-		AdminID int `required:""`
+		AdminID int `param:"admin_id" desc:"管理员ID, 为空时不限"`
+		RoleID  int `param:"role_id" desc:"角色ID, 管理员ID为空时有效"`
+		Limit   int `param:"limit" required:"" num:"1-200"`
+		Offset  int `param:"offset"`
 	}
-	doc := xx.Doc {
-		Title: "统计管理员角色数量",
-		Params:xx.Params {
+	doc := &xx.Doc {
+		Title: "获得管理员-角色列表",
+		Desc:  "非超级管理员只能查询自己后代账号的角色",
+		Params: xx.Params {
 			{
-				Type: xx.Query,
+				Type:   xx.Query,
 				Schema: &params{},
 			},
 		},
-		Responses:xx.Responses {
+		Responses: xx.Responses {
 			{
-				Body: xx.MAP{"total": 5},
+				Body: xx.MAP{"total": 1, "admin_roles": []*admin_model.AdminRoleWithName{{ID: 1, AdminID: 2, RoleID: 1, AdminName: "admin", RoleName: "editor"}}},
 			},
 		},
 	}
-	controller.Handle(method, route, &doc, func(ctx *xx.Context) {
+	controller.Handle(method, route, doc, func(ctx *xx.Context) {
 		p := &params{}
 		err := ctx.Unmarshal(p)
 		if err != nil {
-			xx.HandleUnmarshalError(err, ctx)
-		} else {
-			roles, err := admin_model.CountAdminRoles(p.AdminID)
-			if err != nil {
-				ctx.MsgWarning(err.Error())
-			} else {
-				ctx.SendJSON(xx.MAP{"roles": roles})
-			}
+			ctx.SendJsonMessage(xx.MsgWarning, err.Error())
+			return
 		}
-	})
-}
-
-var GetAdminRoles xx.Action = func(method, route string, controller *xx.Condition) {
-	type params struct {
-		AdminID int `required:""`
-		Limit int `required:"" num:"1-200"`
-		Offset int
-	}
-	doc := xx.Doc {
-		Title: "获得管理员角色列表",
-		Params:xx.Params {
-			{
-				Type: xx.Query,
-				Schema: &params{},
-			},
-		},
-		Responses:xx.Responses {
-			{
-				Body: xx.MAP{"roles": []*admin_model.AdminRole{{}}},
-			},
-		},
-	}
-	controller.Handle(method, route, &doc, func(ctx *xx.Context) {
-		p := &params{}
-		err := ctx.Unmarshal(p)
+		operator, err := loginAdmin(ctx)
 		if err != nil {
-			xx.HandleUnmarshalError(err, ctx)
-		} else {
-			roles, err := admin_model.GetAdminRoles(p.AdminID, p.Limit, p.Offset)
-			if err != nil {
-				ctx.MsgWarning(err.Error())
-			} else {
-				ctx.SendJSON(xx.MAP{"roles": roles})
-			}
+			ctx.Error(err)
+			return
 		}
+		var ancestorID int
+		if !operator.Super {
+			ancestorID = operator.ID
+		}
+		total, err := admin_model.CountAdminRoles(ancestorID, p.AdminID, p.RoleID)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		roles := admin_model.GetAdminRoleWithNames(ancestorID, p.AdminID, p.RoleID, p.Limit, p.Offset)
+		ctx.SendJSON(xx.MAP{"total": total, "admin_roles": roles})
 	})
 }
 
 var SetAdminRole xx.Action = func(method, route string, controller *xx.Condition) {
 	type params struct {
-		AdminID int `required:""`
-		RoleID int `required:""`
+		AdminID int `param:"admin_id" required:"管理员ID不能为空"`
+		RoleID  int `param:"role_id" required:"角色ID不能为空"`
 	}
-	doc := xx.Doc {
+	doc := &xx.Doc {
 		Title: "设置管理员角色",
-		Desc: "如果角色已存在会报错",
-		Params:xx.Params {
+		Desc:  "只能授予当前管理员拥有的角色, 管理员已拥有该角色时不做任何操作",
+		Params: xx.Params {
 			{
-				Type: xx.Query,
+				Type:   xx.Form,
 				Schema: &params{},
 			},
 		},
-		Responses:xx.Responses {
-			xx.MessageResponse(xx.MsgTypeSuccess),
+		Responses: xx.Responses {
+			{
+				Body: xx.MessageData(xx.MsgWarning, admin_model.ErrRoleNotGrantable.Error()),
+			},
+			{
+				Body: xx.MessageData(xx.MsgSuccess, "已保存"),
+			},
 		},
 	}
-	controller.Handle(method, route, &doc, func(ctx *xx.Context) {
+	controller.Handle(method, route, doc, func(ctx *xx.Context) {
 		p := &params{}
 		err := ctx.Unmarshal(p)
 		if err != nil {
-			xx.HandleUnmarshalError(err, ctx)
+			ctx.SendJsonMessage(xx.MsgWarning, err.Error())
+			return
+		}
+		operator, err := loginAdmin(ctx)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		_, err = manageableAdmin(operator, p.AdminID)
+		if err != nil {
+			sendManageError(ctx, err)
+			return
+		}
+		_, err = admin_model.GetRole(p.RoleID)
+		if err != nil {
+			sendManageError(ctx, err)
+			return
+		}
+		err = admin_model.CheckGrantableRoles(operator, []int{p.RoleID})
+		if err != nil {
+			sendManageError(ctx, err)
+			return
+		}
+		err = admin_model.SetAdminRole(p.AdminID, p.RoleID)
+		if err != nil {
+			ctx.Error(err)
 		} else {
-			err := admin_model.SetAdminRole(p.AdminID, p.RoleID)
-			if err != nil {
-				ctx.MsgWarning(err.Error())
-			} else {
-				ctx.MsgSuccess("已保存")
-			}
+			ctx.SendJsonMessage(xx.MsgSuccess, "已保存")
 		}
 	})
 }
 
 var DelAdminRoles xx.Action = func(method, route string, controller *xx.Condition) {
 	type params struct {
-		AdminID int `required:"" desc:"管理员ID"`
-		RoleIDs []int `required:"" desc:"角色ID"`
+		AdminID int   `param:"admin_id" required:"管理员ID不能为空" desc:"管理员ID"`
+		RoleIDs []int `param:"role_ids" required:"角色ID不能为空" desc:"角色ID"`
 	}
-	doc := xx.Doc {
+	doc := &xx.Doc {
 		Title: "移除管理员角色",
-		Params:xx.Params {
+		Desc:  "只能移除当前管理员拥有的角色",
+		Params: xx.Params {
 			{
-				Type: xx.Query,
+				Type:   xx.Query,
 				Schema: &params{},
 			},
 		},
-		Responses:xx.Responses {
-			xx.MessageResponse(xx.MsgTypeSuccess),
+		Responses: xx.Responses {
+			{
+				Body: xx.MessageData(xx.MsgWarning, admin_model.ErrRoleNotGrantable.Error()),
+			},
+			{
+				Body: xx.MessageData(xx.MsgSuccess, "已移除"),
+			},
 		},
 	}
-	controller.Handle(method, route, &doc, func(ctx *xx.Context) {
+	controller.Handle(method, route, doc, func(ctx *xx.Context) {
 		p := &params{}
 		err := ctx.Unmarshal(p)
 		if err != nil {
-			xx.HandleUnmarshalError(err, ctx)
+			ctx.SendJsonMessage(xx.MsgWarning, err.Error())
+			return
+		}
+		operator, err := loginAdmin(ctx)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		_, err = manageableAdmin(operator, p.AdminID)
+		if err != nil {
+			sendManageError(ctx, err)
+			return
+		}
+		err = admin_model.CheckGrantableRoles(operator, p.RoleIDs)
+		if err != nil {
+			sendManageError(ctx, err)
+			return
+		}
+		err = admin_model.RemoveAdminRoles(p.AdminID, p.RoleIDs)
+		if err != nil {
+			ctx.Error(err)
 		} else {
-			err := admin_model.RemoveAdminRoles(p.AdminID, p.RoleIDs)
-			if err != nil {
-				ctx.MsgWarning(err.Error())
-			} else {
-				ctx.MsgSuccess("已移除")
-			}
+			ctx.SendJsonMessage(xx.MsgSuccess, "已移除")
 		}
 	})
-}
\ No newline at end of file
+}
@@ -0,0 +1,224 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package actions
+
+import (
+	"encoding/json"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	admin_middleware "github.com/orivil/morgine/bundles/admin/middleware"
+	admin_model "github.com/orivil/morgine/bundles/admin/model"
+	"github.com/orivil/morgine/utils/session"
+	"github.com/orivil/morgine/utils/token"
+	"github.com/orivil/morgine/xx"
+	"github.com/orivil/morgine/xx/xxtest"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// 使用内存 sqlite 数据库及进程内令牌管理器, 返回的函数用于关闭数据库并恢复原设置
+func setupAdminTest(t *testing.T) func() {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 每个连接都是独立的内存数据库
+	db.DB().SetMaxOpenConns(1)
+	err = db.AutoMigrate(&admin_model.Admin{}, &admin_model.Role{}, &admin_model.AdminRole{}, &admin_model.RolePermission{},
+		&admin_model.AdminTwoFactor{}, &admin_model.AdminRecoveryCode{}).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldDB, oldTokens := admin_model.DB, admin_middleware.Tokens
+	admin_model.DB = db
+	admin_middleware.Tokens = token.NewManager([]byte("secret"), session.NewMemoryStore(), token.NewMemoryDenylist())
+	return func() {
+		admin_model.DB, admin_middleware.Tokens = oldDB, oldTokens
+		db.Close()
+	}
+}
+
+func newAdminMux() *xx.ServeMux {
+	mux, c := xxtest.NewMux("admin", admin_middleware.Auth)
+	GetAdmins("GET", "/admins", c)
+	CreateAdmin("POST", "/admins", c)
+	DisableAdmin("PUT", "/admins/disabled", c)
+	ResetAdminPassword("PUT", "/admins/password", c)
+	UpdateRole("PUT", "/roles", c)
+	DeleteRoles("DELETE", "/roles", c)
+	return mux
+}
+
+// 以 admin 的身份请求, values 为 GET 及 DELETE 的查询参数或其他请求的表单参数
+func serveAs(t *testing.T, mux *xx.ServeMux, admin *admin_model.Admin, method, path string, values url.Values) *httptest.ResponseRecorder {
+	pair, err := admin_middleware.Tokens.Issue(strconv.Itoa(admin.ID), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return serveWithToken(mux, pair.AccessToken, method, path, values)
+}
+
+func serveWithToken(mux *xx.ServeMux, accessToken, method, path string, values url.Values) *httptest.ResponseRecorder {
+	var req *http.Request
+	if method == "GET" || method == "DELETE" {
+		req = httptest.NewRequest(method, path+"?"+values.Encode(), nil)
+	} else {
+		req = httptest.NewRequest(method, path, strings.NewReader(values.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	return xxtest.Serve(mux, req)
+}
+
+func decodeMessage(t *testing.T, res *httptest.ResponseRecorder) xx.Message {
+	msg := xx.Message{}
+	err := json.Unmarshal(res.Body.Bytes(), &msg)
+	if err != nil {
+		t.Fatalf("%v: %s", err, res.Body.String())
+	}
+	return msg
+}
+
+func mustCreateAdmin(t *testing.T, parent *admin_model.Admin, username string, super bool) *admin_model.Admin {
+	admin, err := admin_model.CreateSubAdmin(parent, username, "password", super)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return admin
+}
+
+func TestAdminActions_SubtreeScope(t *testing.T) {
+	defer setupAdminTest(t)()
+	mux := newAdminMux()
+	root := mustCreateAdmin(t, nil, "root", true)
+	a := mustCreateAdmin(t, root, "a", false)
+	sub := mustCreateAdmin(t, a, "sub", false)
+	other := mustCreateAdmin(t, root, "other", false)
+
+	// 非超级管理员不能管理后代账号以外的账号
+	cases := []struct {
+		method, path string
+		values       url.Values
+	} {
+		{"PUT", "/admins/disabled", url.Values{"admin_id": {strconv.Itoa(other.ID)}, "disabled": {"true"}}},
+		{"PUT", "/admins/disabled", url.Values{"admin_id": {strconv.Itoa(root.ID)}, "disabled": {"true"}}},
+		{"PUT", "/admins/password", url.Values{"admin_id": {strconv.Itoa(other.ID)}, "password": {"newpass"}}},
+		{"POST", "/admins", url.Values{"username": {"top"}, "password": {"newpass"}}},
+	}
+	for _, c := range cases {
+		msg := decodeMessage(t, serveAs(t, mux, a, c.method, c.path, c.values))
+		if msg.Type != xx.MsgWarning {
+			t.Errorf("%s %s %v: need warning, got: %+v", c.method, c.path, c.values, msg)
+		}
+	}
+	if got, _ := admin_model.GetAdmin(other.ID); got.Disabled {
+		t.Error("need other not disabled")
+	}
+	if _, err := admin_model.SignIn("other", "password"); err != nil {
+		t.Errorf("need other password unchanged, got: %v", err)
+	}
+
+	// 后代账号可以管理
+	msg := decodeMessage(t, serveAs(t, mux, a, "PUT", "/admins/password", url.Values{"admin_id": {strconv.Itoa(sub.ID)}, "password": {"newpass"}}))
+	if msg.Type != xx.MsgSuccess {
+		t.Errorf("need password reset, got: %+v", msg)
+	}
+	if _, err := admin_model.SignIn("sub", "newpass"); err != nil {
+		t.Errorf("need sub password reset, got: %v", err)
+	}
+
+	// 列表只包含后代账号
+	res := serveAs(t, mux, a, "GET", "/admins", url.Values{"limit": {"10"}})
+	list := &struct {
+		Total  int
+		Admins []*admin_model.Admin
+	}{}
+	if err := json.Unmarshal(res.Body.Bytes(), list); err != nil {
+		t.Fatal(err)
+	}
+	if list.Total != 1 || len(list.Admins) != 1 || list.Admins[0].ID != sub.ID {
+		t.Errorf("need only sub, got: %s", res.Body.String())
+	}
+}
+
+func TestDisableAdmin_RevokeTokens(t *testing.T) {
+	defer setupAdminTest(t)()
+	mux := newAdminMux()
+	root := mustCreateAdmin(t, nil, "root", true)
+	a := mustCreateAdmin(t, root, "a", false)
+	pair, err := admin_middleware.Tokens.Issue(strconv.Itoa(a.ID), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := url.Values{"limit": {"10"}}
+	if res := serveWithToken(mux, pair.AccessToken, "GET", "/admins", values); !strings.Contains(res.Body.String(), `"total"`) {
+		t.Fatalf("need token accepted before disabled, got: %s", res.Body.String())
+	}
+
+	msg := decodeMessage(t, serveAs(t, mux, root, "PUT", "/admins/disabled", url.Values{"admin_id": {strconv.Itoa(a.ID)}, "disabled": {"true"}}))
+	if msg.Type != xx.MsgSuccess {
+		t.Fatalf("need disabled, got: %+v", msg)
+	}
+	res := serveWithToken(mux, pair.AccessToken, "GET", "/admins", values)
+	status := &xx.StatusData{}
+	if err = json.Unmarshal(res.Body.Bytes(), status); err != nil || status.Code != xx.StatusUnauthorized {
+		t.Errorf("need access token rejected, got: %s", res.Body.String())
+	}
+	if _, err = admin_middleware.Tokens.Refresh(pair.RefreshToken); err == nil {
+		t.Error("need refresh token rejected")
+	}
+	if _, err = admin_model.SignIn("a", "password"); err != admin_model.ErrAdminDisabled {
+		t.Errorf("need ErrAdminDisabled, got: %v", err)
+	}
+}
+
+func TestRoleActions_NotGrantable(t *testing.T) {
+	defer setupAdminTest(t)()
+	mux := newAdminMux()
+	root := mustCreateAdmin(t, nil, "root", true)
+	a := mustCreateAdmin(t, root, "a", false)
+	owned, notOwned := &admin_model.Role{Name: "owned"}, &admin_model.Role{Name: "not owned"}
+	for _, role := range []*admin_model.Role{owned, notOwned} {
+		if err := role.Create(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := admin_model.SetAdminRole(a.ID, owned.ID); err != nil {
+		t.Fatal(err)
+	}
+	ownedID, notOwnedID := strconv.Itoa(owned.ID), strconv.Itoa(notOwned.ID)
+
+	msg := decodeMessage(t, serveAs(t, mux, a, "PUT", "/roles", url.Values{"id": {notOwnedID}, "name": {"renamed"}}))
+	if msg.Type != xx.MsgWarning || msg.Content != admin_model.ErrRoleNotGrantable.Error() {
+		t.Errorf("update: need ErrRoleNotGrantable, got: %+v", msg)
+	}
+	msg = decodeMessage(t, serveAs(t, mux, a, "DELETE", "/roles", url.Values{"ids": {ownedID, notOwnedID}}))
+	if msg.Type != xx.MsgWarning || msg.Content != admin_model.ErrRoleNotGrantable.Error() {
+		t.Errorf("delete: need ErrRoleNotGrantable, got: %+v", msg)
+	}
+	if role, err := admin_model.GetRole(notOwned.ID); err != nil || role.Name != "not owned" {
+		t.Errorf("need role unchanged, got: %+v, %v", role, err)
+	}
+	if _, err := admin_model.GetRole(owned.ID); err != nil {
+		t.Errorf("need owned role kept, got: %v", err)
+	}
+
+	// 可以更新自己拥有的角色, 超级管理员可以删除任意角色
+	msg = decodeMessage(t, serveAs(t, mux, a, "PUT", "/roles", url.Values{"id": {ownedID}, "name": {"renamed"}}))
+	if msg.Type != xx.MsgSuccess {
+		t.Errorf("update owned: need success, got: %+v", msg)
+	}
+	msg = decodeMessage(t, serveAs(t, mux, root, "DELETE", "/roles", url.Values{"ids": {notOwnedID}}))
+	if msg.Type != xx.MsgSuccess {
+		t.Errorf("super delete: need success, got: %+v", msg)
+	}
+	if _, err := admin_model.GetRole(notOwned.ID); err == nil {
+		t.Error("need role deleted")
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package actions
+
+import (
+	"github.com/orivil/morgine/bundles/admin/env"
+	admin_model "github.com/orivil/morgine/bundles/admin/model"
+	"github.com/orivil/morgine/log"
+	"github.com/orivil/morgine/xx"
+	"time"
+)
+
+var GetAuditLogs xx.Action = func(method, route string, controller *xx.Condition) {
+	type params struct {
+		AdminID int        `param:"admin_id" desc:"管理员ID"`
+		Method  string     `param:"method" desc:"请求方法, 如: DELETE"`
+		Route   string     `param:"route" desc:"注册的路由, 如: /sub-admins"`
+		Status  int        `param:"status" desc:"响应状态码"`
+		IP      string     `param:"ip" desc:"客户端 IP"`
+		Start   *time.Time `param:"start" time-layout:"2006-01-02T15:04:05" desc:"开始时间"`
+		End     *time.Time `param:"end" time-layout:"2006-01-02T15:04:05" desc:"结束时间, 不包含"`
+		Limit   int        `param:"limit" required:"" num:"1-100"`
+		Offset  int        `param:"offset"`
+	}
+	doc := &xx.Doc {
+		Title: "获得审计日志",
+		Desc:  "按时间倒序返回管理员的修改操作及登录锁定事件(状态码为 LoginLocked), 敏感参数的值显示为 " + xx.Redacted,
+		Params: xx.Params {
+			{
+				Type:   xx.Query,
+				Schema: &params{},
+			},
+		},
+		Responses: xx.Responses {
+			{
+				Body: xx.MAP {
+					"total": 1,
+					"logs": []*admin_model.AuditLog {
+						{
+							ID:       1,
+							AdminID:  1,
+							Username: "root",
+							Method:   "PUT",
+							Route:    "/password",
+							Params:   `{"new_password":["` + xx.Redacted + `"],"username":["root"]}`,
+							Status:   200,
+							IP:       "127.0.0.1",
+						},
+					},
+				},
+			},
+		},
+	}
+	controller.Handle(method, route, doc, func(ctx *xx.Context) {
+		p := &params{}
+		err := ctx.Unmarshal(p)
+		if err != nil {
+			ctx.SendJsonMessage(xx.MsgWarning, err.Error())
+			return
+		}
+		filter := &admin_model.AuditLogFilter {
+			AdminID: p.AdminID,
+			Method:  p.Method,
+			Route:   p.Route,
+			Status:  p.Status,
+			IP:      p.IP,
+			Start:   p.Start,
+			End:     p.End,
+		}
+		total, err := admin_model.CountAuditLogs(filter)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		logs, err := admin_model.GetAuditLogs(filter, p.Limit, p.Offset)
+		if err != nil {
+			ctx.Error(err)
+		} else {
+			ctx.SendJSON(xx.MAP{"total": total, "logs": logs})
+		}
+	})
+}
+
+// 清理超过保留天数的审计日志, 由每日定时任务调用
+func PruneAuditLogs(now *time.Time) {
+	if env.Env.AuditLogDays <= 0 {
+		return
+	}
+	n, err := admin_model.PruneAuditLogs(now.AddDate(0, 0, -env.Env.AuditLogDays))
+	if err != nil {
+		log.Error.Println(err)
+	} else if n > 0 {
+		log.Info.Printf("已清理 %d 条过期审计日志\n", n)
+	}
+}
@@ -0,0 +1,19 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+// actions 包为后台管理的接口.
+//
+// 令牌, 登录限制, 两步验证挑战, 单点登录状态及接口限流默认保存在进程内存中, 只适用于单实例部署.
+// 多实例部署时需在初始化之后替换为共享存储, 否则各实例的状态互不可见, 如:
+//
+//	admin_middleware.Tokens.Store = morgine_redis.NewSessionStore(client, "admin-token:")
+//	admin_middleware.Tokens.Denylist = morgine_redis.NewDenylist(client, "admin-denylist:")
+//	actions.UserLoginLimiter = morgine_redis.NewOperationContainer(client, "admin-login-user:", 24*time.Hour, actions.NewLoginLockTime(5, time.Minute, 24*time.Hour))
+//	actions.IPLoginLimiter = morgine_redis.NewOperationContainer(client, "admin-login-ip:", 24*time.Hour, actions.NewLoginLockTime(20, time.Minute, 24*time.Hour))
+//	actions.TwoFactorChallenges = morgine_redis.NewSessionStore(client, "admin-2fa:")
+//	actions.OIDCFlow.States = morgine_redis.NewSessionStore(client, "admin-oidc:")
+//	xx.RateLimitBackend = func(rule *xx.RateLimit) limiter.RateLimiter {
+//		return morgine_redis.NewTokenBucketLimiter(client, "rate-limit:"+rule.Name+":", rule.Rate, rule.Period, rule.Burst)
+//	}
+package actions
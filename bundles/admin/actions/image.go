@@ -0,0 +1,124 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package actions
+
+import (
+	"errors"
+	admin_middleware "github.com/orivil/morgine/bundles/admin/middleware"
+	admin_model "github.com/orivil/morgine/bundles/admin/model"
+	"github.com/orivil/morgine/bundles/utils/imaging"
+	"github.com/orivil/morgine/bundles/utils/sql"
+	"github.com/orivil/morgine/bundles/utils/storage"
+	"github.com/orivil/morgine/param"
+	"github.com/orivil/morgine/utils/random"
+	"github.com/orivil/morgine/xx"
+	"image"
+	"mime/multipart"
+)
+
+// 保存标签图片的存储, 为 nil 时由 InitImageStorage 以本地目录初始化
+var ImageStorage storage.Storage
+
+var (
+	errLabelNotFound    = errors.New("图片标签不存在")
+	errLabelNotEditable = errors.New("该标签不允许后台上传图片")
+)
+
+// 初始化图片存储
+func InitImageStorage(dir, serveHost string) (err error) {
+	if ImageStorage == nil {
+		ImageStorage, err = storage.NewLocalStorage(dir, serveHost, nil)
+	}
+	return err
+}
+
+var UploadLabelImage xx.Action = func(method, route string, controller *xx.Condition) {
+	type params struct {
+		LabelKey string            `param:"label_key" required:"图片标签不能为空"`
+		Image    param.FileHandler `param:"image" required:"请选择图片" item:"1-1" item-msg:"每次只能上传一张图片" desc:"图片文件, 以文件内容检测图片类型"`
+	}
+	doc := &xx.Doc {
+		Title: "上传标签图片",
+		Desc:  "图片宽高不能小于标签尺寸, 按标签尺寸裁剪压缩并生成 thumb 缩略图, 只能上传到允许后台操作的标签",
+		Params: xx.Params {
+			{
+				Type:   xx.Form,
+				Schema: &params{},
+			},
+		},
+		Responses: xx.Responses {
+			{
+				Body: xx.MessageData(xx.MsgWarning, errLabelNotEditable.Error()),
+			},
+			{
+				Body: xx.MAP {
+					"image": &admin_model.Image{ID: 1, AdminID: 1, File: "e4d9b5c5f4a5c1f9.jpg", Url: "http://static.example.com/e4d9b5c5f4a5c1f9.jpg", LabelKey: "banner"},
+					"variants": []*imaging.Result {
+						{Name: "e4d9b5c5f4a5c1f9.jpg", Url: "http://static.example.com/e4d9b5c5f4a5c1f9.jpg", Width: 750, Height: 300, Size: 51200, Format: imaging.JPEG},
+						{Name: "e4d9b5c5f4a5c1f9-thumb.jpg", Url: "http://static.example.com/e4d9b5c5f4a5c1f9-thumb.jpg", Width: 200, Height: 80, Size: 6144, Format: imaging.JPEG},
+					},
+				},
+			},
+		},
+	}
+	controller.Handle(method, route, doc, func(ctx *xx.Context) {
+		var header *multipart.FileHeader
+		p := &params {
+			Image: func(field string, h *multipart.FileHeader) error {
+				header = h
+				return nil
+			},
+		}
+		err := ctx.Unmarshal(p)
+		if err != nil {
+			ctx.SendJsonMessage(xx.MsgWarning, err.Error())
+			return
+		}
+		label := admin_model.GetLabelByKey(p.LabelKey)
+		if label == nil {
+			ctx.SendJsonMessage(xx.MsgWarning, errLabelNotFound.Error())
+			return
+		}
+		if label.AllowedEdit != sql.True {
+			ctx.SendJsonMessage(xx.MsgWarning, errLabelNotEditable.Error())
+			return
+		}
+		err = param.ValidFile("image", ctx.MultipartForm(), label.Conditions())
+		if err != nil {
+			ctx.SendJsonMessage(xx.MsgWarning, err.Error())
+			return
+		}
+		if ImageStorage == nil {
+			ctx.Error(errors.New("image storage is not initialized"))
+			return
+		}
+		file, err := header.Open()
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		defer file.Close()
+		results, err := label.Pipeline().Store(ImageStorage, string(random.NewRandByte(32)), file)
+		if err != nil {
+			if err == imaging.ErrTooManyPixels || err == image.ErrFormat {
+				ctx.SendJsonMessage(xx.MsgWarning, err.Error())
+			} else {
+				ctx.Error(err)
+			}
+			return
+		}
+		img := &admin_model.Image{File: results[0].Name, Url: results[0].Url, LabelKey: label.Key}
+		id, _ := admin_middleware.GetUserIDFromContext(ctx)
+		err = img.Create(id)
+		if err != nil {
+			for _, result := range results {
+				_ = ImageStorage.Remove(result.Name)
+			}
+			ctx.Error(err)
+			return
+		}
+		ctx.SendJSON(xx.MAP{"image": img, "variants": results})
+	})
+}
@@ -0,0 +1,279 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package actions
+
+import (
+	"encoding/json"
+	"github.com/orivil/morgine/bundles/admin/env"
+	admin_middleware "github.com/orivil/morgine/bundles/admin/middleware"
+	admin_model "github.com/orivil/morgine/bundles/admin/model"
+	"github.com/orivil/morgine/log"
+	"github.com/orivil/morgine/utils/limiter"
+	"github.com/orivil/morgine/xx"
+	"math"
+	"net/url"
+	"time"
+)
+
+// 登录被锁定的状态码
+const StatusLoginLocked xx.StatusCode = 2429
+
+func init() {
+	xx.StatusCodes.InitStatus("admin", StatusLoginLocked, "LoginLocked")
+}
+
+// 分别按用户名及 IP 记录登录失败次数, 由 InitLoginLimiter 初始化
+var (
+	UserLoginLimiter limiter.OperationLimiter
+	IPLoginLimiter   limiter.OperationLimiter
+)
+
+type loginLockTime struct {
+	maxFailed int
+	lock      time.Duration
+	maxLock   time.Duration
+}
+
+// NewLoginLockTime 连续失败 maxFailed 次后锁定 lock 时间, 之后每次失败锁定时间翻倍, 最长锁定 maxLock 时间
+func NewLoginLockTime(maxFailed int, lock, maxLock time.Duration) limiter.TimeProvider {
+	return &loginLockTime{maxFailed: maxFailed, lock: lock, maxLock: maxLock}
+}
+
+func (t *loginLockTime) GetWaitTime(failed int) time.Duration {
+	if failed < t.maxFailed {
+		return 0
+	}
+	wait := t.lock
+	for i := t.maxFailed; i < failed && wait < t.maxLock; i++ {
+		wait *= 2
+	}
+	if wait > t.maxLock {
+		wait = t.maxLock
+	}
+	return wait
+}
+
+func (t *loginLockTime) GetNowTime() time.Time {
+	return time.Now()
+}
+
+// 根据配置初始化进程内的登录限制器, 未配置的选项使用默认值. 配置了快照文件时启动时恢复失败记录,
+// 并定期及服务器退出时保存快照, 使重启后锁定依然有效
+func InitLoginLimiter() {
+	e := env.Env
+	userMaxFailed, ipMaxFailed, lock, maxLock := 5, 20, 1, 1440
+	if e.LoginUserMaxFailed > 0 {
+		userMaxFailed = e.LoginUserMaxFailed
+	}
+	if e.LoginIPMaxFailed > 0 {
+		ipMaxFailed = e.LoginIPMaxFailed
+	}
+	if e.LoginLockMinute > 0 {
+		lock = e.LoginLockMinute
+	}
+	if e.LoginMaxLockMinute > 0 {
+		maxLock = e.LoginMaxLockMinute
+	}
+	lockDuration, maxLockDuration := time.Duration(lock)*time.Minute, time.Duration(maxLock)*time.Minute
+	newContainer := func(maxFailed int, name string) *limiter.OperationContainer {
+		opc := limiter.NewOperationContainer(NewLoginLockTime(maxFailed, lockDuration, maxLockDuration))
+		// 失败记录最多保留最长锁定时间, 避免内存无限增长
+		opc.SetMaxEntries(loginMaxEntries)
+		opc.RunEviction(maxLockDuration, 10*time.Minute)
+		if e.LoginLockSnapshot != "" {
+			store := limiter.FileOperationStore(e.LoginLockSnapshot + "." + name + ".json")
+			err := opc.RestoreFrom(store)
+			if err != nil {
+				log.Error.Printf("restore login limiter %s: %s\n", store, err)
+			}
+			loginSnapshots = append(loginSnapshots, &loginSnapshot{container: opc, store: store})
+		}
+		return opc
+	}
+	UserLoginLimiter = newContainer(userMaxFailed, "user")
+	IPLoginLimiter = newContainer(ipMaxFailed, "ip")
+	if len(loginSnapshots) > 0 {
+		interval := 5
+		if e.LoginLockSnapshotMinute > 0 {
+			interval = e.LoginLockSnapshotMinute
+		}
+		stop := runLoginSnapshots(time.Duration(interval) * time.Minute)
+		xx.AfterShutdown(func() {
+			close(stop)
+			saveLoginSnapshots()
+		})
+	}
+}
+
+// 进程内登录限制器的最大记录数
+var loginMaxEntries = 100000
+
+type loginSnapshot struct {
+	container *limiter.OperationContainer
+	store     limiter.OperationStore
+}
+
+// 需要保存快照的进程内登录限制器
+var loginSnapshots []*loginSnapshot
+
+func saveLoginSnapshots() {
+	for _, snapshot := range loginSnapshots {
+		err := snapshot.container.SaveTo(snapshot.store)
+		if err != nil {
+			log.Error.Printf("save login limiter: %s\n", err)
+		}
+	}
+}
+
+// 每隔 interval 时间保存一次快照, 关闭返回的通道停止保存
+func runLoginSnapshots(interval time.Duration) (stop chan struct{}) {
+	stop = make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				saveLoginSnapshots()
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return stop
+}
+
+// 获得用户名或 IP 的登录锁定结束时间, 未锁定则返回 nil
+func getLoginWaitTime(username, ip string) (waitAt *time.Time) {
+	for _, check := range []struct {
+		limiter limiter.OperationLimiter
+		session string
+	}{{UserLoginLimiter, username}, {IPLoginLimiter, ip}} {
+		if !check.limiter.Allow(check.session) {
+			wa := check.limiter.GetWaitTime(check.session)
+			if wa != nil && (waitAt == nil || wa.After(*waitAt)) {
+				waitAt = wa
+			}
+		}
+	}
+	return waitAt
+}
+
+// 用户名或 IP 被锁定时响应 LoginLocked 状态码并返回 true
+func sendLoginLocked(ctx *xx.Context, username string) bool {
+	waitAt := getLoginWaitTime(username, ctx.ClientIP())
+	if waitAt == nil {
+		return false
+	}
+	ctx.SendStatusJsonData(StatusLoginLocked, xx.MAP {
+		"wait_seconds": int(math.Ceil(time.Until(*waitAt).Seconds())),
+		"wait_at":      waitAt.Unix(),
+	})
+	return true
+}
+
+// 记录登录失败, 由未锁定变为锁定时记录审计日志
+func loginFailed(ctx *xx.Context, username string) {
+	ip := ctx.ClientIP()
+	locked := getLoginWaitTime(username, ip) != nil
+	UserLoginLimiter.Failed(username)
+	IPLoginLimiter.Failed(ip)
+	if waitAt := getLoginWaitTime(username, ip); waitAt != nil && !locked {
+		err := addLoginLockedLog(ctx, username, *waitAt)
+		if err != nil {
+			log.Error.Println(err)
+		}
+	}
+}
+
+func addLoginLockedLog(ctx *xx.Context, username string, waitAt time.Time) error {
+	params, err := json.Marshal(url.Values{"username": {username}, "until": {waitAt.Format(time.RFC3339)}})
+	if err != nil {
+		return err
+	}
+	return admin_model.AddAuditLog(&admin_model.AuditLog {
+		Username:  username,
+		Method:    ctx.Request.Method,
+		Route:     ctx.Request.URL.Path,
+		Params:    string(params),
+		Status:    int(StatusLoginLocked),
+		IP:        ctx.ClientIP(),
+		CreatedAt: time.Now(),
+	})
+}
+
+// 登录成功只清除用户名的失败记录, IP 的失败记录需等待过期, 避免同一 IP 以已知账号重置对其他账号的猜测次数
+func loginSuccess(username string) {
+	UserLoginLimiter.Success(username)
+}
+
+var UnlockLogin xx.Action = func(method, route string, controller *xx.Condition) {
+	type param struct {
+		Username string `desc:"需要解锁的用户名"`
+		IP       string `desc:"需要解锁的 IP"`
+	}
+	doc := &xx.Doc {
+		Title: "解除登录锁定",
+		Desc: "清除用户名或 IP 的登录失败记录",
+		Params: xx.Params {
+			{
+				Type:   xx.Query,
+				Schema: &param{},
+			},
+		},
+		Responses: xx.Responses {
+			{
+				Body: xx.MessageData(xx.MsgSuccess, "解锁成功"),
+			},
+		},
+	}
+	controller.Handle(method, route, doc, func(ctx *xx.Context) {
+		p := &param{}
+		err := ctx.Unmarshal(p)
+		if err != nil {
+			ctx.SendJsonMessage(xx.MsgWarning, err.Error())
+		} else if p.Username == "" && p.IP == "" {
+			ctx.SendJsonMessage(xx.MsgWarning, "用户名及 IP 不能同时为空")
+		} else {
+			if p.Username != "" {
+				UserLoginLimiter.Success(p.Username)
+			}
+			if p.IP != "" {
+				IPLoginLimiter.Success(p.IP)
+			}
+			id, _ := admin_middleware.GetUserIDFromContext(ctx)
+			log.Warning.Printf("admin login unlocked: username=%q ip=%s by=%d\n", p.Username, p.IP, id)
+			ctx.SendJsonMessage(xx.MsgSuccess, "解锁成功")
+		}
+	})
+}
+
+var GetLoginLockStats xx.Action = func(method, route string, controller *xx.Condition) {
+	doc := &xx.Doc {
+		Title: "获得登录锁定统计",
+		Desc:  "返回进程内登录限制器的记录数, 锁定数及累计清理数, 使用共享存储的限制器时不返回对应统计",
+		Responses: xx.Responses {
+			{
+				Body: xx.MAP {
+					"user": &limiter.OperationStats{Entries: 12, Locked: 1, Evicted: 30},
+					"ip":   &limiter.OperationStats{Entries: 8, Locked: 0, Evicted: 16},
+				},
+			},
+		},
+	}
+	controller.Handle(method, route, doc, func(ctx *xx.Context) {
+		type statser interface {
+			Stats() limiter.OperationStats
+		}
+		stats := xx.MAP{}
+		if s, ok := UserLoginLimiter.(statser); ok {
+			stats["user"] = s.Stats()
+		}
+		if s, ok := IPLoginLimiter.(statser); ok {
+			stats["ip"] = s.Stats()
+		}
+		ctx.SendJSON(stats)
+	})
+}
@@ -0,0 +1,104 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package actions
+
+import (
+	"github.com/orivil/morgine/bundles/admin/env"
+	"github.com/orivil/morgine/utils/limiter"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoginLockTime_GetWaitTime(t *testing.T) {
+	lt := NewLoginLockTime(3, time.Minute, 5*time.Minute)
+	cases := map[int]time.Duration{
+		0:  0,
+		2:  0,
+		3:  time.Minute,
+		4:  2 * time.Minute,
+		5:  4 * time.Minute,
+		6:  5 * time.Minute,
+		20: 5 * time.Minute,
+	}
+	for failed, need := range cases {
+		if got := lt.GetWaitTime(failed); got != need {
+			t.Errorf("failed %d: need %s, got %s", failed, need, got)
+		}
+	}
+}
+
+func TestGetLoginWaitTime(t *testing.T) {
+	UserLoginLimiter = limiter.NewOperationContainer(NewLoginLockTime(2, time.Minute, time.Hour))
+	IPLoginLimiter = limiter.NewOperationContainer(NewLoginLockTime(3, 10*time.Minute, time.Hour))
+	ip := "10.0.0.1"
+	fail := func(username string) {
+		UserLoginLimiter.Failed(username)
+		IPLoginLimiter.Failed(ip)
+	}
+	fail("a")
+	if waitAt := getLoginWaitTime("a", ip); waitAt != nil {
+		t.Errorf("need not locked after 1 failure, got: %v", waitAt)
+	}
+	fail("a")
+	waitAt := getLoginWaitTime("a", ip)
+	if waitAt == nil || time.Until(*waitAt) > time.Minute {
+		t.Fatalf("need username locked for 1 minute, got: %v", waitAt)
+	}
+	if waitAt := getLoginWaitTime("b", ip); waitAt != nil {
+		t.Errorf("need other username not locked, got: %v", waitAt)
+	}
+
+	// IP 锁定时间更长时以 IP 为准
+	fail("b")
+	waitAt = getLoginWaitTime("a", ip)
+	if waitAt == nil || time.Until(*waitAt) <= time.Minute {
+		t.Errorf("need locked by ip for 10 minutes, got: %v", waitAt)
+	}
+
+	// 登录成功只清除用户名的失败记录
+	loginSuccess("a")
+	if UserLoginLimiter.GetWaitTime("a") != nil {
+		t.Error("need username failures cleared")
+	}
+	if getLoginWaitTime("a", ip) == nil {
+		t.Error("need ip still locked")
+	}
+}
+
+func TestInitLoginLimiter_Snapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "login_lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func() {
+		env.Env.LoginLockSnapshot = ""
+		loginSnapshots = nil
+	}()
+	env.Env.LoginLockSnapshot = filepath.Join(dir, "login_lock")
+	waitAt := time.Now().Add(time.Hour)
+	err = limiter.FileOperationStore(env.Env.LoginLockSnapshot + ".user.json").Save([]*limiter.OperationRecord{
+		{Session: "a", Failed: 5, WaitAt: &waitAt, FailedAt: time.Now()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	InitLoginLimiter()
+	if UserLoginLimiter.Allow("a") {
+		t.Fatal("need username locked after restore")
+	}
+	IPLoginLimiter.Failed("10.0.0.1")
+	saveLoginSnapshots()
+	records, err := limiter.FileOperationStore(env.Env.LoginLockSnapshot + ".ip.json").Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Session != "10.0.0.1" {
+		t.Errorf("need ip snapshot saved, got: %v", records)
+	}
+}
@@ -0,0 +1,210 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package actions
+
+import (
+	"crypto/sha256"
+	"errors"
+	"github.com/orivil/morgine/bundles/admin/env"
+	admin_middleware "github.com/orivil/morgine/bundles/admin/middleware"
+	admin_model "github.com/orivil/morgine/bundles/admin/model"
+	"github.com/orivil/morgine/utils/oidc"
+	"github.com/orivil/morgine/utils/session"
+	"github.com/orivil/morgine/utils/token"
+	"github.com/orivil/morgine/xx"
+	"net/http"
+	"strings"
+)
+
+var (
+	errOIDCDisabled      = errors.New("未启用单点登录")
+	errOIDCNoUsername    = errors.New("身份提供方未提供用户名")
+	errOIDCStateMismatch = errors.New("登录状态与当前浏览器不一致")
+)
+
+// 保存登录状态的 cookie, 回调时与参数中的 state 比较, 防止将他人的登录结果注入当前浏览器
+const oidcStateCookie = "admin_oidc_state"
+
+// 单点登录流程, 由 InitOIDC 初始化, 未配置 oidc_issuer 时为 nil
+var OIDCFlow *oidc.Flow
+
+// 根据配置获取身份提供方的发现文档并初始化单点登录流程, xx.DefaultServeMux.SecureCookie 未设置时以 auth_key 派生签名密钥
+func InitOIDC() error {
+	if env.Env.OIDCIssuer == "" {
+		return nil
+	}
+	if xx.DefaultServeMux.SecureCookie == nil {
+		sum := sha256.Sum256([]byte("cookie:" + env.Env.AuthKey))
+		sc, err := xx.NewSecureCookie(&xx.CookieKey{HashKey: sum[:]})
+		if err != nil {
+			return err
+		}
+		xx.DefaultServeMux.SecureCookie = sc
+	}
+	provider, err := oidc.Discover(env.Env.OIDCIssuer, nil)
+	if err != nil {
+		return err
+	}
+	client := provider.Client(&oidc.Config {
+		ClientID:     env.Env.OIDCClientID,
+		ClientSecret: env.Env.OIDCClientSecret,
+		RedirectURL:  env.Env.OIDCRedirectURL,
+		Scopes:       strings.Fields(env.Env.OIDCScopes),
+	})
+	OIDCFlow = oidc.NewFlow(client, session.NewMemoryStore())
+	return nil
+}
+
+var OIDCLogin xx.Action = func(method, route string, controller *xx.Condition) {
+	type params struct {
+		Redirect bool `param:"redirect" desc:"为 true 时直接跳转到身份提供方, 否则返回跳转地址"`
+	}
+	doc := &xx.Doc {
+		Title: "单点登录",
+		Desc:  "获得身份提供方的登录地址, 登录后身份提供方携带 code 及 state 跳转到回调地址, 登录地址 10 分钟内有效且只能使用一次. state 同时保存在 cookie 中, 回调时需携带该 cookie",
+		Params: xx.Params {
+			{
+				Type:   xx.Query,
+				Schema: &params{},
+			},
+		},
+		Responses: xx.Responses {
+			{
+				Body: xx.MessageData(xx.MsgWarning, errOIDCDisabled.Error()),
+			},
+			{
+				Body: xx.MAP{"url": "https://idp.example.com/authorize?response_type=code&..."},
+			},
+		},
+	}
+	controller.Handle(method, route, doc, func(ctx *xx.Context) {
+		p := &params{}
+		err := ctx.Unmarshal(p)
+		if err != nil {
+			ctx.SendJsonMessage(xx.MsgWarning, err.Error())
+			return
+		}
+		if OIDCFlow == nil {
+			ctx.SendJsonMessage(xx.MsgWarning, errOIDCDisabled.Error())
+			return
+		}
+		state, url, err := OIDCFlow.StartState()
+		if err == nil {
+			err = ctx.SetSecureCookie(oidcStateCookie, state, OIDCFlow.StateTTL)
+		}
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		if p.Redirect {
+			ctx.Redirect(url, http.StatusFound)
+		} else {
+			ctx.SendJSON(xx.MAP{"url": url})
+		}
+	})
+}
+
+var OIDCCallback xx.Action = func(method, route string, controller *xx.Condition) {
+	type params struct {
+		Code  string `param:"code" sensitive:"" required:"授权码不能为空"`
+		State string `param:"state" required:"state 不能为空"`
+	}
+	doc := &xx.Doc {
+		Title: "单点登录回调",
+		Desc:  "以身份提供方回调中的 code 及 state 换取访问令牌, state 必须与发起登录时设置的 cookie 一致. 首次登录时按配置关联或创建管理员. 已启用两步验证时与登录接口一样返回两步验证状态码",
+		Params: xx.Params {
+			{
+				Type:   xx.Form,
+				Schema: &params{},
+			},
+		},
+		Responses: xx.Responses {
+			{
+				Body: xx.MessageData(xx.MsgWarning, "单点登录失败: "+oidc.ErrStateInvalid.Error()),
+			},
+			{
+				Body: &token.Pair{AccessToken: "access token", TokenType: "Bearer", ExpiresIn: 900, RefreshToken: "refresh token"},
+			},
+		},
+	}
+	controller.Handle(method, route, doc, func(ctx *xx.Context) {
+		p := &params{}
+		err := ctx.Unmarshal(p)
+		if err != nil {
+			ctx.SendJsonMessage(xx.MsgWarning, err.Error())
+			return
+		}
+		if OIDCFlow == nil {
+			ctx.SendJsonMessage(xx.MsgWarning, errOIDCDisabled.Error())
+			return
+		}
+		bound, err := ctx.SecureCookie(oidcStateCookie)
+		ctx.DelCookie(oidcStateCookie)
+		if err != nil || bound != p.State {
+			ctx.SendJsonMessage(xx.MsgWarning, "单点登录失败: "+errOIDCStateMismatch.Error())
+			return
+		}
+		idToken, err := OIDCFlow.Finish(p.State, p.Code)
+		if err != nil {
+			var te *oidc.TokenError
+			if err == oidc.ErrStateInvalid || err == oidc.ErrNonceMismatch || errors.Is(err, oidc.ErrInvalidIDToken) || errors.As(err, &te) {
+				ctx.SendJsonMessage(xx.MsgWarning, "单点登录失败: "+err.Error())
+			} else {
+				ctx.Error(err)
+			}
+			return
+		}
+		admin, err := oidcAdmin(idToken)
+		if err != nil {
+			if err == admin_model.ErrUserNotRegistered || err == admin_model.ErrUsernameRegistered || err == admin_model.ErrAdminDisabled || err == errOIDCNoUsername {
+				ctx.SendJsonMessage(xx.MsgWarning, err.Error())
+			} else {
+				ctx.Error(err)
+			}
+			return
+		}
+		if challengeTwoFactor(ctx, admin) {
+			return
+		}
+		pair, err := admin_middleware.NewToken(ctx, admin)
+		if err != nil {
+			ctx.Error(err)
+		} else {
+			ctx.SendJSON(pair)
+		}
+	})
+}
+
+// 获得单点登录账号对应的管理员, 首次登录时按配置关联同名管理员或自动创建管理员, 并按角色声明同步角色
+func oidcAdmin(idToken *oidc.IDToken) (*admin_model.Admin, error) {
+	externalID := admin_model.ExternalID(idToken.Issuer, idToken.Subject)
+	admin, err := admin_model.GetAdminByExternalID(externalID)
+	if err == admin_model.ErrUserNotRegistered {
+		username, _ := idToken.Claims[env.Env.OIDCUsernameClaim].(string)
+		if username == "" {
+			return nil, errOIDCNoUsername
+		}
+		if env.Env.OIDCLinkUsername {
+			admin, err = admin_model.LinkAdmin(username, externalID)
+		}
+		if err == admin_model.ErrUserNotRegistered && env.Env.OIDCAutoProvision {
+			admin, err = admin_model.ProvisionAdmin(username, externalID)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if admin.Disabled {
+		return nil, admin_model.ErrAdminDisabled
+	}
+	// ID 令牌中没有角色声明时保留现有角色, 避免身份提供方未返回声明时清空角色
+	if _, ok := idToken.Claims[env.Env.OIDCRoleClaim]; ok && env.Env.OIDCRoleClaim != "" {
+		err = admin_model.SyncAdminRoles(admin.ID, idToken.Strings(env.Env.OIDCRoleClaim))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return admin, nil
+}
@@ -0,0 +1,124 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package actions
+
+import (
+	admin_middleware "github.com/orivil/morgine/bundles/admin/middleware"
+	admin_model "github.com/orivil/morgine/bundles/admin/model"
+	"github.com/orivil/morgine/xx"
+)
+
+var GetPermissions xx.Action = func(method, route string, controller *xx.Condition) {
+	doc := &xx.Doc {
+		Title: "获得所有接口权限",
+		Desc:  "列出受授权中间件保护的所有接口, 用于角色编辑器. 权限 \"*\" 表示所有接口",
+		Responses: xx.Responses {
+			{
+				Body: xx.MAP{"permissions": []*xx.PermissionInfo{{Permission: "GET /admins", Method: "GET", Route: "/admins", Title: "获得管理员列表"}}},
+			},
+		},
+	}
+	controller.Handle(method, route, doc, func(ctx *xx.Context) {
+		ctx.SendJSON(xx.MAP{"permissions": admin_middleware.Authorizer.Permissions(xx.DefaultServeMux)})
+	})
+}
+
+var GetRolePermissions xx.Action = func(method, route string, controller *xx.Condition) {
+	type params struct {
+		RoleID int `param:"role_id" required:"角色ID不能为空"`
+	}
+	doc := &xx.Doc {
+		Title: "获得角色的接口权限",
+		Params: xx.Params {
+			{
+				Type:   xx.Query,
+				Schema: &params{},
+			},
+		},
+		Responses: xx.Responses {
+			{
+				Body: xx.MAP{"permissions": []string{"GET /admins"}},
+			},
+		},
+	}
+	controller.Handle(method, route, doc, func(ctx *xx.Context) {
+		p := &params{}
+		err := ctx.Unmarshal(p)
+		if err != nil {
+			ctx.SendJsonMessage(xx.MsgWarning, err.Error())
+			return
+		}
+		perms, err := admin_model.GetRolePermissions(p.RoleID)
+		if err != nil {
+			ctx.Error(err)
+		} else {
+			ctx.SendJSON(xx.MAP{"permissions": perms})
+		}
+	})
+}
+
+var SetRolePermissions xx.Action = func(method, route string, controller *xx.Condition) {
+	type params struct {
+		RoleID      int      `param:"role_id" required:"角色ID不能为空"`
+		Permissions []string `param:"permissions" desc:"角色的全部接口权限, 为空时清除角色权限"`
+	}
+	doc := &xx.Doc {
+		Title: "设置角色的接口权限",
+		Desc:  "以提交的权限替换角色的全部权限, 权限必须来自接口权限列表. 非超级管理员只能设置自己可授予的角色, 且只能授予自己拥有的权限",
+		Params: xx.Params {
+			{
+				Type:   xx.Form,
+				Schema: &params{},
+			},
+		},
+		Responses: xx.Responses {
+			{
+				Body: xx.MessageData(xx.MsgWarning, "GET /unknown: "+xx.ErrUnknownPermission.Error()),
+			},
+			{
+				Body: xx.MessageData(xx.MsgWarning, admin_model.ErrPermissionNotGrantable.Error()),
+			},
+			{
+				Body: xx.MessageData(xx.MsgSuccess, "已保存角色权限"),
+			},
+		},
+	}
+	controller.Handle(method, route, doc, func(ctx *xx.Context) {
+		p := &params{}
+		err := ctx.Unmarshal(p)
+		if err != nil {
+			ctx.SendJsonMessage(xx.MsgWarning, err.Error())
+			return
+		}
+		perms := make([]xx.Permission, len(p.Permissions))
+		for i, perm := range p.Permissions {
+			perms[i] = xx.Permission(perm)
+		}
+		err = xx.CheckPermissions(admin_middleware.Authorizer.Permissions(xx.DefaultServeMux), perms)
+		if err != nil {
+			ctx.SendJsonMessage(xx.MsgWarning, err.Error())
+			return
+		}
+		operator, err := loginAdmin(ctx)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		err = admin_model.CheckGrantableRoles(operator, []int{p.RoleID})
+		if err == nil {
+			err = admin_model.CheckGrantablePermissions(operator, p.Permissions)
+		}
+		if err != nil {
+			sendManageError(ctx, err)
+			return
+		}
+		err = admin_model.SetRolePermissions(p.RoleID, p.Permissions)
+		if err != nil {
+			ctx.Error(err)
+		} else {
+			ctx.SendJsonMessage(xx.MsgSuccess, "已保存角色权限")
+		}
+	})
+}
@@ -6,160 +6,195 @@ package actions
 
 import (
 	admin_model "github.com/orivil/morgine/bundles/admin/model"
+	"github.com/orivil/morgine/utils/sql"
 	"github.com/orivil/morgine/xx"
 )
 
-var CountRoles xx.Action = func(method, route string, controller *xx.Condition) {
-	doc := xx.Doc {
-		Title: "统计角色数量",
-		Responses:xx.Responses{
-			{
-				Body: xx.MAP{"total": 3},
-			},
-		},
-	}
-	controller.Handle(method, route, &doc, func(ctx *xx.Context) {
-		total, err := admin_model.CountRoles()
-		if err != nil {
-			ctx.MsgWarning(err.Error())
-		} else {
-			ctx.SendJSON(xx.MAP{"total": total})
-		}
-	})
-}
-
 var GetRoles xx.Action = func(method, route string, controller *xx.Condition) {
 	type params struct {
-		Limit int `required:"" num:"1-100"`
-		Offset int
+		Order  string `param:"order" enum:"id name" desc:"排序字段, 默认为 id"`
+		Desc   bool   `param:"desc" desc:"是否倒序"`
+		Limit  int    `param:"limit" required:"" num:"1-100"`
+		Offset int    `param:"offset"`
 	}
-	doc := xx.Doc {
+	doc := &xx.Doc {
 		Title: "获得角色列表",
-		Params:xx.Params {
+		Params: xx.Params {
 			{
-				Type: xx.Query,
+				Type:   xx.Query,
 				Schema: &params{},
 			},
 		},
-		Responses:xx.Responses {
+		Responses: xx.Responses {
 			{
-				Body: xx.MAP{"roles": []*admin_model.Role{{}}},
+				Body: xx.MAP{"total": 1, "roles": []*admin_model.Role{{ID: 1, Name: "editor"}}},
 			},
 		},
 	}
-	controller.Handle(method, route, &doc, func(ctx *xx.Context) {
+	controller.Handle(method, route, doc, func(ctx *xx.Context) {
 		p := &params{}
 		err := ctx.Unmarshal(p)
 		if err != nil {
-			xx.HandleUnmarshalError(err, ctx)
+			ctx.SendJsonMessage(xx.MsgWarning, err.Error())
+			return
+		}
+		total, err := admin_model.CountRoles()
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		roles, err := admin_model.GetRoles(sql.InitOrder(p.Order, p.Desc), p.Limit, p.Offset)
+		if err != nil {
+			ctx.Error(err)
 		} else {
-			roles, err := admin_model.GetRoles(p.Limit, p.Offset)
-			if err != nil {
-				ctx.MsgWarning(err.Error())
-			} else {
-				ctx.SendJSON(xx.MAP{"roles": roles})
-			}
+			ctx.SendJSON(xx.MAP{"total": total, "roles": roles})
 		}
 	})
 }
 
 var CreateRole xx.Action = func(method, route string, controller *xx.Condition) {
 	type params struct {
-		Name string `required:"" desc:"角色名称"`
+		Name             string `param:"name" required:"角色名称不能为空" desc:"角色名称"`
+		RequireTwoFactor bool   `param:"require_two_factor" desc:"拥有该角色的管理员是否必须启用两步验证"`
 	}
-	doc := xx.Doc {
+	doc := &xx.Doc {
 		Title: "创建新角色",
-		Params:xx.Params {
+		Params: xx.Params {
 			{
-				Type: xx.Query,
+				Type:   xx.Form,
 				Schema: &params{},
 			},
 		},
-		Responses:xx.Responses {
+		Responses: xx.Responses {
+			{
+				Body: xx.MessageData(xx.MsgWarning, admin_model.ErrRoleExists.Error()),
+			},
 			{
-				Body: xx.MAP{"role": &admin_model.Role{}},
+				Body: xx.MAP{"role": &admin_model.Role{ID: 1, Name: "editor"}},
 			},
 		},
 	}
-	controller.Handle(method, route, &doc, func(ctx *xx.Context) {
+	controller.Handle(method, route, doc, func(ctx *xx.Context) {
 		p := &params{}
 		err := ctx.Unmarshal(p)
 		if err != nil {
-			xx.HandleUnmarshalError(err, ctx)
+			ctx.SendJsonMessage(xx.MsgWarning, err.Error())
+			return
+		}
+		role := &admin_model.Role{Name: p.Name, RequireTwoFactor: p.RequireTwoFactor}
+		err = role.Create()
+		if err != nil {
+			sendManageError(ctx, err)
 		} else {
-			role := &admin_model.Role{Name: p.Name}
-			err := role.Create()
-			if err != nil {
-				ctx.MsgWarning(err.Error())
-			} else {
-				ctx.SendJSON(xx.MAP{"role": role})
-			}
+			ctx.SendJSON(xx.MAP{"role": role})
 		}
 	})
 }
 
 var UpdateRole xx.Action = func(method, route string, controller *xx.Condition) {
 	type params struct {
-		ID int `required:"" desc:"角色ID"`
-		Name string `required:"" desc:"新角色名称"`
+		ID               int    `param:"id" required:"角色ID不能为空" desc:"角色ID"`
+		Name             string `param:"name" required:"角色名称不能为空" desc:"新角色名称"`
+		RequireTwoFactor bool   `param:"require_two_factor" desc:"拥有该角色的管理员是否必须启用两步验证"`
 	}
-	doc := xx.Doc {
-		Title: "更新角色名称",
-		Params:xx.Params {
+	doc := &xx.Doc {
+		Title: "更新角色",
+		Desc:  "非超级管理员只能更新自己拥有的角色",
+		Params: xx.Params {
 			{
-				Type: xx.Query,
+				Type:   xx.Form,
 				Schema: &params{},
 			},
 		},
-		Responses:xx.Responses {
-			xx.MessageResponse(xx.MsgTypeSuccess),
+		Responses: xx.Responses {
+			{
+				Body: xx.MessageData(xx.MsgWarning, admin_model.ErrRoleNotGrantable.Error()),
+			},
+			{
+				Body: xx.MessageData(xx.MsgWarning, admin_model.ErrRoleNotFound.Error()),
+			},
+			{
+				Body: xx.MessageData(xx.MsgSuccess, "已保存"),
+			},
 		},
 	}
-	controller.Handle(method, route, &doc, func(ctx *xx.Context) {
+	controller.Handle(method, route, doc, func(ctx *xx.Context) {
 		p := &params{}
 		err := ctx.Unmarshal(p)
 		if err != nil {
-			xx.HandleUnmarshalError(err, ctx)
+			ctx.SendJsonMessage(xx.MsgWarning, err.Error())
+			return
+		}
+		operator, err := loginAdmin(ctx)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		err = admin_model.CheckGrantableRoles(operator, []int{p.ID})
+		if err != nil {
+			sendManageError(ctx, err)
+			return
+		}
+		role, err := admin_model.GetRole(p.ID)
+		if err != nil {
+			sendManageError(ctx, err)
+			return
+		}
+		role.Name = p.Name
+		role.RequireTwoFactor = p.RequireTwoFactor
+		err = role.Update()
+		if err != nil {
+			sendManageError(ctx, err)
 		} else {
-			role := &admin_model.Role{Name: p.Name, ID: p.ID}
-			err := role.Update()
-			if err != nil {
-				ctx.MsgWarning(err.Error())
-			} else {
-				ctx.MsgSuccess("已保存")
-			}
+			ctx.SendJsonMessage(xx.MsgSuccess, "已保存")
 		}
 	})
 }
 
 var DeleteRoles xx.Action = func(method, route string, controller *xx.Condition) {
 	type params struct {
-		IDs []int `required:""`
+		IDs []int `param:"ids" required:"角色ID不能为空" desc:"角色ID"`
 	}
-	doc := xx.Doc {
-		Title: "获得角色列表",
-		Params:xx.Params {
+	doc := &xx.Doc {
+		Title: "删除角色",
+		Desc:  "同时移除管理员的这些角色及角色的接口权限, 非超级管理员只能删除自己拥有的角色",
+		Params: xx.Params {
 			{
-				Type: xx.Query,
+				Type:   xx.Query,
 				Schema: &params{},
 			},
 		},
-		Responses:xx.Responses {
-			xx.MessageResponse(xx.MsgTypeSuccess),
+		Responses: xx.Responses {
+			{
+				Body: xx.MessageData(xx.MsgWarning, admin_model.ErrRoleNotGrantable.Error()),
+			},
+			{
+				Body: xx.MessageData(xx.MsgSuccess, "已删除"),
+			},
 		},
 	}
-	controller.Handle(method, route, &doc, func(ctx *xx.Context) {
+	controller.Handle(method, route, doc, func(ctx *xx.Context) {
 		p := &params{}
 		err := ctx.Unmarshal(p)
 		if err != nil {
-			xx.HandleUnmarshalError(err, ctx)
+			ctx.SendJsonMessage(xx.MsgWarning, err.Error())
+			return
+		}
+		operator, err := loginAdmin(ctx)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		err = admin_model.CheckGrantableRoles(operator, p.IDs)
+		if err != nil {
+			sendManageError(ctx, err)
+			return
+		}
+		err = admin_model.DeleteRoles(p.IDs)
+		if err != nil {
+			ctx.Error(err)
 		} else {
-			err := admin_model.DeleteRoles(p.IDs)
-			if err != nil {
-				ctx.MsgWarning(err.Error())
-			} else {
-				ctx.MsgSuccess("已删除")
-			}
+			ctx.SendJsonMessage(xx.MsgSuccess, "已删除")
 		}
 	})
-}
\ No newline at end of file
+}
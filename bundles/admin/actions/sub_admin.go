@@ -0,0 +1,319 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package actions
+
+import (
+	admin_middleware "github.com/orivil/morgine/bundles/admin/middleware"
+	admin_model "github.com/orivil/morgine/bundles/admin/model"
+	"github.com/orivil/morgine/xx"
+)
+
+// 获得当前登录的管理员
+func loginAdmin(ctx *xx.Context) (*admin_model.Admin, error) {
+	id, _ := admin_middleware.GetUserIDFromContext(ctx)
+	return admin_model.GetAdmin(id)
+}
+
+// 获得当前管理员可管理的后代账号, 非超级管理员不能管理超级管理员账号
+func manageableSubAdmin(operator *admin_model.Admin, adminID int) (*admin_model.Admin, error) {
+	sub, err := admin_model.GetSubAdmin(operator.ID, adminID)
+	if err != nil {
+		return nil, err
+	}
+	if sub.Super && !operator.Super {
+		return nil, admin_model.ErrNeedSuperAdmin
+	}
+	return sub, nil
+}
+
+// 将可由调用方修正的错误以警告消息返回, 其他错误作为服务器错误处理
+func sendManageError(ctx *xx.Context, err error) {
+	switch err {
+	case admin_model.ErrNotSubAdmin, admin_model.ErrAdminHasSubs, admin_model.ErrMoveIntoSubtree,
+		admin_model.ErrNeedSuperAdmin, admin_model.ErrRoleNotGrantable, admin_model.ErrUsernameRegistered,
+		admin_model.ErrUserNotRegistered, admin_model.ErrRoleNotFound, admin_model.ErrRoleExists, admin_model.ErrPermissionNotGrantable, errManageSelf:
+		ctx.SendJsonMessage(xx.MsgWarning, err.Error())
+	default:
+		ctx.Error(err)
+	}
+}
+
+var GetSubAdmins xx.Action = func(method, route string, controller *xx.Condition) {
+	doc := &xx.Doc {
+		Title: "获得子账号树",
+		Desc:  "返回当前管理员的所有后代账号",
+		Responses: xx.Responses {
+			{
+				Body: xx.MAP{"admins": []*admin_model.AdminNode{{Admin: &admin_model.Admin{ID: 5, Username: "sub", ParentID: 1, Forefather: "|1|"}, Subs: []*admin_model.AdminNode{}}}},
+			},
+		},
+	}
+	controller.Handle(method, route, doc, func(ctx *xx.Context) {
+		id, _ := admin_middleware.GetUserIDFromContext(ctx)
+		admins, err := admin_model.GetSubAdminTree(id)
+		if err != nil {
+			ctx.Error(err)
+		} else {
+			ctx.SendJSON(xx.MAP{"admins": admins})
+		}
+	})
+}
+
+var CreateSubAdmin xx.Action = func(method, route string, controller *xx.Condition) {
+	type params struct {
+		ParentID int    `param:"parent_id" desc:"父账号ID, 为空时为当前管理员, 必须是当前管理员或其后代账号"`
+		Username string `param:"username" required:"用户名不能为空"`
+		Password string `param:"password" required:"密码不能为空" len:"6-12" len-msg:"密码必须在6-12个字符之间" reg:"^[\\w|\\_]+$" reg-msg:"密码只能是字母数字或下划线"`
+		Super    bool   `param:"super" desc:"是否为超级管理员, 只有超级管理员可创建"`
+		RoleIDs  []int  `param:"role_ids" desc:"授予的角色ID, 只能授予当前管理员拥有的角色"`
+	}
+	doc := &xx.Doc {
+		Title: "创建子账号",
+		Params: xx.Params {
+			{
+				Type:   xx.Form,
+				Schema: &params{},
+			},
+		},
+		Responses: xx.Responses {
+			{
+				Body: xx.MessageData(xx.MsgWarning, admin_model.ErrRoleNotGrantable.Error()),
+			},
+			{
+				Body: xx.MAP{"admin": &admin_model.Admin{ID: 5, Username: "sub", ParentID: 1, Forefather: "|1|"}},
+			},
+		},
+	}
+	controller.Handle(method, route, doc, func(ctx *xx.Context) {
+		p := &params{}
+		err := ctx.Unmarshal(p)
+		if err != nil {
+			ctx.SendJsonMessage(xx.MsgWarning, err.Error())
+			return
+		}
+		operator, err := loginAdmin(ctx)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		if p.Super && !operator.Super {
+			sendManageError(ctx, admin_model.ErrNeedSuperAdmin)
+			return
+		}
+		parent := operator
+		if p.ParentID > 0 && p.ParentID != operator.ID {
+			parent, err = manageableSubAdmin(operator, p.ParentID)
+			if err != nil {
+				sendManageError(ctx, err)
+				return
+			}
+		}
+		err = admin_model.CheckGrantableRoles(operator, p.RoleIDs)
+		if err != nil {
+			sendManageError(ctx, err)
+			return
+		}
+		admin, err := admin_model.CreateSubAdmin(parent, p.Username, p.Password, p.Super)
+		if err != nil {
+			sendManageError(ctx, err)
+			return
+		}
+		err = admin_model.ReplaceAdminRoles(admin.ID, p.RoleIDs)
+		if err != nil {
+			ctx.Error(err)
+		} else {
+			ctx.SendJSON(xx.MAP{"admin": admin})
+		}
+	})
+}
+
+var MoveSubAdmin xx.Action = func(method, route string, controller *xx.Condition) {
+	type params struct {
+		AdminID  int `param:"admin_id" required:"账号ID不能为空"`
+		ParentID int `param:"parent_id" required:"父账号ID不能为空" desc:"新的父账号ID, 必须是当前管理员或其后代账号"`
+	}
+	doc := &xx.Doc {
+		Title: "移动子账号",
+		Desc:  "将子账号及其所有后代账号移动到新的父账号下",
+		Params: xx.Params {
+			{
+				Type:   xx.Form,
+				Schema: &params{},
+			},
+		},
+		Responses: xx.Responses {
+			{
+				Body: xx.MessageData(xx.MsgWarning, admin_model.ErrMoveIntoSubtree.Error()),
+			},
+			{
+				Body: xx.MessageData(xx.MsgSuccess, "已移动"),
+			},
+		},
+	}
+	controller.Handle(method, route, doc, func(ctx *xx.Context) {
+		p := &params{}
+		err := ctx.Unmarshal(p)
+		if err != nil {
+			ctx.SendJsonMessage(xx.MsgWarning, err.Error())
+			return
+		}
+		operator, err := loginAdmin(ctx)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		admin, err := manageableSubAdmin(operator, p.AdminID)
+		if err != nil {
+			sendManageError(ctx, err)
+			return
+		}
+		parent := operator
+		if p.ParentID != operator.ID {
+			parent, err = manageableSubAdmin(operator, p.ParentID)
+			if err != nil {
+				sendManageError(ctx, err)
+				return
+			}
+		}
+		err = admin_model.MoveAdmin(admin, parent)
+		if err != nil {
+			sendManageError(ctx, err)
+		} else {
+			ctx.SendJsonMessage(xx.MsgSuccess, "已移动")
+		}
+	})
+}
+
+var SetSubAdminRoles xx.Action = func(method, route string, controller *xx.Condition) {
+	type params struct {
+		AdminID int   `param:"admin_id" required:"账号ID不能为空"`
+		RoleIDs []int `param:"role_ids" desc:"子账号的全部角色ID, 为空时清除子账号角色"`
+	}
+	doc := &xx.Doc {
+		Title: "设置子账号角色",
+		Desc:  "以提交的角色替换子账号的全部角色, 只能授予当前管理员拥有的角色",
+		Params: xx.Params {
+			{
+				Type:   xx.Form,
+				Schema: &params{},
+			},
+		},
+		Responses: xx.Responses {
+			{
+				Body: xx.MessageData(xx.MsgWarning, admin_model.ErrRoleNotGrantable.Error()),
+			},
+			{
+				Body: xx.MessageData(xx.MsgSuccess, "已保存"),
+			},
+		},
+	}
+	controller.Handle(method, route, doc, func(ctx *xx.Context) {
+		p := &params{}
+		err := ctx.Unmarshal(p)
+		if err != nil {
+			ctx.SendJsonMessage(xx.MsgWarning, err.Error())
+			return
+		}
+		operator, err := loginAdmin(ctx)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		_, err = manageableSubAdmin(operator, p.AdminID)
+		if err != nil {
+			sendManageError(ctx, err)
+			return
+		}
+		err = admin_model.CheckGrantableRoles(operator, p.RoleIDs)
+		if err != nil {
+			sendManageError(ctx, err)
+			return
+		}
+		err = admin_model.ReplaceAdminRoles(p.AdminID, p.RoleIDs)
+		if err != nil {
+			ctx.Error(err)
+		} else {
+			ctx.SendJsonMessage(xx.MsgSuccess, "已保存")
+		}
+	})
+}
+
+var DeleteSubAdmin xx.Action = func(method, route string, controller *xx.Condition) {
+	type params struct {
+		AdminID int `param:"admin_id" required:"账号ID不能为空"`
+	}
+	doc := &xx.Doc {
+		Title: "删除子账号",
+		Desc:  "子账号包含子账号时需要先移动或删除其子账号, 删除后其所有设备将退出登录",
+		Params: xx.Params {
+			{
+				Type:   xx.Query,
+				Schema: &params{},
+			},
+		},
+		Responses: xx.Responses {
+			{
+				Body: xx.MessageData(xx.MsgWarning, admin_model.ErrAdminHasSubs.Error()),
+			},
+			{
+				Body: xx.MessageData(xx.MsgSuccess, "已删除"),
+			},
+		},
+	}
+	controller.Handle(method, route, doc, func(ctx *xx.Context) {
+		p := &params{}
+		err := ctx.Unmarshal(p)
+		if err != nil {
+			ctx.SendJsonMessage(xx.MsgWarning, err.Error())
+			return
+		}
+		operator, err := loginAdmin(ctx)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		_, err = manageableSubAdmin(operator, p.AdminID)
+		if err != nil {
+			sendManageError(ctx, err)
+			return
+		}
+		err = admin_model.DeleteAdmin(p.AdminID)
+		if err != nil {
+			sendManageError(ctx, err)
+			return
+		}
+		err = RevokeAdminTokens(p.AdminID)
+		if err != nil {
+			ctx.Error(err)
+		} else {
+			ctx.SendJsonMessage(xx.MsgSuccess, "已删除")
+		}
+	})
+}
+
+var GetGrantableRoles xx.Action = func(method, route string, controller *xx.Condition) {
+	doc := &xx.Doc {
+		Title: "获得可授予的角色",
+		Desc:  "超级管理员可授予所有角色, 其他管理员只能授予自己拥有的角色",
+		Responses: xx.Responses {
+			{
+				Body: xx.MAP{"roles": []*admin_model.Role{{ID: 1, Name: "editor"}}},
+			},
+		},
+	}
+	controller.Handle(method, route, doc, func(ctx *xx.Context) {
+		operator, err := loginAdmin(ctx)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		roles, err := admin_model.GetGrantableRoles(operator)
+		if err != nil {
+			ctx.Error(err)
+		} else {
+			ctx.SendJSON(xx.MAP{"roles": roles})
+		}
+	})
+}
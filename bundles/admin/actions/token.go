@@ -0,0 +1,161 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package actions
+
+import (
+	admin_middleware "github.com/orivil/morgine/bundles/admin/middleware"
+	"github.com/orivil/morgine/utils/token"
+	"github.com/orivil/morgine/xx"
+	"strconv"
+	"time"
+)
+
+var RefreshToken xx.Action = func(method, route string, controller *xx.Condition) {
+	type params struct {
+		RefreshToken string `param:"refresh_token" required:"刷新令牌不能为空"`
+	}
+	doc := &xx.Doc {
+		Title: "刷新访问令牌",
+		Desc:  "使用刷新令牌换取新的访问令牌及刷新令牌, 旧的刷新令牌随即失效. 旧令牌被再次使用时视为被盗用, 该设备将退出登录",
+		Params: xx.Params {
+			{
+				Type:   xx.Form,
+				Schema: &params{},
+			},
+		},
+		Responses: xx.Responses {
+			{
+				Description: "刷新令牌无效, 已过期或已撤销, 需要重新登录",
+				Body:        xx.StatusJsonData(xx.StatusUnauthorized, nil),
+			},
+			{
+				Body: &token.Pair{AccessToken: "access token", TokenType: "Bearer", ExpiresIn: 900, RefreshToken: "refresh token"},
+			},
+		},
+	}
+	controller.Handle(method, route, doc, func(ctx *xx.Context) {
+		p := &params{}
+		err := ctx.Unmarshal(p)
+		if err != nil {
+			ctx.SendJsonMessage(xx.MsgWarning, err.Error())
+			return
+		}
+		pair, err := admin_middleware.Tokens.Refresh(p.RefreshToken)
+		switch err {
+		case nil:
+			ctx.SendJSON(pair)
+		case token.ErrTokenInvalid, token.ErrTokenExpired, token.ErrRefreshReused:
+			ctx.SendStatusJsonData(xx.StatusUnauthorized, nil)
+		default:
+			ctx.Error(err)
+		}
+	})
+}
+
+var Logout xx.Action = func(method, route string, controller *xx.Condition) {
+	doc := &xx.Doc {
+		Title: "退出登录",
+		Desc:  "撤销当前的访问令牌及刷新令牌",
+		Responses: xx.Responses {
+			{
+				Body: xx.MessageData(xx.MsgSuccess, "已退出登录"),
+			},
+		},
+	}
+	controller.Handle(method, route, doc, func(ctx *xx.Context) {
+		claims, _ := admin_middleware.GetClaimsFromContext(ctx)
+		err := admin_middleware.Tokens.Revoke(claims)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		ctx.SendJsonMessage(xx.MsgSuccess, "已退出登录")
+	})
+}
+
+type loginSession struct {
+	ID         string    `json:"id"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at"`
+	AccessedAt time.Time `json:"accessed_at" desc:"最后一次刷新令牌的时间"`
+	Current    bool      `json:"current" desc:"是否为当前设备"`
+}
+
+var GetLoginSessions xx.Action = func(method, route string, controller *xx.Condition) {
+	doc := &xx.Doc {
+		Title: "获得已登录的设备",
+		Responses: xx.Responses {
+			{
+				Body: []*loginSession{{ID: "session id", IP: "127.0.0.1", UserAgent: "Mozilla/5.0", Current: true}},
+			},
+		},
+	}
+	controller.Handle(method, route, doc, func(ctx *xx.Context) {
+		claims, _ := admin_middleware.GetClaimsFromContext(ctx)
+		sessions, err := admin_middleware.Tokens.Sessions(claims.Subject)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		list := make([]*loginSession, 0, len(sessions))
+		for _, s := range sessions {
+			list = append(list, &loginSession {
+				ID:         s.ID,
+				IP:         s.IP,
+				UserAgent:  s.UserAgent,
+				CreatedAt:  s.CreatedAt,
+				AccessedAt: s.AccessedAt,
+				Current:    s.ID == claims.SessionID,
+			})
+		}
+		ctx.SendJSON(list)
+	})
+}
+
+var LogoutAll xx.Action = func(method, route string, controller *xx.Condition) {
+	type params struct {
+		KeepCurrent bool `param:"keep_current" desc:"是否保留当前设备的登录状态"`
+	}
+	doc := &xx.Doc {
+		Title: "退出所有设备",
+		Desc:  "撤销管理员所有设备的访问令牌及刷新令牌, 可用于账号泄露或修改密码后",
+		Params: xx.Params {
+			{
+				Type:   xx.Query,
+				Schema: &params{},
+			},
+		},
+		Responses: xx.Responses {
+			{
+				Body: xx.MessageData(xx.MsgSuccess, "已退出所有设备"),
+			},
+		},
+	}
+	controller.Handle(method, route, doc, func(ctx *xx.Context) {
+		p := &params{}
+		err := ctx.Unmarshal(p)
+		if err != nil {
+			ctx.SendJsonMessage(xx.MsgWarning, err.Error())
+			return
+		}
+		claims, _ := admin_middleware.GetClaimsFromContext(ctx)
+		var except []string
+		if p.KeepCurrent {
+			except = append(except, claims.SessionID)
+		}
+		err = admin_middleware.Tokens.RevokeUser(claims.Subject, except...)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		ctx.SendJsonMessage(xx.MsgSuccess, "已退出所有设备")
+	})
+}
+
+// RevokeAdminTokens 退出管理员所有设备, 用于禁用账号或重置密码后
+func RevokeAdminTokens(adminID int) error {
+	return admin_middleware.Tokens.RevokeUser(strconv.Itoa(adminID))
+}
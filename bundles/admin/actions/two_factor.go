@@ -0,0 +1,702 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package actions
+
+import (
+	"crypto/sha256"
+	"errors"
+	"github.com/orivil/morgine/bundles/admin/env"
+	admin_middleware "github.com/orivil/morgine/bundles/admin/middleware"
+	admin_model "github.com/orivil/morgine/bundles/admin/model"
+	"github.com/orivil/morgine/utils/crypto"
+	"github.com/orivil/morgine/utils/session"
+	"github.com/orivil/morgine/utils/token"
+	"github.com/orivil/morgine/utils/totp"
+	"github.com/orivil/morgine/xx"
+	"strconv"
+	"time"
+)
+
+const (
+	// 需要两步验证的状态码, 客户端收到后以 mfa_token 及验证码调用两步验证登录接口
+	StatusTwoFactorRequired xx.StatusCode = 2450
+
+	// 所属角色要求启用两步验证但尚未设置的状态码, 客户端收到后以 mfa_token 设置两步验证
+	StatusTwoFactorSetupRequired xx.StatusCode = 2451
+)
+
+func init() {
+	xx.StatusCodes.InitStatus("admin", StatusTwoFactorRequired, "TwoFactorRequired")
+	xx.StatusCodes.InitStatus("admin", StatusTwoFactorSetupRequired, "TwoFactorSetupRequired")
+}
+
+var (
+	errChallengeInvalid  = errors.New("验证已过期, 请重新登录")
+	errCodeRequired      = errors.New("请输入验证码或恢复码")
+	errCodeIncorrect     = errors.New("验证码错误")
+	errTwoFactorRequired = errors.New("所属角色要求启用两步验证")
+)
+
+// 两步验证挑战
+const (
+	challengeVerify    = "verify"
+	challengeSetup     = "setup"
+	challengeTTL       = 5 * time.Minute
+	challengeMaxFailed = 5
+)
+
+// 密码正确后等待两步验证的登录挑战, 以 mfa_token 为 ID, 由 InitTwoFactor 初始化
+var TwoFactorChallenges session.Store
+
+// 加密数据库中的 TOTP 密钥
+var twoFactorCrypto crypto.Interface
+
+// 根据配置初始化两步验证
+func InitTwoFactor() error {
+	key := env.Env.TwoFactorKey
+	if key == "" {
+		sum := sha256.Sum256([]byte(env.Env.AuthKey))
+		key = string(sum[:])
+	}
+	c, err := crypto.NewCFBCrypto(key)
+	if err != nil {
+		return err
+	}
+	twoFactorCrypto = c
+	TwoFactorChallenges = session.NewMemoryStore()
+	return nil
+}
+
+func addTwoFactorEvent(ctx *xx.Context, adminID int, event string) error {
+	return admin_model.AddTwoFactorEvent(adminID, event, ctx.ClientIP(), ctx.Request.UserAgent())
+}
+
+// 密码验证通过后检查是否需要两步验证, 需要时响应挑战并返回 true
+func challengeTwoFactor(ctx *xx.Context, admin *admin_model.Admin) bool {
+	var purpose string
+	var status xx.StatusCode
+	tf, err := admin_model.GetTwoFactor(admin.ID)
+	switch {
+	case err == nil && tf.Enabled:
+		purpose, status = challengeVerify, StatusTwoFactorRequired
+	case err == nil || err == admin_model.ErrTwoFactorNotSet:
+		required, err := admin_model.AdminRequiresTwoFactor(admin.ID)
+		if err != nil {
+			ctx.Error(err)
+			return true
+		}
+		if !required {
+			return false
+		}
+		purpose, status = challengeSetup, StatusTwoFactorSetupRequired
+	default:
+		ctx.Error(err)
+		return true
+	}
+	now := time.Now()
+	challenge := &session.Data {
+		ID:         session.NewID(),
+		UserID:     strconv.Itoa(admin.ID),
+		Values:     map[string]string{"purpose": purpose, "username": admin.Username, "failed": "0"},
+		IP:         ctx.ClientIP(),
+		UserAgent:  ctx.Request.UserAgent(),
+		CreatedAt:  now,
+		AccessedAt: now,
+		ExpiresAt:  now.Add(challengeTTL),
+	}
+	err = TwoFactorChallenges.Save(challenge)
+	if err != nil {
+		ctx.Error(err)
+		return true
+	}
+	ctx.SendStatusJsonData(status, xx.MAP{"mfa_token": challenge.ID, "expires_in": int(challengeTTL / time.Second)})
+	return true
+}
+
+func getChallenge(mfaToken, purpose string) (challenge *session.Data, adminID int, err error) {
+	if mfaToken == "" {
+		return nil, 0, errChallengeInvalid
+	}
+	challenge, err = TwoFactorChallenges.Get(mfaToken)
+	if err != nil {
+		if err == session.ErrNotFound {
+			err = errChallengeInvalid
+		}
+		return nil, 0, err
+	}
+	if challenge.Values["purpose"] != purpose {
+		return nil, 0, errChallengeInvalid
+	}
+	adminID, err = strconv.Atoi(challenge.UserID)
+	if err != nil {
+		return nil, 0, errChallengeInvalid
+	}
+	return challenge, adminID, nil
+}
+
+// 记录挑战验证失败, 同时计入登录失败次数, 失败次数过多时挑战作废
+func challengeFailed(ctx *xx.Context, challenge *session.Data) error {
+	loginFailed(ctx, challenge.Values["username"])
+	failed, _ := strconv.Atoi(challenge.Values["failed"])
+	failed++
+	if failed >= challengeMaxFailed {
+		return TwoFactorChallenges.Delete(challenge.ID)
+	}
+	challenge.Values["failed"] = strconv.Itoa(failed)
+	return TwoFactorChallenges.Save(challenge)
+}
+
+// 验证 TOTP 密码或恢复码并记录事件. 每个时间窗口的密码只能使用一次
+func checkSecondFactor(ctx *xx.Context, tf *admin_model.AdminTwoFactor, code, recoveryCode string) (ok bool, err error) {
+	event := admin_model.TwoFactorVerifyFailed
+	if code != "" {
+		secret, err := twoFactorCrypto.Decrypt([]byte(tf.Secret))
+		if err != nil {
+			return false, err
+		}
+		if counter, valid := totp.Default.Validate(string(secret), code, time.Now()); valid {
+			ok, err = admin_model.UseTwoFactorCounter(tf.AdminID, counter)
+			if err != nil {
+				return false, err
+			}
+		}
+		if ok {
+			event = admin_model.TwoFactorVerifySuccess
+		}
+	} else if recoveryCode != "" {
+		ok, err = admin_model.UseRecoveryCode(tf.AdminID, recoveryCode)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			event = admin_model.TwoFactorRecoveryUsed
+		}
+	}
+	return ok, addTwoFactorEvent(ctx, tf.AdminID, event)
+}
+
+// 获得设置两步验证的管理员, 已登录时为当前管理员, 否则为登录时被要求设置两步验证的管理员
+func twoFactorSetupAdmin(ctx *xx.Context, mfaToken string) (adminID int, challenge *session.Data, err error) {
+	if id, ok := admin_middleware.GetUserIDFromContext(ctx); ok {
+		return id, nil, nil
+	}
+	challenge, adminID, err = getChallenge(mfaToken, challengeSetup)
+	return adminID, challenge, err
+}
+
+func generateRecoveryCodes(adminID int) ([]string, error) {
+	codes, err := admin_model.GenerateRecoveryCodes(10)
+	if err != nil {
+		return nil, err
+	}
+	err = admin_model.ReplaceRecoveryCodes(adminID, codes)
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+var TwoFactorLogin xx.Action = func(method, route string, controller *xx.Condition) {
+	type params struct {
+		MfaToken     string `param:"mfa_token" required:"mfa_token 不能为空" desc:"登录接口返回的 mfa_token"`
+		Code         string `param:"code" sensitive:"" desc:"验证器应用中的 6 位密码"`
+		RecoveryCode string `param:"recovery_code" sensitive:"" desc:"恢复码, 无法使用验证器时使用, 每个只能使用一次"`
+	}
+	doc := &xx.Doc {
+		Title: "两步验证登录",
+		Desc:  "登录接口返回 TwoFactorRequired 状态码后, 以 mfa_token 及验证码或恢复码完成登录. 连续失败 5 次后需要重新登录",
+		Params: xx.Params {
+			{
+				Type:   xx.Form,
+				Schema: &params{},
+			},
+		},
+		Responses: xx.Responses {
+			{
+				Body: xx.MessageData(xx.MsgWarning, errCodeIncorrect.Error()),
+			},
+			{
+				Body: &token.Pair{AccessToken: "access token", TokenType: "Bearer", ExpiresIn: 900, RefreshToken: "refresh token"},
+			},
+		},
+	}
+	controller.Handle(method, route, doc, func(ctx *xx.Context) {
+		p := &params{}
+		err := ctx.Unmarshal(p)
+		if err != nil {
+			ctx.SendJsonMessage(xx.MsgWarning, err.Error())
+			return
+		}
+		if p.Code == "" && p.RecoveryCode == "" {
+			ctx.SendJsonMessage(xx.MsgWarning, errCodeRequired.Error())
+			return
+		}
+		challenge, adminID, err := getChallenge(p.MfaToken, challengeVerify)
+		if err != nil {
+			if err == errChallengeInvalid {
+				ctx.SendJsonMessage(xx.MsgWarning, err.Error())
+			} else {
+				ctx.Error(err)
+			}
+			return
+		}
+		if sendLoginLocked(ctx, challenge.Values["username"]) {
+			return
+		}
+		tf, err := admin_model.GetTwoFactor(adminID)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		ok, err := checkSecondFactor(ctx, tf, p.Code, p.RecoveryCode)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		if !ok {
+			err = challengeFailed(ctx, challenge)
+			if err != nil {
+				ctx.Error(err)
+			} else {
+				ctx.SendJsonMessage(xx.MsgWarning, errCodeIncorrect.Error())
+			}
+			return
+		}
+		err = TwoFactorChallenges.Delete(challenge.ID)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		admin, err := admin_model.GetAdmin(adminID)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		// 密码验证后账号可能已被禁用
+		if admin.Disabled {
+			ctx.SendJsonMessage(xx.MsgWarning, admin_model.ErrAdminDisabled.Error())
+			return
+		}
+		pair, err := admin_middleware.NewToken(ctx, admin)
+		if err != nil {
+			ctx.Error(err)
+		} else {
+			loginSuccess(challenge.Values["username"])
+			ctx.SendJSON(pair)
+		}
+	})
+}
+
+var EnrollTwoFactor xx.Action = func(method, route string, controller *xx.Condition) {
+	type params struct {
+		MfaToken string `param:"mfa_token" desc:"未登录时, 登录接口返回 TwoFactorSetupRequired 状态码时的 mfa_token"`
+	}
+	doc := &xx.Doc {
+		Title: "设置两步验证",
+		Desc:  "生成新的 TOTP 密钥, 以 uri 生成二维码供验证器应用扫描, 验证一次密码后启用. 重复调用将替换未启用的密钥",
+		Params: xx.Params {
+			{
+				Type:   xx.Form,
+				Schema: &params{},
+			},
+		},
+		Responses: xx.Responses {
+			{
+				Body: xx.MessageData(xx.MsgWarning, admin_model.ErrTwoFactorEnabled.Error()),
+			},
+			{
+				Body: xx.MAP{"secret": "JBSWY3DPEHPK3PXP", "uri": "otpauth://totp/Morgine%20Admin:root?secret=JBSWY3DPEHPK3PXP&issuer=Morgine+Admin"},
+			},
+		},
+	}
+	controller.Handle(method, route, doc, func(ctx *xx.Context) {
+		p := &params{}
+		err := ctx.Unmarshal(p)
+		if err != nil {
+			ctx.SendJsonMessage(xx.MsgWarning, err.Error())
+			return
+		}
+		adminID, _, err := twoFactorSetupAdmin(ctx, p.MfaToken)
+		if err != nil {
+			if err == errChallengeInvalid {
+				ctx.SendJsonMessage(xx.MsgWarning, err.Error())
+			} else {
+				ctx.Error(err)
+			}
+			return
+		}
+		admin, err := admin_model.GetAdmin(adminID)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		secret := totp.GenerateSecret()
+		encrypted, err := twoFactorCrypto.Encrypt([]byte(secret))
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		err = admin_model.SaveTwoFactorSecret(adminID, string(encrypted))
+		if err != nil {
+			if err == admin_model.ErrTwoFactorEnabled {
+				ctx.SendJsonMessage(xx.MsgWarning, err.Error())
+			} else {
+				ctx.Error(err)
+			}
+			return
+		}
+		err = addTwoFactorEvent(ctx, adminID, admin_model.TwoFactorEnroll)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		issuer := env.Env.TwoFactorIssuer
+		if issuer == "" {
+			issuer = "Morgine Admin"
+		}
+		ctx.SendJSON(xx.MAP{"secret": secret, "uri": totp.Default.URI(issuer, admin.Username, secret)})
+	})
+}
+
+var ActivateTwoFactor xx.Action = func(method, route string, controller *xx.Condition) {
+	type params struct {
+		MfaToken string `param:"mfa_token" desc:"未登录时, 登录接口返回 TwoFactorSetupRequired 状态码时的 mfa_token"`
+		Code     string `param:"code" sensitive:"" required:"验证码不能为空" desc:"验证器应用中的 6 位密码"`
+	}
+	doc := &xx.Doc {
+		Title: "启用两步验证",
+		Desc:  "验证密码后启用两步验证, 返回的恢复码只显示一次. 以 mfa_token 调用时同时完成登录, 返回访问令牌",
+		Params: xx.Params {
+			{
+				Type:   xx.Form,
+				Schema: &params{},
+			},
+		},
+		Responses: xx.Responses {
+			{
+				Body: xx.MessageData(xx.MsgWarning, errCodeIncorrect.Error()),
+			},
+			{
+				Body: xx.MAP {
+					"recovery_codes": []string{"ABCDE-FGHIJ"},
+					"token":          &token.Pair{AccessToken: "access token", TokenType: "Bearer", ExpiresIn: 900, RefreshToken: "refresh token"},
+				},
+			},
+		},
+	}
+	controller.Handle(method, route, doc, func(ctx *xx.Context) {
+		p := &params{}
+		err := ctx.Unmarshal(p)
+		if err != nil {
+			ctx.SendJsonMessage(xx.MsgWarning, err.Error())
+			return
+		}
+		adminID, challenge, err := twoFactorSetupAdmin(ctx, p.MfaToken)
+		if err != nil {
+			if err == errChallengeInvalid {
+				ctx.SendJsonMessage(xx.MsgWarning, err.Error())
+			} else {
+				ctx.Error(err)
+			}
+			return
+		}
+		tf, err := admin_model.GetTwoFactor(adminID)
+		if err != nil {
+			if err == admin_model.ErrTwoFactorNotSet {
+				ctx.SendJsonMessage(xx.MsgWarning, err.Error())
+			} else {
+				ctx.Error(err)
+			}
+			return
+		}
+		if tf.Enabled {
+			ctx.SendJsonMessage(xx.MsgWarning, admin_model.ErrTwoFactorEnabled.Error())
+			return
+		}
+		secret, err := twoFactorCrypto.Decrypt([]byte(tf.Secret))
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		counter, ok := totp.Default.Validate(string(secret), p.Code, time.Now())
+		if !ok {
+			err = addTwoFactorEvent(ctx, adminID, admin_model.TwoFactorVerifyFailed)
+			if err == nil && challenge != nil {
+				err = challengeFailed(ctx, challenge)
+			}
+			if err != nil {
+				ctx.Error(err)
+			} else {
+				ctx.SendJsonMessage(xx.MsgWarning, errCodeIncorrect.Error())
+			}
+			return
+		}
+		err = admin_model.EnableTwoFactor(adminID, counter)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		codes, err := generateRecoveryCodes(adminID)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		err = addTwoFactorEvent(ctx, adminID, admin_model.TwoFactorEnable)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		if challenge == nil {
+			ctx.SendJSON(xx.MAP{"recovery_codes": codes})
+			return
+		}
+		err = TwoFactorChallenges.Delete(challenge.ID)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		admin, err := admin_model.GetAdmin(adminID)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		if admin.Disabled {
+			ctx.SendJsonMessage(xx.MsgWarning, admin_model.ErrAdminDisabled.Error())
+			return
+		}
+		pair, err := admin_middleware.NewToken(ctx, admin)
+		if err != nil {
+			ctx.Error(err)
+		} else {
+			loginSuccess(challenge.Values["username"])
+			ctx.SendJSON(xx.MAP{"recovery_codes": codes, "token": pair})
+		}
+	})
+}
+
+var GetTwoFactorStatus xx.Action = func(method, route string, controller *xx.Condition) {
+	doc := &xx.Doc {
+		Title: "获得两步验证状态",
+		Responses: xx.Responses {
+			{
+				Body: xx.MAP{"enabled": true, "required": false, "recovery_codes_left": 10},
+			},
+		},
+	}
+	controller.Handle(method, route, doc, func(ctx *xx.Context) {
+		adminID, _ := admin_middleware.GetUserIDFromContext(ctx)
+		tf, err := admin_model.GetTwoFactor(adminID)
+		if err != nil && err != admin_model.ErrTwoFactorNotSet {
+			ctx.Error(err)
+			return
+		}
+		required, err := admin_model.AdminRequiresTwoFactor(adminID)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		left, err := admin_model.CountRecoveryCodes(adminID)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		ctx.SendJSON(xx.MAP{"enabled": tf != nil && tf.Enabled, "required": required, "recovery_codes_left": left})
+	})
+}
+
+var DisableTwoFactor xx.Action = func(method, route string, controller *xx.Condition) {
+	type params struct {
+		Code         string `param:"code" sensitive:"" desc:"验证器应用中的 6 位密码"`
+		RecoveryCode string `param:"recovery_code" sensitive:"" desc:"恢复码"`
+	}
+	doc := &xx.Doc {
+		Title: "关闭两步验证",
+		Desc:  "需要验证码或恢复码, 所属角色要求两步验证时不能关闭",
+		Params: xx.Params {
+			{
+				Type:   xx.Query,
+				Schema: &params{},
+			},
+		},
+		Responses: xx.Responses {
+			{
+				Body: xx.MessageData(xx.MsgWarning, errTwoFactorRequired.Error()),
+			},
+			{
+				Body: xx.MessageData(xx.MsgSuccess, "已关闭两步验证"),
+			},
+		},
+	}
+	controller.Handle(method, route, doc, func(ctx *xx.Context) {
+		p := &params{}
+		err := ctx.Unmarshal(p)
+		if err != nil {
+			ctx.SendJsonMessage(xx.MsgWarning, err.Error())
+			return
+		}
+		if p.Code == "" && p.RecoveryCode == "" {
+			ctx.SendJsonMessage(xx.MsgWarning, errCodeRequired.Error())
+			return
+		}
+		admin, err := loginAdmin(ctx)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		if sendLoginLocked(ctx, admin.Username) {
+			return
+		}
+		adminID := admin.ID
+		required, err := admin_model.AdminRequiresTwoFactor(adminID)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		if required {
+			ctx.SendJsonMessage(xx.MsgWarning, errTwoFactorRequired.Error())
+			return
+		}
+		tf, err := admin_model.GetTwoFactor(adminID)
+		if err == nil && !tf.Enabled {
+			err = admin_model.ErrTwoFactorNotSet
+		}
+		if err != nil {
+			if err == admin_model.ErrTwoFactorNotSet {
+				ctx.SendJsonMessage(xx.MsgWarning, err.Error())
+			} else {
+				ctx.Error(err)
+			}
+			return
+		}
+		ok, err := checkSecondFactor(ctx, tf, p.Code, p.RecoveryCode)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		if !ok {
+			loginFailed(ctx, admin.Username)
+			ctx.SendJsonMessage(xx.MsgWarning, errCodeIncorrect.Error())
+			return
+		}
+		err = admin_model.DisableTwoFactor(adminID)
+		if err == nil {
+			err = addTwoFactorEvent(ctx, adminID, admin_model.TwoFactorDisable)
+		}
+		if err != nil {
+			ctx.Error(err)
+		} else {
+			ctx.SendJsonMessage(xx.MsgSuccess, "已关闭两步验证")
+		}
+	})
+}
+
+var RenewRecoveryCodes xx.Action = func(method, route string, controller *xx.Condition) {
+	type params struct {
+		Code string `param:"code" sensitive:"" required:"验证码不能为空" desc:"验证器应用中的 6 位密码"`
+	}
+	doc := &xx.Doc {
+		Title: "重新生成恢复码",
+		Desc:  "旧的恢复码全部失效, 新的恢复码只显示一次",
+		Params: xx.Params {
+			{
+				Type:   xx.Form,
+				Schema: &params{},
+			},
+		},
+		Responses: xx.Responses {
+			{
+				Body: xx.MessageData(xx.MsgWarning, errCodeIncorrect.Error()),
+			},
+			{
+				Body: xx.MAP{"recovery_codes": []string{"ABCDE-FGHIJ"}},
+			},
+		},
+	}
+	controller.Handle(method, route, doc, func(ctx *xx.Context) {
+		p := &params{}
+		err := ctx.Unmarshal(p)
+		if err != nil {
+			ctx.SendJsonMessage(xx.MsgWarning, err.Error())
+			return
+		}
+		admin, err := loginAdmin(ctx)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		if sendLoginLocked(ctx, admin.Username) {
+			return
+		}
+		adminID := admin.ID
+		tf, err := admin_model.GetTwoFactor(adminID)
+		if err == nil && !tf.Enabled {
+			err = admin_model.ErrTwoFactorNotSet
+		}
+		if err != nil {
+			if err == admin_model.ErrTwoFactorNotSet {
+				ctx.SendJsonMessage(xx.MsgWarning, err.Error())
+			} else {
+				ctx.Error(err)
+			}
+			return
+		}
+		ok, err := checkSecondFactor(ctx, tf, p.Code, "")
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		if !ok {
+			loginFailed(ctx, admin.Username)
+			ctx.SendJsonMessage(xx.MsgWarning, errCodeIncorrect.Error())
+			return
+		}
+		codes, err := generateRecoveryCodes(adminID)
+		if err == nil {
+			err = addTwoFactorEvent(ctx, adminID, admin_model.TwoFactorRecoveryRenewed)
+		}
+		if err != nil {
+			ctx.Error(err)
+		} else {
+			ctx.SendJSON(xx.MAP{"recovery_codes": codes})
+		}
+	})
+}
+
+var GetTwoFactorEvents xx.Action = func(method, route string, controller *xx.Condition) {
+	type params struct {
+		Limit  int `required:"" num:"1-100"`
+		Offset int
+	}
+	doc := &xx.Doc {
+		Title: "获得两步验证记录",
+		Desc:  "事件: enroll 设置, enable 启用, disable 关闭, verify_success 验证成功, verify_failed 验证失败, recovery_used 使用恢复码, recovery_renewed 重新生成恢复码",
+		Params: xx.Params {
+			{
+				Type:   xx.Query,
+				Schema: &params{},
+			},
+		},
+		Responses: xx.Responses {
+			{
+				Body: xx.MAP{"events": []*admin_model.AdminTwoFactorEvent{{Event: admin_model.TwoFactorVerifySuccess}}},
+			},
+		},
+	}
+	controller.Handle(method, route, doc, func(ctx *xx.Context) {
+		p := &params{}
+		err := ctx.Unmarshal(p)
+		if err != nil {
+			ctx.SendJsonMessage(xx.MsgWarning, err.Error())
+			return
+		}
+		adminID, _ := admin_middleware.GetUserIDFromContext(ctx)
+		events, err := admin_model.GetTwoFactorEvents(adminID, p.Limit, p.Offset)
+		if err != nil {
+			ctx.Error(err)
+		} else {
+			ctx.SendJSON(xx.MAP{"events": events})
+		}
+	})
+}
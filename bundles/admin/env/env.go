@@ -11,23 +11,106 @@ var Env = &env{}
 # 授权加密 key
 auth_key: "change this pass"
 
-# 授权过期时间/小时
-auth_expire_hour: 168
+# 访问令牌过期时间/分钟
+auth_access_minute: 15
 
-# casbin 权限模型文件
-auth_model_file: "configs/rbac_model.conf"
+# 刷新令牌过期时间/小时, 超过该时间未刷新则需要重新登录
+auth_expire_hour: 168
 
 # 初始管理员用户名
 root_user: "root"
 
 # 初始管理员密码
 root_password: "root654321"
+
+# 同一用户名连续登录失败多少次后锁定
+login_user_max_failed: 5
+
+# 同一 IP 连续登录失败多少次后锁定
+login_ip_max_failed: 20
+
+# 首次锁定时间/分钟, 之后每次失败锁定时间翻倍
+login_lock_minute: 1
+
+# 最长锁定时间/分钟
+login_max_lock_minute: 1440
+
+# 登录失败记录快照文件前缀, 如: "data/login_lock", 分别保存为 .user.json 及 .ip.json. 默认为空, 不保存, 重启后失败记录清空
+login_lock_snapshot: ""
+
+# 保存快照的间隔时间/分钟, 服务器退出时也会保存
+login_lock_snapshot_minute: 5
+
+# OpenID Connect 身份提供方, 为空时不启用单点登录
+oidc_issuer: ""
+
+# 在身份提供方注册的客户端
+oidc_client_id: ""
+oidc_client_secret: ""
+
+# 登录后的回调地址, 通常为前端页面, 由前端将 code 及 state 提交到 /oidc/callback 换取令牌
+oidc_redirect_url: ""
+
+# 申请的权限范围, 多个以空格分隔
+oidc_scopes: "openid profile email"
+
+# 作为管理员用户名的声明
+oidc_username_claim: "preferred_username"
+
+# 角色声明, 每次登录时按角色名称同步管理员角色, 为空或 ID 令牌中没有该声明时不同步
+oidc_role_claim: ""
+
+# 首次登录时是否按用户名关联未关联过的管理员, 只有身份提供方的用户名不可由用户修改时才能开启
+oidc_link_username: false
+
+# 首次登录时是否自动创建管理员
+oidc_auto_provision: false
+
+# 两步验证密钥的 AES 加密 key, 长度为 16, 24 或 32 位, 为空时由 auth_key 派生
+two_factor_key: ""
+
+# 验证器应用中显示的服务名称
+two_factor_issuer: "Morgine Admin"
+
+# 审计日志保留天数, 每天清理一次过期日志, 为 0 时不清理
+audit_log_days: 180
+
+# 标签图片保存目录, 需将该目录注册为静态文件服务
+image_dir: "admin_images"
+
+# 标签图片服务域名
+image_serve_host: ""
 **/
 type env struct {
 	AuthKey string `yaml:"auth_key"`
+	AuthAccessMinute int `yaml:"auth_access_minute"`
 	AuthExpireHour int `yaml:"auth_expire_hour"`
-	AuthModelFile string `yaml:"auth_model_file"`
 
 	RootUser string `yaml:"root_user"`
 	RootPassword string `yaml:"root_password"`
+
+	LoginUserMaxFailed int `yaml:"login_user_max_failed"`
+	LoginIPMaxFailed int `yaml:"login_ip_max_failed"`
+	LoginLockMinute int `yaml:"login_lock_minute"`
+	LoginMaxLockMinute int `yaml:"login_max_lock_minute"`
+	LoginLockSnapshot string `yaml:"login_lock_snapshot"`
+	LoginLockSnapshotMinute int `yaml:"login_lock_snapshot_minute"`
+
+	OIDCIssuer string `yaml:"oidc_issuer"`
+	OIDCClientID string `yaml:"oidc_client_id"`
+	OIDCClientSecret string `yaml:"oidc_client_secret"`
+	OIDCRedirectURL string `yaml:"oidc_redirect_url"`
+	OIDCScopes string `yaml:"oidc_scopes"`
+	OIDCUsernameClaim string `yaml:"oidc_username_claim"`
+	OIDCRoleClaim string `yaml:"oidc_role_claim"`
+	OIDCLinkUsername bool `yaml:"oidc_link_username"`
+	OIDCAutoProvision bool `yaml:"oidc_auto_provision"`
+
+	TwoFactorKey string `yaml:"two_factor_key"`
+	TwoFactorIssuer string `yaml:"two_factor_issuer"`
+
+	AuditLogDays int `yaml:"audit_log_days"`
+
+	ImageDir string `yaml:"image_dir"`
+	ImageServeHost string `yaml:"image_serve_host"`
 }
\ No newline at end of file
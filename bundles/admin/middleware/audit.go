@@ -0,0 +1,38 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package admin_middleware
+
+import (
+	"encoding/json"
+	admin_model "github.com/orivil/morgine/bundles/admin/model"
+	"github.com/orivil/morgine/xx"
+	"strconv"
+)
+
+// 审计中间件, 需要在 Auth 之后使用, 记录管理员的所有修改操作
+var Audit = xx.NewAuditor(recordAudit).Handler()
+
+func recordAudit(entry *xx.AuditEntry) error {
+	params, err := json.Marshal(entry.Params)
+	if err != nil {
+		return err
+	}
+	log := &admin_model.AuditLog {
+		Method:    entry.Method,
+		Route:     entry.Route,
+		Params:    string(params),
+		Status:    entry.Status,
+		IP:        entry.IP,
+		CreatedAt: entry.Time,
+	}
+	if entry.Actor != nil {
+		log.AdminID, _ = strconv.Atoi(entry.Actor.ID)
+		// 记录操作时的用户名, 账号删除后仍可查看
+		if admin, err := admin_model.GetAdmin(log.AdminID); err == nil {
+			log.Username = admin.Username
+		}
+	}
+	return admin_model.AddAuditLog(log)
+}
@@ -5,121 +5,113 @@
 package admin_middleware
 
 import (
-	"github.com/casbin/casbin"
-	gormadapter "github.com/casbin/gorm-adapter"
-	"github.com/dgrijalva/jwt-go"
-	"github.com/jinzhu/gorm"
+	"fmt"
 	"github.com/orivil/morgine/bundles/admin/env"
 	admin_model "github.com/orivil/morgine/bundles/admin/model"
+	"github.com/orivil/morgine/utils/session"
+	"github.com/orivil/morgine/utils/token"
 	"github.com/orivil/morgine/xx"
+	"github.com/orivil/morgine/xx/auth"
 	"strconv"
 	"time"
 )
 
+// 访问令牌过期的状态码, 客户端收到后应使用刷新令牌换取新的令牌
+const StatusTokenExpired xx.StatusCode = 2440
 
-var Enforcer *casbin.Enforcer
+func init() {
+	xx.StatusCodes.InitStatus("admin", StatusTokenExpired, "TokenExpired")
+}
+
+// 访问令牌管理器, 由 InitTokens 初始化
+var Tokens *token.Manager
 
-func InitEnforcer(modelFile string, db *gorm.DB) error {
-	a, err := gormadapter.NewAdapterByDB(db)
-	if err != nil {
-		return err
+// 根据配置初始化进程内的令牌管理器, 未配置的选项使用默认值
+func InitTokens() {
+	Tokens = token.NewManager([]byte(env.Env.AuthKey), session.NewMemoryStore(), token.NewMemoryDenylist())
+	if env.Env.AuthAccessMinute > 0 {
+		Tokens.AccessTTL = time.Duration(env.Env.AuthAccessMinute) * time.Minute
 	}
-	e, err := casbin.NewEnforcer(modelFile, a)
-	if err != nil {
-		return err
+	if env.Env.AuthExpireHour > 0 {
+		Tokens.RefreshTTL = time.Duration(env.Env.AuthExpireHour) * time.Hour
 	}
-	err = e.LoadPolicy()
-	if err != nil {
-		return err
+}
+
+// 验证 utils/token 签发的访问令牌
+func verifyToken(tokenString string) (*xx.Principal, error) {
+	claims, err := Tokens.Verify(tokenString)
+	switch err {
+	case nil:
+	case token.ErrTokenExpired:
+		return nil, auth.ErrTokenExpired
+	case token.ErrTokenInvalid, token.ErrTokenRevoked:
+		return nil, fmt.Errorf("%w: %s", auth.ErrInvalidCredentials, err)
+	default:
+		return nil, err
 	}
-	Enforcer = e
-	return nil
+	if _, err = strconv.Atoi(claims.Subject); err != nil {
+		return nil, auth.ErrInvalidCredentials
+	}
+	return &xx.Principal{ID: claims.Subject, Claims: claims}, nil
 }
 
-var userIDContextKey = "user-id"
+// 登录中间件
+var Auth = newAuth(false)
 
-var userRolesContextKey = "user-roles"
+// 可选的登录中间件, 未提供令牌时以匿名身份访问, 用于登录前后都可调用的接口
+var OptionalAuth = newAuth(true)
 
-var Auth = func() *xx.Handler {
-	type ps struct {
-		Authorization string `required:"未提供授权码" desc:"该数据需通过登录接口获得"`
+func newAuth(optional bool) *xx.Handler {
+	a := auth.New(&auth.Bearer{Name: "adminToken", Format: "JWT", Verify: verifyToken})
+	a.Optional = optional
+	// 以状态码区分令牌过期, 便于客户端自动刷新令牌
+	a.Unauthorized = func(ctx *xx.Context, err error) {
+		if err == auth.ErrTokenExpired {
+			ctx.SendStatusJsonData(StatusTokenExpired, nil)
+		} else {
+			ctx.SendStatusJsonData(xx.StatusUnauthorized, nil)
+		}
 	}
-	doc := &xx.Doc {
-		Title: "User Auth",
-		Desc: "用户登录中间件",
-		Params: xx.Params {
-			{
-				Type:   xx.Header,
-				Schema: &ps{},
-			},
-		},
-		Responses: xx.Responses {
-			xx.MessageResponse(xx.MsgTypeWarning),
-		},
+	h := a.Handler()
+	h.Doc.Title = "User Auth"
+	h.Doc.Desc = "用户登录中间件, Authorization 请求头为 \"Bearer 访问令牌\", 访问令牌通过登录接口获得"
+	if optional {
+		h.Doc.Title = "Optional User Auth"
+		h.Doc.Desc += ", 未提供令牌时以匿名身份访问"
 	}
-	return &xx.Handler {
-		Doc: doc,
-		HandleFunc: func(ctx *xx.Context) {
-			p := &ps{}
-			err := ctx.Unmarshal(p)
-			if err != nil {
-				xx.HandleUnmarshalError(err, ctx)
-			} else {
-				token, err := jwt.ParseWithClaims(p.Authorization, &adminClaims{}, func(token *jwt.Token) (i interface{}, e error) {
-					return []byte(env.Env.AuthKey), nil
-				})
-				if err != nil {
-					if ve, ok := err.(*jwt.ValidationError); ok {
-						if ve.Errors&jwt.ValidationErrorExpired != 0 {
-							ctx.MsgWarning("管理员授权过期")
-							return
-						}
-					}
-					ctx.MsgWarning(err.Error())
-				} else {
-					claims := token.Claims.(*adminClaims)
-					ok, err := Enforcer.Enforce(claims.Admin.Username)
-					if err != nil {
-						ctx.Error(err)
-					} else {
-						if ok {
-							ctx.Set(userIDContextKey, claims.Admin)
-						} else {
-
-						}
-					}
-					ctx.Set(userRolesContextKey, claims.Roles)
-				}
-			}
+	h.Doc.Responses = xx.Responses {
+		{
+			Description: "未登录或令牌已撤销",
+			Body: xx.StatusJsonData(xx.StatusUnauthorized, nil),
+		},
+		{
+			Description: "令牌已过期",
+			Body: xx.StatusJsonData(StatusTokenExpired, nil),
 		},
 	}
-}()
-
-type adminClaims struct {
-	Admin *admin_model.Admin
-	//Roles []int
-	jwt.StandardClaims
+	return h
 }
 
 // 从上下文(Context)中获取管理员ID，需要在授权中间件处理之后调用
 func GetUserIDFromContext(ctx *xx.Context) (int, bool) {
-	id, ok := ctx.Get(userIDContextKey).(int)
-	return id, ok
+	p := ctx.Principal()
+	if p == nil {
+		return 0, false
+	}
+	id, err := strconv.Atoi(p.ID)
+	return id, err == nil
 }
 
-// 从上下文(Context)中获取管理员角色列表, 需要在授权中间件处理之后调用
-func GetUserRolesFromContext(ctx *xx.Context) ([]int, bool) {
-	ids, ok := ctx.Get(userRolesContextKey).([]int)
-	return ids, ok
+// 从上下文(Context)中获取访问令牌声明, 需要在授权中间件处理之后调用
+func GetClaimsFromContext(ctx *xx.Context) (*token.Claims, bool) {
+	if p := ctx.Principal(); p != nil {
+		claims, ok := p.Claims.(*token.Claims)
+		return claims, ok
+	}
+	return nil, false
 }
 
-func NewToken(admin *admin_model.Admin) (token string, err error) {
-	jt := jwt.NewWithClaims(jwt.SigningMethodHS256, adminClaims{
-		Admin:admin,
-		StandardClaims: jwt.StandardClaims {
-			ExpiresAt: time.Now().Add(time.Duration(env.Env.AuthExpireHour) * time.Hour).Unix(),
-			Id:        strconv.Itoa(admin.ID),
-		},
-	})
-	return jt.SignedString([]byte(env.Env.AuthKey))
-}
\ No newline at end of file
+// 登录成功后签发访问令牌及刷新令牌
+func NewToken(ctx *xx.Context, admin *admin_model.Admin) (*token.Pair, error) {
+	return Tokens.Issue(strconv.Itoa(admin.ID), ctx.ClientIP(), ctx.Request.UserAgent())
+}
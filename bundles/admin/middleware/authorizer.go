@@ -0,0 +1,63 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package admin_middleware
+
+import (
+	admin_model "github.com/orivil/morgine/bundles/admin/model"
+	"github.com/orivil/morgine/xx"
+	"strconv"
+)
+
+// 超级管理员角色, 可访问所有接口, Super 为 true 的管理员拥有该角色
+const SuperRole = "super"
+
+// 接口授权中间件, 需要在 Auth 之后使用. 管理员的角色为其角色 ID, 角色权限保存在 RolePermission 中
+var Authorizer = func() *xx.Authorizer {
+	a := xx.NewAuthorizer(rolePolicy{})
+	a.SuperRoles = []string{SuperRole}
+	a.Roles = adminRoles
+	return a
+}()
+
+func adminRoles(p *xx.Principal) ([]string, error) {
+	id, err := strconv.Atoi(p.ID)
+	if err != nil {
+		return nil, nil
+	}
+	admin, err := admin_model.GetAdmin(id)
+	if err != nil {
+		return nil, err
+	}
+	if admin.Disabled {
+		return nil, nil
+	}
+	if admin.Super {
+		return []string{SuperRole}, nil
+	}
+	ids, err := admin_model.GetAdminRoleIDs(id)
+	if err != nil {
+		return nil, err
+	}
+	roles := make([]string, len(ids))
+	for i, id := range ids {
+		roles[i] = strconv.Itoa(id)
+	}
+	return roles, nil
+}
+
+type rolePolicy struct{}
+
+func (rolePolicy) Allowed(roles []string, perm xx.Permission) (bool, error) {
+	ids := make([]int, 0, len(roles))
+	for _, role := range roles {
+		if id, err := strconv.Atoi(role); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return false, nil
+	}
+	return admin_model.RolesAllowed(ids, string(perm), string(xx.AllPermissions))
+}
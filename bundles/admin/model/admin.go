@@ -8,6 +8,7 @@ import (
 	"errors"
 	"github.com/jinzhu/gorm"
 	"golang.org/x/crypto/bcrypt"
+	"time"
 )
 
 var (
@@ -15,13 +16,20 @@ var (
 	ErrUserNotRegistered  = errors.New("用户不存在")
 	ErrPasswordIncorrect  = errors.New("密码错误")
 	ErrUsernameIncorrect  = errors.New("用户名不存在")
+	ErrAdminDisabled      = errors.New("账号已禁用")
 )
 
 type Admin struct {
 	ID       int
 	Username string `gorm:"unique_index"`
-	Password string
+	Password string `json:"-"`
 	RoleID int `gorm:"index"`
+	ExternalID string `gorm:"index" desc:"关联的单点登录账号, 格式为 issuer#sub"`
+	ParentID int `gorm:"index" desc:"父账号ID, 顶级账号为 0"`
+	Forefather string `gorm:"index" desc:"所有祖先账号ID, 如: |1|5|, 顶级账号为 |"`
+	Super bool `desc:"超级管理员可访问所有接口及授予所有角色"`
+	Disabled bool `gorm:"index" desc:"禁用的账号不能登录, 账号及其数据仍然保留"`
+	CreatedAt time.Time
 }
 
 func CountAdmins() (total int, err error) {
@@ -31,7 +39,7 @@ func CountAdmins() (total int, err error) {
 
 func CreateAdmin(username, password string) error {
 	var a = &Admin{}
-	DB.Model(a).Where("username=?", a.Username).Select("id").First(a)
+	DB.Model(a).Where("username=?", username).Select("id").First(a)
 	if a.ID > 0 {
 		return ErrUsernameRegistered
 	}
@@ -42,6 +50,7 @@ func CreateAdmin(username, password string) error {
 	return DB.Create(&Admin{
 		Username: username,
 		Password: password,
+		Forefather: "|",
 	}).Error
 }
 
@@ -80,11 +89,14 @@ func SignIn(username, password string) (admin *Admin, err error) {
 	err = bcrypt.CompareHashAndPassword([]byte(admin.Password), []byte(password))
 	if err != nil {
 		if err == bcrypt.ErrMismatchedHashAndPassword {
-			return 0, ErrPasswordIncorrect
+			return nil, ErrPasswordIncorrect
 		} else {
-			return 0, err
+			return nil, err
 		}
 	}
+	if admin.Disabled {
+		return nil, ErrAdminDisabled
+	}
 	return admin, nil
 }
 
@@ -97,3 +109,49 @@ func GetAdmin(id int) (*Admin, error) {
 		return admin, nil
 	}
 }
+
+// AdminFilter 为管理员列表查询条件, 零值表示不限
+type AdminFilter struct {
+	Username   string // 用户名包含的字符
+	Disabled   *bool
+	AncestorID int // 只查询该账号的后代账号
+}
+
+func (f *AdminFilter) where(db *gorm.DB) *gorm.DB {
+	if f.Username != "" {
+		db = db.Where("username LIKE ?", "%"+f.Username+"%")
+	}
+	if f.Disabled != nil {
+		db = db.Where("disabled=?", *f.Disabled)
+	}
+	if f.AncestorID > 0 {
+		db = db.Where("forefather LIKE ?", descendantPattern(f.AncestorID))
+	}
+	return db
+}
+
+// 统计符合条件的管理员数量
+func CountAdminList(filter *AdminFilter) (total int, err error) {
+	err = filter.where(DB.Model(&Admin{})).Count(&total).Error
+	return
+}
+
+// 获得管理员列表, order 由 sql.InitOrder 生成
+func GetAdminList(filter *AdminFilter, order string, limit, offset int) (admins []*Admin, err error) {
+	err = filter.where(DB).Order(order).Limit(limit).Offset(offset).Find(&admins).Error
+	return
+}
+
+// 禁用或启用管理员
+func SetAdminDisabled(id int, disabled bool) error {
+	return DB.Model(&Admin{}).Where("id=?", id).UpdateColumn("disabled", disabled).Error
+}
+
+// 重置管理员密码
+func ResetPassword(id int, password string) error {
+	password, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+	return DB.Model(&Admin{}).Where("id=?", id).UpdateColumn("password", password).Error
+}
@@ -4,21 +4,31 @@
 
 package admin_model
 
+import "github.com/jinzhu/gorm"
+
 type AdminRole struct {
 	ID int
 	AdminID int `gorm:"unique_index:admin_role_idx"`
 	RoleID int `gorm:"unique_index:admin_role_idx"`
 }
 
-// 统计管理员角色数量
-func CountAdminRoles(adminID, roleID int) (total int, err error) {
+// ancestorID 大于 0 时只查询其后代账号的角色
+func adminRolesModel(ancestorID, adminID, roleID int) *gorm.DB {
 	model := DB.Model(&AdminRole{})
+	if ancestorID > 0 {
+		model = model.Where("admin_id in (?)", DB.Model(&Admin{}).Where("forefather LIKE ?", descendantPattern(ancestorID)).Select("id").QueryExpr())
+	}
 	if adminID > 0 {
 		model = model.Where("admin_id=?", adminID)
 	} else if roleID > 0 {
 		model = model.Where("role_id=?", roleID)
 	}
-	err = model.Count(&total).Error
+	return model
+}
+
+// 统计管理员角色数量
+func CountAdminRoles(ancestorID, adminID, roleID int) (total int, err error) {
+	err = adminRolesModel(ancestorID, adminID, roleID).Count(&total).Error
 	return
 }
 
@@ -30,13 +40,8 @@ type AdminRoleWithName struct {
 	RoleName string
 }
 
-func GetAdminRoleWithNames(adminID, roleID, limit, offset int) (roles []*AdminRoleWithName) {
-	model := DB.Model(&AdminRole{})
-	if adminID > 0 {
-		model = model.Where("admin_id=?", adminID)
-	} else if roleID > 0 {
-		model = model.Where("role_id=?", roleID)
-	}
+func GetAdminRoleWithNames(ancestorID, adminID, roleID, limit, offset int) (roles []*AdminRoleWithName) {
+	model := adminRolesModel(ancestorID, adminID, roleID)
 	var ars []*AdminRole
 	model.Order("id").Limit(limit).Offset(offset).Find(&ars)
 	if ln := len(ars); ln > 0 {
@@ -64,8 +69,12 @@ func GetAdminRoleWithNames(adminID, roleID, limit, offset int) (roles []*AdminRo
 				ID: ar.ID,
 				AdminID: ar.AdminID,
 				RoleID: ar.RoleID,
-				AdminName: asm[ar.AdminID].Username,
-				RoleName: rsm[ar.RoleID].Name,
+			}
+			if a, ok := asm[ar.AdminID]; ok {
+				roles[key].AdminName = a.Username
+			}
+			if r, ok := rsm[ar.RoleID]; ok {
+				roles[key].RoleName = r.Name
 			}
 		}
 	}
@@ -79,9 +88,14 @@ func GetAdminRoles(adminID, limit, offset int) (roles []*Role, err error) {
 	return
 }
 
-// 设置管理员角色
+// 设置管理员角色, 管理员已拥有该角色时不做任何操作
 func SetAdminRole(adminID, roleID int) error {
-	return DB.Create(&AdminRole{AdminID:adminID, RoleID:roleID}).Error
+	return DB.Where(AdminRole{AdminID: adminID, RoleID: roleID}).FirstOrCreate(&AdminRole{}).Error
+}
+
+// 移除管理员的指定角色
+func RemoveAdminRoles(adminID int, roleIDs []int) error {
+	return DB.Where("admin_id=? AND role_id in (?)", adminID, roleIDs).Delete(&AdminRole{}).Error
 }
 
 // 移除管理员角色
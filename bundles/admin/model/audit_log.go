@@ -0,0 +1,82 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package admin_model
+
+import (
+	"github.com/jinzhu/gorm"
+	"time"
+)
+
+// AuditLog 为管理员操作审计日志, 只能添加及按保留期限清理, 不提供修改及删除单条日志的方法
+type AuditLog struct {
+	ID        int
+	AdminID   int       `gorm:"index" desc:"操作的管理员ID, 未登录时为 0"`
+	Username  string    `desc:"操作时的管理员用户名"`
+	Method    string    `gorm:"index:audit_log_route_idx" desc:"请求方法"`
+	Route     string    `gorm:"index:audit_log_route_idx" desc:"注册的路由, 如: /sub-admins"`
+	Params    string    `gorm:"type:text" desc:"请求参数 JSON, 敏感参数的值已隐藏"`
+	Status    int       `desc:"响应状态码"`
+	IP        string    `desc:"客户端 IP"`
+	CreatedAt time.Time `gorm:"index"`
+}
+
+// AuditLogFilter 为审计日志查询条件, 零值表示不限
+type AuditLogFilter struct {
+	AdminID int
+	Method  string
+	Route   string
+	Status  int
+	IP      string
+	Start   *time.Time
+	End     *time.Time
+}
+
+func (f *AuditLogFilter) where(db *gorm.DB) *gorm.DB {
+	if f.AdminID > 0 {
+		db = db.Where("admin_id=?", f.AdminID)
+	}
+	if f.Method != "" {
+		db = db.Where("method=?", f.Method)
+	}
+	if f.Route != "" {
+		db = db.Where("route=?", f.Route)
+	}
+	if f.Status > 0 {
+		db = db.Where("status=?", f.Status)
+	}
+	if f.IP != "" {
+		db = db.Where("ip=?", f.IP)
+	}
+	if f.Start != nil {
+		db = db.Where("created_at>=?", *f.Start)
+	}
+	if f.End != nil {
+		db = db.Where("created_at<?", *f.End)
+	}
+	return db
+}
+
+// 添加审计日志
+func AddAuditLog(log *AuditLog) error {
+	return DB.Create(log).Error
+}
+
+// 统计审计日志数量
+func CountAuditLogs(filter *AuditLogFilter) (total int, err error) {
+	err = filter.where(DB.Model(&AuditLog{})).Count(&total).Error
+	return
+}
+
+// 获得审计日志列表, 按时间倒序排列
+func GetAuditLogs(filter *AuditLogFilter, limit, offset int) (logs []*AuditLog, err error) {
+	err = filter.where(DB).Order("id desc").Limit(limit).Offset(offset).Find(&logs).Error
+	return
+}
+
+// 清理 before 之前的审计日志, 返回清理的数量
+func PruneAuditLogs(before time.Time) (int64, error) {
+	res := DB.Where("created_at<?", before).Delete(&AuditLog{})
+	return res.RowsAffected, res.Error
+}
@@ -0,0 +1,216 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package admin_model
+
+/**
+管理员账号树以物化路径保存:
+1.Forefather 为所有祖先账号ID, 由 | 分隔且首尾都有 |, 如: 1 的子账号 5 的子账号为 |1|5|
+2.顶级账号的 Forefather 为 |, ParentID 为 0
+3.查询后代账号: forefather LIKE '%|ID|%'
+**/
+
+import (
+	"errors"
+	"github.com/jinzhu/gorm"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrNotSubAdmin      = errors.New("只能管理自己的后代账号")
+	ErrAdminHasSubs     = errors.New("不可删除包含子账号的账号")
+	ErrMoveIntoSubtree  = errors.New("不能移动到自身或后代账号下")
+	ErrNeedSuperAdmin   = errors.New("需要超级管理员才能操作")
+	ErrRoleNotGrantable = errors.New("只能授予自己拥有的角色")
+)
+
+func forefatherOf(parent *Admin) string {
+	return parent.Forefather + strconv.Itoa(parent.ID) + "|"
+}
+
+func descendantPattern(ancestorID int) string {
+	return "%|" + strconv.Itoa(ancestorID) + "|%"
+}
+
+// 判断 adminID 是否为 ancestorID 的后代账号
+func IsSubAdmin(ancestorID, adminID int) (bool, error) {
+	var total int
+	err := DB.Model(&Admin{}).Where("id=? AND forefather LIKE ?", adminID, descendantPattern(ancestorID)).Count(&total).Error
+	return total > 0, err
+}
+
+// 获得 ancestorID 的后代账号, 不存在或不是后代账号时返回 ErrNotSubAdmin
+func GetSubAdmin(ancestorID, adminID int) (*Admin, error) {
+	admin := &Admin{}
+	err := DB.Where("id=? AND forefather LIKE ?", adminID, descendantPattern(ancestorID)).First(admin).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrNotSubAdmin
+		}
+		return nil, err
+	}
+	return admin, nil
+}
+
+// 创建子账号, parent 为 nil 时创建顶级账号
+func CreateSubAdmin(parent *Admin, username, password string, super bool) (*Admin, error) {
+	var exist = &Admin{}
+	DB.Model(exist).Where("username=?", username).Select("id").First(exist)
+	if exist.ID > 0 {
+		return nil, ErrUsernameRegistered
+	}
+	password, err := HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+	admin := &Admin {
+		Username:   username,
+		Password:   password,
+		Forefather: "|",
+		Super:      super,
+	}
+	if parent != nil {
+		admin.ParentID = parent.ID
+		admin.Forefather = forefatherOf(parent)
+	}
+	err = DB.Create(admin).Error
+	if err != nil {
+		return nil, err
+	}
+	return admin, nil
+}
+
+// AdminNode 为账号树中的节点
+type AdminNode struct {
+	*Admin
+	Subs []*AdminNode `json:"subs"`
+}
+
+// 获得 ancestorID 的所有后代账号, 以树形结构返回
+func GetSubAdminTree(ancestorID int) ([]*AdminNode, error) {
+	var admins []*Admin
+	err := DB.Where("forefather LIKE ?", descendantPattern(ancestorID)).Order("id").Find(&admins).Error
+	if err != nil {
+		return nil, err
+	}
+	nodes := make(map[int]*AdminNode, len(admins))
+	for _, a := range admins {
+		nodes[a.ID] = &AdminNode{Admin: a, Subs: []*AdminNode{}}
+	}
+	var roots []*AdminNode
+	for _, a := range admins {
+		node := nodes[a.ID]
+		if a.ParentID == ancestorID {
+			roots = append(roots, node)
+		} else if parent, ok := nodes[a.ParentID]; ok {
+			parent.Subs = append(parent.Subs, node)
+		}
+	}
+	return roots, nil
+}
+
+// 将账号及其所有后代账号移动到新的父账号下, 新的父账号不能是自身或后代账号
+func MoveAdmin(admin, parent *Admin) error {
+	if parent.ID == admin.ID || strings.Contains(parent.Forefather, "|"+strconv.Itoa(admin.ID)+"|") {
+		return ErrMoveIntoSubtree
+	}
+	oldPrefix := forefatherOf(admin)
+	newForefather := forefatherOf(parent)
+	newPrefix := newForefather + strconv.Itoa(admin.ID) + "|"
+	return DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(admin).Updates(map[string]interface{}{"parent_id": parent.ID, "forefather": newForefather}).Error
+		if err != nil {
+			return err
+		}
+		var subs []*Admin
+		err = tx.Where("forefather LIKE ?", oldPrefix+"%").Select("id, forefather").Find(&subs).Error
+		if err != nil {
+			return err
+		}
+		for _, sub := range subs {
+			err = tx.Model(sub).UpdateColumn("forefather", newPrefix+strings.TrimPrefix(sub.Forefather, oldPrefix)).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// 删除没有子账号的账号, 同时删除其角色及两步验证设置
+func DeleteAdmin(adminID int) error {
+	var total int
+	err := DB.Model(&Admin{}).Where("parent_id=?", adminID).Count(&total).Error
+	if err != nil {
+		return err
+	}
+	if total > 0 {
+		return ErrAdminHasSubs
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		for _, model := range []interface{}{&AdminRole{}, &AdminTwoFactor{}, &AdminRecoveryCode{}} {
+			err := tx.Where("admin_id=?", adminID).Delete(model).Error
+			if err != nil {
+				return err
+			}
+		}
+		return tx.Where("id=?", adminID).Delete(&Admin{}).Error
+	})
+}
+
+// 获得管理员可授予的角色, 超级管理员可授予所有角色, 其他管理员只能授予自己拥有的角色
+func GetGrantableRoles(granter *Admin) (roles []*Role, err error) {
+	if granter.Super {
+		err = DB.Order("id").Find(&roles).Error
+		return
+	}
+	expr := DB.Model(&AdminRole{}).Where("admin_id=?", granter.ID).Select("role_id").QueryExpr()
+	err = DB.Where("id in (?)", expr).Order("id").Find(&roles).Error
+	return
+}
+
+// 检查角色是否都可由管理员授予, 否则返回 ErrRoleNotGrantable
+func CheckGrantableRoles(granter *Admin, roleIDs []int) error {
+	roles, err := GetGrantableRoles(granter)
+	if err != nil {
+		return err
+	}
+	grantable := make(map[int]bool, len(roles))
+	for _, r := range roles {
+		grantable[r.ID] = true
+	}
+	for _, id := range roleIDs {
+		if !grantable[id] {
+			return ErrRoleNotGrantable
+		}
+	}
+	return nil
+}
+
+// 以 roleIDs 替换管理员的全部角色
+func ReplaceAdminRoles(adminID int, roleIDs []int) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("admin_id=?", adminID).Delete(&AdminRole{}).Error
+		if err != nil {
+			return err
+		}
+		for _, id := range roleIDs {
+			err = tx.Create(&AdminRole{AdminID: adminID, RoleID: id}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// 将初始管理员设为超级管理员, 并补全旧数据中为空的 Forefather
+func InitSuperAdmin(username string) error {
+	err := DB.Model(&Admin{}).Where("forefather=?", "").UpdateColumn("forefather", "|").Error
+	if err != nil {
+		return err
+	}
+	return DB.Model(&Admin{}).Where("username=?", username).UpdateColumn("super", true).Error
+}
@@ -0,0 +1,168 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package admin_model
+
+import (
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"strconv"
+	"testing"
+)
+
+// 使用内存 sqlite 数据库替换 DB, 返回的函数用于关闭数据库并恢复 DB
+func openTestDB(t *testing.T) func() {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 每个连接都是独立的内存数据库
+	db.DB().SetMaxOpenConns(1)
+	err = db.AutoMigrate(&Admin{}, &Role{}, &AdminRole{}, &AdminTwoFactor{}, &AdminRecoveryCode{}).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := DB
+	DB = db
+	return func() {
+		DB = old
+		db.Close()
+	}
+}
+
+func mustCreateSubAdmin(t *testing.T, parent *Admin, username string) *Admin {
+	admin, err := CreateSubAdmin(parent, username, "password", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return admin
+}
+
+func mustGetAdmin(t *testing.T, id int) *Admin {
+	admin := &Admin{}
+	err := DB.Where("id=?", id).First(admin).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	return admin
+}
+
+func TestMoveAdmin(t *testing.T) {
+	defer openTestDB(t)()
+	root := mustCreateSubAdmin(t, nil, "root")
+	a := mustCreateSubAdmin(t, root, "a")
+	b := mustCreateSubAdmin(t, a, "b")
+	c := mustCreateSubAdmin(t, b, "c")
+	other := mustCreateSubAdmin(t, root, "other")
+	id := func(a *Admin) string { return strconv.Itoa(a.ID) }
+	if c.Forefather != "|"+id(root)+"|"+id(a)+"|"+id(b)+"|" {
+		t.Fatalf("got forefather: %s", c.Forefather)
+	}
+
+	err := MoveAdmin(mustGetAdmin(t, a.ID), mustGetAdmin(t, other.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	prefix := "|" + id(root) + "|" + id(other) + "|"
+	needs := map[int]struct {
+		parentID   int
+		forefather string
+	} {
+		a.ID:     {other.ID, prefix},
+		b.ID:     {a.ID, prefix + id(a) + "|"},
+		c.ID:     {b.ID, prefix + id(a) + "|" + id(b) + "|"},
+		other.ID: {root.ID, "|" + id(root) + "|"},
+	}
+	for adminID, need := range needs {
+		got := mustGetAdmin(t, adminID)
+		if got.ParentID != need.parentID || got.Forefather != need.forefather {
+			t.Errorf("admin %d need %d %s, got %d %s", adminID, need.parentID, need.forefather, got.ParentID, got.Forefather)
+		}
+	}
+	if ok, err := IsSubAdmin(other.ID, c.ID); err != nil || !ok {
+		t.Errorf("need c under other, got: %v, %v", ok, err)
+	}
+
+	// 不能移动到自身或后代账号下
+	for _, parent := range []*Admin{a, b, c} {
+		err = MoveAdmin(mustGetAdmin(t, a.ID), mustGetAdmin(t, parent.ID))
+		if err != ErrMoveIntoSubtree {
+			t.Errorf("move a under %s need ErrMoveIntoSubtree, got: %v", parent.Username, err)
+		}
+	}
+}
+
+func TestDeleteAdmin(t *testing.T) {
+	defer openTestDB(t)()
+	root := mustCreateSubAdmin(t, nil, "root")
+	a := mustCreateSubAdmin(t, root, "a")
+	b := mustCreateSubAdmin(t, a, "b")
+
+	if err := DeleteAdmin(a.ID); err != ErrAdminHasSubs {
+		t.Fatalf("need ErrAdminHasSubs, got: %v", err)
+	}
+	err := SetAdminRole(b.ID, 1)
+	if err == nil {
+		err = DB.Create(&AdminTwoFactor{AdminID: b.ID, Secret: "secret"}).Error
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = DeleteAdmin(b.ID); err != nil {
+		t.Fatal(err)
+	}
+	conditions := []struct {
+		model  interface{}
+		column string
+	} {
+		{&Admin{}, "id"},
+		{&AdminRole{}, "admin_id"},
+		{&AdminTwoFactor{}, "admin_id"},
+	}
+	for _, c := range conditions {
+		var total int
+		err = DB.Model(c.model).Where(c.column+"=?", b.ID).Count(&total).Error
+		if err != nil || total != 0 {
+			t.Errorf("need %T of b deleted, got: %d, %v", c.model, total, err)
+		}
+	}
+	if err = DeleteAdmin(a.ID); err != nil {
+		t.Errorf("need a deleted after b, got: %v", err)
+	}
+}
+
+func TestGetSubAdmin(t *testing.T) {
+	defer openTestDB(t)()
+	root := mustCreateSubAdmin(t, nil, "root")
+	a := mustCreateSubAdmin(t, root, "a")
+	b := mustCreateSubAdmin(t, a, "b")
+	other := mustCreateSubAdmin(t, root, "other")
+
+	if got, err := GetSubAdmin(root.ID, b.ID); err != nil || got.ID != b.ID {
+		t.Errorf("need b under root, got: %v, %v", got, err)
+	}
+	if got, err := GetSubAdmin(a.ID, b.ID); err != nil || got.ID != b.ID {
+		t.Errorf("need b under a, got: %v, %v", got, err)
+	}
+	cases := [][2]*Admin {
+		{a, other},
+		{other, b},
+		{b, a},
+		{a, a},
+		{a, root},
+	}
+	for _, c := range cases {
+		if _, err := GetSubAdmin(c[0].ID, c[1].ID); err != ErrNotSubAdmin {
+			t.Errorf("%s under %s need ErrNotSubAdmin, got: %v", c[1].Username, c[0].Username, err)
+		}
+	}
+
+	tree, err := GetSubAdminTree(root.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tree) != 2 || tree[0].ID != a.ID || len(tree[0].Subs) != 1 || tree[0].Subs[0].ID != b.ID || tree[1].ID != other.ID {
+		t.Errorf("got tree: %+v", tree)
+	}
+}
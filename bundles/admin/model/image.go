@@ -6,6 +6,8 @@ package admin_model
 
 import (
 	"errors"
+	"fmt"
+	"github.com/orivil/morgine/bundles/utils/imaging"
 	"github.com/orivil/morgine/bundles/utils/sql"
 	"os"
 	"time"
@@ -91,6 +93,40 @@ type Label struct {
 	SizeKB int `desc:"图片大小/KB"`
 }
 
+// Conditions 获得标签图片的验证条件, 以文件内容检测图片类型, 宽高不能小于标签尺寸, 避免 Pipeline 放大图片.
+// 较大的图片及文件大小由 Pipeline 裁剪压缩, 不作限制, 如:
+//
+//	err := param.ValidFile("image", ctx.MultipartForm(), label.Conditions())
+func (l *Label) Conditions() string {
+	cdt := `sniff:"image"`
+	if l.Width > 0 {
+		cdt += fmt.Sprintf(` min-width:"%d"`, l.Width)
+	}
+	if l.Height > 0 {
+		cdt += fmt.Sprintf(` min-height:"%d"`, l.Height)
+	}
+	if l.Width > 0 || l.Height > 0 {
+		cdt += fmt.Sprintf(` image-msg:"图片尺寸不能小于 %dx%d"`, l.Width, l.Height)
+	}
+	return cdt
+}
+
+// 标签缩略图宽度
+var LabelThumbWidth = 200
+
+// Pipeline 获得标签图片的处理管道, 主图裁剪到标签尺寸并压缩到 SizeKB 以内, 同时生成 thumb 缩略图, 如:
+//
+//	results, err := label.Pipeline().Store(store, name, file)
+func (l *Label) Pipeline() *imaging.Pipeline {
+	return &imaging.Pipeline {
+		Format: imaging.JPEG,
+		Variants: []*imaging.Variant {
+			{Width: l.Width, Height: l.Height, Fit: imaging.FitCover, MaxKB: l.SizeKB},
+			{Name: "thumb", Width: LabelThumbWidth, Fit: imaging.FitContain},
+		},
+	}
+}
+
 func GetLabel(id int) *Label {
 	l := &Label{}
 	DB.Model(&Label{ID: id}).Where("id=?", id).First(l)
@@ -0,0 +1,54 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package admin_model
+
+import (
+	"bytes"
+	"github.com/orivil/morgine/param"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"testing"
+)
+
+func imageForm(t *testing.T, width, height int) *multipart.Form {
+	img := &bytes.Buffer{}
+	err := png.Encode(img, image.NewRGBA(image.Rect(0, 0, width, height)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	fw, _ := w.CreateFormFile("image", "a.png")
+	fw.Write(img.Bytes())
+	w.Close()
+	form, err := multipart.NewReader(body, w.Boundary()).ReadForm(1 << 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return form
+}
+
+func TestLabel_Conditions(t *testing.T) {
+	label := &Label{Width: 300, Height: 200}
+	cases := []struct {
+		width, height int
+		valid         bool
+	}{
+		{300, 200, true},
+		{600, 300, true},
+		{299, 200, false},
+		{300, 100, false},
+	}
+	for _, c := range cases {
+		err := param.ValidFile("image", imageForm(t, c.width, c.height), label.Conditions())
+		if (err == nil) != c.valid {
+			t.Errorf("%dx%d: need valid %v, got: %v", c.width, c.height, c.valid, err)
+		}
+	}
+	if err := param.ValidFile("image", imageForm(t, 10, 10), (&Label{}).Conditions()); err != nil {
+		t.Errorf("need no size limit without label size, got: %v", err)
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package admin_model
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"github.com/jinzhu/gorm"
+)
+
+// ExternalID 获得单点登录账号标识, 同一身份提供方的 sub 唯一且不变
+func ExternalID(issuer, subject string) string {
+	return issuer + "#" + subject
+}
+
+// 根据单点登录账号标识获得管理员, 不存在时返回 ErrUserNotRegistered
+func GetAdminByExternalID(externalID string) (*Admin, error) {
+	admin := &Admin{}
+	err := DB.Where("external_id=?", externalID).First(admin).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrUserNotRegistered
+		}
+		return nil, err
+	}
+	return admin, nil
+}
+
+// 将单点登录账号关联到未关联过的同名管理员, 不存在时返回 ErrUserNotRegistered
+func LinkAdmin(username, externalID string) (*Admin, error) {
+	admin := &Admin{}
+	err := DB.Where("username=? AND external_id=?", username, "").First(admin).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrUserNotRegistered
+		}
+		return nil, err
+	}
+	err = DB.Model(admin).UpdateColumn("external_id", externalID).Error
+	if err != nil {
+		return nil, err
+	}
+	return admin, nil
+}
+
+// 为单点登录账号创建管理员, 密码为随机值, 只能通过单点登录
+func ProvisionAdmin(username, externalID string) (*Admin, error) {
+	var exist = &Admin{}
+	DB.Model(exist).Where("username=?", username).Select("id").First(exist)
+	if exist.ID > 0 {
+		return nil, ErrUsernameRegistered
+	}
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return nil, err
+	}
+	password, err := HashPassword(base64.RawURLEncoding.EncodeToString(b))
+	if err != nil {
+		return nil, err
+	}
+	admin := &Admin {
+		Username:   username,
+		Password:   password,
+		ExternalID: externalID,
+		Forefather: "|",
+	}
+	err = DB.Create(admin).Error
+	if err != nil {
+		return nil, err
+	}
+	return admin, nil
+}
+
+// 按角色名称同步管理员角色, 授予名称在 roleNames 中的角色, 并移除其他角色. 不存在的角色名称将被忽略
+func SyncAdminRoles(adminID int, roleNames []string) error {
+	var roles []*Role
+	if len(roleNames) > 0 {
+		err := DB.Where("name in (?)", roleNames).Find(&roles).Error
+		if err != nil {
+			return err
+		}
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var roleIDs = make([]int, 0, len(roles))
+		for _, r := range roles {
+			roleIDs = append(roleIDs, r.ID)
+		}
+		remove := tx.Where("admin_id=?", adminID)
+		if len(roleIDs) > 0 {
+			remove = remove.Where("role_id not in (?)", roleIDs)
+		}
+		err := remove.Delete(&AdminRole{}).Error
+		if err != nil {
+			return err
+		}
+		for _, id := range roleIDs {
+			ar := &AdminRole{}
+			err = tx.Where(AdminRole{AdminID: adminID, RoleID: id}).FirstOrCreate(ar).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,90 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package admin_model
+
+import (
+	"errors"
+	"github.com/jinzhu/gorm"
+)
+
+var ErrPermissionNotGrantable = errors.New("只能授予自己拥有的接口权限")
+
+// 拥有所有接口的权限, 与 xx.AllPermissions 一致
+const allPermissions = "*"
+
+// 角色-接口权限对照表
+type RolePermission struct {
+	ID int
+	RoleID int `gorm:"unique_index:role_permission_idx"`
+	Permission string `gorm:"unique_index:role_permission_idx" desc:"接口权限, 如: GET /admins"`
+}
+
+// 获得管理员的角色 ID 列表
+func GetAdminRoleIDs(adminID int) (ids []int, err error) {
+	err = DB.Model(&AdminRole{}).Where("admin_id=?", adminID).Pluck("role_id", &ids).Error
+	return
+}
+
+// 获得角色的接口权限
+func GetRolePermissions(roleID int) (perms []string, err error) {
+	err = DB.Model(&RolePermission{}).Where("role_id=?", roleID).Order("permission").Pluck("permission", &perms).Error
+	return
+}
+
+// 设置角色的全部接口权限
+func SetRolePermissions(roleID int, perms []string) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("role_id=?", roleID).Delete(&RolePermission{}).Error
+		if err != nil {
+			return err
+		}
+		for _, perm := range perms {
+			err = tx.Create(&RolePermission{RoleID: roleID, Permission: perm}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// 检查接口权限是否都为管理员拥有的权限, 超级管理员拥有所有权限, 否则返回 ErrPermissionNotGrantable
+func CheckGrantablePermissions(granter *Admin, perms []string) error {
+	if granter.Super || len(perms) == 0 {
+		return nil
+	}
+	ids, err := GetAdminRoleIDs(granter.ID)
+	if err != nil || len(ids) == 0 {
+		if err == nil {
+			err = ErrPermissionNotGrantable
+		}
+		return err
+	}
+	var held []string
+	err = DB.Model(&RolePermission{}).Where("role_id in (?)", ids).Pluck("permission", &held).Error
+	if err != nil {
+		return err
+	}
+	set := make(map[string]bool, len(held))
+	for _, perm := range held {
+		set[perm] = true
+	}
+	if set[allPermissions] {
+		return nil
+	}
+	for _, perm := range perms {
+		if !set[perm] {
+			return ErrPermissionNotGrantable
+		}
+	}
+	return nil
+}
+
+// 判断角色中是否有任意一个拥有权限
+func RolesAllowed(roleIDs []int, perms ...string) (bool, error) {
+	var total int
+	err := DB.Model(&RolePermission{}).Where("role_id in (?) AND permission in (?)", roleIDs, perms).Count(&total).Error
+	return total > 0, err
+}
@@ -4,11 +4,22 @@
 
 package admin_model
 
+import (
+	"errors"
+	"github.com/jinzhu/gorm"
+)
+
+var (
+	ErrRoleNotFound = errors.New("角色不存在")
+	ErrRoleExists   = errors.New("该角色名称已存在")
+)
+
 type Role struct {
 	ID int
 	Name string `gorm:"unique_index"`
 	AuthIDs string `desc:"权限ID, 如: 1,2"`
 	AuthCa string `desc:"角色权限控制器-方法, 如: Goods-list,Goods-add"`
+	RequireTwoFactor bool `desc:"拥有该角色的管理员是否必须启用两步验证"`
 }
 
 func CountRoles() (total int, err error) {
@@ -16,24 +27,60 @@ func CountRoles() (total int, err error) {
 	return
 }
 
-func GetRoles(limit, offset int) (roles []*Role, err error) {
-	err = DB.Order("id").Limit(limit).Offset(offset).Find(&roles).Error
+// 获得角色列表, order 由 sql.InitOrder 生成
+func GetRoles(order string, limit, offset int) (roles []*Role, err error) {
+	err = DB.Order(order).Limit(limit).Offset(offset).Find(&roles).Error
 	return
 }
 
 func (r *Role) Create() error {
 	r.ID = 0
+	if roleNameExists(r.Name, 0) {
+		return ErrRoleExists
+	}
 	return DB.Create(r).Error
 }
 
+func roleNameExists(name string, exceptID int) bool {
+	var exist = &Role{}
+	DB.Model(exist).Where("name=? AND id<>?", name, exceptID).Select("id").First(exist)
+	return exist.ID > 0
+}
+
+// 更新角色名称及两步验证要求, 零值同样会被保存
 func (r *Role) Update() error {
-	return DB.Model(r).Updates(r).Error
+	if roleNameExists(r.Name, r.ID) {
+		return ErrRoleExists
+	}
+	return DB.Model(r).Updates(map[string]interface{}{"name": r.Name, "require_two_factor": r.RequireTwoFactor}).Error
 }
 
 func (r *Role) Delete() error {
-	return DB.Delete(r).Error
+	return DeleteRoles([]int{r.ID})
 }
 
+// 删除角色, 同时移除管理员的这些角色及角色的接口权限
 func DeleteRoles(ids []int) error {
-	return DB.Where("id in (?)", ids).Delete(&Role{}).Error
+	return DB.Transaction(func(tx *gorm.DB) error {
+		for _, model := range []interface{}{&AdminRole{}, &RolePermission{}} {
+			err := tx.Where("role_id in (?)", ids).Delete(model).Error
+			if err != nil {
+				return err
+			}
+		}
+		return tx.Where("id in (?)", ids).Delete(&Role{}).Error
+	})
+}
+
+// 获得角色, 不存在时返回 ErrRoleNotFound
+func GetRole(id int) (*Role, error) {
+	role := &Role{}
+	err := DB.Where("id=?", id).First(role).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrRoleNotFound
+		}
+		return nil, err
+	}
+	return role, nil
 }
\ No newline at end of file
@@ -0,0 +1,193 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package admin_model
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"github.com/jinzhu/gorm"
+	"strings"
+	"time"
+)
+
+var (
+	ErrTwoFactorNotSet  = errors.New("未设置两步验证")
+	ErrTwoFactorEnabled = errors.New("已启用两步验证")
+)
+
+// 两步验证事件
+const (
+	TwoFactorEnroll          = "enroll"
+	TwoFactorEnable          = "enable"
+	TwoFactorDisable         = "disable"
+	TwoFactorVerifySuccess   = "verify_success"
+	TwoFactorVerifyFailed    = "verify_failed"
+	TwoFactorRecoveryUsed    = "recovery_used"
+	TwoFactorRecoveryRenewed = "recovery_renewed"
+)
+
+// 管理员 TOTP 两步验证设置
+type AdminTwoFactor struct {
+	ID int
+	AdminID int `gorm:"unique_index"`
+	Secret string `desc:"加密后的 TOTP 密钥"`
+	Enabled bool `desc:"验证过一次密码后启用"`
+	LastCounter int64 `desc:"最后使用的密码时间窗口序号, 防止密码重放"`
+	CreatedAt time.Time
+	EnabledAt *time.Time
+}
+
+// 两步验证恢复码, 只保存哈希值
+type AdminRecoveryCode struct {
+	ID int
+	AdminID int `gorm:"index"`
+	Hash string `gorm:"index"`
+	UsedAt *time.Time
+}
+
+// 两步验证事件记录, 只增不改
+type AdminTwoFactorEvent struct {
+	ID int
+	AdminID int `gorm:"index"`
+	Event string
+	IP string
+	UserAgent string
+	CreatedAt time.Time
+}
+
+// 获得管理员两步验证设置, 未设置时返回 ErrTwoFactorNotSet
+func GetTwoFactor(adminID int) (*AdminTwoFactor, error) {
+	tf := &AdminTwoFactor{}
+	err := DB.Where("admin_id=?", adminID).First(tf).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrTwoFactorNotSet
+		}
+		return nil, err
+	}
+	return tf, nil
+}
+
+// 保存待启用的 TOTP 密钥, 覆盖未启用的密钥, 已启用时返回 ErrTwoFactorEnabled
+func SaveTwoFactorSecret(adminID int, secret string) error {
+	tf, err := GetTwoFactor(adminID)
+	switch err {
+	case nil:
+		if tf.Enabled {
+			return ErrTwoFactorEnabled
+		}
+		return DB.Model(tf).UpdateColumn("secret", secret).Error
+	case ErrTwoFactorNotSet:
+		return DB.Create(&AdminTwoFactor{AdminID: adminID, Secret: secret}).Error
+	default:
+		return err
+	}
+}
+
+// 启用两步验证, counter 为启用时验证的密码时间窗口序号
+func EnableTwoFactor(adminID int, counter int64) error {
+	now := time.Now()
+	return DB.Model(&AdminTwoFactor{}).Where("admin_id=?", adminID).Updates(map[string]interface{} {
+		"enabled":      true,
+		"last_counter": counter,
+		"enabled_at":   &now,
+	}).Error
+}
+
+// 使用密码时间窗口序号, 序号不大于最后使用的序号时返回 false. 以条件更新保证并发请求中同一密码只能使用一次
+func UseTwoFactorCounter(adminID int, counter int64) (bool, error) {
+	db := DB.Model(&AdminTwoFactor{}).Where("admin_id=? AND last_counter<?", adminID, counter).UpdateColumn("last_counter", counter)
+	return db.RowsAffected == 1, db.Error
+}
+
+// 关闭两步验证, 同时删除恢复码
+func DisableTwoFactor(adminID int) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("admin_id=?", adminID).Delete(&AdminTwoFactor{}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("admin_id=?", adminID).Delete(&AdminRecoveryCode{}).Error
+	})
+}
+
+// 生成 n 个恢复码, 格式如: ABCDE-FGHIJ
+func GenerateRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, n)
+	b := make([]byte, 7)
+	for i := range codes {
+		_, err := rand.Read(b)
+		if err != nil {
+			return nil, err
+		}
+		code := base32.StdEncoding.EncodeToString(b)[:10]
+		codes[i] = code[:5] + "-" + code[5:]
+	}
+	return codes, nil
+}
+
+// 恢复码为 50 位随机值, 无需慢哈希即可抵御暴力破解
+func hashRecoveryCode(code string) string {
+	code = strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// 以新的恢复码替换管理员所有恢复码
+func ReplaceRecoveryCodes(adminID int, codes []string) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("admin_id=?", adminID).Delete(&AdminRecoveryCode{}).Error
+		if err != nil {
+			return err
+		}
+		for _, code := range codes {
+			err = tx.Create(&AdminRecoveryCode{AdminID: adminID, Hash: hashRecoveryCode(code)}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// 使用恢复码, 恢复码不存在或已使用时返回 false
+func UseRecoveryCode(adminID int, code string) (bool, error) {
+	now := time.Now()
+	db := DB.Model(&AdminRecoveryCode{}).Where("admin_id=? AND hash=? AND used_at IS NULL", adminID, hashRecoveryCode(code)).UpdateColumn("used_at", &now)
+	return db.RowsAffected == 1, db.Error
+}
+
+// 统计未使用的恢复码数量
+func CountRecoveryCodes(adminID int) (total int, err error) {
+	err = DB.Model(&AdminRecoveryCode{}).Where("admin_id=? AND used_at IS NULL", adminID).Count(&total).Error
+	return
+}
+
+// 判断管理员是否拥有要求两步验证的角色
+func AdminRequiresTwoFactor(adminID int) (bool, error) {
+	var total int
+	expr := DB.Model(&AdminRole{}).Where("admin_id=?", adminID).Select("role_id").QueryExpr()
+	err := DB.Model(&Role{}).Where("id in (?) AND require_two_factor=?", expr, true).Count(&total).Error
+	return total > 0, err
+}
+
+// 记录两步验证事件
+func AddTwoFactorEvent(adminID int, event, ip, userAgent string) error {
+	return DB.Create(&AdminTwoFactorEvent {
+		AdminID:   adminID,
+		Event:     event,
+		IP:        ip,
+		UserAgent: userAgent,
+	}).Error
+}
+
+// 获得管理员两步验证事件, 按时间倒序
+func GetTwoFactorEvents(adminID, limit, offset int) (events []*AdminTwoFactorEvent, err error) {
+	err = DB.Where("admin_id=?", adminID).Order("id desc").Limit(limit).Offset(offset).Find(&events).Error
+	return
+}
@@ -24,8 +24,52 @@ func registerRoutes() {
 }
 
 func handleAdmin(c *xx.Condition) {
-	auth := c.Use(admin_middleware.Auth)
+	auth := c.Use(admin_middleware.Auth, admin_middleware.Audit)
 	actions.Login("POST", "/login", c)
+	actions.TwoFactorLogin("POST", "/login/2fa", c)
+	actions.RefreshToken("POST", "/token/refresh", c)
+	actions.OIDCLogin("GET", "/oidc/login", c)
+	actions.OIDCCallback("POST", "/oidc/callback", c)
+	actions.Logout("POST", "/logout", auth)
+	actions.GetLoginSessions("GET", "/login-sessions", auth)
+	actions.LogoutAll("DELETE", "/login-sessions", auth)
 	actions.ChangePassword("PUT", "/password", auth)
 	actions.GetHashedPassword("GET", "/hashed-password", c)
+
+	optionalAuth := c.Use(admin_middleware.OptionalAuth, admin_middleware.Audit)
+	actions.EnrollTwoFactor("POST", "/2fa/enroll", optionalAuth)
+	actions.ActivateTwoFactor("POST", "/2fa/activate", optionalAuth)
+	actions.GetTwoFactorStatus("GET", "/2fa", auth)
+	actions.DisableTwoFactor("DELETE", "/2fa", auth)
+	actions.RenewRecoveryCodes("POST", "/2fa/recovery-codes", auth)
+	actions.GetTwoFactorEvents("GET", "/2fa/events", auth)
+
+	authorized := auth.Use(admin_middleware.Authorizer.Handler())
+	actions.UnlockLogin("DELETE", "/login-lock", authorized)
+	actions.GetLoginLockStats("GET", "/login-lock/stats", authorized)
+	actions.GetPermissions("GET", "/permissions", authorized)
+	actions.GetRolePermissions("GET", "/role-permissions", authorized)
+	actions.SetRolePermissions("PUT", "/role-permissions", authorized)
+	actions.GetSubAdmins("GET", "/sub-admins", authorized)
+	actions.CreateSubAdmin("POST", "/sub-admins", authorized)
+	actions.MoveSubAdmin("PUT", "/sub-admins/parent", authorized)
+	actions.SetSubAdminRoles("PUT", "/sub-admins/roles", authorized)
+	actions.DeleteSubAdmin("DELETE", "/sub-admins", authorized)
+	actions.GetGrantableRoles("GET", "/grantable-roles", authorized)
+	actions.GetAuditLogs("GET", "/audit-logs", authorized)
+	actions.UploadLabelImage("POST", "/label-images", authorized)
+
+	actions.GetAdmins("GET", "/admins", authorized)
+	actions.CreateAdmin("POST", "/admins", authorized)
+	actions.DisableAdmin("PUT", "/admins/disabled", authorized)
+	actions.ResetAdminPassword("PUT", "/admins/password", authorized)
+
+	actions.GetRoles("GET", "/roles", authorized)
+	actions.CreateRole("POST", "/roles", authorized)
+	actions.UpdateRole("PUT", "/roles", authorized)
+	actions.DeleteRoles("DELETE", "/roles", authorized)
+
+	actions.GetAdminRoles("GET", "/admin-roles", authorized)
+	actions.SetAdminRole("POST", "/admin-roles", authorized)
+	actions.DelAdminRoles("DELETE", "/admin-roles", authorized)
 }
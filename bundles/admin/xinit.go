@@ -5,11 +5,13 @@
 package admin
 
 import (
+	"github.com/orivil/morgine/bundles/admin/actions"
 	"github.com/orivil/morgine/bundles/admin/env"
 	admin_middleware "github.com/orivil/morgine/bundles/admin/middleware"
 	"github.com/orivil/morgine/bundles/admin/model"
 	"github.com/orivil/morgine/bundles/utils/api"
 	"github.com/orivil/morgine/bundles/utils/sql"
+	day_ticker "github.com/orivil/morgine/bundles/utils/ticker/day"
 	"github.com/orivil/morgine/cfg"
 )
 
@@ -44,6 +46,13 @@ func (b bundle) Init(configs cfg.Configs) {
 		admin_model.DB = api.AutoMigrate(admin_model.DB, &admin_model.Admin{}, "管理员账号数据模型")
 		admin_model.DB = api.AutoMigrate(admin_model.DB, &admin_model.Role{}, "角色数据模型")
 		admin_model.DB = api.AutoMigrate(admin_model.DB, &admin_model.AdminRole{}, "管理员-角色对照表")
+		admin_model.DB = api.AutoMigrate(admin_model.DB, &admin_model.RolePermission{}, "角色-接口权限对照表")
+		admin_model.DB = api.AutoMigrate(admin_model.DB, &admin_model.AdminTwoFactor{}, "管理员两步验证数据模型")
+		admin_model.DB = api.AutoMigrate(admin_model.DB, &admin_model.AdminRecoveryCode{}, "两步验证恢复码数据模型")
+		admin_model.DB = api.AutoMigrate(admin_model.DB, &admin_model.AdminTwoFactorEvent{}, "两步验证事件数据模型")
+		admin_model.DB = api.AutoMigrate(admin_model.DB, &admin_model.AuditLog{}, "审计日志数据模型")
+		admin_model.DB = api.AutoMigrate(admin_model.DB, &admin_model.Label{}, "图片标签数据模型")
+		admin_model.DB = api.AutoMigrate(admin_model.DB, &admin_model.Image{}, "标签图片数据模型")
 
 		// 创建初始账户
 		total, err := admin_model.CountAdmins()
@@ -56,15 +65,51 @@ func (b bundle) Init(configs cfg.Configs) {
 				panic(err)
 			}
 		}
+		// 初始账户为超级管理员
+		err = admin_model.InitSuperAdmin(env.Env.RootUser)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	{
+		// 初始化登录限制器
+		actions.InitLoginLimiter()
+	}
+
+	{
+		// 初始化两步验证
+		err := actions.InitTwoFactor()
+		if err != nil {
+			panic(err)
+		}
 	}
 
 	{
-		// 初始化角色权限控制器
-		err := admin_middleware.InitEnforcer(env.Env.AuthModelFile, admin_model.DB)
+		// 初始化单点登录
+		err := actions.InitOIDC()
 		if err != nil {
 			panic(err)
 		}
 	}
+
+	{
+		// 初始化图片存储
+		err := actions.InitImageStorage(env.Env.ImageDir, env.Env.ImageServeHost)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	{
+		// 初始化令牌管理器
+		admin_middleware.InitTokens()
+	}
+
+	{
+		// 每日清理过期审计日志
+		day_ticker.Runner.AddCallback(actions.PruneAuditLogs)
+	}
 }
 
 func (b bundle) AddRoute() {
@@ -0,0 +1,134 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package imaging
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"sync"
+)
+
+// Format 为输出图片格式
+type Format string
+
+const (
+	JPEG Format = "jpeg"
+	PNG  Format = "png"
+	WebP Format = "webp" // 默认为无损编码, 见 encodeWebP
+)
+
+// 文件后缀名
+func (f Format) Ext() string {
+	if f == JPEG {
+		return ".jpg"
+	}
+	return "." + string(f)
+}
+
+// Encoder 将图片编码为指定格式, quality 为 1-100 的质量参数, 无损格式可忽略
+type Encoder func(w io.Writer, img image.Image, quality int) error
+
+var (
+	encoders = map[Format]Encoder {
+		JPEG: encodeJPEG,
+		PNG:  encodePNG,
+		WebP: encodeWebP,
+	}
+	encodersMu sync.RWMutex
+)
+
+// RegisterEncoder 注册图片编码器, 用于支持标准库以外的输出格式, 如:
+//
+//	imaging.RegisterEncoder("bmp", func(w io.Writer, img image.Image, quality int) error {
+//		return bmp.Encode(w, img)
+//	})
+func RegisterEncoder(format Format, encoder Encoder) {
+	encodersMu.Lock()
+	defer encodersMu.Unlock()
+	encoders[format] = encoder
+}
+
+func getEncoder(format Format) (Encoder, error) {
+	encodersMu.RLock()
+	defer encodersMu.RUnlock()
+	encoder, ok := encoders[format]
+	if !ok {
+		return nil, fmt.Errorf("image format '%s' encoder is not registered", format)
+	}
+	return encoder, nil
+}
+
+// JPEG 不支持透明通道, 透明部分以白色填充
+func encodeJPEG(w io.Writer, img image.Image, quality int) error {
+	if !isOpaque(img) {
+		bg := image.NewRGBA(img.Bounds())
+		draw.Draw(bg, bg.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+		draw.Draw(bg, bg.Bounds(), img, img.Bounds().Min, draw.Over)
+		img = bg
+	}
+	return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+}
+
+func encodePNG(w io.Writer, img image.Image, quality int) error {
+	encoder := &png.Encoder{CompressionLevel: png.BestCompression}
+	return encoder.Encode(w, img)
+}
+
+func isOpaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return false
+}
+
+// 最低压缩质量, 为达到文件大小限制时不会低于此质量
+const minQuality = 30
+
+// 编码图片, maxBytes 大于 0 时降低质量直到文件大小不超过 maxBytes, 达到最低质量仍超出时返回最低质量的结果.
+// PNG 质量参数无效, 只编码一次. 先以最低质量编码, 仍超出时(包括质量参数无效的编码器, 如默认的无损 WebP)直接返回该结果
+func encode(img image.Image, format Format, quality int, maxBytes int64) ([]byte, error) {
+	encoder, err := getEncoder(format)
+	if err != nil {
+		return nil, err
+	}
+	encodeQuality := func(q int) ([]byte, error) {
+		buf := &bytes.Buffer{}
+		err := encoder(buf, img, q)
+		if err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	data, err := encodeQuality(quality)
+	if err != nil || maxBytes <= 0 || int64(len(data)) <= maxBytes || format == PNG || quality <= minQuality {
+		return data, err
+	}
+	best, err := encodeQuality(minQuality)
+	if err != nil || int64(len(best)) > maxBytes {
+		return best, err
+	}
+	// 二分查找满足大小限制的最高质量
+	low, high := minQuality+1, quality-1
+	for low <= high {
+		mid := (low + high) / 2
+		result, err := encodeQuality(mid)
+		if err != nil {
+			return nil, err
+		}
+		if int64(len(result)) <= maxBytes {
+			best = result
+			low = mid + 1
+		} else {
+			high = mid - 1
+		}
+	}
+	return best, nil
+}
@@ -0,0 +1,180 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+// imaging 为图片处理管道: 按 EXIF 方向校正图片, 缩放裁剪到指定尺寸, 重新编码以满足文件大小限制,
+// 并生成缩略图等多个版本. 图片重新编码后不再包含 EXIF 信息
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"github.com/orivil/morgine/bundles/utils/storage"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"io/ioutil"
+)
+
+// 默认压缩质量
+const DefaultQuality = 85
+
+// 解码图片的最大像素数, 为 0 时不限制. 解码前先读取图片头部的尺寸, 避免解码超大图片耗尽内存
+var MaxPixels = 40000000
+
+var ErrTooManyPixels = errors.New("image has too many pixels")
+
+// Variant 为图片的一个输出版本
+type Variant struct {
+	// 版本名称, 作为文件名后缀, 如: thumb 生成 name-thumb.jpg, 为空时使用原文件名
+	Name string
+
+	// 输出尺寸, 为 0 时按比例计算, 都为 0 时保持原尺寸
+	Width, Height int
+
+	Fit Fit
+
+	// 输出格式, 为空时使用 Pipeline.Format
+	Format Format
+
+	// 压缩质量(1-100), 为 0 时使用 DefaultQuality
+	Quality int
+
+	// 文件大小限制(KB), 为 0 时不限制
+	MaxKB int
+}
+
+// Pipeline 定义图片需要生成的所有版本
+type Pipeline struct {
+	// 默认输出格式, 为空时使用 JPEG
+	Format   Format
+	Variants []*Variant
+}
+
+// Output 为处理后的图片数据
+type Output struct {
+	Variant *Variant
+	Format  Format
+	Width   int
+	Height  int
+	Data    []byte
+}
+
+// Result 为已保存的图片信息
+type Result struct {
+	Name   string
+	Url    string
+	Width  int
+	Height int
+	Size   int64
+	Format Format
+}
+
+// Decode 解码图片并按照 EXIF 方向信息校正, 像素数超过 MaxPixels 时返回 ErrTooManyPixels
+func Decode(r io.Reader) (image.Image, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if MaxPixels > 0 && int64(config.Width)*int64(config.Height) > int64(MaxPixels) {
+		return nil, ErrTooManyPixels
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return orient(img, orientation(data)), nil
+}
+
+// Process 读取图片并生成所有版本
+func (p *Pipeline) Process(r io.Reader) ([]*Output, error) {
+	img, err := Decode(r)
+	if err != nil {
+		return nil, err
+	}
+	outputs := make([]*Output, 0, len(p.Variants))
+	for _, v := range p.Variants {
+		output, err := p.process(img, v)
+		if err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, output)
+	}
+	return outputs, nil
+}
+
+func (p *Pipeline) process(img image.Image, v *Variant) (*Output, error) {
+	format := v.Format
+	if format == "" {
+		format = p.Format
+	}
+	if format == "" {
+		format = JPEG
+	}
+	quality := v.Quality
+	if quality <= 0 {
+		quality = DefaultQuality
+	}
+	dst := Resize(img, v.Width, v.Height, v.Fit)
+	data, err := encode(dst, format, quality, int64(v.MaxKB)<<10)
+	if err != nil {
+		return nil, err
+	}
+	return &Output {
+		Variant: v,
+		Format:  format,
+		Width:   dst.Bounds().Dx(),
+		Height:  dst.Bounds().Dy(),
+		Data:    data,
+	}, nil
+}
+
+// FileName 获得版本的文件名, name 为不含后缀名的文件名
+func (o *Output) FileName(name string) string {
+	if o.Variant.Name != "" {
+		name += "-" + o.Variant.Name
+	}
+	return name + o.Format.Ext()
+}
+
+// Store 处理图片并将所有版本保存到存储中, name 为不含后缀名的文件名. 任一版本保存失败时删除已保存的版本
+func (p *Pipeline) Store(s storage.Storage, name string, r io.Reader) (results []*Result, err error) {
+	outputs, err := p.Process(r)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			for _, result := range results {
+				_ = s.Remove(result.Name)
+			}
+			results = nil
+		}
+	}()
+	for _, output := range outputs {
+		file := output.FileName(name)
+		err = s.Write(file, output.Data)
+		if err != nil {
+			return results, err
+		}
+		result := &Result {
+			Name:   file,
+			Width:  output.Width,
+			Height: output.Height,
+			Size:   int64(len(output.Data)),
+			Format: output.Format,
+		}
+		results = append(results, result)
+		result.Url, err = s.GetServeUrl(file)
+		if err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
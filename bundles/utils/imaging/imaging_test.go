@@ -0,0 +1,199 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"golang.org/x/image/webp"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math/rand"
+	"testing"
+)
+
+type memStorage struct {
+	files   map[string][]byte
+	failure string
+}
+
+func (m *memStorage) Write(name string, data []byte) error {
+	if name == m.failure {
+		return errors.New("write failed")
+	}
+	m.files[name] = data
+	return nil
+}
+
+func (m *memStorage) IsExist(name string) (bool, error) {
+	_, ok := m.files[name]
+	return ok, nil
+}
+
+func (m *memStorage) Read(name string) ([]byte, error) {
+	return m.files[name], nil
+}
+
+func (m *memStorage) Remove(name string) error {
+	delete(m.files, name)
+	return nil
+}
+
+func (m *memStorage) GetServeUrl(name string) (string, error) {
+	return "http://static/" + name, nil
+}
+
+// 生成带噪点的图片, 避免压缩后体积过小
+func newImage(width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	r := rand.New(rand.NewSource(1))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(r.Intn(256))
+	}
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 0xFF
+	}
+	return img
+}
+
+func TestResize(t *testing.T) {
+	img := newImage(400, 200)
+	cases := []struct {
+		width, height int
+		fit           Fit
+		w, h          int
+	}{
+		{100, 100, FitCover, 100, 100},
+		{100, 0, FitCover, 100, 50},
+		{0, 100, FitCover, 200, 100},
+		{100, 100, FitContain, 100, 50},
+		{800, 800, FitContain, 400, 200},
+		{800, 100, FitCover, 800, 100},
+		{0, 0, FitCover, 400, 200},
+	}
+	for i, c := range cases {
+		b := Resize(img, c.width, c.height, c.fit).Bounds()
+		if b.Dx() != c.w || b.Dy() != c.h {
+			t.Errorf("case %d: need %dx%d, got %dx%d", i, c.w, c.h, b.Dx(), b.Dy())
+		}
+	}
+}
+
+// 构造带有 EXIF 方向信息的 JPEG 图片
+func withOrientation(t *testing.T, img image.Image, value byte) []byte {
+	buf := &bytes.Buffer{}
+	if err := jpeg.Encode(buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	tiff := []byte{'M', 'M', 0, 42, 0, 0, 0, 8, 0, 1, 0x01, 0x12, 0, 3, 0, 0, 0, 1, 0, value, 0, 0, 0, 0, 0, 0}
+	app1 := append([]byte("Exif\x00\x00"), tiff...)
+	size := len(app1) + 2
+	data := append([]byte{0xFF, 0xD8, 0xFF, 0xE1, byte(size >> 8), byte(size)}, app1...)
+	return append(data, buf.Bytes()[2:]...)
+}
+
+func TestDecode_Orientation(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	// 左半部分为红色
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 20; x++ {
+			src.Set(x, y, color.RGBA{R: 0xFF, A: 0xFF})
+		}
+	}
+	data := withOrientation(t, src, 6)
+	if o := orientation(data); o != 6 {
+		t.Fatalf("need orientation 6, got %d", o)
+	}
+	img, err := Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := img.Bounds()
+	if b.Dx() != 20 || b.Dy() != 40 {
+		t.Fatalf("need 20x40, got %dx%d", b.Dx(), b.Dy())
+	}
+	// 顺时针旋转 90° 后红色在上半部分
+	if r, _, _, _ := img.At(10, 5).RGBA(); r < 0xC000 {
+		t.Error("need red on top")
+	}
+	if r, _, _, _ := img.At(10, 35).RGBA(); r > 0x4000 {
+		t.Error("need black on bottom")
+	}
+}
+
+func TestDecode_MaxPixels(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, image.NewGray(image.Rect(0, 0, 100, 100))); err != nil {
+		t.Fatal(err)
+	}
+	defer func(max int) { MaxPixels = max }(MaxPixels)
+	MaxPixels = 100 * 100
+	if _, err := Decode(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	MaxPixels = 100*100 - 1
+	if _, err := Decode(bytes.NewReader(buf.Bytes())); err != ErrTooManyPixels {
+		t.Errorf("need ErrTooManyPixels, got: %v", err)
+	}
+}
+
+func TestPipeline_Store(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, newImage(600, 400)); err != nil {
+		t.Fatal(err)
+	}
+	p := &Pipeline {
+		Variants: []*Variant {
+			{Width: 300, Height: 300, MaxKB: 40},
+			{Name: "thumb", Width: 100, Fit: FitContain, Format: PNG},
+		},
+	}
+	s := &memStorage{files: make(map[string][]byte)}
+	results, err := p.Store(s, "a", bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Name != "a.jpg" || results[1].Name != "a-thumb.png" {
+		t.Fatalf("got results: %+v", results)
+	}
+	main, thumb := results[0], results[1]
+	if main.Width != 300 || main.Height != 300 || main.Size > 40<<10 || main.Url != "http://static/a.jpg" {
+		t.Errorf("got main variant: %+v", main)
+	}
+	if thumb.Width != 100 || thumb.Height != 66 {
+		t.Errorf("got thumb variant: %+v", thumb)
+	}
+	config, format, err := image.DecodeConfig(bytes.NewReader(s.files["a.jpg"]))
+	if err != nil || format != "jpeg" || config.Width != 300 {
+		t.Errorf("got stored file: %v, %s, %v", config, format, err)
+	}
+
+	// 保存失败时删除已保存的版本
+	s = &memStorage{files: make(map[string][]byte), failure: "b-thumb.png"}
+	results, err = p.Store(s, "b", bytes.NewReader(buf.Bytes()))
+	if err == nil || results != nil || len(s.files) != 0 {
+		t.Errorf("need cleanup, got: %v, %v", err, s.files)
+	}
+
+	// WebP 版本
+	p = &Pipeline{Format: WebP, Variants: []*Variant{{Name: "small", Width: 60}}}
+	s = &memStorage{files: make(map[string][]byte)}
+	results, err = p.Store(s, "c", bytes.NewReader(buf.Bytes()))
+	if err != nil || len(results) != 1 || results[0].Name != "c-small.webp" {
+		t.Fatalf("got webp results: %+v, %v", results, err)
+	}
+	small, err := webp.Decode(bytes.NewReader(s.files["c-small.webp"]))
+	if err != nil || small.Bounds().Dx() != 60 || small.Bounds().Dy() != 40 {
+		t.Errorf("got stored webp: %v, %v", small, err)
+	}
+
+	// 未注册编码器
+	p = &Pipeline{Format: "bmp", Variants: []*Variant{{}}}
+	if _, err = p.Process(bytes.NewReader(buf.Bytes())); err == nil {
+		t.Error("need bmp encoder error")
+	}
+}
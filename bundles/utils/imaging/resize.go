@@ -0,0 +1,119 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package imaging
+
+import (
+	"image"
+	"image/draw"
+)
+
+// Fit 为缩放方式
+type Fit int
+
+const (
+	// 等比缩放并居中裁剪, 输出尺寸与目标尺寸一致
+	FitCover Fit = iota
+	// 等比缩放到目标尺寸之内, 不会放大图片
+	FitContain
+)
+
+// 转换为 RGBA 图片, 便于直接读取像素
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok && rgba.Bounds().Min == (image.Point{}) {
+		return rgba
+	}
+	b := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, b.Min, draw.Src)
+	return rgba
+}
+
+// 计算输出尺寸及需要裁剪的源图区域, width 或 height 为 0 时按比例计算
+func fitRect(sw, sh, width, height int, fit Fit) (dw, dh int, crop image.Rectangle) {
+	crop = image.Rect(0, 0, sw, sh)
+	if width <= 0 && height <= 0 {
+		return sw, sh, crop
+	}
+	if width <= 0 || height <= 0 {
+		if width <= 0 {
+			width = max(1, sw*height/sh)
+		} else {
+			height = max(1, sh*width/sw)
+		}
+		if fit == FitContain && sw <= width && sh <= height {
+			return sw, sh, crop
+		}
+		return width, height, crop
+	}
+	if fit == FitContain {
+		if sw <= width && sh <= height {
+			return sw, sh, crop
+		}
+		if sw*height > sh*width { // 源图更宽
+			return width, max(1, sh*width/sw), crop
+		}
+		return max(1, sw*height/sh), height, crop
+	}
+	// 裁剪与目标宽高比一致的中间区域
+	cw, ch := sw, sh
+	if sw*height > sh*width {
+		cw = max(1, sh*width/height)
+	} else {
+		ch = max(1, sw*height/width)
+	}
+	x, y := (sw-cw)/2, (sh-ch)/2
+	return width, height, image.Rect(x, y, x+cw, y+ch)
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// Resize 缩放并裁剪图片
+func Resize(img image.Image, width, height int, fit Fit) *image.RGBA {
+	src := toRGBA(img)
+	b := src.Bounds()
+	dw, dh, crop := fitRect(b.Dx(), b.Dy(), width, height, fit)
+	if dw == b.Dx() && dh == b.Dy() && crop == b {
+		return src
+	}
+	return resample(src.SubImage(crop).(*image.RGBA), dw, dh)
+}
+
+// 缩小时取源图对应区域的平均值, 放大时取最近的像素
+func resample(src *image.RGBA, dw, dh int) *image.RGBA {
+	b := src.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		sy0 := y * sh / dh
+		sy1 := max(sy0+1, (y+1)*sh/dh)
+		for x := 0; x < dw; x++ {
+			sx0 := x * sw / dw
+			sx1 := max(sx0+1, (x+1)*sw/dw)
+			var r, g, bl, a, n uint32
+			for sy := sy0; sy < sy1; sy++ {
+				i := src.PixOffset(b.Min.X+sx0, b.Min.Y+sy)
+				for sx := sx0; sx < sx1; sx++ {
+					r += uint32(src.Pix[i])
+					g += uint32(src.Pix[i+1])
+					bl += uint32(src.Pix[i+2])
+					a += uint32(src.Pix[i+3])
+					i += 4
+					n++
+				}
+			}
+			j := dst.PixOffset(x, y)
+			dst.Pix[j] = uint8(r / n)
+			dst.Pix[j+1] = uint8(g / n)
+			dst.Pix[j+2] = uint8(bl / n)
+			dst.Pix[j+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
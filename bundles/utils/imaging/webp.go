@@ -0,0 +1,303 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package imaging
+
+import (
+	"container/heap"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/draw"
+	"io"
+	"sort"
+)
+
+// WebP 编码器为纯 Go 实现的无损(VP8L)编码, 不依赖 cgo, 质量参数无效. 需要有损 WebP 时可以注册 libwebp 的编码器, 如:
+//
+//	imaging.RegisterEncoder(imaging.WebP, func(w io.Writer, img image.Image, quality int) error {
+//		return webp.Encode(w, img, &webp.Options{Quality: float32(quality)})
+//	})
+
+// VP8L 图片的最大宽高
+const webpMaxSize = 1 << 14
+
+var ErrWebPTooLarge = errors.New("imaging: webp image width and height must not exceed 16384")
+
+const (
+	webpMaxCodeLength       = 15 // 像素编码的最大码长
+	webpMaxCodeLengthLength = 7  // 码长编码的最大码长
+	webpGreenAlphabetSize   = 256 + 24
+	webpDistanceAlphabet    = 40
+)
+
+// 码长编码的码长写入顺序
+var webpCodeLengthOrder = [19]int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// 以最低位优先的顺序写入比特
+type bitWriter struct {
+	buf   []byte
+	bits  uint64
+	nbits uint
+}
+
+func (w *bitWriter) write(value uint32, n uint) {
+	w.bits |= uint64(value) << w.nbits
+	w.nbits += n
+	for w.nbits >= 8 {
+		w.buf = append(w.buf, byte(w.bits))
+		w.bits >>= 8
+		w.nbits -= 8
+	}
+}
+
+func (w *bitWriter) bytes() []byte {
+	if w.nbits > 0 {
+		w.buf = append(w.buf, byte(w.bits))
+		w.bits, w.nbits = 0, 0
+	}
+	return w.buf
+}
+
+// 范式 Huffman 编码, codes 已按写入顺序反转
+type prefixCode struct {
+	lengths []int
+	codes   []uint32
+	used    []int // 码长不为 0 的符号
+}
+
+func newPrefixCode(freqs []int, maxLength int) *prefixCode {
+	pc := &prefixCode{lengths: huffmanLengths(freqs, maxLength), codes: make([]uint32, len(freqs))}
+	for symbol, length := range pc.lengths {
+		if length > 0 {
+			pc.used = append(pc.used, symbol)
+		}
+	}
+	if len(pc.used) == 1 {
+		// 只有一个符号时不占用比特
+		pc.lengths[pc.used[0]] = 0
+		return pc
+	}
+	var count [webpMaxCodeLength + 1]int
+	for _, length := range pc.lengths {
+		count[length]++
+	}
+	count[0] = 0
+	var next [webpMaxCodeLength + 2]uint32
+	code := uint32(0)
+	for length := 1; length <= webpMaxCodeLength; length++ {
+		code = (code + uint32(count[length-1])) << 1
+		next[length] = code
+	}
+	for symbol, length := range pc.lengths {
+		if length > 0 {
+			pc.codes[symbol] = reverseBits(next[length], uint(length))
+			next[length]++
+		}
+	}
+	return pc
+}
+
+func reverseBits(code uint32, n uint) uint32 {
+	var r uint32
+	for i := uint(0); i < n; i++ {
+		r = r<<1 | code&1
+		code >>= 1
+	}
+	return r
+}
+
+func (pc *prefixCode) writeSymbol(w *bitWriter, symbol int) {
+	if length := pc.lengths[symbol]; length > 0 {
+		w.write(pc.codes[symbol], uint(length))
+	}
+}
+
+// 写入编码的码长, 不超过两个 8 位符号时使用简单编码
+func (pc *prefixCode) writeHeader(w *bitWriter) {
+	if len(pc.used) <= 2 && (len(pc.used) == 0 || pc.used[len(pc.used)-1] < 256) {
+		symbols := pc.used
+		if len(symbols) == 0 {
+			symbols = []int{0}
+		}
+		w.write(1, 1)
+		w.write(uint32(len(symbols)-1), 1)
+		if symbols[0] < 2 {
+			w.write(0, 1)
+			w.write(uint32(symbols[0]), 1)
+		} else {
+			w.write(1, 1)
+			w.write(uint32(symbols[0]), 8)
+		}
+		if len(symbols) == 2 {
+			w.write(uint32(symbols[1]), 8)
+		}
+		return
+	}
+	w.write(0, 1)
+	freqs := make([]int, 19)
+	for _, length := range pc.lengths {
+		freqs[length]++
+	}
+	clc := newPrefixCode(freqs, webpMaxCodeLengthLength)
+	clcLengths := clc.lengths
+	if len(clc.used) == 1 {
+		// 单个符号的码长写为 1, 解码时不读取比特
+		clcLengths = make([]int, 19)
+		clcLengths[clc.used[0]] = 1
+	}
+	n := len(webpCodeLengthOrder)
+	for n > 4 && clcLengths[webpCodeLengthOrder[n-1]] == 0 {
+		n--
+	}
+	w.write(uint32(n-4), 4)
+	for _, symbol := range webpCodeLengthOrder[:n] {
+		w.write(uint32(clcLengths[symbol]), 3)
+	}
+	// 写入所有符号的码长, 不使用 max_symbol
+	w.write(0, 1)
+	for _, length := range pc.lengths {
+		clc.writeSymbol(w, length)
+	}
+}
+
+type huffmanNode struct {
+	freq   int
+	symbol int
+	left   *huffmanNode
+	right  *huffmanNode
+}
+
+type huffmanHeap []*huffmanNode
+
+func (h huffmanHeap) Len() int            { return len(h) }
+func (h huffmanHeap) Less(i, j int) bool  { return h[i].freq < h[j].freq }
+func (h huffmanHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *huffmanHeap) Push(x interface{}) { *h = append(*h, x.(*huffmanNode)) }
+func (h *huffmanHeap) Pop() interface{} {
+	old := *h
+	n := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return n
+}
+
+// 计算码长不超过 maxLength 的 Huffman 码长, 超出时减小频率差距后重新计算
+func huffmanLengths(freqs []int, maxLength int) []int {
+	freqs = append([]int(nil), freqs...)
+	for {
+		lengths := make([]int, len(freqs))
+		h := &huffmanHeap{}
+		for symbol, freq := range freqs {
+			if freq > 0 {
+				*h = append(*h, &huffmanNode{freq: freq, symbol: symbol})
+			}
+		}
+		if h.Len() <= 1 {
+			for _, node := range *h {
+				lengths[node.symbol] = 1
+			}
+			return lengths
+		}
+		// 频率相同时按符号排序, 保证结果稳定
+		sort.SliceStable(*h, func(i, j int) bool { return (*h)[i].freq < (*h)[j].freq })
+		heap.Init(h)
+		for h.Len() > 1 {
+			a, b := heap.Pop(h).(*huffmanNode), heap.Pop(h).(*huffmanNode)
+			heap.Push(h, &huffmanNode{freq: a.freq + b.freq, symbol: -1, left: a, right: b})
+		}
+		max := 0
+		var walk func(node *huffmanNode, depth int)
+		walk = func(node *huffmanNode, depth int) {
+			if node.left == nil {
+				lengths[node.symbol] = depth
+				if depth > max {
+					max = depth
+				}
+				return
+			}
+			walk(node.left, depth+1)
+			walk(node.right, depth+1)
+		}
+		walk((*h)[0], 0)
+		if max <= maxLength {
+			return lengths
+		}
+		for symbol, freq := range freqs {
+			if freq > 0 {
+				freqs[symbol] = (freq + 1) / 2
+			}
+		}
+	}
+}
+
+// 以无损 VP8L 格式编码图片, 每个像素直接以 ARGB 字面量编码
+func encodeWebP(w io.Writer, img image.Image, quality int) error {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width < 1 || height < 1 || width > webpMaxSize || height > webpMaxSize {
+		return ErrWebPTooLarge
+	}
+	nrgba, ok := img.(*image.NRGBA)
+	if !ok || nrgba.Rect.Min != (image.Point{}) {
+		nrgba = image.NewNRGBA(image.Rect(0, 0, width, height))
+		draw.Draw(nrgba, nrgba.Rect, img, bounds.Min, draw.Src)
+	}
+	green, red := make([]int, webpGreenAlphabetSize), make([]int, 256)
+	blue, alpha := make([]int, 256), make([]int, 256)
+	for y := 0; y < height; y++ {
+		row := nrgba.Pix[y*nrgba.Stride : y*nrgba.Stride+width*4]
+		for x := 0; x < len(row); x += 4 {
+			red[row[x]]++
+			green[row[x+1]]++
+			blue[row[x+2]]++
+			alpha[row[x+3]]++
+		}
+	}
+	codes := []*prefixCode {
+		newPrefixCode(green, webpMaxCodeLength),
+		newPrefixCode(red, webpMaxCodeLength),
+		newPrefixCode(blue, webpMaxCodeLength),
+		newPrefixCode(alpha, webpMaxCodeLength),
+		newPrefixCode(make([]int, webpDistanceAlphabet), webpMaxCodeLength),
+	}
+	bw := &bitWriter{buf: []byte{0x2f}}
+	bw.write(uint32(width-1), 14)
+	bw.write(uint32(height-1), 14)
+	if alpha[255] != width*height {
+		bw.write(1, 1)
+	} else {
+		bw.write(0, 1)
+	}
+	bw.write(0, 3) // 版本
+	bw.write(0, 1) // 无变换
+	bw.write(0, 1) // 无颜色缓存
+	bw.write(0, 1) // 无元前缀编码
+	for _, code := range codes {
+		code.writeHeader(bw)
+	}
+	for y := 0; y < height; y++ {
+		row := nrgba.Pix[y*nrgba.Stride : y*nrgba.Stride+width*4]
+		for x := 0; x < len(row); x += 4 {
+			codes[0].writeSymbol(bw, int(row[x+1]))
+			codes[1].writeSymbol(bw, int(row[x]))
+			codes[2].writeSymbol(bw, int(row[x+2]))
+			codes[3].writeSymbol(bw, int(row[x+3]))
+		}
+	}
+	data := bw.bytes()
+	chunkSize := len(data)
+	if len(data)%2 == 1 {
+		data = append(data, 0)
+	}
+	header := make([]byte, 20)
+	copy(header[0:], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], uint32(12+len(data)))
+	copy(header[8:], "WEBPVP8L")
+	binary.LittleEndian.PutUint32(header[16:], uint32(chunkSize))
+	_, err := w.Write(header)
+	if err == nil {
+		_, err = w.Write(data)
+	}
+	return err
+}
@@ -0,0 +1,85 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package imaging
+
+import (
+	"bytes"
+	"golang.org/x/image/webp"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func TestEncodeWebP(t *testing.T) {
+	noise := newImage(37, 21)
+	transparent := image.NewNRGBA(image.Rect(0, 0, 16, 9))
+	for i := range transparent.Pix {
+		transparent.Pix[i] = uint8(i * 7)
+	}
+	solid := image.NewRGBA(image.Rect(0, 0, 5, 3))
+	draw.Draw(solid, solid.Bounds(), image.NewUniform(color.RGBA{R: 200, G: 10, B: 30, A: 255}), image.Point{}, draw.Src)
+	twoColors := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for i := 0; i < len(twoColors.Pix); i += 4 {
+		twoColors.Pix[i+3] = 255
+		if i%8 == 0 {
+			twoColors.Pix[i+1] = 255
+		}
+	}
+	cases := map[string]image.Image {
+		"noise":       noise,
+		"transparent": transparent,
+		"solid":       solid,
+		"two colors":  twoColors,
+		"sub image":   noise.SubImage(image.Rect(3, 4, 20, 11)),
+	}
+	for name, img := range cases {
+		buf := &bytes.Buffer{}
+		err := encodeWebP(buf, img, 80)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		got, err := webp.Decode(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatalf("%s: decode: %v", name, err)
+		}
+		bounds := img.Bounds()
+		if got.Bounds().Dx() != bounds.Dx() || got.Bounds().Dy() != bounds.Dy() {
+			t.Fatalf("%s: need size %v, got %v", name, bounds.Size(), got.Bounds().Size())
+		}
+		for y := 0; y < bounds.Dy(); y++ {
+			for x := 0; x < bounds.Dx(); x++ {
+				need := color.NRGBAModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y))
+				if c := color.NRGBAModel.Convert(got.At(x, y)); c != need {
+					t.Fatalf("%s: pixel (%d, %d) need %v, got %v", name, x, y, need, c)
+				}
+			}
+		}
+	}
+	if err := encodeWebP(&bytes.Buffer{}, image.NewRGBA(image.Rect(0, 0, webpMaxSize+1, 1)), 80); err != ErrWebPTooLarge {
+		t.Errorf("need ErrWebPTooLarge, got: %v", err)
+	}
+}
+
+func TestHuffmanLengths_MaxLength(t *testing.T) {
+	// 斐波那契频率会生成最深的 Huffman 树
+	freqs := make([]int, 30)
+	a, b := 1, 1
+	for i := range freqs {
+		freqs[i] = a
+		a, b = b, a+b
+	}
+	lengths := huffmanLengths(freqs, webpMaxCodeLength)
+	kraft := 0.0
+	for _, length := range lengths {
+		if length < 1 || length > webpMaxCodeLength {
+			t.Fatalf("need length in 1-%d, got: %v", webpMaxCodeLength, lengths)
+		}
+		kraft += 1 / float64(int(1)<<uint(length))
+	}
+	if kraft != 1 {
+		t.Errorf("need complete code, got kraft sum %v", kraft)
+	}
+}
@@ -0,0 +1,40 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package morgine_redis
+
+import (
+	"github.com/go-redis/redis"
+	"github.com/orivil/morgine/utils/token"
+	"time"
+)
+
+var _ token.Denylist = (*Denylist)(nil)
+
+// Denylist 为共享的令牌黑名单, 到期后由 Redis 删除
+type Denylist struct {
+	client *redis.Client
+	prefix string
+}
+
+// prefix 为存储键前缀
+func NewDenylist(client *redis.Client, prefix string) *Denylist {
+	return &Denylist{client: client, prefix: prefix}
+}
+
+func (d *Denylist) Add(id string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+	return d.client.Set(d.prefix+id, 1, ttl).Err()
+}
+
+func (d *Denylist) Contains(id string) (bool, error) {
+	n, err := d.client.Exists(d.prefix + id).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
@@ -0,0 +1,33 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package morgine_redis
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDenylist(t *testing.T) {
+	client, closer := newTestClient(t)
+	defer closer()
+	d := NewDenylist(client, "denylist:")
+	err := d.Add("a", time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = d.Add("b", time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id, need := range map[string]bool{"a": true, "b": false, "c": false} {
+		got, err := d.Contains(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != need {
+			t.Errorf("%s: need: %t, got: %t", id, need, got)
+		}
+	}
+}
@@ -0,0 +1,307 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package morgine_redis
+
+import (
+	"fmt"
+	"github.com/go-redis/redis"
+	"github.com/orivil/morgine/log"
+	"github.com/orivil/morgine/utils/limiter"
+	"math/rand"
+	"strconv"
+	"time"
+)
+
+// 以下限制器通过客户端传入当前时间, 所以多个实例的系统时间需保持同步.
+// 替换 xx 的默认限流器:
+//
+//	xx.RateLimitBackend = func(rule *xx.RateLimit) limiter.RateLimiter {
+//		return morgine_redis.NewTokenBucketLimiter(client, "rate-limit:"+rule.Name+":", rule.Rate, rule.Period, rule.Burst)
+//	}
+
+var (
+	_ limiter.RateLimiter      = (*SlidingWindowLimiter)(nil)
+	_ limiter.RateLimiter      = (*TokenBucketLimiter)(nil)
+	_ limiter.OperationLimiter = (*OperationContainer)(nil)
+)
+
+// 时间单位为微秒, ARGV[5] 为 1 时只检测不记录
+var slidingWindowScript = redis.NewScript(`
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+local peek = ARGV[5] == '1'
+redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+local count = redis.call('ZCARD', key)
+local allowed = 0
+if count < limit then
+	if not peek then
+		redis.call('ZADD', key, ARGV[1], ARGV[4])
+		count = count + 1
+	end
+	allowed = 1
+end
+local retry = 0
+local reset = 0
+if count > 0 then
+	local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+	local newest = redis.call('ZRANGE', key, -1, -1, 'WITHSCORES')
+	retry = tonumber(oldest[2]) + window - now
+	reset = tonumber(newest[2]) + window - now
+	if not peek then
+		redis.call('PEXPIRE', key, math.ceil(window / 1000))
+	end
+end
+if allowed == 1 then
+	retry = 0
+end
+return {allowed, limit - count, reset, retry}
+`)
+
+// SlidingWindowLimiter 为滑动窗口限流器, 任意 window 时间内最多允许 limit 次请求
+type SlidingWindowLimiter struct {
+	client *redis.Client
+	prefix string
+	limit  int
+	window time.Duration
+	now    func() time.Time
+}
+
+// prefix 为存储键前缀, 用于区分不同的限流规则
+func NewSlidingWindowLimiter(client *redis.Client, prefix string, limit int, window time.Duration) *SlidingWindowLimiter {
+	return &SlidingWindowLimiter {
+		client: client,
+		prefix: prefix,
+		limit:  limit,
+		window: window,
+		now:    time.Now,
+	}
+}
+
+func (l *SlidingWindowLimiter) Take(session string) (*limiter.Result, error) {
+	return l.run(session, 0)
+}
+
+// Peek 检测当前窗口是否还有配额, 不记录本次请求
+func (l *SlidingWindowLimiter) Peek(session string) (*limiter.Result, error) {
+	return l.run(session, 1)
+}
+
+func (l *SlidingWindowLimiter) run(session string, peek int) (*limiter.Result, error) {
+	now := l.now().UnixNano() / int64(time.Microsecond)
+	member := strconv.FormatInt(now, 10) + "-" + strconv.FormatInt(rand.Int63(), 36)
+	vs, err := slidingWindowScript.Run(l.client, []string{l.prefix + session}, now, int64(l.window/time.Microsecond), l.limit, member, peek).Result()
+	if err != nil {
+		return nil, err
+	}
+	return scriptResult(vs, l.limit)
+}
+
+// Allow 消耗一次配额, Redis 出错时记录错误并允许访问
+func (l *SlidingWindowLimiter) Allow(session string) bool {
+	return allowResult(l.Take(session))
+}
+
+// 时间单位为微秒, ARGV[5] 为 1 时只检测不消耗令牌
+var tokenBucketScript = redis.NewScript(`
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local rate = tonumber(ARGV[3])
+local burst = tonumber(ARGV[4])
+local peek = ARGV[5] == '1'
+local state = redis.call('HMGET', key, 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+	tokens = burst
+	ts = now
+end
+if now > ts then
+	tokens = math.min(burst, tokens + (now - ts) * rate / period)
+end
+local allowed = 0
+local retry = 0
+if tokens >= 1 then
+	if not peek then
+		tokens = tokens - 1
+	end
+	allowed = 1
+else
+	retry = math.ceil((1 - tokens) * period / rate)
+end
+local reset = math.ceil((burst - tokens) * period / rate)
+if not peek then
+	redis.call('HMSET', key, 'tokens', tostring(tokens), 'ts', ARGV[1])
+	redis.call('PEXPIRE', key, math.ceil(reset / 1000) + 1000)
+end
+return {allowed, math.floor(tokens), reset, retry}
+`)
+
+// TokenBucketLimiter 为令牌桶限流器, 每 period 时间补充 rate 个令牌, 最多存储 burst 个令牌, 与 VisitorContainer 规则相同
+type TokenBucketLimiter struct {
+	client *redis.Client
+	prefix string
+	rate   int
+	period time.Duration
+	burst  int
+	now    func() time.Time
+}
+
+// prefix 为存储键前缀, 用于区分不同的限流规则, burst 小于等于 0 时等于 rate
+func NewTokenBucketLimiter(client *redis.Client, prefix string, rate int, period time.Duration, burst int) *TokenBucketLimiter {
+	if burst <= 0 {
+		burst = rate
+	}
+	return &TokenBucketLimiter {
+		client: client,
+		prefix: prefix,
+		rate:   rate,
+		period: period,
+		burst:  burst,
+		now:    time.Now,
+	}
+}
+
+func (l *TokenBucketLimiter) Take(session string) (*limiter.Result, error) {
+	return l.run(session, 0)
+}
+
+// Peek 检测当前是否还有令牌, 不消耗令牌
+func (l *TokenBucketLimiter) Peek(session string) (*limiter.Result, error) {
+	return l.run(session, 1)
+}
+
+func (l *TokenBucketLimiter) run(session string, peek int) (*limiter.Result, error) {
+	now := l.now().UnixNano() / int64(time.Microsecond)
+	vs, err := tokenBucketScript.Run(l.client, []string{l.prefix + session}, now, int64(l.period/time.Microsecond), l.rate, l.burst, peek).Result()
+	if err != nil {
+		return nil, err
+	}
+	return scriptResult(vs, l.burst)
+}
+
+// Allow 消耗一个令牌, Redis 出错时记录错误并允许访问
+func (l *TokenBucketLimiter) Allow(session string) bool {
+	return allowResult(l.Take(session))
+}
+
+// 解析脚本返回的 {allowed, remaining, reset, retry}
+func scriptResult(v interface{}, limit int) (*limiter.Result, error) {
+	vs, ok := v.([]interface{})
+	if !ok || len(vs) != 4 {
+		return nil, fmt.Errorf("unexpected limiter script result: %v", v)
+	}
+	var ns [4]int64
+	for i, v := range vs {
+		ns[i], ok = v.(int64)
+		if !ok {
+			return nil, fmt.Errorf("unexpected limiter script result: %v", vs)
+		}
+	}
+	return &limiter.Result {
+		Allowed:    ns[0] == 1,
+		Limit:      limit,
+		Remaining:  int(ns[1]),
+		Reset:      time.Duration(ns[2]) * time.Microsecond,
+		RetryAfter: time.Duration(ns[3]) * time.Microsecond,
+	}, nil
+}
+
+func allowResult(res *limiter.Result, err error) bool {
+	if err != nil {
+		log.Error.Println(err)
+		return true
+	}
+	return res.Allowed
+}
+
+// 事务因并发修改失败时的最大重试次数
+const maxTxRetries = 10
+
+// OperationContainer 为 limiter.OperationContainer 的 Redis 实现, 失败记录在最后一次失败 expire 时间之后删除
+type OperationContainer struct {
+	client       *redis.Client
+	prefix       string
+	expire       time.Duration
+	timeProvider limiter.TimeProvider
+}
+
+// prefix 为存储键前缀, expire 为失败记录保存时间, 等待时间超过 expire 时以等待时间为准
+func NewOperationContainer(client *redis.Client, prefix string, expire time.Duration, timeProvider limiter.TimeProvider) *OperationContainer {
+	return &OperationContainer {
+		client:       client,
+		prefix:       prefix,
+		expire:       expire,
+		timeProvider: timeProvider,
+	}
+}
+
+// 操作成功，删除失败记录
+func (oc *OperationContainer) Success(session string) {
+	err := oc.client.Del(oc.prefix + session).Err()
+	if err != nil {
+		log.Error.Println(err)
+	}
+}
+
+// 添加失败记录. 等待时间由 TimeProvider 按失败次数计算, 所以通过 WATCH 及 MULTI 保证读取次数与写入记录是一个原子操作,
+// 并发修改导致事务失败时重试
+func (oc *OperationContainer) Failed(session string) {
+	key := oc.prefix + session
+	var err error
+	for i := 0; i < maxTxRetries; i++ {
+		err = oc.client.Watch(func(tx *redis.Tx) error {
+			failed, err := tx.HGet(key, "failed").Int()
+			if err != nil && err != redis.Nil {
+				return err
+			}
+			failed++
+			expire := oc.expire
+			waitDuration := oc.timeProvider.GetWaitTime(failed)
+			_, err = tx.TxPipelined(func(pipe redis.Pipeliner) error {
+				pipe.HSet(key, "failed", failed)
+				if waitDuration > 0 {
+					waitAt := oc.timeProvider.GetNowTime().Add(waitDuration)
+					pipe.HSet(key, "wait_at", waitAt.UnixNano())
+					if waitDuration > expire {
+						expire = waitDuration
+					}
+				} else {
+					pipe.HDel(key, "wait_at")
+				}
+				pipe.Expire(key, expire)
+				return nil
+			})
+			return err
+		}, key)
+		if err != redis.TxFailedErr {
+			break
+		}
+	}
+	if err != nil {
+		log.Error.Println(err)
+	}
+}
+
+// 检测当前时间是否允许操作, Redis 出错时记录错误并允许操作
+func (oc *OperationContainer) Allow(session string) bool {
+	waitAt := oc.GetWaitTime(session)
+	return waitAt == nil || waitAt.Before(oc.timeProvider.GetNowTime())
+}
+
+// 获得用户等待时间
+func (oc *OperationContainer) GetWaitTime(session string) (waitAt *time.Time) {
+	nano, err := oc.client.HGet(oc.prefix+session, "wait_at").Int64()
+	if err != nil {
+		if err != redis.Nil {
+			log.Error.Println(err)
+		}
+		return nil
+	}
+	wa := time.Unix(0, nano)
+	return &wa
+}
@@ -0,0 +1,185 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package morgine_redis
+
+import (
+	"github.com/alicebob/miniredis"
+	"github.com/go-redis/redis"
+	"github.com/orivil/morgine/utils/limiter"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 默认使用 miniredis 测试, 设置环境变量 REDIS_ADDR 则使用真实的 Redis 测试
+func newTestClient(t *testing.T) (client *redis.Client, closer func()) {
+	if addr := os.Getenv("REDIS_ADDR"); addr != "" {
+		env := &Env{RedisAddr: addr, RedisPassword: os.Getenv("REDIS_PASSWORD")}
+		client, err := env.Connect(15)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = client.FlushDB().Err()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return client, func() {}
+	}
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := &Env{RedisAddr: s.Addr()}
+	client, err = env.Connect(0)
+	if err != nil {
+		s.Close()
+		t.Fatal(err)
+	}
+	return client, s.Close
+}
+
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+// 每个周期允许 3 次请求的限流器的通用测试
+func testRateLimiter(t *testing.T, l limiter.RateLimiter, clock *testClock, period time.Duration) {
+	for i := 0; i < 2; i++ {
+		res, err := l.Peek("a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !res.Allowed || res.Remaining != 3 {
+			t.Errorf("peek need allowed with remaining 3, got: %+v", res)
+		}
+	}
+	for i := 2; i >= 0; i-- {
+		res, err := l.Take("a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !res.Allowed || res.Remaining != i || res.Limit != 3 {
+			t.Errorf("need allowed with remaining %d, got: %+v", i, res)
+		}
+	}
+	res, err := l.Take("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Allowed || res.RetryAfter <= 0 || res.RetryAfter > period {
+		t.Errorf("need denied, got: %+v", res)
+	}
+	res, err = l.Peek("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Allowed || res.RetryAfter <= 0 {
+		t.Errorf("peek need denied, got: %+v", res)
+	}
+	if !l.Allow("b") {
+		t.Error("need other session allowed")
+	}
+	clock.now = clock.now.Add(period)
+	if !l.Allow("a") {
+		t.Error("need allowed after period")
+	}
+}
+
+func TestSlidingWindowLimiter(t *testing.T) {
+	client, closer := newTestClient(t)
+	defer closer()
+	clock := &testClock{now: time.Now()}
+	l := NewSlidingWindowLimiter(client, "sliding:", 3, time.Minute)
+	l.now = clock.Now
+	testRateLimiter(t, l, clock, time.Minute)
+}
+
+func TestTokenBucketLimiter(t *testing.T) {
+	client, closer := newTestClient(t)
+	defer closer()
+	clock := &testClock{now: time.Now()}
+	l := NewTokenBucketLimiter(client, "bucket:", 3, time.Minute, 0)
+	l.now = clock.Now
+	testRateLimiter(t, l, clock, time.Minute)
+
+	res, _ := l.Take("c")
+	if res.Remaining != 2 || res.Reset != 20*time.Second {
+		t.Errorf("need remaining 2 and reset 20s, got: %+v", res)
+	}
+}
+
+type waitTime struct {
+	now time.Time
+}
+
+func (w *waitTime) GetNowTime() time.Time {
+	return w.now
+}
+
+func (w *waitTime) GetWaitTime(failed int) time.Duration {
+	if failed < 3 {
+		return 0
+	}
+	return time.Duration(failed-2) * time.Minute
+}
+
+func TestOperationContainer(t *testing.T) {
+	client, closer := newTestClient(t)
+	defer closer()
+	provider := &waitTime{now: time.Now()}
+	opc := NewOperationContainer(client, "operation:", time.Hour, provider)
+	ip := "127.0.0.1"
+	opc.Failed(ip)
+	opc.Failed(ip)
+	if !opc.Allow(ip) || opc.GetWaitTime(ip) != nil {
+		t.Error("need allowed after 2 failures")
+	}
+	opc.Failed(ip)
+	waitAt := opc.GetWaitTime(ip)
+	if opc.Allow(ip) || waitAt == nil || !waitAt.Equal(provider.now.Add(time.Minute)) {
+		t.Errorf("need wait 1 minute after 3 failures, got: %v", waitAt)
+	}
+	provider.now = provider.now.Add(2 * time.Minute)
+	if !opc.Allow(ip) {
+		t.Error("need allowed after waiting")
+	}
+	opc.Success(ip)
+	if opc.GetWaitTime(ip) != nil {
+		t.Error("need failures deleted after success")
+	}
+}
+
+func TestOperationContainer_FailedConcurrent(t *testing.T) {
+	// miniredis 在 WATCH 的键被修改时 EXEC 返回空数组而不是 nil, 客户端无法识别事务失败
+	if os.Getenv("REDIS_ADDR") == "" {
+		t.Skip("need REDIS_ADDR to test concurrent transactions")
+	}
+	client, closer := newTestClient(t)
+	defer closer()
+	provider := &waitTime{now: time.Now()}
+	opc := NewOperationContainer(client, "operation:", time.Hour, provider)
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			opc.Failed("a")
+		}()
+	}
+	wg.Wait()
+	failed, err := client.HGet("operation:a", "failed").Int()
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitAt := opc.GetWaitTime("a")
+	if failed != 5 || waitAt == nil || !waitAt.Equal(provider.now.Add(3*time.Minute)) {
+		t.Errorf("need 5 failures and wait 3 minutes, got: %d %v", failed, waitAt)
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package morgine_redis
+
+import (
+	"encoding/json"
+	"github.com/go-redis/redis"
+	"github.com/orivil/morgine/utils/session"
+	"time"
+)
+
+var _ session.Store = (*SessionStore)(nil)
+
+// SessionStore 将会话以 JSON 保存在 Redis 中, 并通过集合索引用户的会话. 使用方式:
+//
+//	manager := xx.NewSessionManager(morgine_redis.NewSessionStore(client, "session:"))
+type SessionStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// prefix 为存储键前缀
+func NewSessionStore(client *redis.Client, prefix string) *SessionStore {
+	return &SessionStore{client: client, prefix: prefix}
+}
+
+func (s *SessionStore) key(id string) string {
+	return s.prefix + id
+}
+
+func (s *SessionStore) userKey(userID string) string {
+	return s.prefix + "user:" + userID
+}
+
+func (s *SessionStore) decode(value []byte) (*session.Data, error) {
+	data := &session.Data{}
+	err := json.Unmarshal(value, data)
+	if err != nil {
+		return nil, err
+	}
+	// 键的过期时间以 Redis 服务器时间为准, 此处再以本地时间检查一次
+	if data.IsExpired(time.Now()) {
+		return nil, session.ErrNotFound
+	}
+	return data, nil
+}
+
+func (s *SessionStore) Get(id string) (*session.Data, error) {
+	value, err := s.client.Get(s.key(id)).Bytes()
+	if err == redis.Nil {
+		return nil, session.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.decode(value)
+}
+
+func (s *SessionStore) Save(data *session.Data) error {
+	pipe := s.client.TxPipeline()
+	err := s.save(pipe, data)
+	if err != nil {
+		return err
+	}
+	_, err = pipe.Exec()
+	return err
+}
+
+// 将保存会话的命令加入事务, 已过期的会话则删除
+func (s *SessionStore) save(pipe redis.Pipeliner, data *session.Data) error {
+	ttl := time.Until(data.ExpiresAt)
+	if ttl <= 0 {
+		pipe.Del(s.key(data.ID))
+		return nil
+	}
+	value, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	pipe.Set(s.key(data.ID), value, ttl)
+	if data.UserID != "" {
+		userKey := s.userKey(data.UserID)
+		pipe.SAdd(userKey, data.ID)
+		// 索引的过期时间不短于最晚过期的会话
+		pttl := s.client.PTTL(userKey).Val()
+		if pttl < ttl {
+			pipe.PExpire(userKey, ttl)
+		}
+	}
+	return nil
+}
+
+// Update 通过 WATCH 监视会话, 读取后会话被其他请求修改时事务失败, 视为比较不相等
+func (s *SessionStore) Update(data *session.Data, key, expected string) (swapped bool, err error) {
+	err = s.client.Watch(func(tx *redis.Tx) error {
+		value, err := tx.Get(s.key(data.ID)).Bytes()
+		if err == redis.Nil {
+			return session.ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		current, err := s.decode(value)
+		if err != nil {
+			return err
+		}
+		if current.Values[key] != expected {
+			return nil
+		}
+		_, err = tx.TxPipelined(func(pipe redis.Pipeliner) error {
+			return s.save(pipe, data)
+		})
+		swapped = err == nil
+		return err
+	}, s.key(data.ID))
+	if err == redis.TxFailedErr {
+		return false, nil
+	}
+	return swapped, err
+}
+
+func (s *SessionStore) Delete(id string) error {
+	return s.client.Del(s.key(id)).Err()
+}
+
+// List 获得用户的会话, 并从索引中移除已过期或已删除的会话
+func (s *SessionStore) List(userID string) ([]*session.Data, error) {
+	if userID == "" {
+		return nil, session.ErrEmptyUserID
+	}
+	userKey := s.userKey(userID)
+	ids, err := s.client.SMembers(userKey).Result()
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = s.key(id)
+	}
+	values, err := s.client.MGet(keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	var sessions []*session.Data
+	var removed []interface{}
+	for i, value := range values {
+		str, ok := value.(string)
+		if !ok {
+			removed = append(removed, ids[i])
+			continue
+		}
+		data, err := s.decode([]byte(str))
+		if err == session.ErrNotFound {
+			removed = append(removed, ids[i])
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		// 会话已属于其他用户
+		if data.UserID != userID {
+			removed = append(removed, ids[i])
+			continue
+		}
+		sessions = append(sessions, data)
+	}
+	if len(removed) > 0 {
+		err = s.client.SRem(userKey, removed...).Err()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return sessions, nil
+}
@@ -0,0 +1,16 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package morgine_redis
+
+import (
+	"github.com/orivil/morgine/utils/session/sessiontest"
+	"testing"
+)
+
+func TestSessionStore(t *testing.T) {
+	client, closer := newTestClient(t)
+	defer closer()
+	sessiontest.TestStore(t, NewSessionStore(client, "session:"))
+}
@@ -4,7 +4,11 @@
 
 package storage
 
-import "net/http"
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+)
 
 // Interface is the object storage
 type Storage interface {
@@ -25,6 +29,29 @@ type Storage interface {
 	GetServeUrl(name string) (url string, err error)
 }
 
+// StreamStorage is the object storage which supports writing from a reader
+// without buffering the whole object in memory
+type StreamStorage interface {
+	Storage
+
+	// WriteStream for saving the object data read from r, the object should
+	// not exist if any error occurs
+	WriteStream(name string, r io.Reader) error
+}
+
+// WriteStream saves the object data read from r, if the storage does not
+// support streaming, the data will be read into memory first
+func WriteStream(s Storage, name string, r io.Reader) error {
+	if ss, ok := s.(StreamStorage); ok {
+		return ss.WriteStream(name, r)
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return s.Write(name, data)
+}
+
 type HttpHandler interface {
 	RoutePattern() string
 
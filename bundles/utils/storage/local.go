@@ -6,6 +6,7 @@
 package storage
 
 import (
+	"io"
 	"io/ioutil"
 	"net/http"
 	"os"
@@ -47,6 +48,31 @@ func (l *LocalStorage) Write(name string, data []byte) error {
 	return ioutil.WriteFile(l.file(name), data, os.ModePerm)
 }
 
+// 先写入临时文件, 写入完成后再重命名, 避免出错时留下不完整的文件.
+// 临时文件的权限为 0600, 重命名前改为与 Write 相同的权限, 以便其他静态文件服务读取
+func (l *LocalStorage) WriteStream(name string, r io.Reader) error {
+	file := l.file(name)
+	tmp, err := ioutil.TempFile(filepath.Dir(file), ".upload-*")
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(tmp, r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), os.ModePerm)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), file)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
 func (l *LocalStorage) Read(name string) (data []byte, err error) {
 	return ioutil.ReadFile(name)
 }
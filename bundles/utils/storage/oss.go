@@ -8,6 +8,7 @@ import (
 	"bytes"
 	"github.com/aliyun/aliyun-oss-go-sdk/oss"
 	"github.com/orivil/morgine/bundles/utils/ticker"
+	"io"
 	"io/ioutil"
 	"strings"
 	"sync"
@@ -49,6 +50,10 @@ func (os *OssStorage) Write(name string, data []byte) error {
 	return os.Bucket.PutObject(name, bytes.NewBuffer(data))
 }
 
+func (os *OssStorage) WriteStream(name string, r io.Reader) error {
+	return os.Bucket.PutObject(name, r)
+}
+
 func (os *OssStorage) Read(name string) (data []byte, err error) {
 	buf, err := os.Bucket.GetObject(name)
 	if err != nil {
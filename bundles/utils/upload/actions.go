@@ -0,0 +1,336 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package upload
+
+import (
+	"encoding/base64"
+	"github.com/orivil/morgine/xx"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// tus 协议版本及支持的扩展
+const (
+	TusVersion    = "1.0.0"
+	TusExtensions = "creation,checksum,expiration,termination"
+)
+
+// 协议请求头及响应头
+const (
+	HeaderTusResumable  = "Tus-Resumable"
+	HeaderTusVersion    = "Tus-Version"
+	HeaderTusExtension  = "Tus-Extension"
+	HeaderTusMaxSize    = "Tus-Max-Size"
+	HeaderTusChecksum   = "Tus-Checksum-Algorithm"
+	HeaderLength        = "Upload-Length"
+	HeaderOffset        = "Upload-Offset"
+	HeaderMetadata      = "Upload-Metadata"
+	HeaderChecksum      = "Upload-Checksum"
+	HeaderExpires       = "Upload-Expires"
+	HeaderUrl           = "Upload-Url"
+	OffsetOctetStream   = "application/offset+octet-stream"
+	StatusChecksumError = 460
+)
+
+// 上传任务 ID 路由参数
+type pathID struct {
+	ID string `param:"id" required:"upload id is required"`
+}
+
+// 解析 Upload-Metadata 请求头, 格式为逗号分隔的 "key base64(value)"
+func ParseMetadata(header string) map[string]string {
+	metadata := make(map[string]string)
+	for _, pair := range strings.Split(header, ",") {
+		parts := strings.SplitN(strings.TrimSpace(pair), " ", 2)
+		if parts[0] == "" {
+			continue
+		}
+		var value []byte
+		if len(parts) == 2 {
+			value, _ = base64.StdEncoding.DecodeString(parts[1])
+		}
+		metadata[parts[0]] = string(value)
+	}
+	return metadata
+}
+
+func encodeMetadata(metadata map[string]string) string {
+	var pairs []string
+	for key, value := range metadata {
+		pairs = append(pairs, key+" "+base64.StdEncoding.EncodeToString([]byte(value)))
+	}
+	return strings.Join(pairs, ",")
+}
+
+func sendError(ctx *xx.Context, code int, err error) {
+	http.Error(ctx.Writer, err.Error(), code)
+	ctx.Abort()
+}
+
+// 根据错误类型返回对应的状态码, 其他错误记录日志并返回 500
+func handleError(ctx *xx.Context, err error) {
+	switch err {
+	case ErrNotFound:
+		sendError(ctx, http.StatusNotFound, err)
+	case ErrLocked:
+		sendError(ctx, http.StatusLocked, err)
+	case ErrOffsetMismatch:
+		sendError(ctx, http.StatusConflict, err)
+	case ErrTooLarge:
+		sendError(ctx, http.StatusRequestEntityTooLarge, err)
+	case ErrChecksumInvalid:
+		sendError(ctx, http.StatusBadRequest, err)
+	case ErrChecksumMismatch:
+		sendError(ctx, StatusChecksumError, err)
+	default:
+		ctx.Error(err)
+	}
+}
+
+func (u *Uploader) writeHeaders(ctx *xx.Context, upload *Upload) {
+	header := ctx.Writer.Header()
+	header.Set(HeaderOffset, strconv.FormatInt(upload.Offset, 10))
+	header.Set(HeaderExpires, upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	if upload.Url != "" {
+		header.Set(HeaderUrl, upload.Url)
+	}
+}
+
+func tusHeaders(h http.Header) http.Header {
+	if h == nil {
+		h = http.Header{}
+	}
+	h.Set(HeaderTusResumable, TusVersion)
+	return h
+}
+
+// Options 获得服务端支持的协议版本、扩展及限制
+func (u *Uploader) Options() xx.Action {
+	return func(method, route string, controller *xx.Condition) {
+		headers := tusHeaders(http.Header {
+			HeaderTusVersion:   {TusVersion},
+			HeaderTusExtension: {TusExtensions},
+			HeaderTusChecksum:  {strings.Join(checksumAlgorithms(), ",")},
+		})
+		if u.MaxSize > 0 {
+			headers.Set(HeaderTusMaxSize, strconv.FormatInt(u.MaxSize, 10))
+		}
+		doc := &xx.Doc {
+			Title: "获得断点续传服务信息",
+			Responses: xx.Responses {
+				{Code: http.StatusNoContent, Headers: headers},
+			},
+		}
+		controller.Handle(method, route, doc, func(ctx *xx.Context) {
+			for key, values := range headers {
+				ctx.Writer.Header()[key] = values
+			}
+			ctx.Writer.WriteHeader(http.StatusNoContent)
+			ctx.Abort()
+		})
+	}
+}
+
+// Create 新建上传任务, Location 响应头为上传地址, 即当前路由加上任务 ID
+func (u *Uploader) Create() xx.Action {
+	return func(method, route string, controller *xx.Condition) {
+		type headers struct {
+			Length   int64  `param:"Upload-Length" required:"Upload-Length is required" num:"0<=x" desc:"文件总字节数"`
+			Metadata string `param:"Upload-Metadata" desc:"文件信息, 逗号分隔的 \"key base64(value)\", 如: filename d29ybGQucG5n"`
+		}
+		doc := &xx.Doc {
+			Title: "新建上传任务",
+			Desc:  "任务创建后通过 PATCH 请求上传数据, 长时间未上传数据的任务将被删除",
+			Params: xx.Params {
+				{Type: xx.Header, Schema: &headers{}},
+			},
+			Responses: xx.Responses {
+				{
+					Code: http.StatusCreated,
+					Headers: tusHeaders(http.Header {
+						"Location":    {route + "/e4d9b5c5f4a5c1f9cba1f07d6d7c2a3e"},
+						HeaderExpires: {"Wed, 25 Jun 2020 16:00:00 GMT"},
+					}),
+				},
+				xx.HttpErrorResponse("文件过大", ErrTooLarge.Error(), http.StatusRequestEntityTooLarge),
+			},
+		}
+		controller.Handle(method, route, doc, func(ctx *xx.Context) {
+			ctx.Writer.Header().Set(HeaderTusResumable, TusVersion)
+			h := &headers{}
+			err := ctx.Unmarshal(h)
+			if err != nil {
+				sendError(ctx, http.StatusBadRequest, err)
+				return
+			}
+			upload, err := u.NewUpload(h.Length, ParseMetadata(h.Metadata))
+			if err != nil {
+				handleError(ctx, err)
+				return
+			}
+			header := ctx.Writer.Header()
+			header.Set("Location", strings.TrimSuffix(ctx.Request.URL.Path, "/")+"/"+upload.ID)
+			header.Set(HeaderExpires, upload.ExpiresAt.UTC().Format(http.TimeFormat))
+			ctx.Writer.WriteHeader(http.StatusCreated)
+			ctx.Abort()
+		})
+	}
+}
+
+// Head 查询已上传的字节数, 上传完成后 Upload-Url 响应头为文件地址. 路由需包含 {id} 参数
+func (u *Uploader) Head() xx.Action {
+	return func(method, route string, controller *xx.Condition) {
+		doc := &xx.Doc {
+			Title: "查询上传进度",
+			Desc:  "客户端上传中断后通过 Upload-Offset 继续上传",
+			Params: xx.Params {
+				{Type: xx.Path, Schema: &pathID{}},
+			},
+			Responses: xx.Responses {
+				{
+					Code: http.StatusOK,
+					Headers: tusHeaders(http.Header {
+						HeaderOffset:    {"1048576"},
+						HeaderLength:    {"5242880"},
+						HeaderMetadata:  {"filename d29ybGQucG5n"},
+						HeaderExpires:   {"Wed, 25 Jun 2020 16:00:00 GMT"},
+						HeaderUrl:       {"http://static.example.com/e4d9b5c5f4a5c1f9cba1f07d6d7c2a3e.png"},
+						"Cache-Control": {"no-store"},
+					}),
+				},
+				{Code: http.StatusNotFound, Description: "任务不存在或已过期"},
+			},
+		}
+		controller.Handle(method, route, doc, func(ctx *xx.Context) {
+			header := ctx.Writer.Header()
+			header.Set(HeaderTusResumable, TusVersion)
+			header.Set("Cache-Control", "no-store")
+			p := &pathID{}
+			err := ctx.Unmarshal(p)
+			if err != nil {
+				sendError(ctx, http.StatusNotFound, ErrNotFound)
+				return
+			}
+			upload, err := u.GetUpload(p.ID)
+			if err != nil {
+				handleError(ctx, err)
+				return
+			}
+			u.writeHeaders(ctx, upload)
+			header.Set(HeaderLength, strconv.FormatInt(upload.Length, 10))
+			if len(upload.Metadata) > 0 {
+				header.Set(HeaderMetadata, encodeMetadata(upload.Metadata))
+			}
+			ctx.Writer.WriteHeader(http.StatusOK)
+			ctx.Abort()
+		})
+	}
+}
+
+// Patch 从 Upload-Offset 处上传数据块, 请求体为数据块内容. 路由需包含 {id} 参数
+func (u *Uploader) Patch() xx.Action {
+	return func(method, route string, controller *xx.Condition) {
+		type headers struct {
+			ContentType string `param:"Content-Type" desc:"数据块类型, 必须为 application/offset+octet-stream"`
+			Offset      int64  `param:"Upload-Offset" required:"Upload-Offset is required" num:"0<=x" desc:"数据块偏移量, 必须等于已上传的字节数"`
+			Checksum    string `param:"Upload-Checksum" desc:"数据块校验值, 如: sha1 Kq5sNclPz7QV2+lfQIuc6R7oRu0=, 校验失败时丢弃整个数据块"`
+		}
+		doc := &xx.Doc {
+			Title: "上传数据块",
+			Desc:  "上传完成后文件将保存到存储中, Upload-Url 响应头为文件地址",
+			Params: xx.Params {
+				{Type: xx.Path, Schema: &pathID{}},
+				{Type: xx.Header, Schema: &headers{}},
+			},
+			Responses: xx.Responses {
+				{
+					Code: http.StatusNoContent,
+					Headers: tusHeaders(http.Header {
+						HeaderOffset:  {"2097152"},
+						HeaderExpires: {"Wed, 25 Jun 2020 16:00:00 GMT"},
+					}),
+				},
+				xx.HttpErrorResponse("偏移量与已上传的字节数不一致", ErrOffsetMismatch.Error(), http.StatusConflict),
+				xx.HttpErrorResponse("任务正在上传中", ErrLocked.Error(), http.StatusLocked),
+				xx.HttpErrorResponse("数据块校验失败", ErrChecksumMismatch.Error(), StatusChecksumError),
+				xx.HttpErrorResponse("数据超出文件大小", ErrTooLarge.Error(), http.StatusRequestEntityTooLarge),
+			},
+		}
+		controller.Handle(method, route, doc, func(ctx *xx.Context) {
+			ctx.Writer.Header().Set(HeaderTusResumable, TusVersion)
+			p, h := &pathID{}, &headers{}
+			err := ctx.Unmarshal(p, h)
+			if err != nil {
+				sendError(ctx, http.StatusBadRequest, err)
+				return
+			}
+			if ct, _, _ := mime.ParseMediaType(h.ContentType); ct != OffsetOctetStream {
+				sendError(ctx, http.StatusUnsupportedMediaType, ErrContentType)
+				return
+			}
+			upload, err := u.WriteChunk(p.ID, &Chunk{Offset: h.Offset, Body: ctx.Request.Body, Checksum: h.Checksum})
+			if upload != nil {
+				u.writeHeaders(ctx, upload)
+			}
+			if err != nil {
+				handleError(ctx, err)
+				return
+			}
+			ctx.Writer.WriteHeader(http.StatusNoContent)
+			ctx.Abort()
+		})
+	}
+}
+
+// Delete 取消上传任务并删除已上传的数据. 路由需包含 {id} 参数
+func (u *Uploader) Delete() xx.Action {
+	return func(method, route string, controller *xx.Condition) {
+		doc := &xx.Doc {
+			Title: "取消上传任务",
+			Params: xx.Params {
+				{Type: xx.Path, Schema: &pathID{}},
+			},
+			Responses: xx.Responses {
+				{Code: http.StatusNoContent, Headers: tusHeaders(nil)},
+				{Code: http.StatusNotFound, Description: "任务不存在或已过期"},
+			},
+		}
+		controller.Handle(method, route, doc, func(ctx *xx.Context) {
+			ctx.Writer.Header().Set(HeaderTusResumable, TusVersion)
+			p := &pathID{}
+			err := ctx.Unmarshal(p)
+			if err != nil {
+				sendError(ctx, http.StatusNotFound, ErrNotFound)
+				return
+			}
+			err = u.DeleteUpload(p.ID)
+			if err != nil {
+				handleError(ctx, err)
+				return
+			}
+			ctx.Writer.WriteHeader(http.StatusNoContent)
+			ctx.Abort()
+		})
+	}
+}
+
+// Register 注册所有上传接口, 如 route 为 "/uploads" 时:
+//
+//	OPTIONS /uploads
+//	POST    /uploads
+//	HEAD    /uploads/{id}
+//	PATCH   /uploads/{id}
+//	DELETE  /uploads/{id}
+func (u *Uploader) Register(controller *xx.Condition, route string) {
+	route = strings.TrimSuffix(route, "/")
+	item := route + "/{id}"
+	u.Options()(http.MethodOptions, route, controller)
+	u.Create()(http.MethodPost, route, controller)
+	u.Head()(http.MethodHead, item, controller)
+	u.Patch()(http.MethodPatch, item, controller)
+	u.Delete()(http.MethodDelete, item, controller)
+}
@@ -0,0 +1,409 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+// upload 为可断点续传的分块上传(兼容 tus 1.0.0 协议的 creation, checksum, expiration 及 termination 扩展).
+// 客户端先创建上传任务, 再通过 PATCH 请求从指定偏移量开始上传数据块, 网络中断后通过 HEAD 请求查询已上传的偏移量继续上传.
+// 上传中的数据保存在本地临时目录, 上传完成后保存到存储中, 如:
+//
+//	uploader := upload.NewUploader("temp/uploads", store)
+//	uploader.RunExpiration(hour.Runner)
+//	uploader.Register(controller, "/uploads")
+package upload
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/orivil/morgine/bundles/utils/storage"
+	"github.com/orivil/morgine/log"
+	"github.com/orivil/morgine/utils/timer"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNotFound         = errors.New("upload not found")
+	ErrLocked           = errors.New("upload is in progress")
+	ErrOffsetMismatch   = errors.New("upload offset mismatch")
+	ErrTooLarge         = errors.New("upload too large")
+	ErrChecksumMismatch = errors.New("upload checksum mismatch")
+	ErrChecksumInvalid  = errors.New("upload checksum invalid")
+	ErrContentType      = errors.New("content type should be application/offset+octet-stream")
+)
+
+// 支持的校验算法, 客户端通过 "Upload-Checksum: sha1 <base64 digest>" 请求头提交数据块的校验值
+var ChecksumAlgorithms = map[string]func() hash.Hash {
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+}
+
+// Upload 为上传任务
+type Upload struct {
+	ID        string
+	Length    int64             // 文件总字节数
+	Offset    int64             `json:"-"` // 已上传字节数
+	Metadata  map[string]string // 客户端提交的文件信息, 如: filename
+	ExpiresAt time.Time         // 过期时间, 过期未完成的任务将被删除
+
+	// 上传完成后保存到存储中的文件名及服务地址
+	Name string `json:",omitempty"`
+	Url  string `json:",omitempty"`
+}
+
+// IsComplete 检测文件是否已保存到存储中
+func (u *Upload) IsComplete() bool {
+	return u.Name != ""
+}
+
+// Uploader 管理上传任务
+type Uploader struct {
+	// 临时目录, 保存上传中的数据及任务信息
+	Dir string
+
+	// 保存上传文件的存储, 实现了 storage.StreamStorage 时文件将以流的方式写入, 否则会先读入内存
+	Storage storage.Storage
+
+	// 文件最大字节数, 为 0 时不限制
+	MaxSize int64
+
+	// 任务过期时间, 每次上传数据块后重新计算
+	Expiration time.Duration
+
+	// 获得保存到存储中的文件名, 默认为任务 ID 加上 filename 的后缀名
+	Name func(u *Upload) string
+
+	// 文件保存到存储中之后调用, 可用于保存文件记录
+	OnComplete func(u *Upload) error
+
+	mu    sync.Mutex
+	locks map[string]struct{}
+}
+
+// NewUploader 新建上传管理器, 任务默认 24 小时过期
+func NewUploader(dir string, s storage.Storage) *Uploader {
+	return &Uploader {
+		Dir:        dir,
+		Storage:    s,
+		Expiration: 24 * time.Hour,
+		locks:      make(map[string]struct{}),
+	}
+}
+
+func (u *Uploader) infoFile(id string) string {
+	return filepath.Join(u.Dir, id+".info")
+}
+
+func (u *Uploader) dataFile(id string) string {
+	return filepath.Join(u.Dir, id+".bin")
+}
+
+// 锁定任务, 同一任务同时只允许一个请求写入
+func (u *Uploader) lock(id string) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if _, ok := u.locks[id]; ok {
+		return false
+	}
+	u.locks[id] = struct{}{}
+	return true
+}
+
+func (u *Uploader) unlock(id string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	delete(u.locks, id)
+}
+
+func newID() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// NewUpload 新建上传任务
+func (u *Uploader) NewUpload(length int64, metadata map[string]string) (*Upload, error) {
+	if length < 0 || (u.MaxSize > 0 && length > u.MaxSize) {
+		return nil, ErrTooLarge
+	}
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+	err = os.MkdirAll(u.Dir, os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+	err = ioutil.WriteFile(u.dataFile(id), nil, 0666)
+	if err != nil {
+		return nil, err
+	}
+	upload := &Upload {
+		ID:        id,
+		Length:    length,
+		Metadata:  metadata,
+		ExpiresAt: time.Now().Add(u.Expiration),
+	}
+	err = u.save(upload)
+	if err != nil {
+		os.Remove(u.dataFile(id))
+		return nil, err
+	}
+	return upload, nil
+}
+
+func (u *Uploader) save(upload *Upload) error {
+	data, err := json.Marshal(upload)
+	if err != nil {
+		return err
+	}
+	tmp := u.infoFile(upload.ID) + ".tmp"
+	err = ioutil.WriteFile(tmp, data, 0666)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, u.infoFile(upload.ID))
+}
+
+// GetUpload 获得上传任务, 已上传字节数以临时文件的大小为准
+func (u *Uploader) GetUpload(id string) (*Upload, error) {
+	if id == "" || strings.ContainsAny(id, `/\.`) {
+		return nil, ErrNotFound
+	}
+	data, err := ioutil.ReadFile(u.infoFile(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	upload := &Upload{}
+	err = json.Unmarshal(data, upload)
+	if err != nil {
+		return nil, err
+	}
+	if upload.IsComplete() {
+		upload.Offset = upload.Length
+		return upload, nil
+	}
+	stat, err := os.Stat(u.dataFile(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	upload.Offset = stat.Size()
+	return upload, nil
+}
+
+// Chunk 为上传的数据块
+type Chunk struct {
+	Offset int64
+	Body   io.Reader
+
+	// 校验值, 格式为 "<算法> <base64 校验值>", 为空时不校验. 校验失败时丢弃整个数据块,
+	// 不校验时即使连接中断, 已接收的数据也会被保留
+	Checksum string
+}
+
+// WriteChunk 从 chunk.Offset 处写入数据块, 返回写入后的任务. 数据全部上传后将文件保存到存储中
+func (u *Uploader) WriteChunk(id string, chunk *Chunk) (*Upload, error) {
+	if !u.lock(id) {
+		return nil, ErrLocked
+	}
+	defer u.unlock(id)
+	upload, err := u.GetUpload(id)
+	if err != nil {
+		return nil, err
+	}
+	if upload.IsComplete() || chunk.Offset != upload.Offset {
+		return upload, ErrOffsetMismatch
+	}
+	var h hash.Hash
+	var sum []byte
+	if chunk.Checksum != "" {
+		h, sum, err = parseChecksum(chunk.Checksum)
+		if err != nil {
+			return upload, err
+		}
+	}
+	file, err := os.OpenFile(u.dataFile(id), os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		return upload, err
+	}
+	var w io.Writer = file
+	if h != nil {
+		w = io.MultiWriter(file, h)
+	}
+	remain := upload.Length - upload.Offset
+	n, err := io.Copy(w, io.LimitReader(chunk.Body, remain+1))
+	if err == nil && n > remain {
+		err = ErrTooLarge
+	}
+	if err == nil && h != nil && string(h.Sum(nil)) != string(sum) {
+		err = ErrChecksumMismatch
+	}
+	if err != nil && (h != nil || err == ErrTooLarge) {
+		// 丢弃整个数据块
+		if e := file.Truncate(upload.Offset); e != nil {
+			log.Error.Println(e)
+		}
+		n = 0
+	}
+	if e := file.Close(); e != nil && err == nil {
+		err = e
+	}
+	upload.Offset += n
+	if n > 0 {
+		upload.ExpiresAt = time.Now().Add(u.Expiration)
+		if e := u.save(upload); e != nil && err == nil {
+			err = e
+		}
+	}
+	if err != nil {
+		return upload, err
+	}
+	if upload.Offset == upload.Length {
+		err = u.complete(upload)
+	}
+	return upload, err
+}
+
+func parseChecksum(checksum string) (hash.Hash, []byte, error) {
+	parts := strings.SplitN(strings.TrimSpace(checksum), " ", 2)
+	if len(parts) != 2 {
+		return nil, nil, ErrChecksumInvalid
+	}
+	newHash, ok := ChecksumAlgorithms[parts[0]]
+	if !ok {
+		return nil, nil, ErrChecksumInvalid
+	}
+	sum, err := base64.StdEncoding.DecodeString(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return nil, nil, ErrChecksumInvalid
+	}
+	return newHash(), sum, nil
+}
+
+// 获得支持的校验算法列表
+func checksumAlgorithms() []string {
+	var algs []string
+	for alg := range ChecksumAlgorithms {
+		algs = append(algs, alg)
+	}
+	sort.Strings(algs)
+	return algs
+}
+
+func (u *Uploader) objectName(upload *Upload) string {
+	if u.Name != nil {
+		return u.Name(upload)
+	}
+	return upload.ID + strings.ToLower(filepath.Ext(upload.Metadata["filename"]))
+}
+
+// 将上传完成的文件保存到存储中, 任务信息保留到过期时间, 以便客户端查询文件地址
+func (u *Uploader) complete(upload *Upload) error {
+	name := u.objectName(upload)
+	file, err := os.Open(u.dataFile(upload.ID))
+	if err != nil {
+		return err
+	}
+	err = storage.WriteStream(u.Storage, name, file)
+	file.Close()
+	if err != nil {
+		return err
+	}
+	upload.Url, err = u.Storage.GetServeUrl(name)
+	if err != nil {
+		return err
+	}
+	upload.Name = name
+	err = u.save(upload)
+	if err != nil {
+		return err
+	}
+	os.Remove(u.dataFile(upload.ID))
+	if u.OnComplete != nil {
+		return u.OnComplete(upload)
+	}
+	return nil
+}
+
+// DeleteUpload 删除上传任务及已上传的数据, 不会删除已保存到存储中的文件
+func (u *Uploader) DeleteUpload(id string) error {
+	if !u.lock(id) {
+		return ErrLocked
+	}
+	defer u.unlock(id)
+	if _, err := u.GetUpload(id); err != nil {
+		return err
+	}
+	return u.remove(id)
+}
+
+func (u *Uploader) remove(id string) error {
+	err := os.Remove(u.dataFile(id))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.Remove(u.infoFile(id))
+}
+
+// Expire 删除 now 之前过期的任务, 删除出错时继续删除其他任务, 并返回所有错误
+func (u *Uploader) Expire(now time.Time) error {
+	infos, err := filepath.Glob(filepath.Join(u.Dir, "*.info"))
+	if err != nil {
+		return err
+	}
+	var errs []string
+	for _, info := range infos {
+		id := strings.TrimSuffix(filepath.Base(info), ".info")
+		if !u.lock(id) {
+			continue
+		}
+		upload, err := u.GetUpload(id)
+		if err == nil && upload.ExpiresAt.Before(now) {
+			err = u.remove(id)
+		} else if err == ErrNotFound {
+			err = os.Remove(info)
+		}
+		u.unlock(id)
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// RunExpiration 定时删除过期的任务, 如:
+//
+//	uploader.RunExpiration(hour.Runner)
+func (u *Uploader) RunExpiration(runner *timer.TickerRunner) {
+	runner.AddCallback(func(now *time.Time) {
+		err := u.Expire(*now)
+		if err != nil {
+			log.Error.Println(err)
+		}
+	})
+}
@@ -0,0 +1,197 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package upload_test
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"github.com/orivil/morgine/bundles/utils/upload"
+	"github.com/orivil/morgine/xx"
+	"github.com/orivil/morgine/xx/xxtest"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type memStorage map[string][]byte
+
+func (m memStorage) Write(name string, data []byte) error {
+	m[name] = data
+	return nil
+}
+
+func (m memStorage) IsExist(name string) (bool, error) {
+	_, ok := m[name]
+	return ok, nil
+}
+
+func (m memStorage) Read(name string) ([]byte, error) {
+	return m[name], nil
+}
+
+func (m memStorage) Remove(name string) error {
+	delete(m, name)
+	return nil
+}
+
+func (m memStorage) GetServeUrl(name string) (string, error) {
+	return "http://static/" + name, nil
+}
+
+func newServer(t *testing.T) (*xx.ServeMux, *upload.Uploader, memStorage, func()) {
+	dir, err := ioutil.TempDir("", "upload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := memStorage{}
+	u := upload.NewUploader(dir, store)
+	u.MaxSize = 100
+	mux, controller := xxtest.NewMux("uploads")
+	u.Register(controller, "/uploads")
+	return mux, u, store, func() { os.RemoveAll(dir) }
+}
+
+func serve(mux *xx.ServeMux, method, path string, body io.Reader, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, body)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	return xxtest.Serve(mux, req)
+}
+
+func patch(mux *xx.ServeMux, location string, offset int, chunk, checksum string) *httptest.ResponseRecorder {
+	headers := map[string]string {
+		"Content-Type":  upload.OffsetOctetStream,
+		"Upload-Offset": strconv.Itoa(offset),
+	}
+	if checksum != "" {
+		headers["Upload-Checksum"] = checksum
+	}
+	return serve(mux, http.MethodPatch, location, strings.NewReader(chunk), headers)
+}
+
+func sha1Sum(data string) string {
+	sum := sha1.Sum([]byte(data))
+	return "sha1 " + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func TestUploader_Resume(t *testing.T) {
+	mux, _, store, clean := newServer(t)
+	defer clean()
+
+	res := serve(mux, http.MethodPost, "/uploads", nil, map[string]string {
+		"Upload-Length":   "11",
+		"Upload-Metadata": "filename " + base64.StdEncoding.EncodeToString([]byte("hello.TXT")),
+	})
+	location := res.Header().Get("Location")
+	if res.Code != http.StatusCreated || !strings.HasPrefix(location, "/uploads/") {
+		t.Fatalf("need created, got: %d %s", res.Code, res.Body)
+	}
+	if res := serve(mux, http.MethodPost, "/uploads", nil, map[string]string{"Upload-Length": "101"}); res.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("need 413, got: %d", res.Code)
+	}
+
+	cases := []struct {
+		offset          int
+		chunk, checksum string
+		code            int
+		newOffset       string
+	}{
+		{0, "hello", "", http.StatusNoContent, "5"},
+		{0, "hello", "", http.StatusConflict, "5"},
+		{5, " wor", sha1Sum("other"), upload.StatusChecksumError, "5"},
+		{5, " wor", "crc32 xxx", http.StatusBadRequest, "5"},
+		{5, " wor", sha1Sum(" wor"), http.StatusNoContent, "9"},
+		{9, "ld and more", "", http.StatusRequestEntityTooLarge, "9"},
+		{9, "ld", "", http.StatusNoContent, "11"},
+		{11, "", "", http.StatusConflict, "11"},
+	}
+	for i, c := range cases {
+		res := patch(mux, location, c.offset, c.chunk, c.checksum)
+		if res.Code != c.code || res.Header().Get(upload.HeaderOffset) != c.newOffset {
+			t.Errorf("case %d: need %d offset %s, got: %d offset %s", i, c.code, c.newOffset, res.Code, res.Header().Get(upload.HeaderOffset))
+		}
+	}
+	if res := serve(mux, http.MethodPatch, location, strings.NewReader("x"), map[string]string{"Upload-Offset": "0"}); res.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("need 415, got: %d", res.Code)
+	}
+
+	id := strings.TrimPrefix(location, "/uploads/")
+	if got := string(store[id+".txt"]); got != "hello world" {
+		t.Errorf("need stored file, got: %q", got)
+	}
+	res = serve(mux, http.MethodHead, location, nil, nil)
+	if res.Code != http.StatusOK || res.Header().Get(upload.HeaderUrl) != "http://static/"+id+".txt" || res.Header().Get(upload.HeaderLength) != "11" {
+		t.Errorf("need completed upload, got: %d %v", res.Code, res.Header())
+	}
+	if res := serve(mux, http.MethodHead, "/uploads/notexist", nil, nil); res.Code != http.StatusNotFound {
+		t.Errorf("need 404, got: %d", res.Code)
+	}
+}
+
+func TestUploader_Expire(t *testing.T) {
+	mux, u, _, clean := newServer(t)
+	defer clean()
+	res := serve(mux, http.MethodPost, "/uploads", nil, map[string]string{"Upload-Length": "10"})
+	location := res.Header().Get("Location")
+	patch(mux, location, 0, "abc", "")
+
+	if err := u.Expire(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if res := serve(mux, http.MethodHead, location, nil, nil); res.Header().Get(upload.HeaderOffset) != "3" {
+		t.Errorf("need unexpired upload, got: %d", res.Code)
+	}
+	if err := u.Expire(time.Now().Add(u.Expiration + time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if res := serve(mux, http.MethodHead, location, nil, nil); res.Code != http.StatusNotFound {
+		t.Errorf("need expired upload removed, got: %d", res.Code)
+	}
+
+	res = serve(mux, http.MethodPost, "/uploads", nil, map[string]string{"Upload-Length": "10"})
+	location = res.Header().Get("Location")
+	if res := serve(mux, http.MethodDelete, location, nil, nil); res.Code != http.StatusNoContent {
+		t.Errorf("need deleted, got: %d", res.Code)
+	}
+	if res := patch(mux, location, 0, "abc", ""); res.Code != http.StatusNotFound {
+		t.Errorf("need 404 after delete, got: %d", res.Code)
+	}
+	if res := serve(mux, http.MethodOptions, "/uploads", nil, nil); res.Header().Get(upload.HeaderTusChecksum) != "md5,sha1,sha256" {
+		t.Errorf("need checksum algorithms, got: %v", res.Header())
+	}
+}
+
+func TestUploader_ExpireContinuesPastErrors(t *testing.T) {
+	mux, u, _, clean := newServer(t)
+	defer clean()
+	var locations []string
+	for i := 0; i < 2; i++ {
+		res := serve(mux, http.MethodPost, "/uploads", nil, map[string]string{"Upload-Length": "10"})
+		locations = append(locations, res.Header().Get("Location"))
+	}
+	// 数据文件替换为非空目录, 使该任务删除失败
+	data := filepath.Join(u.Dir, path.Base(locations[0])+".bin")
+	if err := os.Remove(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(data, "sub"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.Expire(time.Now().Add(u.Expiration + time.Minute)); err == nil {
+		t.Error("need remove error")
+	}
+	if res := serve(mux, http.MethodHead, locations[1], nil, nil); res.Code != http.StatusNotFound {
+		t.Errorf("need other expired upload removed, got: %d", res.Code)
+	}
+}
@@ -2,14 +2,12 @@
 // Use of this source code is governed by a MIT-style
 // license that can be found at https://mit-license.org.
 
+// 管理员账号及子账号层级(CreateSubAdmin, IsSubAdmin, Forefather 等)已由 bundles/admin/model 实现, 见 hierarchy.go
 package api
 
 import (
 	"github.com/jinzhu/gorm"
-	"github.com/orivil/morgine/components/admin/models"
-	"github.com/orivil/morgine/components/admin/models/db"
 	"github.com/orivil/morgine/log"
-	"github.com/orivil/morgine/utils/sql"
 )
 
 // TODO 测试该方法
@@ -19,7 +17,3 @@ func IsIDExist(condition *gorm.DB) bool {
 	log.Error.Println("测试 IsIDExist", len(ids) > 0)
 	return len(ids) > 0
 }
-
-func IsSuperAdmin(adminID int) bool {
-	return IsIDExist(db.DB.Model(&models.Admin{}).Where("id=? AND super=?", adminID, sql.True))
-}
\ No newline at end of file
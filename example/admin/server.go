@@ -24,9 +24,6 @@ auth_key: "change this pass"
 # 授权过期时间/小时
 auth_expire_hour: 168
 
-# casbin 权限模型文件
-auth_model_file: "configs/rbac_model.conf"
-
 # 开启日志
 db_log: true
 
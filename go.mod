@@ -3,11 +3,18 @@ module github.com/orivil/morgine
 go 1.13
 
 require (
-	github.com/dgrijalva/jwt-go v3.2.0+incompatible // indirect
-	github.com/go-redis/redis v6.15.7+incompatible // indirect
+	github.com/alicebob/gopher-json v0.0.0-20180125190556-5a6b3ba71ee6 // indirect
+	github.com/alicebob/miniredis v2.5.0+incompatible
+	github.com/dgrijalva/jwt-go v3.2.0+incompatible
+	github.com/go-redis/redis v6.15.7+incompatible
+	github.com/go-sql-driver/mysql v1.4.1
+	github.com/gomodule/redigo v2.0.0+incompatible // indirect
 	github.com/jinzhu/gorm v1.9.12
-	gopkg.in/yaml.v2 v2.2.8
+	github.com/lib/pq v1.1.1
 	github.com/pkg/errors v0.8.1
-	golang.org/x/time v0.0.0-20191024005414-555d28b269f0
+	github.com/yuin/gopher-lua v0.0.0-20190514113301-1cd887cd7036 // indirect
 	golang.org/x/crypto v0.0.0-20200320181102-891825fb96df
+	golang.org/x/image v0.0.0-20200119044424-58c23975cae1
+	golang.org/x/time v0.0.0-20191024005414-555d28b269f0
+	gopkg.in/yaml.v2 v2.2.8
 )
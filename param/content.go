@@ -0,0 +1,209 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package param
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// 文件内容验证标签, 不依赖客户端提交的 Content-Type 及文件后缀名
+const (
+	TagFileSniff = "sniff"      // 根据文件内容(magic bytes)检测的文件类型, 如: sniff:"image/jpeg image/png" 或 sniff:"image"
+	TagMinWidth  = "min-width"  // 图片最小宽度(像素)
+	TagMaxWidth  = "max-width"  // 图片最大宽度(像素)
+	TagMinHeight = "min-height" // 图片最小高度(像素)
+	TagMaxHeight = "max-height" // 图片最大高度(像素)
+	TagRatio     = "ratio"      // 图片宽高比, 误差不超过 1%, 如: ratio:"16:9" 或 ratio:"1.5"
+	TagImage     = "image"      // 图片条件的错误信息标签名, 如: image-msg:"图片尺寸不正确"
+)
+
+// 检测图片尺寸时最多读取的字节数, 超出则认为不是有效的图片
+var MaxImageHeaderBytes = int64(1 << 20) // 1MB
+
+// 检测文件类型需要读取的字节数
+const sniffLen = 512
+
+// 以文件内容检测文件类型, types 可以只包含主类型, 如: image
+func (c *condition) FileSniff(types []string, msg string) *condition {
+	if msg == "" {
+		msg = fmt.Sprintf("file content types %v", types)
+	}
+	c.fileSniffTypes = types
+	c.fileSniffMsgID = &msg
+	return c
+}
+
+// 限制图片宽度, 0 表示不限制
+func (c *condition) ImageWidth(min, max int, msg string) *condition {
+	if min > 0 {
+		c.minWidth = &min
+	}
+	if max > 0 {
+		c.maxWidth = &max
+	}
+	return c.imageMsg(msg)
+}
+
+// 限制图片高度, 0 表示不限制
+func (c *condition) ImageHeight(min, max int, msg string) *condition {
+	if min > 0 {
+		c.minHeight = &min
+	}
+	if max > 0 {
+		c.maxHeight = &max
+	}
+	return c.imageMsg(msg)
+}
+
+// 限制图片宽高比, 如: "16:9" 或 "1.5"
+func (c *condition) ImageRatio(ratio, msg string) *condition {
+	value, err := parseRatio(ratio)
+	if err != nil {
+		panic(err)
+	}
+	c.ratio = &ratio
+	c.ratioValue = value
+	return c.imageMsg(msg)
+}
+
+func (c *condition) imageMsg(msg string) *condition {
+	if msg != "" {
+		c.imageMsgID = &msg
+	} else if c.imageMsgID == nil {
+		msg = "image dimensions incorrect"
+		c.imageMsgID = &msg
+	}
+	return c
+}
+
+func parseRatio(ratio string) (float64, error) {
+	parts := strings.SplitN(ratio, ":", 2)
+	w, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	h := 1.0
+	if err == nil && len(parts) == 2 {
+		h, err = strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	}
+	if err != nil || w <= 0 || h <= 0 {
+		return 0, fmt.Errorf("invalid image ratio '%s'", ratio)
+	}
+	return w / h, nil
+}
+
+func (c *condition) syntaxContent(tag reflect.StructTag) error {
+	if syntax, ok := tag.Lookup(TagFileSniff); ok {
+		types := strings.Split(syntax, Separator)
+		for key, typ := range types {
+			types[key] = strings.TrimSpace(typ)
+		}
+		c.FileSniff(types, tag.Get(MsgName(TagFileSniff)))
+	}
+	msg := tag.Get(MsgName(TagImage))
+	sizes := []struct {
+		tag string
+		set func(n int)
+	}{
+		{TagMinWidth, func(n int) { c.ImageWidth(n, 0, msg) }},
+		{TagMaxWidth, func(n int) { c.ImageWidth(0, n, msg) }},
+		{TagMinHeight, func(n int) { c.ImageHeight(n, 0, msg) }},
+		{TagMaxHeight, func(n int) { c.ImageHeight(0, n, msg) }},
+	}
+	for _, size := range sizes {
+		if syntax, ok := tag.Lookup(size.tag); ok {
+			n, err := strconv.Atoi(strings.TrimSpace(syntax))
+			if err != nil || n <= 0 {
+				return fmt.Errorf("%s: need positive integer, got '%s'", size.tag, syntax)
+			}
+			size.set(n)
+		}
+	}
+	if syntax, ok := tag.Lookup(TagRatio); ok {
+		if _, err := parseRatio(syntax); err != nil {
+			return err
+		}
+		c.ImageRatio(syntax, msg)
+	}
+	return nil
+}
+
+func (c *condition) hasImageCondition() bool {
+	return c.minWidth != nil || c.maxWidth != nil || c.minHeight != nil || c.maxHeight != nil || c.ratio != nil
+}
+
+// 读取文件头部, 验证文件内容类型及图片尺寸, 返回可以重新读取完整文件内容的 reader
+func (c *condition) validFileContent(field string, r io.Reader) (io.Reader, error) {
+	if len(c.fileSniffTypes) == 0 && !c.hasImageCondition() {
+		return r, nil
+	}
+	buf := &bytes.Buffer{}
+	tee := io.TeeReader(io.LimitReader(r, MaxImageHeaderBytes), buf)
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(tee, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	head = head[:n]
+	if len(c.fileSniffTypes) > 0 {
+		contentType := http.DetectContentType(head)
+		if idx := strings.Index(contentType, ";"); idx > 0 {
+			contentType = contentType[:idx]
+		}
+		if !matchMimeType(c.fileSniffTypes, contentType) {
+			return nil, &ValidatorErr{Field: field, Message: *c.fileSniffMsgID, Kind: ConditionFileContent, Enums: c.fileSniffTypes}
+		}
+	}
+	if c.hasImageCondition() {
+		config, _, err := image.DecodeConfig(io.MultiReader(bytes.NewReader(head), tee))
+		if err != nil || !c.validImageSize(config.Width, config.Height) {
+			return nil, &ValidatorErr{Field: field, Message: *c.imageMsgID, Kind: ConditionImage}
+		}
+	}
+	return io.MultiReader(buf, r), nil
+}
+
+func (c *condition) validImageSize(width, height int) bool {
+	if (c.minWidth != nil && width < *c.minWidth) || (c.maxWidth != nil && width > *c.maxWidth) {
+		return false
+	}
+	if (c.minHeight != nil && height < *c.minHeight) || (c.maxHeight != nil && height > *c.maxHeight) {
+		return false
+	}
+	if c.ratio != nil {
+		if height == 0 || math.Abs(float64(width)/float64(height)-c.ratioValue) > c.ratioValue*0.01 {
+			return false
+		}
+	}
+	return true
+}
+
+// 验证已缓存文件的内容
+func (c *condition) validFileContents(field string, form *multipart.Form) error {
+	if len(c.fileSniffTypes) == 0 && !c.hasImageCondition() {
+		return nil
+	}
+	for _, header := range form.File[field] {
+		file, err := header.Open()
+		if err != nil {
+			return err
+		}
+		_, err = c.validFileContent(field, file)
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
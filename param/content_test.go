@@ -0,0 +1,137 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package param_test
+
+import (
+	"bytes"
+	"github.com/orivil/morgine/param"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"mime/multipart"
+	"testing"
+	"unsafe"
+)
+
+func newPNG(t *testing.T, width, height int) string {
+	buf := &bytes.Buffer{}
+	err := png.Encode(buf, image.NewRGBA(image.Rect(0, 0, width, height)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+type avatar struct {
+	Image param.FileHandler   `param:"image" sniff:"image/png image/jpeg" min-width:"10" max-width:"100" ratio:"1:1" image-msg:"avatar incorrect"`
+	Photo param.StreamHandler `param:"photo" sniff:"image" max-height:"50"`
+}
+
+func TestSchema_ParseContent(t *testing.T) {
+	schema := param.MustNewSchema(&avatar{}, nil, nil)
+	cases := []struct {
+		field, content string
+		kind           param.ConditionKind
+	}{
+		{"image", newPNG(t, 20, 20), -1},
+		{"image", "plain text pretending to be png", param.ConditionFileContent},
+		{"image", newPNG(t, 5, 5), param.ConditionImage},
+		{"image", newPNG(t, 200, 200), param.ConditionImage},
+		{"image", newPNG(t, 40, 20), param.ConditionImage},
+		{"photo", newPNG(t, 80, 50), -1},
+		{"photo", newPNG(t, 80, 51), param.ConditionImage},
+	}
+	for i, c := range cases {
+		var saved []byte
+		a := &avatar {
+			Image: func(field string, header *multipart.FileHeader) error {
+				file, err := header.Open()
+				if err != nil {
+					return err
+				}
+				defer file.Close()
+				saved, err = ioutil.ReadAll(file)
+				return err
+			},
+			Photo: func(field string, file *param.StreamFile) (err error) {
+				saved, err = ioutil.ReadAll(file)
+				return err
+			},
+		}
+		// 客户端提交的文件类型及后缀名与文件内容无关
+		body, boundary := newMultipart(t, []part{{c.field, "a.png", c.content}})
+		for _, stream := range []bool{false, true} {
+			saved = nil
+			var err error
+			if stream && c.field == "photo" {
+				err = schema.ParseStream(uintptr(unsafe.Pointer(a)), multipart.NewReader(bytes.NewReader(body.Bytes()), boundary))
+			} else {
+				var form *multipart.Form
+				form, err = multipart.NewReader(bytes.NewReader(body.Bytes()), boundary).ReadForm(1 << 20)
+				if err != nil {
+					t.Fatal(err)
+				}
+				err = schema.Parse(uintptr(unsafe.Pointer(a)), form)
+			}
+			if c.kind < 0 {
+				if err != nil || string(saved) != c.content {
+					t.Errorf("case %d: need saved file, got: %v", i, err)
+				}
+			} else if ve, ok := err.(*param.ValidatorErr); !ok || ve.Kind != c.kind || saved != nil {
+				t.Errorf("case %d: need %s error, got: %v", i, param.Conditions[c.kind], err)
+			}
+		}
+	}
+}
+
+func TestNewSchema_ContentSyntax(t *testing.T) {
+	type invalid struct {
+		Image param.FileHandler `ratio:"16:0"`
+	}
+	if _, err := param.NewSchema(&invalid{}, nil, nil); err == nil {
+		t.Error("need ratio syntax error")
+	}
+	i := &invalid{}
+	v := param.NewValidator(i)
+	err := v.Field(&i.Image).Syntax(`sniff:"image" min-width:"100" max-width:"100" size-KB:"0-500"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := param.MustNewSchema(i, v, nil)
+	if c := schema.Fields[0].Condition; c == nil || *c.MinWidth != 100 || c.FileSniffTypes[0] != "image" {
+		t.Errorf("need image condition in document, got: %+v", c)
+	}
+}
+
+func TestValidFile(t *testing.T) {
+	syntax := `sniff:"image" min-width:"10" size-KB:"0-1"`
+	cases := []struct {
+		content string
+		kind    param.ConditionKind
+	}{
+		{newPNG(t, 20, 20), -1},
+		{newPNG(t, 5, 5), param.ConditionImage},
+		{"plain text pretending to be png", param.ConditionFileContent},
+		{newPNG(t, 200, 200) + string(make([]byte, 1<<10)), param.ConditionFileSize},
+	}
+	for i, c := range cases {
+		body, boundary := newMultipart(t, []part{{"image", "a.png", c.content}})
+		form, err := multipart.NewReader(bytes.NewReader(body.Bytes()), boundary).ReadForm(1 << 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = param.ValidFile("image", form, syntax)
+		if c.kind < 0 {
+			if err != nil {
+				t.Errorf("case %d: need no error, got: %v", i, err)
+			}
+		} else if ve, ok := err.(*param.ValidatorErr); !ok || ve.Kind != c.kind {
+			t.Errorf("case %d: need %s error, got: %v", i, param.Conditions[c.kind], err)
+		}
+	}
+	if err := param.ValidFile("image", &multipart.Form{}, `ratio:"16:0"`); err == nil {
+		t.Error("need ratio syntax error")
+	}
+}
@@ -12,3 +12,19 @@ type FileHandler func(field string, header *multipart.FileHeader) error
 func (h FileHandler) MarshalJSON() ([]byte, error) {
 	return []byte(`""`), nil
 }
+
+// ValidFile 以标签语法验证表单中的上传文件, 用于验证条件在运行时才能确定的场景, 如:
+//
+//	err := param.ValidFile("image", ctx.MultipartForm(), label.Conditions())
+func ValidFile(field string, form *multipart.Form, syntax string) error {
+	c := &condition{}
+	err := c.Syntax(syntax)
+	if err != nil {
+		return err
+	}
+	err = c.validFile(field, form)
+	if err != nil {
+		return err
+	}
+	return c.validFileContents(field, form)
+}
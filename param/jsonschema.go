@@ -0,0 +1,486 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package param
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// JSON Schema 版本
+const JSONSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// Go time.Duration 字符串格式, 如: "1h30m"
+const durationPattern = `^[-+]?([0-9]*(\.[0-9]*)?(ns|us|µs|ms|s|m|h))+$`
+
+// JSONSchema 为 JSON Schema (draft 2020-12) 文档, 以 "x-" 开头的为无法用标准关键字描述的扩展条件
+type JSONSchema struct {
+	Schema      string      `json:"$schema,omitempty"`
+	Title       string      `json:"title,omitempty"`
+	Description string      `json:"description,omitempty"`
+	Type        string      `json:"type,omitempty"`
+	Format      string      `json:"format,omitempty"`
+	Default     interface{} `json:"default,omitempty"`
+	Enum        []interface{} `json:"enum,omitempty"`
+	Const       interface{} `json:"const,omitempty"`
+	WriteOnly   bool        `json:"writeOnly,omitempty"`
+
+	Minimum          *float64 `json:"minimum,omitempty"`
+	ExclusiveMinimum *float64 `json:"exclusiveMinimum,omitempty"`
+	Maximum          *float64 `json:"maximum,omitempty"`
+	ExclusiveMaximum *float64 `json:"exclusiveMaximum,omitempty"`
+
+	MinLength *int   `json:"minLength,omitempty"`
+	MaxLength *int   `json:"maxLength,omitempty"`
+	Pattern   string `json:"pattern,omitempty"`
+
+	ContentMediaType string `json:"contentMediaType,omitempty"`
+
+	Items    *JSONSchema `json:"items,omitempty"`
+	MinItems *int        `json:"minItems,omitempty"`
+	MaxItems *int        `json:"maxItems,omitempty"`
+
+	Properties           Properties          `json:"properties,omitempty"`
+	AdditionalProperties *JSONSchema         `json:"additionalProperties,omitempty"`
+	Required             []string            `json:"required,omitempty"`
+	DependentRequired    map[string][]string `json:"dependentRequired,omitempty"`
+
+	If    *JSONSchema   `json:"if,omitempty"`
+	Then  *JSONSchema   `json:"then,omitempty"`
+	AllOf []*JSONSchema `json:"allOf,omitempty"`
+
+	// 文件条件
+	File *FileSchema `json:"x-file,omitempty"`
+
+	// 自定义验证规则
+	Rules []*RuleCall `json:"x-rules,omitempty"`
+
+	// 字段比较条件
+	FieldCompares []*FieldCompare `json:"x-fieldCompares,omitempty"`
+
+	// 结构体验证描述
+	StructRules []string `json:"x-structRules,omitempty"`
+
+	// 验证失败时的提示信息, 键为条件标签名, 如: required, num, len, item, enum, reg
+	Messages map[string]string `json:"x-messages,omitempty"`
+}
+
+// FileSchema 为上传文件的验证条件
+type FileSchema struct {
+	MinBytes   *int64   `json:"minBytes,omitempty"`
+	MaxBytes   *int64   `json:"maxBytes,omitempty"`
+	Extensions []string `json:"extensions,omitempty"`
+	MimeTypes  []string `json:"mimeTypes,omitempty"`
+	SniffTypes []string `json:"sniffTypes,omitempty"`
+	MinWidth   *int     `json:"minWidth,omitempty"`
+	MaxWidth   *int     `json:"maxWidth,omitempty"`
+	MinHeight  *int     `json:"minHeight,omitempty"`
+	MaxHeight  *int     `json:"maxHeight,omitempty"`
+	Ratio      *string  `json:"ratio,omitempty"`
+}
+
+// Property 为对象的属性
+type Property struct {
+	Name   string
+	Schema *JSONSchema
+}
+
+// Properties 为对象的属性列表, 按字段定义的顺序输出, 便于前端按顺序渲染表单
+type Properties []*Property
+
+func (ps Properties) MarshalJSON() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	buf.WriteByte('{')
+	for i, p := range ps {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(p.Name)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		schema, err := json.Marshal(p.Schema)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(schema)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// Get 获得属性的 schema
+func (ps Properties) Get(name string) *JSONSchema {
+	for _, p := range ps {
+		if p.Name == name {
+			return p.Schema
+		}
+	}
+	return nil
+}
+
+// JSONSchema 导出 JSON Schema, catalog 不为 nil 时按 locales 翻译提示信息, 否则提示信息为信息 ID
+func (s *Schema) JSONSchema(catalog *Catalog, locales ...string) *JSONSchema {
+	js := NewJSONSchema(s.Fields, catalog, locales...)
+	js.Schema = JSONSchemaDraft
+	t := s.Type
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	js.Title = t.Name()
+	js.StructRules = s.Rules
+	return js
+}
+
+// NewJSONSchema 以字段列表生成对象的 JSON Schema
+func NewJSONSchema(fields []*Field, catalog *Catalog, locales ...string) *JSONSchema {
+	e := &jsonSchemaExporter{catalog: catalog, locales: locales}
+	return e.object(fields)
+}
+
+type jsonSchemaExporter struct {
+	catalog *Catalog
+	locales []string
+}
+
+func (e *jsonSchemaExporter) object(fields []*Field) *JSONSchema {
+	obj := &JSONSchema{Type: "object"}
+	for _, field := range fields {
+		obj.Properties = append(obj.Properties, &Property{Name: field.Name, Schema: e.field(field)})
+		c := field.Condition
+		if c == nil {
+			continue
+		}
+		if c.Required != nil {
+			obj.Required = append(obj.Required, field.Name)
+		}
+		if ri := c.RequiredIf; ri != nil {
+			if ri.Value == "" {
+				if obj.DependentRequired == nil {
+					obj.DependentRequired = make(map[string][]string)
+				}
+				obj.DependentRequired[ri.Field] = append(obj.DependentRequired[ri.Field], field.Name)
+			} else {
+				var value interface{} = ri.Value
+				if ref := findField(fields, ri.Field); ref != nil {
+					value = typedValue(ref.Kind, ri.Value)
+				}
+				obj.AllOf = append(obj.AllOf, &JSONSchema {
+					If: &JSONSchema {
+						Properties: Properties{{Name: ri.Field, Schema: &JSONSchema{Const: value}}},
+						Required:   []string{ri.Field},
+					},
+					Then: &JSONSchema{Required: []string{field.Name}},
+				})
+			}
+		}
+	}
+	return obj
+}
+
+func (e *jsonSchemaExporter) field(field *Field) *JSONSchema {
+	var js *JSONSchema
+	c := field.Condition
+	switch field.Kind {
+	case Struct:
+		js = e.object(field.Fields)
+		js.StructRules = field.Rules
+	case SliceStruct:
+		js = &JSONSchema{Type: "array", Items: e.object(field.Fields), StructRules: field.Rules}
+	case MapString:
+		js = &JSONSchema{Type: "object", AdditionalProperties: &JSONSchema{Type: "string"}}
+	case TimePtr, Time:
+		js = timeSchema(field.Layout)
+	case File, FileStream:
+		js = &JSONSchema{Type: "string", Format: "binary"}
+		if c != nil && c.MaxItem != nil && *c.MaxItem > 1 {
+			js = &JSONSchema{Type: "array", Items: js}
+		}
+	default:
+		if elem := sliceElemKinds[field.Kind]; elem != Invalid {
+			js = &JSONSchema{Type: "array", Items: scalarSchema(elem)}
+		} else {
+			js = scalarSchema(field.Kind)
+		}
+	}
+	js.Description = field.Desc
+	js.WriteOnly = field.Sensitive
+	if !field.Kind.isFile() && field.Kind != Struct && !isEmptyValue(field.Kind, field.Value) {
+		js.Default = field.Value
+	}
+	if c != nil {
+		e.condition(js, field, c)
+	}
+	return js
+}
+
+var sliceElemKinds = map[Kind]Kind {
+	SliceString:  String,
+	SliceInt:     Int,
+	SliceInt32:   Int32,
+	SliceInt64:   Int64,
+	SliceFloat32: Float32,
+	SliceFloat64: Float64,
+	SliceBool:    Bool,
+}
+
+func scalarSchema(kind Kind) *JSONSchema {
+	switch kind {
+	case Bool:
+		return &JSONSchema{Type: "boolean"}
+	case Int, Int8, Int16, Int32, Int64:
+		return &JSONSchema{Type: "integer"}
+	case Uint, Uint8, Uint16, Uint32, Uint64:
+		zero := 0.0
+		return &JSONSchema{Type: "integer", Minimum: &zero}
+	case Float32, Float64:
+		return &JSONSchema{Type: "number"}
+	case Duration:
+		return &JSONSchema{Type: "string", Pattern: durationPattern}
+	default:
+		return &JSONSchema{Type: "string"}
+	}
+}
+
+// 时间字段的 JSON Schema, RFC3339 及 "2006-01-02" 格式使用标准 format, 其他格式以正则描述
+func timeSchema(layout string) *JSONSchema {
+	switch layout {
+	case time.RFC3339, time.RFC3339Nano:
+		return &JSONSchema{Type: "string", Format: "date-time"}
+	case "2006-01-02":
+		return &JSONSchema{Type: "string", Format: "date"}
+	}
+	return &JSONSchema{Type: "string", Pattern: layoutPattern(layout)}
+}
+
+// Go 时间格式元素对应的正则, 较长的元素排在前面
+var layoutElements = []struct {
+	elem, pattern string
+}{
+	{"January", `[A-Z][a-z]+`}, {"Monday", `[A-Z][a-z]+`}, {"Jan", `[A-Z][a-z]{2}`}, {"Mon", `[A-Z][a-z]{2}`},
+	{"MST", `[A-Z]{3,5}`}, {"2006", `\d{4}`}, {"Z07:00", `(Z|[+-]\d{2}:\d{2})`}, {"Z0700", `(Z|[+-]\d{4})`},
+	{"-07:00", `[+-]\d{2}:\d{2}`}, {"-0700", `[+-]\d{4}`}, {"Z07", `(Z|[+-]\d{2})`}, {"-07", `[+-]\d{2}`},
+	{"01", `\d{2}`}, {"02", `\d{2}`}, {"03", `\d{2}`}, {"04", `\d{2}`}, {"05", `\d{2}`}, {"06", `\d{2}`},
+	{"15", `\d{2}`}, {"_2", `[ \d]\d`}, {"1", `\d{1,2}`}, {"2", `\d{1,2}`}, {"3", `\d{1,2}`}, {"4", `\d{1,2}`},
+	{"5", `\d{1,2}`}, {"PM", `(AM|PM)`}, {"pm", `(am|pm)`},
+}
+
+// 将 Go 时间格式转换为正则, 如: "2006-01-02 15:04" 转换为 "^\d{4}-\d{2}-\d{2} \d{2}:\d{2}$"
+func layoutPattern(layout string) string {
+	buf := &bytes.Buffer{}
+	buf.WriteByte('^')
+	for layout != "" {
+		// 秒的小数部分, ".000" 为固定位数, ".999" 可省略末尾的 0
+		if n := fractionLen(layout); n > 0 {
+			if layout[1] == '0' {
+				buf.WriteString(`\.\d{` + strconv.Itoa(n) + `}`)
+			} else {
+				buf.WriteString(`(\.\d+)?`)
+			}
+			layout = layout[n+1:]
+			continue
+		}
+		matched := false
+		for _, e := range layoutElements {
+			if strings.HasPrefix(layout, e.elem) {
+				buf.WriteString(e.pattern)
+				layout = layout[len(e.elem):]
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			_, size := utf8.DecodeRuneInString(layout)
+			buf.WriteString(regexp.QuoteMeta(layout[:size]))
+			layout = layout[size:]
+		}
+	}
+	buf.WriteByte('$')
+	return buf.String()
+}
+
+// 获得 ".000" 或 ".999" 中 0 或 9 的个数
+func fractionLen(layout string) int {
+	if len(layout) < 2 || layout[0] != '.' || (layout[1] != '0' && layout[1] != '9') {
+		return 0
+	}
+	n := 1
+	for n+1 < len(layout) && layout[n+1] == layout[1] {
+		n++
+	}
+	// 小数部分后面不能紧跟数字, 如 ".0" 后的 "01" 不是小数部分
+	if n+1 < len(layout) && layout[n+1] >= '0' && layout[n+1] <= '9' {
+		return 0
+	}
+	return n
+}
+
+func isEmptyValue(kind Kind, value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	if kind.isTime() {
+		return value == (time.Time{}).Format(DefaultTimeLayout)
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	case reflect.Ptr, reflect.Func, reflect.Interface:
+		return v.IsNil()
+	}
+	return v.IsZero()
+}
+
+// 将字符串转换为字段类型对应的 JSON 值
+func typedValue(kind Kind, value string) interface{} {
+	if elem := sliceElemKinds[kind]; elem != Invalid {
+		kind = elem
+	}
+	switch scalarSchema(kind).Type {
+	case "boolean":
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	case "integer", "number":
+		if n, err := strconv.ParseFloat(value, 64); err == nil {
+			return n
+		}
+	}
+	return value
+}
+
+func (e *jsonSchemaExporter) message(js *JSONSchema, tag string, id *string, args map[string]string) {
+	if id == nil || *id == "" {
+		return
+	}
+	msg := *id
+	if e.catalog != nil {
+		msg = e.catalog.Message(e.locales, msg, args)
+	}
+	if js.Messages == nil {
+		js.Messages = make(map[string]string)
+	}
+	js.Messages[tag] = msg
+}
+
+func formatFloat(f *float64) string {
+	if f == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*f, 'f', -1, 64)
+}
+
+func formatInt(i *int) string {
+	if i == nil {
+		return ""
+	}
+	return strconv.Itoa(*i)
+}
+
+func (e *jsonSchemaExporter) condition(js *JSONSchema, field *Field, c *info) {
+	args := func(min, max string) map[string]string {
+		return map[string]string{"field": field.Name, "min": min, "max": max}
+	}
+	e.message(js, TagRequired, c.Required, args("", ""))
+	// 切片的值条件作用于元素
+	value := js
+	if js.Type == "array" && js.Items != nil && !field.Kind.isFile() {
+		value = js.Items
+	}
+	if c.MinNum != nil {
+		if c.EqMinNum != nil {
+			value.Minimum = c.MinNum
+		} else {
+			value.Minimum, value.ExclusiveMinimum = nil, c.MinNum
+		}
+	}
+	if c.MaxNum != nil {
+		if c.EqMaxNum != nil {
+			value.Maximum = c.MaxNum
+		} else {
+			value.ExclusiveMaximum = c.MaxNum
+		}
+	}
+	e.message(js, TagNum, c.NumMsgID, args(formatFloat(c.MinNum), formatFloat(c.MaxNum)))
+	value.MinLength, value.MaxLength = c.MinLen, c.MaxLen
+	e.message(js, TagLen, c.LenMsgID, args(formatInt(c.MinLen), formatInt(c.MaxLen)))
+	if js.Type == "array" {
+		js.MinItems, js.MaxItems = c.MinItem, c.MaxItem
+	}
+	e.message(js, TagItem, c.ItemMsgID, args(formatInt(c.MinItem), formatInt(c.MaxItem)))
+	for _, enum := range c.Enums {
+		value.Enum = append(value.Enum, typedValue(field.Kind, enum))
+	}
+	if c.EnumMsgID != nil {
+		a := args("", "")
+		a["enums"] = strings.Join(c.Enums, ", ")
+		e.message(js, TagEnum, c.EnumMsgID, a)
+	}
+	if c.Pattern != nil {
+		value.Pattern = *c.Pattern
+		if *c.Pattern == emailPattern {
+			value.Format = "email"
+			e.message(js, TagEmail, c.RegMsgID, args("", ""))
+		} else {
+			e.message(js, TagRegexp, c.RegMsgID, args("", ""))
+		}
+	}
+	js.Rules = c.Rules
+	js.FieldCompares = c.FieldCompares
+	if c.RequiredIf != nil {
+		e.message(js, TagRequiredIf, &c.RequiredIf.Message, args("", ""))
+	}
+	if field.Kind.isFile() {
+		e.file(js, field, c, args)
+	}
+}
+
+func (e *jsonSchemaExporter) file(js *JSONSchema, field *Field, c *info, args func(min, max string) map[string]string) {
+	f := &FileSchema {
+		MinBytes:   c.MinFileByte,
+		MaxBytes:   c.MaxFileByte,
+		Extensions: c.FileExtensions,
+		MimeTypes:  c.FileMimeTypes,
+		SniffTypes: c.FileSniffTypes,
+		MinWidth:   c.MinWidth,
+		MaxWidth:   c.MaxWidth,
+		MinHeight:  c.MinHeight,
+		MaxHeight:  c.MaxHeight,
+		Ratio:      c.Ratio,
+	}
+	if f.MinBytes == nil && f.MaxBytes == nil && len(f.Extensions) == 0 && len(f.MimeTypes) == 0 && len(f.SniffTypes) == 0 && 
+		f.MinWidth == nil && f.MaxWidth == nil && f.MinHeight == nil && f.MaxHeight == nil && f.Ratio == nil {
+		return
+	}
+	js.File = f
+	file := js
+	if js.Items != nil {
+		file = js.Items
+	}
+	if len(c.FileMimeTypes) == 1 {
+		file.ContentMediaType = c.FileMimeTypes[0]
+	}
+	var min, max string
+	if c.MinFileByte != nil {
+		min = strconv.FormatInt(*c.MinFileByte, 10)
+	}
+	if c.MaxFileByte != nil {
+		max = strconv.FormatInt(*c.MaxFileByte, 10)
+	}
+	e.message(js, "size", c.FileSizeMsgID, args(min, max))
+	e.message(js, TagFileExt, c.FileExtMsgID, args("", ""))
+	e.message(js, TagFileType, c.FileMimeMsgID, args("", ""))
+	e.message(js, TagFileSniff, c.FileSniffMsgID, args("", ""))
+	e.message(js, TagImage, c.ImageMsgID, args("", ""))
+}
@@ -0,0 +1,124 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package param_test
+
+import (
+	"encoding/json"
+	"github.com/orivil/morgine/param"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+type profile struct {
+	Name    string            `param:"name" desc:"用户名" required:"name.required" len:"6-20" len-msg:"name.len"`
+	Email   string            `param:"email" email:"email incorrect"`
+	Gender  int               `param:"gender" enum:"1 2"`
+	Age     uint              `param:"age" num:"18<=x<60"`
+	Tags    []string          `param:"tags" item:"1-5" len:"2-10"`
+	Type    string            `param:"type" enum:"person company"`
+	Company string            `param:"company" required-if:"type=company"`
+	Extra   map[string]string `param:"extra"`
+	Address struct {
+		City string `param:"city" required:""`
+	} `param:"address"`
+	Avatar param.FileHandler `param:"avatar" mime:"image/png" size-KB:"0-500"`
+}
+
+func TestSchema_JSONSchema(t *testing.T) {
+	catalog := param.NewCatalog("zh")
+	catalog.Add("en", map[string]string {
+		"name.required": "{field} is required",
+		"name.len":      "{field} length should be {min}-{max}",
+	})
+	p := &profile{Name: "guest", Gender: 1}
+	schema := param.MustNewSchema(p, nil, nil)
+	js := schema.JSONSchema(catalog, "en")
+	if js.Schema != param.JSONSchemaDraft || js.Title != "profile" || js.Type != "object" {
+		t.Errorf("got schema header: %s %s %s", js.Schema, js.Title, js.Type)
+	}
+	if need := []string{"name"}; !reflect.DeepEqual(js.Required, need) {
+		t.Errorf("need required %v, got %v", need, js.Required)
+	}
+
+	name := js.Properties.Get("name")
+	if name.Type != "string" || name.Description != "用户名" || name.Default != "guest" || *name.MinLength != 6 || *name.MaxLength != 20 {
+		t.Errorf("got name: %+v", name)
+	}
+	if name.Messages["required"] != "name is required" || name.Messages["len"] != "name length should be 6-20" {
+		t.Errorf("got name messages: %v", name.Messages)
+	}
+	if email := js.Properties.Get("email"); email.Format != "email" || email.Messages["email"] != "email incorrect" {
+		t.Errorf("got email: %+v", email)
+	}
+	if gender := js.Properties.Get("gender"); gender.Type != "integer" || !reflect.DeepEqual(gender.Enum, []interface{}{1.0, 2.0}) {
+		t.Errorf("got gender: %+v", gender)
+	}
+	if age := js.Properties.Get("age"); *age.Minimum != 18 || *age.ExclusiveMaximum != 60 || age.Maximum != nil {
+		t.Errorf("got age: %+v", age)
+	}
+	tags := js.Properties.Get("tags")
+	if tags.Type != "array" || *tags.MinItems != 1 || *tags.MaxItems != 5 || tags.Items.Type != "string" || *tags.Items.MinLength != 2 {
+		t.Errorf("got tags: %+v", tags)
+	}
+	if len(js.AllOf) != 1 || js.AllOf[0].If.Properties.Get("type").Const != "company" || js.AllOf[0].Then.Required[0] != "company" {
+		t.Errorf("need required-if condition, got: %+v", js.AllOf)
+	}
+	if extra := js.Properties.Get("extra"); extra.Type != "object" || extra.AdditionalProperties.Type != "string" {
+		t.Errorf("got extra: %+v", extra)
+	}
+	if address := js.Properties.Get("address"); address.Type != "object" || address.Required[0] != "city" {
+		t.Errorf("got address: %+v", address)
+	}
+	avatar := js.Properties.Get("avatar")
+	if avatar.Format != "binary" || avatar.ContentMediaType != "image/png" || *avatar.File.MaxBytes != 500<<10 {
+		t.Errorf("got avatar: %+v", avatar)
+	}
+
+	// 属性按字段定义的顺序输出
+	data, err := json.Marshal(js)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := string(data)
+	if strings.Index(s, `"name":`) > strings.Index(s, `"email":`) || strings.Index(s, `"email":`) > strings.Index(s, `"avatar":`) {
+		t.Errorf("need ordered properties, got: %s", s)
+	}
+}
+
+func TestSchema_JSONSchemaTimeLayout(t *testing.T) {
+	type times struct {
+		Created  *time.Time `param:"created"`
+		Date     *time.Time `param:"date" time-layout:"2006-01-02"`
+		RFC      *time.Time `param:"rfc" time-layout:"2006-01-02T15:04:05Z07:00"`
+		Minute   *time.Time `param:"minute" time-layout:"2006/01/02 15:04"`
+		Millis   *time.Time `param:"millis" time-layout:"Jan _2 15:04:05.000 PM"`
+		Optional *time.Time `param:"optional" time-layout:"15:04:05.999"`
+	}
+	js := param.MustNewSchema(&times{}, nil, nil).JSONSchema(nil)
+	cases := []struct {
+		name, format, pattern, layout string
+	}{
+		{"created", "", `^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}$`, param.DefaultTimeLayout},
+		{"date", "date", "", "2006-01-02"},
+		{"rfc", "date-time", "", time.RFC3339},
+		{"minute", "", `^\d{4}/\d{2}/\d{2} \d{2}:\d{2}$`, "2006/01/02 15:04"},
+		{"millis", "", `^[A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2}\.\d{3} (AM|PM)$`, "Jan _2 15:04:05.000 PM"},
+		{"optional", "", `^\d{2}:\d{2}:\d{2}(\.\d+)?$`, "15:04:05.999"},
+	}
+	now := time.Date(2020, 6, 5, 8, 4, 5, 120000000, time.UTC)
+	for _, c := range cases {
+		got := js.Properties.Get(c.name)
+		if got == nil || got.Format != c.format || got.Pattern != c.pattern {
+			t.Errorf("%s: need format %q pattern %q, got: %+v", c.name, c.format, c.pattern, got)
+			continue
+		}
+		if c.pattern != "" && !regexp.MustCompile(c.pattern).MatchString(now.Format(c.layout)) {
+			t.Errorf("%s: pattern %q does not match %q", c.name, c.pattern, now.Format(c.layout))
+		}
+	}
+}
@@ -5,6 +5,7 @@
 package param
 
 import (
+	"encoding"
 	"reflect"
 	"time"
 )
@@ -39,6 +40,20 @@ const (
 	SliceFloat32 // []float32
 	SliceFloat64 // []float64
 	SliceBool    // []bool
+	Struct       // 嵌套结构体
+	SliceStruct  // []struct
+	Int8
+	Int16
+	Uint
+	Uint8
+	Uint16
+	Uint32
+	Uint64
+	Duration  // time.Duration, 如: "1h30m"
+	Text      // implement 'encoding.TextUnmarshaler'
+	MapString // map[string]string, 如: meta[key]=value 或 meta.key=value
+	FileStream // implement 'param.StreamHandler'
+	Time       // time.Time, 与 *time.Time 相同由 time-layout 标签设置格式
 )
 
 var FieldTypes = map[Kind]string{
@@ -58,6 +73,20 @@ var FieldTypes = map[Kind]string{
 	SliceFloat32: "[]float32",
 	SliceFloat64: "[]float64",
 	SliceBool:    "[]bool",
+	Struct:       "struct",
+	SliceStruct:  "[]struct",
+	Int8:         "int8",
+	Int16:        "int16",
+	Uint:         "uint",
+	Uint8:        "uint8",
+	Uint16:       "uint16",
+	Uint32:       "uint32",
+	Uint64:       "uint64",
+	Duration:     "duration",
+	Text:         "text",
+	MapString:    "map[string]string",
+	FileStream:   "file-stream",
+	Time:         "time-value",
 	Invalid:      "invalid",
 }
 
@@ -87,6 +116,20 @@ var JSDocFieldTypes = map[Kind]string{
 	SliceFloat32: "number[]",
 	SliceFloat64: "number[]",
 	SliceBool:    "boolean[]",
+	Struct:       "object",
+	SliceStruct:  "object[]",
+	Int8:         "number",
+	Int16:        "number",
+	Uint:         "number",
+	Uint8:        "number",
+	Uint16:       "number",
+	Uint32:       "number",
+	Uint64:       "number",
+	Duration:     "string",
+	Text:         "string",
+	MapString:    "Object.<string, string>",
+	FileStream:   "File",
+	Time:         "date",
 	Invalid:      "invalid",
 }
 
@@ -98,38 +141,112 @@ func (k Kind) JSType() string {
 	return JSDocFieldTypes[k]
 }
 
-func fieldKind(field reflect.StructField) Kind {
-	switch field.Type.Kind() {
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	timeValueType       = reflect.TypeOf(time.Time{})
+	textUnmarshalerType = reflect.TypeOf(new(encoding.TextUnmarshaler)).Elem()
+)
+
+// 除 *time.Time 外, 标量类型的指针类型也被支持, 参数不存在时为 nil, 如: *int, *string, *Text
+func fieldKind(field reflect.StructField) (kind Kind, optional bool) {
+	kind = typeKind(field.Type)
+	if kind == Invalid && field.Type.Kind() == reflect.Ptr {
+		if elem := typeKind(field.Type.Elem()); elem.isScalar() {
+			return elem, true
+		}
+	}
+	return kind, false
+}
+
+// 是否为单个值的类型
+func (k Kind) isScalar() bool {
+	switch k {
+	case Bool, Int, Int8, Int16, Int32, Int64, Uint, Uint8, Uint16, Uint32, Uint64, Float32, Float64, String, Duration, Text:
+		return true
+	}
+	return false
+}
+
+// 是否为上传文件类型
+func (k Kind) isFile() bool {
+	return k == File || k == FileStream
+}
+
+// 是否为时间类型, 按 time-layout 标签的格式解析
+func (k Kind) isTime() bool {
+	return k == TimePtr || k == Time
+}
+
+// 是否由 getSetter 提供设置器
+func (k Kind) isBuiltin() bool {
+	return k <= SliceBool
+}
+
+func typeKind(typ reflect.Type) Kind {
+	if typ == durationType {
+		return Duration
+	}
+	if typ == timeValueType {
+		return Time
+	}
+	if typ.Kind() != reflect.Ptr && reflect.PtrTo(typ).Implements(textUnmarshalerType) {
+		return Text
+	}
+	switch typ.Kind() {
 	case reflect.String:
 		return String
 	case reflect.Int:
 		return Int
+	case reflect.Int8:
+		return Int8
+	case reflect.Int16:
+		return Int16
 	case reflect.Int32:
 		return Int32
 	case reflect.Int64:
 		return Int64
+	case reflect.Uint:
+		return Uint
+	case reflect.Uint8:
+		return Uint8
+	case reflect.Uint16:
+		return Uint16
+	case reflect.Uint32:
+		return Uint32
+	case reflect.Uint64:
+		return Uint64
 	case reflect.Float32:
 		return Float32
 	case reflect.Float64:
 		return Float64
 	case reflect.Bool:
 		return Bool
+	case reflect.Struct:
+		return Struct
+	case reflect.Map:
+		if typ.Key().Kind() == reflect.String && typ.Elem().Kind() == reflect.String {
+			return MapString
+		}
+		return Invalid
 	case reflect.Ptr:
 		var transformerType = reflect.TypeOf(new(time.Time))
-		if field.Type.ConvertibleTo(transformerType) {
+		if typ.ConvertibleTo(transformerType) {
 			return TimePtr
 		} else {
 			return Invalid
 		}
 	case reflect.Func:
 		var fileHandlerType = reflect.TypeOf(new(FileHandler)).Elem()
-		if field.Type.ConvertibleTo(fileHandlerType) {
+		var streamHandlerType = reflect.TypeOf(new(StreamHandler)).Elem()
+		if typ.ConvertibleTo(fileHandlerType) {
 			return File
+		} else if typ.ConvertibleTo(streamHandlerType) {
+			return FileStream
 		} else {
 			return Invalid
 		}
 	case reflect.Slice:
-		element := field.Type.Elem()
+		element := typ.Elem()
 		switch element.Kind() {
 		case reflect.String:
 			return SliceString
@@ -145,7 +262,9 @@ func fieldKind(field reflect.StructField) Kind {
 			return SliceFloat64
 		case reflect.Bool:
 			return SliceBool
+		case reflect.Struct:
+			return SliceStruct
 		}
 	}
 	return Invalid
-}
\ No newline at end of file
+}
@@ -0,0 +1,208 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package param
+
+import (
+	"fmt"
+	"github.com/orivil/morgine/cfg"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 验证信息可以使用信息 ID, 如: required:"username.required", 通过 Catalog 翻译为对应语言的信息.
+// 信息模板中可以使用占位符: {field}, {min}, {max}, {enums}, 如:
+//
+//	en:
+//	  username:
+//	    required: "{field} is required"
+//	  age: "{field} must between {min} and {max}"
+
+// Messages 为默认的信息目录, ValidatorErr.Error() 使用默认语言翻译
+var Messages = NewCatalog("zh")
+
+// Catalog 为多语言信息目录, 语言名称不区分大小写, "_" 等同于 "-"
+type Catalog struct {
+	defaultLocale string
+	messages      map[string]map[string]string // locale => id => template
+	fallbacks     map[string][]string
+	mu            sync.RWMutex
+}
+
+func NewCatalog(defaultLocale string) *Catalog {
+	return &Catalog {
+		defaultLocale: normalizeLocale(defaultLocale),
+		messages:      make(map[string]map[string]string),
+		fallbacks:     make(map[string][]string),
+	}
+}
+
+func normalizeLocale(locale string) string {
+	return strings.ToLower(strings.Replace(strings.TrimSpace(locale), "_", "-", -1))
+}
+
+// DefaultLocale 获得默认语言
+func (c *Catalog) DefaultLocale() string {
+	return c.defaultLocale
+}
+
+// Add 添加信息, 已存在的信息会被覆盖
+func (c *Catalog) Add(locale string, messages map[string]string) {
+	locale = normalizeLocale(locale)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ms := c.messages[locale]
+	if ms == nil {
+		ms = make(map[string]string, len(messages))
+		c.messages[locale] = ms
+	}
+	for id, msg := range messages {
+		ms[id] = msg
+	}
+}
+
+// SetFallback 设置语言的后备语言, 如: SetFallback("zh-HK", "zh-TW", "zh")
+func (c *Catalog) SetFallback(locale string, fallbacks ...string) {
+	locale = normalizeLocale(locale)
+	fs := make([]string, len(fallbacks))
+	for i, f := range fallbacks {
+		fs[i] = normalizeLocale(f)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.fallbacks[locale] = fs
+}
+
+// Load 通过 cfg 加载 YAML/JSON 格式的信息文件, 嵌套的 ID 以 "." 连接
+func (c *Catalog) Load(locale, file string) error {
+	configs, err := cfg.UnmarshalMap(file)
+	if err != nil {
+		return err
+	}
+	messages := make(map[string]string, len(configs))
+	flattenMessages("", map[string]interface{}(configs), messages)
+	c.Add(locale, messages)
+	return nil
+}
+
+// LoadDir 加载目录下所有 YAML/JSON 格式的信息文件, 文件名为语言名称, 如: en.yml, zh-CN.json
+func (c *Catalog) LoadDir(dir string) error {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		name := info.Name()
+		ext := filepath.Ext(name)
+		if info.IsDir() || (ext != ".yml" && ext != ".yaml" && ext != ".json") {
+			continue
+		}
+		err = c.Load(strings.TrimSuffix(name, ext), filepath.Join(dir, name))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func flattenMessages(prefix string, values map[string]interface{}, messages map[string]string) {
+	for key, value := range values {
+		id := prefix + key
+		switch v := value.(type) {
+		case string:
+			messages[id] = v
+		case map[string]interface{}:
+			flattenMessages(id+".", v, messages)
+		case map[interface{}]interface{}: // yaml
+			sub := make(map[string]interface{}, len(v))
+			for k, vv := range v {
+				sub[fmt.Sprint(k)] = vv
+			}
+			flattenMessages(id+".", sub, messages)
+		default:
+			messages[id] = fmt.Sprint(v)
+		}
+	}
+}
+
+// 获得语言的查找顺序: 语言本身, 设置的后备语言, 基础语言(如: en-us => en), 默认语言
+func (c *Catalog) chain(locales []string) []string {
+	var result []string
+	exist := make(map[string]bool)
+	var add func(locale string)
+	add = func(locale string) {
+		if locale == "" || exist[locale] {
+			return
+		}
+		exist[locale] = true
+		result = append(result, locale)
+		for _, f := range c.fallbacks[locale] {
+			add(f)
+		}
+		if idx := strings.Index(locale, "-"); idx > 0 {
+			add(locale[:idx])
+		}
+	}
+	for _, locale := range locales {
+		add(normalizeLocale(locale))
+	}
+	add(c.defaultLocale)
+	return result
+}
+
+// Message 按 locales 顺序查找信息并替换占位符, 不存在则将 id 作为信息模板
+func (c *Catalog) Message(locales []string, id string, args map[string]string) string {
+	c.mu.RLock()
+	msg, found := id, false
+	for _, locale := range c.chain(locales) {
+		if m, ok := c.messages[locale][id]; ok {
+			msg, found = m, true
+			break
+		}
+	}
+	c.mu.RUnlock()
+	if !found && !strings.Contains(msg, "{") {
+		return msg
+	}
+	return interpolate(msg, args)
+}
+
+// Translate 翻译验证错误信息
+func (c *Catalog) Translate(locales []string, err *ValidatorErr) string {
+	return c.Message(locales, err.Message, err.Args())
+}
+
+func interpolate(msg string, args map[string]string) string {
+	if len(args) == 0 {
+		return msg
+	}
+	pairs := make([]string, 0, len(args)*2)
+	for key, value := range args {
+		pairs = append(pairs, "{"+key+"}", value)
+	}
+	return strings.NewReplacer(pairs...).Replace(msg)
+}
+
+// Args 获得信息模板的占位符参数
+func (re *ValidatorErr) Args() map[string]string {
+	args := map[string]string{"field": re.Field}
+	if re.Min != nil {
+		args["min"] = strconv.FormatFloat(*re.Min, 'f', -1, 64)
+	}
+	if re.Max != nil {
+		args["max"] = strconv.FormatFloat(*re.Max, 'f', -1, 64)
+	}
+	if len(re.Enums) > 0 {
+		args["enums"] = strings.Join(re.Enums, ", ")
+	}
+	return args
+}
+
+// Localize 使用 catalog 按 locales 顺序翻译错误信息
+func (re *ValidatorErr) Localize(catalog *Catalog, locales ...string) string {
+	return catalog.Translate(locales, re)
+}
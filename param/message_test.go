@@ -0,0 +1,108 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package param_test
+
+import (
+	"github.com/orivil/morgine/param"
+	"io/ioutil"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"testing"
+	"unsafe"
+)
+
+func TestCatalog_Message(t *testing.T) {
+	catalog := param.NewCatalog("zh")
+	catalog.Add("zh", map[string]string{"age.range": "年龄必须在 {min} 到 {max} 之间", "hello": "你好"})
+	catalog.Add("en", map[string]string{"age.range": "{field} must be between {min} and {max}"})
+	catalog.Add("zh-TW", map[string]string{"hello": "妳好"})
+	catalog.SetFallback("zh-HK", "zh-TW")
+
+	type account struct {
+		Age int `param:"age" num:"18<=x<=60" num-msg:"age.range"`
+	}
+	schema := param.MustNewSchema(&account{}, nil, nil)
+	err := schema.Parse(uintptr(unsafe.Pointer(&account{})), &multipart.Form{Value: map[string][]string{"age": {"10"}}})
+	ve, ok := err.(*param.ValidatorErr)
+	if !ok {
+		t.Fatalf("need *param.ValidatorErr, got: %v", err)
+	}
+	cases := []struct {
+		locales []string
+		need    string
+	}{
+		{[]string{"en-US"}, "age must be between 18 and 60"},
+		{[]string{"fr", "EN"}, "age must be between 18 and 60"},
+		{[]string{"fr"}, "年龄必须在 18 到 60 之间"},
+		{nil, "年龄必须在 18 到 60 之间"},
+	}
+	for _, c := range cases {
+		if got := ve.Localize(catalog, c.locales...); got != c.need {
+			t.Errorf("%v need: %s got: %s", c.locales, c.need, got)
+		}
+	}
+	if got := catalog.Message([]string{"zh_HK"}, "hello", nil); got != "妳好" {
+		t.Errorf("need fallback message, got: %s", got)
+	}
+	if got := catalog.Message([]string{"en"}, "not exist", nil); got != "not exist" {
+		t.Errorf("need id as message, got: %s", got)
+	}
+}
+
+func TestCatalog_LoadDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "messages")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string {
+		"en.yml":     "user:\n  name:\n    required: \"{field} is required\"\n",
+		"zh-CN.json": `{"user": {"name": {"required": "用户名不能为空"}}}`,
+		"README.md":  "ignored",
+	}
+	for name, content := range files {
+		err = ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	catalog := param.NewCatalog("en")
+	err = catalog.LoadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ve := &param.ValidatorErr{Field: "name", Message: "user.name.required", Kind: param.ConditionRequired}
+	if got := ve.Localize(catalog, "zh-cn"); got != "用户名不能为空" {
+		t.Errorf("need zh-CN message, got: %s", got)
+	}
+	if got := ve.Localize(catalog, "de"); got != "name is required" {
+		t.Errorf("need default message, got: %s", got)
+	}
+}
+
+func TestValidatorErr_NumberBounds(t *testing.T) {
+	type account struct {
+		Age   int `param:"age" num:"18<=x<=60"`
+		Score int `param:"score" num:"0<x<100"`
+	}
+	schema := param.MustNewSchema(&account{}, nil, nil)
+	parse := func(age, score string) *param.ValidatorErr {
+		err := schema.Parse(uintptr(unsafe.Pointer(&account{})), &multipart.Form{Value: map[string][]string{"age": {age}, "score": {score}}})
+		ve, ok := err.(*param.ValidatorErr)
+		if !ok {
+			t.Fatalf("need *param.ValidatorErr, got: %v", err)
+		}
+		return ve
+	}
+	ve := parse("61", "1")
+	if ve.Field != "age" || ve.EQMin == nil || ve.EQMax == nil || *ve.Min != 18 || *ve.Max != 60 {
+		t.Errorf("need inclusive bounds, got: %+v", ve)
+	}
+	ve = parse("18", "100")
+	if ve.Field != "score" || ve.EQMin != nil || ve.EQMax != nil || *ve.Min != 0 || *ve.Max != 100 {
+		t.Errorf("need exclusive bounds, got: %+v", ve)
+	}
+}
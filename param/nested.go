@@ -0,0 +1,168 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package param
+
+import (
+	"mime/multipart"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+// 嵌套参数支持方括号及点两种写法, 可以混合使用:
+//
+//	filter[status]=1&filter[range][from]=2020-01-01
+//	filter.status=1&filter.range.from=2020-01-01
+//	items[0][name]=a&items[1].name=b
+
+// 去掉参数名的第一段, 如: "[range][from]" => "range[from]", ".range.from" => "range.from"
+func trimFirstSegment(rest string) (sub string, ok bool) {
+	if strings.HasPrefix(rest, ".") {
+		return rest[1:], len(rest) > 1
+	}
+	if strings.HasPrefix(rest, "[") {
+		end := strings.Index(rest, "]")
+		if end > 1 {
+			return rest[1:end] + rest[end+1:], true
+		}
+	}
+	return "", false
+}
+
+// 获得嵌套结构体的参数, 参数名去掉 param 前缀
+func subForm(param string, form *multipart.Form) (sub *multipart.Form, exist bool) {
+	sub = &multipart.Form {
+		Value: make(map[string][]string),
+		File:  make(map[string][]*multipart.FileHeader),
+	}
+	for key, values := range form.Value {
+		if strings.HasPrefix(key, param) {
+			if name, ok := trimFirstSegment(key[len(param):]); ok {
+				sub.Value[name] = values
+				exist = true
+			}
+		}
+	}
+	for key, files := range form.File {
+		if strings.HasPrefix(key, param) {
+			if name, ok := trimFirstSegment(key[len(param):]); ok {
+				sub.File[name] = files
+				exist = true
+			}
+		}
+	}
+	return sub, exist
+}
+
+// 获得结构体切片每个元素的参数及其提交时的下标, 按下标排序, 不连续的下标会被压缩
+func subForms(param string, form *multipart.Form) ([]*multipart.Form, []int) {
+	forms := make(map[int]*multipart.Form)
+	getForm := func(key string) (*multipart.Form, string) {
+		if !strings.HasPrefix(key, param+"[") {
+			return nil, ""
+		}
+		rest := key[len(param)+1:]
+		end := strings.Index(rest, "]")
+		if end < 1 {
+			return nil, ""
+		}
+		idx, err := strconv.Atoi(rest[:end])
+		if err != nil || idx < 0 {
+			return nil, ""
+		}
+		name, ok := trimFirstSegment(rest[end+1:])
+		if !ok {
+			return nil, ""
+		}
+		f := forms[idx]
+		if f == nil {
+			f = &multipart.Form {
+				Value: make(map[string][]string),
+				File:  make(map[string][]*multipart.FileHeader),
+			}
+			forms[idx] = f
+		}
+		return f, name
+	}
+	for key, values := range form.Value {
+		if f, name := getForm(key); f != nil {
+			f.Value[name] = values
+		}
+	}
+	for key, files := range form.File {
+		if f, name := getForm(key); f != nil {
+			f.File[name] = files
+		}
+	}
+	idxes := make([]int, 0, len(forms))
+	for idx := range forms {
+		idxes = append(idxes, idx)
+	}
+	sort.Ints(idxes)
+	result := make([]*multipart.Form, len(idxes))
+	for i, idx := range idxes {
+		result[i] = forms[idx]
+	}
+	return result, idxes
+}
+
+// 给嵌套字段的验证错误加上父字段名, 如: "name" => "items[0].name"
+func nestedErr(param string, err error) error {
+	if ve, ok := err.(*ValidatorErr); ok {
+		ne := *ve
+		if ve.Field == "" {
+			ne.Field = param
+		} else {
+			ne.Field = param + "." + ve.Field
+		}
+		return &ne
+	}
+	return err
+}
+
+func newStructSetter(param string, offset uintptr, fields []*Field, check structCheck, cdt *condition) setter {
+	return func(begin uintptr, form *multipart.Form) (err error) {
+		sub, exist := subForm(param, form)
+		if cdt != nil && cdt.required != nil && !exist {
+			return &ValidatorErr{Field: param, Message: *cdt.required, Kind: ConditionRequired}
+		}
+		err = parseFields(begin+offset, fields, sub, check)
+		if err != nil {
+			return nestedErr(param, err)
+		}
+		return nil
+	}
+}
+
+func newSliceStructSetter(param string, typ reflect.Type, offset uintptr, dvalue interface{}, fields []*Field, check structCheck, cdt *condition) setter {
+	return func(begin uintptr, form *multipart.Form) (err error) {
+		forms, idxes := subForms(param, form)
+		if cdt != nil {
+			err = cdt.validItem(param, len(forms))
+			if err != nil {
+				return err
+			}
+		}
+		var slice reflect.Value
+		if len(forms) > 0 {
+			slice = reflect.MakeSlice(typ, len(forms), len(forms))
+			for i, f := range forms {
+				err = parseFields(slice.Index(i).UnsafeAddr(), fields, f, check)
+				if err != nil {
+					// 错误中使用提交时的下标, 而不是压缩后的下标
+					return nestedErr(param+"["+strconv.Itoa(idxes[i])+"]", err)
+				}
+			}
+		} else {
+			dv := reflect.ValueOf(dvalue)
+			slice = reflect.MakeSlice(typ, dv.Len(), dv.Len())
+			reflect.Copy(slice, dv)
+		}
+		reflect.NewAt(typ, unsafe.Pointer(begin+offset)).Elem().Set(slice)
+		return nil
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package param_test
+
+import (
+	"github.com/orivil/morgine/param"
+	"mime/multipart"
+	"reflect"
+	"testing"
+	"unsafe"
+)
+
+type dateRange struct {
+	From string `param:"from"`
+	To   string `param:"to"`
+}
+
+type filter struct {
+	Status int       `param:"status" num:"0<=x<=2"`
+	Range  dateRange `param:"range"`
+	Tags   []string  `param:"tags"`
+}
+
+type lineItem struct {
+	Name  string `param:"name" required:"name required"`
+	Count int    `param:"count"`
+}
+
+type order struct {
+	Filter filter     `param:"filter"`
+	Items  []lineItem `param:"items" required:"items required" item:"1-3"`
+}
+
+func TestSchema_ParseNested(t *testing.T) {
+	schema := param.MustNewSchema(&order{}, nil, nil)
+	form := &multipart.Form {
+		Value: map[string][]string {
+			"filter[status]":      {"1"},
+			"filter[range][from]": {"2020-01-01"},
+			"filter.range.to":     {"2020-02-01"},
+			"filter[tags][]":      {"a", "b"},
+			"items[1].name":       {"second"},
+			"items[0][name]":      {"first"},
+			"items[0][count]":     {"2"},
+		},
+	}
+	got := &order{}
+	err := schema.Parse(uintptr(unsafe.Pointer(got)), form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	need := &order {
+		Filter: filter {
+			Status: 1,
+			Range:  dateRange{From: "2020-01-01", To: "2020-02-01"},
+			Tags:   []string{"a", "b"},
+		},
+		Items: []lineItem{{Name: "first", Count: 2}, {Name: "second"}},
+	}
+	if !reflect.DeepEqual(got, need) {
+		t.Errorf("need: %+v got: %+v", need, got)
+	}
+
+	if fields := schema.Fields[0].Fields; schema.Fields[0].Kind != param.Struct || len(fields) != 3 || fields[1].Fields[0].Name != "from" {
+		t.Errorf("need nested field tree, got: %+v", schema.Fields[0])
+	}
+}
+
+func TestSchema_ParseNestedError(t *testing.T) {
+	schema := param.MustNewSchema(&order{}, nil, nil)
+	cases := []struct {
+		form  map[string][]string
+		field string
+	}{
+		{map[string][]string{"items[0][count]": {"1"}}, "items[0].name"},
+		{map[string][]string{"items[1][count]": {"1"}}, "items[1].name"}, // 不连续的下标
+		{map[string][]string{"items[0][name]": {"a"}, "items[5][count]": {"1"}}, "items[5].name"},
+		{map[string][]string{"filter.status": {"3"}, "items[0][name]": {"a"}}, "filter.status"},
+		{map[string][]string{}, "items"},
+	}
+	for _, c := range cases {
+		err := schema.Parse(uintptr(unsafe.Pointer(&order{})), &multipart.Form{Value: c.form})
+		if ve, ok := err.(*param.ValidatorErr); !ok || ve.Field != c.field {
+			t.Errorf("need error of field %s, got: %v", c.field, err)
+		}
+	}
+}
+
+func TestValidator_Elem(t *testing.T) {
+	o := &order{}
+	v := param.NewValidator(o)
+	item := &lineItem{}
+	v.Elem(&o.Items, item).Field(&item.Count).MinNum(1, true, "count must be positive")
+	schema := param.MustNewSchema(o, v, nil)
+	form := &multipart.Form{Value: map[string][]string{"items[0][name]": {"a"}, "items[0][count]": {"0"}}}
+	err := schema.Parse(uintptr(unsafe.Pointer(&order{})), form)
+	if ve, ok := err.(*param.ValidatorErr); !ok || ve.Field != "items[0].count" {
+		t.Errorf("need error of field items[0].count, got: %v", err)
+	}
+}
+
+func TestValidator_NestedStruct(t *testing.T) {
+	o := &order{}
+	v := param.NewValidator(o)
+	v.Nested(&o.Filter.Range).Struct("结束日期不能早于开始日期", func(v interface{}) error {
+		r := v.(*dateRange)
+		if r.To != "" && r.To < r.From {
+			return param.FieldErr("to", "to is before from")
+		}
+		return nil
+	})
+	item := &lineItem{}
+	v.Elem(&o.Items, item).Struct("", func(v interface{}) error {
+		if v.(*lineItem).Count > 10 {
+			return param.FieldErr("count", "too many")
+		}
+		return nil
+	})
+	schema := param.MustNewSchema(o, v, nil)
+	if rules := schema.Fields[0].Fields[1].Rules; len(rules) != 1 {
+		t.Errorf("need nested struct rules in document, got: %v", rules)
+	}
+	cases := []struct {
+		form  map[string][]string
+		field string
+	}{
+		{map[string][]string{"filter.range.from": {"2020-02-01"}, "filter.range.to": {"2020-01-01"}, "items[0][name]": {"a"}}, "filter.range.to"},
+		{map[string][]string{"items[0][name]": {"a"}, "items[1][name]": {"b"}, "items[1][count]": {"11"}}, "items[1].count"},
+	}
+	for _, c := range cases {
+		err := schema.Parse(uintptr(unsafe.Pointer(&order{})), &multipart.Form{Value: c.form})
+		if ve, ok := err.(*param.ValidatorErr); !ok || ve.Field != c.field {
+			t.Errorf("need error of field %s, got: %v", c.field, err)
+		}
+	}
+	form := map[string][]string{"filter.range.from": {"2020-01-01"}, "filter.range.to": {"2020-02-01"}, "items[0][name]": {"a"}}
+	if err := schema.Parse(uintptr(unsafe.Pointer(&order{})), &multipart.Form{Value: form}); err != nil {
+		t.Errorf("need nil, got: %v", err)
+	}
+}
@@ -22,6 +22,13 @@ const DefaultTimeLayout = "2006-01-02T15:04:05"
 // 时间模板标签名, 设置此标签之后时间按此标签模板解析
 const TimeLayoutTag = "time-layout"
 
+// 参数来源标签名, 如: in:"path", in:"query", in:"header", in:"cookie" 或 in:"form".
+// 由 xx 包按字段来源读取参数, 未设置时使用参数声明的来源
+const TagIn = "in"
+
+// 敏感字段标签名, 如: sensitive:"". 密码等敏感字段在审计日志中不会记录原值
+const TagSensitive = "sensitive"
+
 var ErrFileHandlerIsNil = errors.New("file handler is nil")
 
 type EncodeType string
@@ -39,36 +46,76 @@ type Parser interface {
 type Schema struct {
 	Type   reflect.Type
 	Fields []*Field
+
+	// 结构体验证描述, 由 Validator.Struct 添加
+	Rules []string
+
+	check structCheck
 }
 
 func (s *Schema) EncodeType() EncodeType {
-	for _, field := range s.Fields {
-		if field.Kind == File {
-			return FormDataEncodeType
-		}
+	if hasFileField(s.Fields) {
+		return FormDataEncodeType
 	}
 	return UrlEncodeType
 }
 
+func hasFileField(fields []*Field) bool {
+	for _, field := range fields {
+		if field.Kind.isFile() || hasFileField(field.Fields) {
+			return true
+		}
+	}
+	return false
+}
+
 // 验证并解析数据, 该方法直接映射内存, 是不安全的, 使用时一定要保证模型一致.
 // 如果有上传文件, 则先设置数据, 后保存文件
 func (s *Schema) Parse(pointer uintptr, form *multipart.Form) (err error) {
-	for _, field := range s.Fields {
-		if field.Kind != File {
+	return parseFields(pointer, s.Fields, form, s.check)
+}
+
+// 先设置非文件字段, 再验证跨字段条件及结构体, 最后保存文件
+func parseFields(pointer uintptr, fields []*Field, form *multipart.Form, check structCheck) (err error) {
+	err = parseValues(pointer, fields, form, check)
+	if err != nil {
+		return err
+	}
+	for _, field := range fields {
+		if field.Kind.isFile() {
 			err = field.setter.SetValue(pointer, form)
 			if err != nil {
 				return err
 			}
 		}
 	}
-	for _, field := range s.Fields {
-		if field.Kind == File {
+	return nil
+}
+
+// 设置非文件字段, 验证跨字段条件及结构体
+func parseValues(pointer uintptr, fields []*Field, form *multipart.Form, check structCheck) (err error) {
+	for _, field := range fields {
+		if !field.Kind.isFile() {
 			err = field.setter.SetValue(pointer, form)
 			if err != nil {
 				return err
 			}
 		}
 	}
+	for _, field := range fields {
+		if field.cdt != nil {
+			err = field.cdt.validCross(field, pointer, fields, form)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if check != nil {
+		err = check(pointer)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -88,6 +135,28 @@ type Field struct {
 
 	// 字段类型
 	Kind Kind
+
+	// 嵌套结构体或结构体切片的字段
+	Fields []*Field `json:",omitempty"`
+
+	// 指针类型字段, 参数不存在时为 nil
+	Optional bool `json:",omitempty"`
+
+	// 嵌套结构体或结构体切片元素的结构体验证描述
+	Rules []string `json:",omitempty"`
+
+	// 参数来源, 为空时使用参数声明的来源
+	In string `json:",omitempty"`
+
+	// 敏感字段, 由 sensitive 标签设置
+	Sensitive bool `json:",omitempty"`
+
+	// 时间字段的格式, 由 time-layout 标签设置, 默认为 DefaultTimeLayout
+	Layout string `json:",omitempty"`
+
+	offset uintptr
+	typ    reflect.Type
+	cdt    *condition
 }
 
 type Setter interface {
@@ -110,40 +179,64 @@ func NewSchema(v interface{}, validator *Validator, filter *Filter) (*Schema, er
 	}
 	t = t.Elem()
 	ptr := reflect.ValueOf(v).Pointer()
-	fields := structFields(t, 0)
-	for _, field := range fields {
+	fields, err := newFields(t, ptr, 0, fmt.Sprintf("%T", v), "", validator, filter)
+	if err != nil {
+		return nil, err
+	}
+	schema.Fields = fields
+	schema.Rules = validator.structRules()
+	schema.check = newStructCheck(t, validator)
+	return schema, nil
+}
+
+// 获得结构体的字段, ptr 为结构体地址, base 为结构体相对于顶层结构体的偏移量, 用于匹配 validator 及 filter.
+// typeName 及 prefix 用于错误信息
+func newFields(t reflect.Type, ptr, base uintptr, typeName, prefix string, validator *Validator, filter *Filter) ([]*Field, error) {
+	var fields []*Field
+	for _, field := range structFields(t, 0) {
 		if !isFieldIgnore(field) {
+			path := prefix + field.Name
 			offset := field.Offset
 			// 过滤字段
 			if filter != nil {
-				if b, ok := filter.except[offset]; ok && b {
+				if b, ok := filter.except[base+offset]; ok && b {
 					continue
 				}
 				lnOnly := len(filter.only)
-				if lnOnly > 0 && !filter.only[offset] {
+				if lnOnly > 0 && !filter.only[base+offset] {
 					continue
 				}
 			}
 
-			kind := fieldKind(field)
+			kind, optional := fieldKind(field)
 			if kind == Invalid {
-				return nil, fmt.Errorf("field '%s': the kind is invalid", field.Name)
+				return nil, fmt.Errorf("field '%s': the kind is invalid", path)
 			}
 			timeLayout := field.Tag.Get(TimeLayoutTag)
 			if timeLayout == "" {
 				timeLayout = DefaultTimeLayout
 			}
 			f := &Field{
-				Name:  fieldName(field),
-				Desc:  fieldDesc(field),
-				Value: fieldDefaultValue(timeLayout, kind, ptr, field.Offset),
-				Kind:  kind,
+				Name:      fieldName(field),
+				Desc:      fieldDesc(field),
+				Kind:      kind,
+				Optional:  optional,
+				In:        field.Tag.Get(TagIn),
+				Sensitive: isFieldSensitive(field),
+				offset:    offset,
+				typ:       field.Type,
+			}
+			if kind.isTime() {
+				f.Layout = timeLayout
+			}
+			if !optional {
+				f.Value = fieldDefaultValue(timeLayout, kind, ptr, field.Offset)
 			}
 
 			var cdt *condition
 			// 添加接口条件
 			if validator != nil {
-				if c, ok := validator.conditions[field.Offset]; ok {
+				if c, ok := validator.conditions[base+field.Offset]; ok {
 					cdt = c
 				}
 			}
@@ -154,20 +247,66 @@ func NewSchema(v interface{}, validator *Validator, filter *Filter) (*Schema, er
 					err := c.Syntax(string(field.Tag))
 					if err != nil {
 						// 语法错误
-						return nil, fmt.Errorf("%T.%s error:%s", v, field.Name, err)
+						return nil, fmt.Errorf("%s.%s error:%s", typeName, path, err)
 					} else {
 						cdt = c
 					}
 				}
 			}
-			f.setter = getSetter(f.Name, timeLayout, kind, offset, f.Value, cdt)
+			switch kind {
+			case Struct:
+				subs, err := newFields(field.Type, ptr+offset, base+offset, typeName, path+".", validator, filter)
+				if err != nil {
+					return nil, err
+				}
+				var nestedValidator *Validator
+				if validator != nil {
+					nestedValidator = validator.nested[base+offset]
+				}
+				f.Fields = subs
+				f.Rules = nestedValidator.structRules()
+				f.setter = newStructSetter(f.Name, offset, subs, newStructCheck(field.Type, nestedValidator), cdt)
+			case SliceStruct:
+				elem := field.Type.Elem()
+				var elemValidator *Validator
+				if validator != nil {
+					elemValidator = validator.elems[base+offset]
+				}
+				subs, err := newFields(elem, reflect.New(elem).Pointer(), 0, typeName, path+"[].", elemValidator, nil)
+				if err != nil {
+					return nil, err
+				}
+				f.Value = reflect.NewAt(field.Type, unsafe.Pointer(ptr+offset)).Elem().Interface()
+				f.Fields = subs
+				f.Rules = elemValidator.structRules()
+				f.setter = newSliceStructSetter(f.Name, field.Type, offset, f.Value, subs, newStructCheck(elem, elemValidator), cdt)
+			case MapString:
+				f.Value = reflect.NewAt(field.Type, unsafe.Pointer(ptr+offset)).Elem().Interface()
+				f.setter = newMapStringSetter(f.Name, field.Type, offset, f.Value, cdt)
+			case FileStream:
+				f.setter = newStreamSetter(f.Name, offset, f.Value.(StreamHandler), cdt)
+			case Time:
+				f.setter = newTimeSetter(f.Name, timeLayout, offset, f.Value.(string), false, cdt)
+			default:
+				if optional || !kind.isBuiltin() {
+					f.Value = reflect.NewAt(field.Type, unsafe.Pointer(ptr+offset)).Elem().Interface()
+					f.setter = newValueSetter(f.Name, field.Type, kind, offset, f.Value, cdt)
+				} else {
+					f.setter = getSetter(f.Name, timeLayout, kind, offset, f.Value, cdt)
+				}
+			}
 			if cdt != nil {
 				f.Condition = cdt.getInfo()
+				f.cdt = cdt
 			}
-			schema.Fields = append(schema.Fields, f)
+			fields = append(fields, f)
 		}
 	}
-	return schema, nil
+	err := checkFieldRefs(fields, typeName, prefix)
+	if err != nil {
+		return nil, err
+	}
+	return fields, nil
 }
 
 func MustNewSchema(v interface{}, validator *Validator, filter *Filter) *Schema {
@@ -178,15 +317,16 @@ func MustNewSchema(v interface{}, validator *Validator, filter *Filter) *Schema
 	return schema
 }
 
-// 获取 struct 的字段偏移量及字段类型, 包括嵌套的字段. 只支持嵌套的 struct, 不支持 struct ptr
+// 获取 struct 的字段偏移量及字段类型, 包括嵌入的字段. 只支持嵌入的 struct, 不支持 struct ptr.
+// 非嵌入的 struct 字段作为嵌套字段处理
 func structFields(structural reflect.Type, fieldsOffset uintptr) (fields []reflect.StructField) {
 	fieldNum := structural.NumField()
 	for idx := 0; idx < fieldNum; idx++ {
 		field := structural.Field(idx)
-		// 嵌套 struct 需要加上父字段的偏移量
+		// 嵌入 struct 需要加上父字段的偏移量
 		field.Offset += fieldsOffset
 		kind := field.Type.Kind()
-		if kind == reflect.Struct { // 只支持 struct, 不支持 struct ptr
+		if kind == reflect.Struct && field.Anonymous { // 只支持 struct, 不支持 struct ptr
 			subFields := structFields(field.Type, field.Offset)
 			fields = append(fields, subFields...)
 		} else {
@@ -212,6 +352,11 @@ func fieldDesc(field reflect.StructField) string {
 	return field.Tag.Get("desc")
 }
 
+func isFieldSensitive(field reflect.StructField) bool {
+	_, ok := field.Tag.Lookup(TagSensitive)
+	return ok
+}
+
 func fieldDefaultValue(layout string, kind Kind, ptr, offset uintptr) interface{} {
 	switch kind {
 	case String:
@@ -237,12 +382,23 @@ func fieldDefaultValue(layout string, kind Kind, ptr, offset uintptr) interface{
 			return ErrFileHandlerIsNil
 		}
 		return f
+	case FileStream:
+		handler := *(*StreamHandler)(unsafe.Pointer(ptr + offset))
+		if handler != nil {
+			return handler
+		}
+		var f StreamHandler = func(field string, file *StreamFile) error {
+			return ErrFileHandlerIsNil
+		}
+		return f
 	case TimePtr:
 		dt := *(**time.Time)(unsafe.Pointer(ptr + offset))
 		if dt == nil {
 			dt = &time.Time{}
 		}
 		return dt.Format(layout)
+	case Time:
+		return (*time.Time)(unsafe.Pointer(ptr + offset)).Format(layout)
 	case SliceString:
 		return *(*[]string)(unsafe.Pointer(ptr + offset))
 	case SliceInt:
@@ -281,7 +437,7 @@ func getSetter(param, timeLayout string, kind Kind, offset uintptr, dvalue inter
 	case File:
 		return newFileSetter(param, offset, dvalue.(FileHandler), cdt)
 	case TimePtr:
-		return newTimeSetter(param, timeLayout, offset, dvalue.(string), cdt)
+		return newTimeSetter(param, timeLayout, offset, dvalue.(string), true, cdt)
 	case SliceString:
 		return newSliceStringSetter(param, offset, dvalue.([]string), cdt)
 	case SliceInt:
@@ -491,6 +647,9 @@ func newFileSetter(param string, offset uintptr, dvalue FileHandler, cdt *condit
 	return func(begin uintptr, form *multipart.Form) (err error) {
 		if cdt != nil {
 			err = cdt.validFile(param, form)
+			if err == nil {
+				err = cdt.validFileContents(param, form)
+			}
 			if err != nil {
 				return err
 			}
@@ -509,7 +668,8 @@ func newFileSetter(param string, offset uintptr, dvalue FileHandler, cdt *condit
 	}
 }
 
-func newTimeSetter(param, layout string, offset uintptr, dvalue string, cdt *condition) setter {
+// 时间类型的设置器, ptr 为 true 时字段为 *time.Time, 否则为 time.Time
+func newTimeSetter(param, layout string, offset uintptr, dvalue string, ptr bool, cdt *condition) setter {
 	return func(begin uintptr, form *multipart.Form) (err error) {
 		if cdt != nil {
 			err = cdt.validTime(param, form)
@@ -526,7 +686,11 @@ func newTimeSetter(param, layout string, offset uintptr, dvalue string, cdt *con
 		if err != nil {
 			return
 		}
-		*(**time.Time)(unsafe.Pointer(begin + offset)) = &t
+		if ptr {
+			*(**time.Time)(unsafe.Pointer(begin + offset)) = &t
+		} else {
+			*(*time.Time)(unsafe.Pointer(begin + offset)) = t
+		}
 		return
 	}
 }
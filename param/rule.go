@@ -0,0 +1,457 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package param
+
+import (
+	"fmt"
+	"mime/multipart"
+	"net"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// 自定义规则及跨字段验证标签
+const (
+	TagValidate   = "validate"    // 自定义规则, 多个规则以空格分隔, 规则参数以 "=" 分隔, 如: validate:"phone-cn prefix=86"
+	TagRequiredIf = "required-if" // 条件必填, 如: required-if:"type=company", 只有字段名则表示该字段存在时必填
+	TagEqField    = "eqfield"     // 等于另一个字段, 如: eqfield:"password"
+	TagNeField    = "nefield"
+	TagGtField    = "gtfield"
+	TagGteField   = "gtefield"
+	TagLtField    = "ltfield"
+	TagLteField   = "ltefield"
+)
+
+// 字段比较标签及比较操作
+var compareTags = map[string]string {
+	TagEqField:  "eq",
+	TagNeField:  "ne",
+	TagGtField:  "gt",
+	TagGteField: "gte",
+	TagLtField:  "lt",
+	TagLteField: "lte",
+}
+
+// RuleFunc 为自定义验证规则, value 为参数值, arg 为规则参数, 如: validate:"prefix=86" 的 arg 为 "86"
+type RuleFunc func(value, arg string) bool
+
+// Rule 为已注册的验证规则
+type Rule struct {
+	Name    string
+	Desc    string // 规则描述, 用于接口文档
+	Message string // 默认错误信息
+	check   RuleFunc
+}
+
+var (
+	rules   = make(map[string]*Rule)
+	rulesMu sync.RWMutex
+)
+
+// RegisterRule 注册验证规则, 同名规则会被覆盖. 规则在创建 Schema 时查找, 所以需要在注册路由之前注册
+func RegisterRule(name, desc, msg string, check RuleFunc) {
+	if msg == "" {
+		msg = name
+	}
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+	rules[name] = &Rule{Name: name, Desc: desc, Message: msg, check: check}
+}
+
+// Rules 获得所有已注册的规则, 按名称排序
+func Rules() []*Rule {
+	rulesMu.RLock()
+	defer rulesMu.RUnlock()
+	rs := make([]*Rule, 0, len(rules))
+	for _, rule := range rules {
+		rs = append(rs, rule)
+	}
+	sort.Slice(rs, func(i, j int) bool {
+		return rs[i].Name < rs[j].Name
+	})
+	return rs
+}
+
+func getRule(name string) *Rule {
+	rulesMu.RLock()
+	defer rulesMu.RUnlock()
+	return rules[name]
+}
+
+var phoneCNExp = regexp.MustCompile(`^1[3-9]\d{9}$`)
+
+var alphanumExp = regexp.MustCompile(`^[a-zA-Z0-9]+$`)
+
+func init() {
+	RegisterRule("phone-cn", "中国大陆手机号", "phone number incorrect", func(value, arg string) bool {
+		return phoneCNExp.MatchString(value)
+	})
+	RegisterRule("alphanum", "只包含字母及数字", "only letters and numbers", func(value, arg string) bool {
+		return alphanumExp.MatchString(value)
+	})
+	RegisterRule("ip", "IPv4 或 IPv6 地址", "ip address incorrect", func(value, arg string) bool {
+		return net.ParseIP(value) != nil
+	})
+	RegisterRule("url", "包含协议及主机的 URL", "url incorrect", func(value, arg string) bool {
+		u, err := url.ParseRequestURI(value)
+		return err == nil && u.Scheme != "" && u.Host != ""
+	})
+	RegisterRule("prefix", "以规则参数开头, 如: prefix=86", "prefix incorrect", func(value, arg string) bool {
+		return strings.HasPrefix(value, arg)
+	})
+}
+
+// RuleCall 为字段使用的规则
+type RuleCall struct {
+	Name    string
+	Arg     string `json:",omitempty"`
+	Message string
+	rule    *Rule
+}
+
+// RequiredIf 为条件必填, Value 为空则表示 Field 存在时必填
+type RequiredIf struct {
+	Field   string
+	Value   string `json:",omitempty"`
+	Message string
+}
+
+// FieldCompare 为字段比较条件, Op 为 eq, ne, gt, gte, lt, lte 之一
+type FieldCompare struct {
+	Op      string
+	Field   string
+	Message string
+}
+
+// 添加自定义规则
+func (c *condition) Rule(name, arg, msg string) *condition {
+	rule := getRule(name)
+	if rule == nil {
+		panic(fmt.Errorf("validate rule '%s' is not registered", name))
+	}
+	if msg == "" {
+		msg = rule.Message
+	}
+	c.rules = append(c.rules, &RuleCall{Name: name, Arg: arg, Message: msg, rule: rule})
+	return c
+}
+
+// 字段 field 的值等于 value 时必填, value 为空则表示 field 存在时必填
+func (c *condition) RequiredIf(field, value, msg string) *condition {
+	if msg == "" {
+		if value == "" {
+			msg = fmt.Sprintf("required if %s exists", field)
+		} else {
+			msg = fmt.Sprintf("required if %s is %s", field, value)
+		}
+	}
+	c.requiredIf = &RequiredIf{Field: field, Value: value, Message: msg}
+	return c
+}
+
+// 与另一个字段比较, op 为 eq, ne, gt, gte, lt, lte 之一. 两个字段都存在时才比较
+func (c *condition) CompareField(op, field, msg string) *condition {
+	if msg == "" {
+		msg = fmt.Sprintf("must %s %s", op, field)
+	}
+	c.fieldCompares = append(c.fieldCompares, &FieldCompare{Op: op, Field: field, Message: msg})
+	return c
+}
+
+func (c *condition) syntaxRules(tag reflect.StructTag) error {
+	if syntax, ok := tag.Lookup(TagValidate); ok {
+		msg := tag.Get(MsgName(TagValidate))
+		for _, call := range strings.Split(syntax, Separator) {
+			call = strings.TrimSpace(call)
+			if call == "" {
+				continue
+			}
+			var arg string
+			if idx := strings.Index(call, "="); idx > 0 {
+				call, arg = call[:idx], call[idx+1:]
+			}
+			if getRule(call) == nil {
+				return fmt.Errorf("validate rule '%s' is not registered", call)
+			}
+			c.Rule(call, arg, msg)
+		}
+	}
+	if syntax, ok := tag.Lookup(TagRequiredIf); ok {
+		field, value := syntax, ""
+		if idx := strings.Index(syntax, "="); idx > 0 {
+			field, value = syntax[:idx], syntax[idx+1:]
+		}
+		c.RequiredIf(strings.TrimSpace(field), strings.TrimSpace(value), tag.Get(MsgName(TagRequiredIf)))
+	}
+	for _, t := range []string{TagEqField, TagNeField, TagGtField, TagGteField, TagLtField, TagLteField} {
+		if field, ok := tag.Lookup(t); ok {
+			c.CompareField(compareTags[t], strings.TrimSpace(field), tag.Get(MsgName(t)))
+		}
+	}
+	return nil
+}
+
+// 获得字段的参数值, 用于自定义规则验证
+func fieldValues(f *Field, form *multipart.Form) []string {
+	switch f.Kind {
+	case SliceString, SliceInt, SliceInt32, SliceInt64, SliceFloat32, SliceFloat64, SliceBool:
+		return getSliceValues(f.Name, form)
+	case File, FileStream, Struct, SliceStruct, MapString:
+		return nil
+	default:
+		if value := url.Values(form.Value).Get(f.Name); value != "" {
+			return []string{value}
+		}
+		return nil
+	}
+}
+
+// 检测字段参数是否存在
+func fieldExist(f *Field, form *multipart.Form) bool {
+	switch f.Kind {
+	case File, FileStream:
+		return len(form.File[f.Name]) > 0
+	case Struct, MapString:
+		_, exist := subForm(f.Name, form)
+		return exist
+	case SliceStruct:
+		forms, _ := subForms(f.Name, form)
+		return len(forms) > 0
+	default:
+		return len(fieldValues(f, form)) > 0
+	}
+}
+
+func findField(fields []*Field, name string) *Field {
+	for _, f := range fields {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// 检测字段比较及条件必填所引用的字段是否存在
+func checkFieldRefs(fields []*Field, typeName, prefix string) error {
+	for _, f := range fields {
+		if f.cdt == nil {
+			continue
+		}
+		var refs []string
+		if ri := f.cdt.requiredIf; ri != nil {
+			refs = append(refs, ri.Field)
+		}
+		for _, fc := range f.cdt.fieldCompares {
+			if _, ok := compareOps[fc.Op]; !ok {
+				return fmt.Errorf("%s.%s%s error:unknown compare operation '%s'", typeName, prefix, f.Name, fc.Op)
+			}
+			refs = append(refs, fc.Field)
+		}
+		for _, ref := range refs {
+			if findField(fields, ref) == nil {
+				return fmt.Errorf("%s.%s%s error:field '%s' not found", typeName, prefix, f.Name, ref)
+			}
+		}
+	}
+	return nil
+}
+
+// 验证条件必填, 自定义规则及字段比较, 在设置完非文件字段之后, 保存文件之前调用
+func (c *condition) validCross(field *Field, pointer uintptr, fields []*Field, form *multipart.Form) error {
+	// 流式上传时文件在验证之后才读取, 文件字段的条件必填不生效
+	if ri := c.requiredIf; ri != nil && !(field.Kind == FileStream && form.File == nil) && !fieldExist(field, form) {
+		if other := findField(fields, ri.Field); other != nil && fieldExist(other, form) {
+			if ri.Value == "" || url.Values(form.Value).Get(ri.Field) == ri.Value {
+				return &ValidatorErr{Field: field.Name, Message: ri.Message, Kind: ConditionRequired}
+			}
+		}
+	}
+	for _, call := range c.rules {
+		for _, value := range fieldValues(field, form) {
+			if !call.rule.check(value, call.Arg) {
+				return &ValidatorErr{Field: field.Name, Message: call.Message, Kind: ConditionRule}
+			}
+		}
+	}
+	if len(c.fieldCompares) > 0 && fieldExist(field, form) {
+		for _, fc := range c.fieldCompares {
+			other := findField(fields, fc.Field)
+			if other == nil || !fieldExist(other, form) {
+				continue
+			}
+			a := reflect.NewAt(field.typ, unsafe.Pointer(pointer+field.offset)).Elem()
+			b := reflect.NewAt(other.typ, unsafe.Pointer(pointer+other.offset)).Elem()
+			if !compareValues(fc.Op, a, b) {
+				return &ValidatorErr{Field: field.Name, Message: fc.Message, Kind: ConditionField}
+			}
+		}
+	}
+	return nil
+}
+
+var compareOps = map[string]func(n int) bool {
+	"eq":  func(n int) bool { return n == 0 },
+	"ne":  func(n int) bool { return n != 0 },
+	"gt":  func(n int) bool { return n > 0 },
+	"gte": func(n int) bool { return n >= 0 },
+	"lt":  func(n int) bool { return n < 0 },
+	"lte": func(n int) bool { return n <= 0 },
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// 比较两个字段的值, 支持数字, 字符串, 时间类型, 其他类型只支持 eq 及 ne
+func compareValues(op string, a, b reflect.Value) bool {
+	for a.Kind() == reflect.Ptr {
+		if a.IsNil() {
+			return true
+		}
+		a = a.Elem()
+	}
+	for b.Kind() == reflect.Ptr {
+		if b.IsNil() {
+			return true
+		}
+		b = b.Elem()
+	}
+	n, ok := compareOrder(a, b)
+	if !ok {
+		switch op {
+		case "eq":
+			return reflect.DeepEqual(a.Interface(), b.Interface())
+		case "ne":
+			return !reflect.DeepEqual(a.Interface(), b.Interface())
+		default:
+			return false
+		}
+	}
+	return compareOps[op](n)
+}
+
+func compareOrder(a, b reflect.Value) (n int, ok bool) {
+	if a.Type() == timeType && b.Type() == timeType {
+		ta, tb := a.Interface().(time.Time), b.Interface().(time.Time)
+		switch {
+		case ta.Before(tb):
+			return -1, true
+		case ta.After(tb):
+			return 1, true
+		default:
+			return 0, true
+		}
+	}
+	if a.Kind() == reflect.String && b.Kind() == reflect.String {
+		return strings.Compare(a.String(), b.String()), true
+	}
+	fa, okA := floatValue(a)
+	fb, okB := floatValue(b)
+	if !okA || !okB {
+		return 0, false
+	}
+	switch {
+	case fa < fb:
+		return -1, true
+	case fa > fb:
+		return 1, true
+	default:
+		return 0, true
+	}
+}
+
+func floatValue(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+// StructValidator 由参数结构体(指针接收者)实现, 在所有字段解析及验证完成之后, 保存文件之前调用.
+// 返回的错误不是 *ValidatorErr 时, 作为整个结构体的错误
+type StructValidator interface {
+	Validate() error
+}
+
+var structValidatorType = reflect.TypeOf(new(StructValidator)).Elem()
+
+type structHook struct {
+	desc  string
+	check func(v interface{}) error
+}
+
+// Struct 添加结构体验证, check 的参数为解析后的结构体指针, desc 用于接口文档, 如:
+//
+//	v.Struct("结束时间必须晚于开始时间", func(v interface{}) error {
+//		p := v.(*Param)
+//		...
+//	})
+func (v *Validator) Struct(desc string, check func(v interface{}) error) *Validator {
+	v.structs = append(v.structs, &structHook{desc: desc, check: check})
+	return v
+}
+
+func (v *Validator) structRules() (descs []string) {
+	if v == nil {
+		return nil
+	}
+	for _, hook := range v.structs {
+		if hook.desc != "" {
+			descs = append(descs, hook.desc)
+		}
+	}
+	return descs
+}
+
+// 结构体验证, 参数为结构体地址
+type structCheck func(pointer uintptr) error
+
+func newStructCheck(t reflect.Type, validator *Validator) structCheck {
+	var hooks []*structHook
+	if validator != nil {
+		hooks = validator.structs
+	}
+	implements := reflect.PtrTo(t).Implements(structValidatorType)
+	if !implements && len(hooks) == 0 {
+		return nil
+	}
+	return func(pointer uintptr) (err error) {
+		v := reflect.NewAt(t, unsafe.Pointer(pointer)).Interface()
+		if implements {
+			err = v.(StructValidator).Validate()
+			if err != nil {
+				return structErr(err)
+			}
+		}
+		for _, hook := range hooks {
+			err = hook.check(v)
+			if err != nil {
+				return structErr(err)
+			}
+		}
+		return nil
+	}
+}
+
+func structErr(err error) error {
+	if _, ok := err.(*ValidatorErr); ok {
+		return err
+	}
+	return &ValidatorErr{Message: err.Error(), Kind: ConditionStruct}
+}
+
+// FieldErr 用于结构体验证返回字段错误
+func FieldErr(field, msg string) *ValidatorErr {
+	return &ValidatorErr{Field: field, Message: msg, Kind: ConditionStruct}
+}
@@ -0,0 +1,160 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package param_test
+
+import (
+	"errors"
+	"github.com/orivil/morgine/param"
+	"mime/multipart"
+	"strings"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+type register struct {
+	Type     string     `param:"type" enum:"person company"`
+	Company  string     `param:"company" required-if:"type=company" required-if-msg:"company required"`
+	Phone    string     `param:"phone" validate:"phone-cn"`
+	Code     string     `param:"code" validate:"upper prefix=AB" validate-msg:"code incorrect"`
+	Password string     `param:"password"`
+	Confirm  string     `param:"confirm" eqfield:"password" eqfield-msg:"password not match"`
+	StartAt  *time.Time `param:"start_at"`
+	EndAt    *time.Time `param:"end_at" gtfield:"start_at"`
+	Min      int        `param:"min"`
+	Max      *int       `param:"max" gtefield:"min"`
+}
+
+var errReserved = errors.New("reserved company")
+
+func (r *register) Validate() error {
+	if r.Company == "orivil" {
+		return errReserved
+	}
+	return nil
+}
+
+func init() {
+	param.RegisterRule("upper", "大写字母或数字", "upper only", func(value, arg string) bool {
+		return strings.ToUpper(value) == value
+	})
+}
+
+func TestSchema_ParseRules(t *testing.T) {
+	schema := param.MustNewSchema(&register{}, nil, nil)
+	valid := map[string][]string {
+		"type":     {"company"},
+		"company":  {"morgine"},
+		"phone":    {"13800138000"},
+		"code":     {"AB12"},
+		"password": {"secret"},
+		"confirm":  {"secret"},
+		"start_at": {"2020-01-01T00:00:00"},
+		"end_at":   {"2020-01-02T00:00:00"},
+		"min":      {"3"},
+		"max":      {"3"},
+	}
+	err := schema.Parse(uintptr(unsafe.Pointer(&register{})), &multipart.Form{Value: valid})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		field string
+		value string
+		kind  param.ConditionKind
+		msg   string
+	}{
+		{"company", "", param.ConditionRequired, "company required"},
+		{"phone", "12345", param.ConditionRule, "phone number incorrect"},
+		{"code", "ab12", param.ConditionRule, "code incorrect"},
+		{"code", "CD12", param.ConditionRule, "code incorrect"},
+		{"confirm", "other", param.ConditionField, "password not match"},
+		{"end_at", "2019-12-31T00:00:00", param.ConditionField, "must gt start_at"},
+		{"max", "2", param.ConditionField, "must gte min"},
+		{"company", "orivil", param.ConditionStruct, errReserved.Error()},
+	}
+	for _, c := range cases {
+		values := make(map[string][]string, len(valid))
+		for key, value := range valid {
+			values[key] = value
+		}
+		if c.value == "" {
+			delete(values, c.field)
+		} else {
+			values[c.field] = []string{c.value}
+		}
+		err := schema.Parse(uintptr(unsafe.Pointer(&register{})), &multipart.Form{Value: values})
+		ve, ok := err.(*param.ValidatorErr)
+		if !ok || ve.Kind != c.kind || ve.Message != c.msg {
+			t.Errorf("%s=%q: need %s error %q, got: %v", c.field, c.value, param.Conditions[c.kind], c.msg, err)
+			continue
+		}
+		if c.kind != param.ConditionStruct && ve.Field != c.field {
+			t.Errorf("%s=%q: need error of field %s, got: %s", c.field, c.value, c.field, ve.Field)
+		}
+	}
+
+	// 比较的字段不存在时不比较, 条件不满足时不必填
+	optional := map[string][]string{"type": {"person"}, "end_at": {"2019-12-31T00:00:00"}, "max": {"-1"}}
+	err = schema.Parse(uintptr(unsafe.Pointer(&register{})), &multipart.Form{Value: optional})
+	if err != nil {
+		t.Errorf("need nil, got: %v", err)
+	}
+}
+
+func TestValidator_Struct(t *testing.T) {
+	type period struct {
+		From int `param:"from"`
+		To   int `param:"to"`
+	}
+	p := &period{}
+	v := param.NewValidator(p)
+	v.Field(&p.To).CompareField("gt", "from", "to must be greater than from")
+	v.Struct("时间跨度不能超过 10", func(v interface{}) error {
+		p := v.(*period)
+		if p.To-p.From > 10 {
+			return param.FieldErr("to", "period too long")
+		}
+		return nil
+	})
+	schema := param.MustNewSchema(p, v, nil)
+	if len(schema.Rules) != 1 || schema.Fields[1].Condition.FieldCompares[0].Field != "from" {
+		t.Errorf("need rules in document, got: %v %+v", schema.Rules, schema.Fields[1].Condition)
+	}
+	cases := []struct {
+		from, to string
+		msg      string
+	}{
+		{"1", "5", ""},
+		{"5", "1", "to must be greater than from"},
+		{"1", "20", "period too long"},
+	}
+	for _, c := range cases {
+		form := &multipart.Form{Value: map[string][]string{"from": {c.from}, "to": {c.to}}}
+		err := schema.Parse(uintptr(unsafe.Pointer(&period{})), form)
+		if c.msg == "" {
+			if err != nil {
+				t.Errorf("need nil, got: %v", err)
+			}
+		} else if ve, ok := err.(*param.ValidatorErr); !ok || ve.Field != "to" || ve.Message != c.msg {
+			t.Errorf("need error %q, got: %v", c.msg, err)
+		}
+	}
+}
+
+func TestNewSchema_RuleError(t *testing.T) {
+	type unknownRule struct {
+		Name string `validate:"not-registered"`
+	}
+	type unknownField struct {
+		Name string `eqfield:"not-exist"`
+	}
+	for _, v := range []interface{}{&unknownRule{}, &unknownField{}} {
+		if _, err := param.NewSchema(v, nil, nil); err == nil {
+			t.Errorf("%T: need error", v)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package param
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/textproto"
+	"unsafe"
+)
+
+// 流式上传时普通字段的最大字节数总和
+var MaxStreamValueBytes = int64(10 << 20) // 10MB
+
+var ErrStreamValueTooLarge = errors.New("multipart values too large")
+
+// StreamHandler 定义流式上传文件处理函数, field 是上传文件的字段名. 文件内容在读取 file 时才从请求体中读取,
+// 不会缓存到内存或临时文件中, 可直接写入存储, 如:
+//
+//	Video: func(field string, file *param.StreamFile) error {
+//		return storage.WriteStream(store, name, file)
+//	}
+//
+// 文件大小在读取时检测, 超出限制时 Read 方法返回 *ValidatorErr, 文件过小则在处理函数返回之后才能检测到,
+// 此时文件可能已被保存
+type StreamHandler func(field string, file *StreamFile) error
+
+func (h StreamHandler) MarshalJSON() ([]byte, error) {
+	return []byte(`""`), nil
+}
+
+// StreamFile 为上传的文件流
+type StreamFile struct {
+	Filename string
+	Header   textproto.MIMEHeader
+	field    string
+	r        io.Reader
+	size     int64
+	cdt      *condition
+	err      error
+}
+
+// 读取文件内容, 超出大小限制时返回 *ValidatorErr
+func (f *StreamFile) Read(p []byte) (n int, err error) {
+	if f.err != nil {
+		return 0, f.err
+	}
+	n, err = f.r.Read(p)
+	f.size += int64(n)
+	if f.cdt != nil && f.cdt.maxFileByte != nil && f.size > *f.cdt.maxFileByte {
+		f.err = f.cdt.validFileSize(f.field, f.size)
+		return n, f.err
+	}
+	return n, err
+}
+
+// ContentType 获得客户端提交的文件类型
+func (f *StreamFile) ContentType() string {
+	return f.Header.Get("Content-Type")
+}
+
+// Size 获得已读取的字节数
+func (f *StreamFile) Size() int64 {
+	return f.size
+}
+
+// 验证文件信息并调用处理函数, 处理函数未读取完的数据会被丢弃, 用于验证文件大小
+func handleStream(handler StreamHandler, file *StreamFile) (err error) {
+	if file.cdt != nil {
+		err = file.cdt.validFileHeader(file.field, file.Filename, file.ContentType())
+		if err != nil {
+			return err
+		}
+		file.r, err = file.cdt.validFileContent(file.field, file.r)
+		if err != nil {
+			return err
+		}
+	}
+	err = handler(file.field, file)
+	if file.err != nil {
+		return file.err
+	}
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(ioutil.Discard, file)
+	if err != nil {
+		return err
+	}
+	if file.cdt != nil {
+		return file.cdt.validFileSize(file.field, file.size)
+	}
+	return nil
+}
+
+func getStreamHandler(begin uintptr, f *Field) StreamHandler {
+	handler := *(*StreamHandler)(unsafe.Pointer(begin + f.offset))
+	if handler == nil {
+		handler = f.Value.(StreamHandler)
+	}
+	return handler
+}
+
+// 非流式解析时, 从已缓存的文件中读取
+func newStreamSetter(param string, offset uintptr, dvalue StreamHandler, cdt *condition) setter {
+	return func(begin uintptr, form *multipart.Form) (err error) {
+		if cdt != nil {
+			err = cdt.validFile(param, form)
+			if err != nil {
+				return err
+			}
+		}
+		handler := *(*StreamHandler)(unsafe.Pointer(begin + offset))
+		if handler == nil {
+			handler = dvalue
+		}
+		for _, header := range form.File[param] {
+			err = openStream(param, header, handler, cdt)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func openStream(param string, header *multipart.FileHeader, handler StreamHandler, cdt *condition) error {
+	file, err := header.Open()
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return handleStream(handler, &StreamFile {
+		Filename: header.Filename,
+		Header:   header.Header,
+		field:    param,
+		r:        file,
+		cdt:      cdt,
+	})
+}
+
+// IsStream 检测是否包含流式上传字段
+func (s *Schema) IsStream() bool {
+	for _, field := range s.Fields {
+		if field.Kind == FileStream {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseStream 边读取请求体边解析数据, 文件字段的处理函数在读取到文件时调用.
+// 只有文件之前的普通字段会被解析, 所以客户端需要先提交普通字段再提交文件. 嵌套结构体中的文件字段不会被处理
+func (s *Schema) ParseStream(pointer uintptr, reader *multipart.Reader) (err error) {
+	form := &multipart.Form{Value: make(map[string][]string)}
+	var valueBytes int64
+	prepared := false
+	counts := make(map[string]int)
+	for {
+		var part *multipart.Part
+		part, err = reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		name := part.FormName()
+		if part.FileName() == "" {
+			if prepared {
+				continue
+			}
+			var data []byte
+			data, err = ioutil.ReadAll(io.LimitReader(part, MaxStreamValueBytes-valueBytes+1))
+			if err != nil {
+				return err
+			}
+			valueBytes += int64(len(data))
+			if valueBytes > MaxStreamValueBytes {
+				return ErrStreamValueTooLarge
+			}
+			form.Value[name] = append(form.Value[name], string(data))
+			continue
+		}
+		if !prepared {
+			prepared = true
+			err = parseValues(pointer, s.Fields, form, s.check)
+			if err != nil {
+				return err
+			}
+		}
+		field := findField(s.Fields, name)
+		if field == nil || !field.Kind.isFile() {
+			continue
+		}
+		if field.Kind != FileStream {
+			return fmt.Errorf("field '%s' does not support streaming, use param.StreamHandler instead", name)
+		}
+		counts[name]++
+		if field.cdt != nil && field.cdt.maxItem != nil && counts[name] > *field.cdt.maxItem {
+			return field.cdt.validFileCount(name, counts[name])
+		}
+		err = handleStream(getStreamHandler(pointer, field), &StreamFile {
+			Filename: part.FileName(),
+			Header:   part.Header,
+			field:    name,
+			r:        part,
+			cdt:      field.cdt,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if !prepared {
+		err = parseValues(pointer, s.Fields, form, s.check)
+		if err != nil {
+			return err
+		}
+	}
+	for _, field := range s.Fields {
+		if field.Kind.isFile() && field.cdt != nil {
+			err = field.cdt.validFileCount(field.Name, counts[field.Name])
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
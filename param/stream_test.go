@@ -0,0 +1,117 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package param_test
+
+import (
+	"bytes"
+	"github.com/orivil/morgine/param"
+	"io"
+	"mime/multipart"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+type upload struct {
+	Title string              `param:"title" required:"title required"`
+	Video param.StreamHandler `param:"video" required:"video required" exts:".mp4" size-Byte:"4-10" item:"1-2"`
+}
+
+type part struct {
+	field, filename, content string
+}
+
+func newMultipart(t *testing.T, parts []part) (*bytes.Buffer, string) {
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	for _, p := range parts {
+		var err error
+		if p.filename == "" {
+			err = w.WriteField(p.field, p.content)
+		} else {
+			var fw io.Writer
+			fw, err = w.CreateFormFile(p.field, p.filename)
+			if err == nil {
+				_, err = fw.Write([]byte(p.content))
+			}
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body, w.Boundary()
+}
+
+func TestSchema_ParseStream(t *testing.T) {
+	schema := param.MustNewSchema(&upload{}, nil, nil)
+	if !schema.IsStream() || schema.EncodeType() != param.FormDataEncodeType {
+		t.Fatal("need stream schema")
+	}
+	cases := []struct {
+		parts []part
+		saved []string
+		field string
+		kind  param.ConditionKind
+	}{
+		{parts: []part{{"title", "", "a"}, {"video", "a.mp4", "12345"}, {"video", "b.mp4", "abcdef"}}, saved: []string{"a.mp4:12345", "b.mp4:abcdef"}},
+		{parts: []part{{"video", "a.mp4", "12345"}}, field: "title", kind: param.ConditionRequired},
+		{parts: []part{{"title", "", "a"}}, field: "video", kind: param.ConditionRequired},
+		{parts: []part{{"title", "", "a"}, {"video", "a.avi", "12345"}}, field: "video", kind: param.ConditionFileExtensions},
+		{parts: []part{{"title", "", "a"}, {"video", "a.mp4", "12345678901"}}, field: "video", kind: param.ConditionFileSize},
+		{parts: []part{{"title", "", "a"}, {"video", "a.mp4", "123"}}, field: "video", kind: param.ConditionFileSize},
+		{parts: []part{{"title", "", "a"}, {"video", "a.mp4", "1234"}, {"video", "b.mp4", "1234"}, {"video", "c.mp4", "1234"}}, field: "video", kind: param.ConditionItem},
+	}
+	for i, c := range cases {
+		body, boundary := newMultipart(t, c.parts)
+		var saved []string
+		u := &upload {
+			Video: func(field string, file *param.StreamFile) error {
+				buf := &bytes.Buffer{}
+				_, err := io.Copy(buf, file)
+				if err != nil {
+					return err
+				}
+				saved = append(saved, file.Filename+":"+buf.String())
+				return nil
+			},
+		}
+		err := schema.ParseStream(uintptr(unsafe.Pointer(u)), multipart.NewReader(body, boundary))
+		if c.field == "" {
+			if err != nil {
+				t.Errorf("case %d: need nil, got: %v", i, err)
+			} else if strings.Join(saved, ",") != strings.Join(c.saved, ",") || u.Title != "a" {
+				t.Errorf("case %d: need saved: %v got: %v", i, c.saved, saved)
+			}
+		} else if ve, ok := err.(*param.ValidatorErr); !ok || ve.Field != c.field || ve.Kind != c.kind {
+			t.Errorf("case %d: need %s error of field %s, got: %v", i, param.Conditions[c.kind], c.field, err)
+		}
+	}
+}
+
+func TestSchema_ParseStreamBuffered(t *testing.T) {
+	schema := param.MustNewSchema(&upload{}, nil, nil)
+	body, boundary := newMultipart(t, []part{{"title", "", "a"}, {"video", "a.mp4", "12345"}})
+	form, err := multipart.NewReader(body, boundary).ReadForm(1 << 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got string
+	u := &upload {
+		Video: func(field string, file *param.StreamFile) error {
+			buf := &bytes.Buffer{}
+			_, err := io.Copy(buf, file)
+			got = buf.String()
+			return err
+		},
+	}
+	err = schema.Parse(uintptr(unsafe.Pointer(u)), form)
+	if err != nil || got != "12345" {
+		t.Errorf("need nil and 12345, got: %v %s", err, got)
+	}
+}
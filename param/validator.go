@@ -79,6 +79,28 @@ type condition struct {
 	pattern  *string
 	regexp   *regexp.Regexp
 	regMsgID *string
+
+	// custom rule condition
+	rules []*RuleCall
+
+	// conditional required
+	requiredIf *RequiredIf
+
+	// cross-field condition
+	fieldCompares []*FieldCompare
+
+	// file content condition
+	fileSniffTypes []string
+	fileSniffMsgID *string
+
+	// image condition
+	minWidth   *int
+	maxWidth   *int
+	minHeight  *int
+	maxHeight  *int
+	ratio      *string
+	ratioValue float64
+	imageMsgID *string
 }
 
 type info struct {
@@ -122,6 +144,28 @@ type info struct {
 	Pattern  *string `json:",omitempty"`
 	regexp   *regexp.Regexp
 	RegMsgID *string `json:",omitempty"`
+
+	// custom rule condition
+	Rules []*RuleCall `json:",omitempty"`
+
+	// conditional required
+	RequiredIf *RequiredIf `json:",omitempty"`
+
+	// cross-field condition
+	FieldCompares []*FieldCompare `json:",omitempty"`
+
+	// file content condition
+	FileSniffTypes []string `json:",omitempty"`
+	FileSniffMsgID *string  `json:",omitempty"`
+
+	// image condition
+	MinWidth   *int    `json:",omitempty"`
+	MaxWidth   *int    `json:",omitempty"`
+	MinHeight  *int    `json:",omitempty"`
+	MaxHeight  *int    `json:",omitempty"`
+	Ratio      *string `json:",omitempty"`
+	ratioValue float64
+	ImageMsgID *string `json:",omitempty"`
 }
 
 func (c *condition) getInfo() *info {
@@ -300,6 +344,20 @@ func isCondition(tag reflect.StructTag) bool {
 		TagFileMB,
 		TagFileExt,
 		TagFileType,
+		TagValidate,
+		TagRequiredIf,
+		TagEqField,
+		TagNeField,
+		TagGtField,
+		TagGteField,
+		TagLtField,
+		TagLteField,
+		TagFileSniff,
+		TagMinWidth,
+		TagMaxWidth,
+		TagMinHeight,
+		TagMaxHeight,
+		TagRatio,
 	}
 	return containsTags(tag, tags)
 }
@@ -402,12 +460,19 @@ func (c *condition) Syntax(syntax string) error {
 		msg := tag.Get(MsgName(TagEnum))
 		c.Enums(enums, msg)
 	}
-	return nil
+	err := c.syntaxContent(tag)
+	if err != nil {
+		return err
+	}
+	return c.syntaxRules(tag)
 }
 
 type Validator struct {
 	ptr        uintptr
 	conditions map[uintptr]*condition
+	elems      map[uintptr]*Validator
+	nested     map[uintptr]*Validator
+	structs    []*structHook
 }
 
 func (v *Validator) offsetField(offset uintptr) *condition {
@@ -423,10 +488,43 @@ func (v *Validator) Field(pointer interface{}) *condition {
 	return v.offsetField(offset)
 }
 
+// Elem 获得结构体切片元素的验证器, slice 为结构体切片字段指针, elem 为元素结构体指针, 如:
+//
+//	item := &Item{}
+//	v.Elem(&p.Items, item).Field(&item.Name).Required("")
+func (v *Validator) Elem(slice interface{}, elem interface{}) *Validator {
+	offset := reflect.ValueOf(slice).Pointer() - v.ptr
+	ev := NewValidator(elem)
+	v.elems[offset] = ev
+	return ev
+}
+
+// Nested 获得嵌套结构体字段的验证器, 用于添加该结构体的 Struct 验证, field 为嵌套结构体字段指针, 如:
+//
+//	v.Nested(&p.Range).Struct("结束日期不能早于开始日期", func(v interface{}) error {
+//		r := v.(*DateRange)
+//		...
+//	})
+//
+// 返回的验证器与 v 共用字段条件, 其 Field 及 Elem 与 v 的相同
+func (v *Validator) Nested(field interface{}) *Validator {
+	offset := reflect.ValueOf(field).Pointer() - v.ptr
+	nv := &Validator {
+		ptr:        v.ptr,
+		conditions: v.conditions,
+		elems:      v.elems,
+		nested:     v.nested,
+	}
+	v.nested[offset] = nv
+	return nv
+}
+
 func NewValidator(schema interface{}) *Validator {
 	return &Validator{
 		ptr:        reflect.ValueOf(schema).Pointer(),
 		conditions: make(map[uintptr]*condition),
+		elems:      make(map[uintptr]*Validator),
+		nested:     make(map[uintptr]*Validator),
 	}
 }
 
@@ -443,8 +541,13 @@ type ValidatorErr struct {
 
 type ConditionKind int
 
+// 使用默认信息目录的默认语言翻译错误信息
 func (re *ValidatorErr) Error() string {
-	return fmt.Sprintf("%s: %s", re.Field, re.Message)
+	msg := Messages.Translate(nil, re)
+	if re.Field == "" {
+		return msg
+	}
+	return fmt.Sprintf("%s: %s", re.Field, msg)
 }
 
 const (
@@ -459,6 +562,12 @@ const (
 	ConditionFileExtensions
 	ConditionFileMimeTypes
 	ConditionEnums
+	ConditionInvalidValue
+	ConditionRule
+	ConditionField
+	ConditionStruct
+	ConditionFileContent
+	ConditionImage
 )
 
 var Conditions = map[ConditionKind]string{
@@ -473,6 +582,12 @@ var Conditions = map[ConditionKind]string{
 	ConditionFileExtensions: "file-extensions",
 	ConditionFileMimeTypes:  "file-mime-types",
 	ConditionEnums:          "enums",
+	ConditionInvalidValue:   "invalid-value",
+	ConditionRule:           "rule",
+	ConditionField:          "field-compare",
+	ConditionStruct:         "struct",
+	ConditionFileContent:    "file-content",
+	ConditionImage:          "image",
 }
 
 // 用非空指针表示 equal, 空指针表示 not equal
@@ -541,37 +656,65 @@ func (c *condition) validNum(field string, valueStr string, value float64) (err
 	if c.minNum != nil {
 		if c.eqMinNum != nil {
 			if value < *c.minNum {
-				return &ValidatorErr{Field: field, Message: *c.numMsgID, Kind: ConditionNumber, Min: c.minNum}
+				return c.numErr(field)
 			}
 		} else {
 			if value <= *c.minNum {
-				return &ValidatorErr{Field: field, Message: *c.numMsgID, Kind: ConditionNumber, Min: c.minNum, EQMin: Equal()}
+				return c.numErr(field)
 			}
 		}
 	}
 	if c.maxNum != nil {
 		if c.eqMaxNum != nil {
 			if value > *c.maxNum {
-				return &ValidatorErr{Field: field, Message: *c.numMsgID, Kind: ConditionNumber, Max: c.maxNum}
+				return c.numErr(field)
 			}
 		} else {
 			if value >= *c.maxNum {
-				return &ValidatorErr{Field: field, Message: *c.numMsgID, Kind: ConditionNumber, Max: c.maxNum, EQMax: Equal()}
+				return c.numErr(field)
 			}
 		}
 	}
 	return nil
 }
 
+// 同时返回最小值及最大值, 用于信息模板占位符, EQMin 及 EQMax 表示是否包含边界值
+func (c *condition) numErr(field string) *ValidatorErr {
+	ve := &ValidatorErr{Field: field, Message: *c.numMsgID, Kind: ConditionNumber, Min: c.minNum, Max: c.maxNum}
+	if c.minNum != nil && c.eqMinNum != nil {
+		ve.EQMin = Equal()
+	}
+	if c.maxNum != nil && c.eqMaxNum != nil {
+		ve.EQMax = Equal()
+	}
+	return ve
+}
+
 func (c *condition) validFile(field string, form *multipart.Form) (err error) {
-	fileItem := len(form.File[field])
+	err = c.validFileCount(field, len(form.File[field]))
+	if err != nil {
+		return err
+	}
+	for _, header := range form.File[field] {
+		err = c.validFileSize(field, header.Size)
+		if err == nil {
+			err = c.validFileHeader(field, header.Filename, header.Header.Get("Content-Type"))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 验证文件个数
+func (c *condition) validFileCount(field string, fileItem int) (err error) {
 	if c.required == nil && fileItem == 0 {
 		return nil
 	}
 	if c.required != nil && fileItem == 0 {
 		return &ValidatorErr{Field: field, Message: *c.required, Kind: ConditionRequired}
 	}
-	// 验证文件个数
 	if min, max := c.minItem, c.maxItem; min != nil && max != nil {
 		if *min > fileItem || *max < fileItem {
 			return &ValidatorErr{
@@ -583,59 +726,57 @@ func (c *condition) validFile(field string, form *multipart.Form) (err error) {
 			}
 		}
 	}
-	// 验证文件大小
+	return nil
+}
+
+// 验证文件大小
+func (c *condition) validFileSize(field string, size int64) (err error) {
 	if min, max := c.minFileByte, c.maxFileByte; min != nil && max != nil {
-		for _, header := range form.File[field] {
-			if header.Size > *max || header.Size < *min {
-				return &ValidatorErr{
-					Field:   field,
-					Message: *c.fileSizeMsgID,
-					Kind:    ConditionFileSize,
-					Max:     ptrInt64ToFloat(max), EQMax: Equal(),
-					Min: ptrInt64ToFloat(min), EQMin: Equal(),
-				}
+		if size > *max || size < *min {
+			return &ValidatorErr{
+				Field:   field,
+				Message: *c.fileSizeMsgID,
+				Kind:    ConditionFileSize,
+				Max:     ptrInt64ToFloat(max), EQMax: Equal(),
+				Min: ptrInt64ToFloat(min), EQMin: Equal(),
 			}
 		}
 	}
-	// 验证后缀名
+	return nil
+}
+
+// 验证文件后缀名及 Mime type
+func (c *condition) validFileHeader(field, filename, contentType string) (err error) {
 	if len(c.fileExtensions) > 0 {
-		for _, header := range form.File[field] {
-			exist := false
-			for _, ext := range c.fileExtensions {
-				if filepath.Ext(header.Filename) == ext {
-					exist = true
-					break
-				}
-			}
-			if !exist {
-				return &ValidatorErr{Field: field, Message: *c.fileExtMsgID, Kind: ConditionFileExtensions, Enums: c.fileExtensions}
+		exist := false
+		for _, ext := range c.fileExtensions {
+			if filepath.Ext(filename) == ext {
+				exist = true
+				break
 			}
 		}
+		if !exist {
+			return &ValidatorErr{Field: field, Message: *c.fileExtMsgID, Kind: ConditionFileExtensions, Enums: c.fileExtensions}
+		}
 	}
-	// 验证 Mime type
-	if len(c.fileMimeTypes) > 0 {
-		for _, header := range form.File[field] {
-			exist := false
-			for _, need := range c.fileMimeTypes {
-				got := header.Header.Get("Content-Type")
-				if got == need {
-					exist = true
-					break
-				} else {
-					if !strings.Contains(need, "/") { // e.g. need = "image" got = "image/png"
-						if need == got[:strings.Index(got, "/")] {
-							exist = true
-							break
-						}
-					}
-				}
-			}
-			if !exist {
-				return &ValidatorErr{Field: field, Message: *c.fileMimeMsgID, Kind: ConditionFileMimeTypes, Enums: c.fileMimeTypes}
+	if len(c.fileMimeTypes) > 0 && !matchMimeType(c.fileMimeTypes, contentType) {
+		return &ValidatorErr{Field: field, Message: *c.fileMimeMsgID, Kind: ConditionFileMimeTypes, Enums: c.fileMimeTypes}
+	}
+	return nil
+}
+
+// 检测 Mime type 是否为 types 之一, types 可以只包含主类型, 如: image
+func matchMimeType(types []string, contentType string) bool {
+	for _, need := range types {
+		if contentType == need {
+			return true
+		} else if !strings.Contains(need, "/") { // e.g. need = "image" got = "image/png"
+			if idx := strings.Index(contentType, "/"); idx > 0 && need == contentType[:idx] {
+				return true
 			}
 		}
 	}
-	return nil
+	return false
 }
 
 func (c *condition) validItem(field string, lenVs int) (err error) {
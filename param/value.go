@@ -0,0 +1,165 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package param
+
+import (
+	"encoding"
+	"mime/multipart"
+	"net/url"
+	"reflect"
+	"strconv"
+	"time"
+	"unsafe"
+)
+
+// 将字符串解析到 value 中, value 为 kind 类型的可设置值
+func parseValue(kind Kind, value reflect.Value, str string) (err error) {
+	switch kind {
+	case String:
+		value.SetString(str)
+	case Bool:
+		var b bool
+		b, err = strconv.ParseBool(str)
+		if err == nil {
+			value.SetBool(b)
+		}
+	case Duration:
+		var d time.Duration
+		d, err = time.ParseDuration(str)
+		if err == nil {
+			value.SetInt(int64(d))
+		}
+	case Int, Int8, Int16, Int32, Int64:
+		var n int64
+		n, err = strconv.ParseInt(str, 10, value.Type().Bits())
+		if err == nil {
+			value.SetInt(n)
+		}
+	case Uint, Uint8, Uint16, Uint32, Uint64:
+		var n uint64
+		n, err = strconv.ParseUint(str, 10, value.Type().Bits())
+		if err == nil {
+			value.SetUint(n)
+		}
+	case Float32, Float64:
+		var n float64
+		n, err = strconv.ParseFloat(str, value.Type().Bits())
+		if err == nil {
+			value.SetFloat(n)
+		}
+	case Text:
+		err = value.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(str))
+	}
+	return err
+}
+
+// 获得数字类型的值, 用于数值条件验证
+func numberValue(kind Kind, value reflect.Value) (n float64, ok bool) {
+	switch kind {
+	case Int, Int8, Int16, Int32, Int64, Duration:
+		return float64(value.Int()), true
+	case Uint, Uint8, Uint16, Uint32, Uint64:
+		return float64(value.Uint()), true
+	case Float32, Float64:
+		return value.Float(), true
+	}
+	return 0, false
+}
+
+func invalidValueErr(param string, kind Kind, err error) *ValidatorErr {
+	switch kind {
+	case Bool:
+		return &ValidatorErr{Field: param, Kind: ConditionInvalidBoolean, Message: err.Error()}
+	case String, Text, Duration:
+		return &ValidatorErr{Field: param, Kind: ConditionInvalidValue, Message: err.Error()}
+	default:
+		return &ValidatorErr{Field: param, Kind: ConditionInvalidNumber, Message: err.Error()}
+	}
+}
+
+// 基于反射的设置器, 用于 getSetter 不支持的单值类型及所有指针类型.
+// 指针类型字段在参数不存在时设为默认值(通常为 nil), 以区分未传参数及零值
+func newValueSetter(param string, typ reflect.Type, kind Kind, offset uintptr, dvalue interface{}, cdt *condition) setter {
+	optional := typ.Kind() == reflect.Ptr
+	elemType := typ
+	if optional {
+		elemType = typ.Elem()
+	}
+	dv := reflect.ValueOf(dvalue)
+	return func(begin uintptr, form *multipart.Form) (err error) {
+		field := reflect.NewAt(typ, unsafe.Pointer(begin+offset)).Elem()
+		valueStr := url.Values(form.Value).Get(param)
+		if valueStr == "" {
+			if cdt != nil && cdt.required != nil {
+				return &ValidatorErr{Field: param, Message: *cdt.required, Kind: ConditionRequired}
+			}
+			if optional && !dv.IsNil() {
+				// 复制默认值, 避免请求之间共享同一个指针
+				ptr := reflect.New(elemType)
+				ptr.Elem().Set(dv.Elem())
+				field.Set(ptr)
+			} else {
+				field.Set(dv)
+			}
+			return nil
+		}
+		ptr := reflect.New(elemType)
+		err = parseValue(kind, ptr.Elem(), valueStr)
+		if err != nil {
+			return invalidValueErr(param, kind, err)
+		}
+		if cdt != nil {
+			if n, ok := numberValue(kind, ptr.Elem()); ok {
+				err = cdt.validNum(param, valueStr, n)
+			} else {
+				err = cdt.validStr(param, valueStr)
+			}
+			if err == nil {
+				err = cdt.validEnum(param, form.Value[param])
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if optional {
+			field.Set(ptr)
+		} else {
+			field.Set(ptr.Elem())
+		}
+		return nil
+	}
+}
+
+// map[string]string 类型的设置器, 参数写法与嵌套结构体相同, 如: meta[key]=value 或 meta.key=value
+func newMapStringSetter(param string, typ reflect.Type, offset uintptr, dvalue interface{}, cdt *condition) setter {
+	dv := reflect.ValueOf(dvalue)
+	return func(begin uintptr, form *multipart.Form) (err error) {
+		sub, _ := subForm(param, form)
+		if cdt != nil {
+			err = cdt.validItem(param, len(sub.Value))
+			if err != nil {
+				return err
+			}
+		}
+		m := reflect.MakeMapWithSize(typ, len(sub.Value))
+		if len(sub.Value) > 0 {
+			for key, values := range sub.Value {
+				if len(values) > 0 {
+					m.SetMapIndex(reflect.ValueOf(key).Convert(typ.Key()), reflect.ValueOf(values[0]).Convert(typ.Elem()))
+				}
+			}
+		} else if !dv.IsNil() {
+			// 复制默认值, 避免请求之间共享同一个 map
+			iter := dv.MapRange()
+			for iter.Next() {
+				m.SetMapIndex(iter.Key(), iter.Value())
+			}
+		} else {
+			m = dv
+		}
+		reflect.NewAt(typ, unsafe.Pointer(begin+offset)).Elem().Set(m)
+		return nil
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package param_test
+
+import (
+	"errors"
+	"github.com/orivil/morgine/param"
+	"mime/multipart"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+// 以分为单位的金额, 如: "12.34" => 1234
+type amount int64
+
+func (a *amount) UnmarshalText(text []byte) error {
+	parts := strings.SplitN(string(text), ".", 2)
+	yuan, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return err
+	}
+	var cent int64
+	if len(parts) == 2 {
+		if len(parts[1]) != 2 {
+			return errors.New("invalid amount")
+		}
+		cent, err = strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			return err
+		}
+	}
+	*a = amount(yuan*100 + cent)
+	return nil
+}
+
+type values struct {
+	Page    uint              `param:"page" num:"1<=x<=100"`
+	Level   int8              `param:"level"`
+	Size    uint64            `param:"size"`
+	Timeout time.Duration     `param:"timeout"`
+	Price   amount            `param:"price"`
+	At      time.Time         `param:"at" time-layout:"2006/01/02 15:04"`
+	Limit   *int              `param:"limit"`
+	Name    *string           `param:"name"`
+	Paid    *amount           `param:"paid"`
+	Meta    map[string]string `param:"meta"`
+}
+
+func TestSchema_ParseValues(t *testing.T) {
+	schema := param.MustNewSchema(&values{Page: 1, Meta: map[string]string{"from": "default"}}, nil, nil)
+	form := &multipart.Form {
+		Value: map[string][]string {
+			"page":       {"2"},
+			"level":      {"-3"},
+			"size":       {"18446744073709551615"},
+			"timeout":    {"1m30s"},
+			"price":      {"12.34"},
+			"at":         {"2020/01/02 03:04"},
+			"limit":      {"0"},
+			"meta[from]": {"web"},
+			"meta.lang":  {"zh"},
+		},
+	}
+	got := &values{}
+	err := schema.Parse(uintptr(unsafe.Pointer(got)), form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zero := 0
+	need := &values {
+		Page:    2,
+		Level:   -3,
+		Size:    18446744073709551615,
+		Timeout: 90 * time.Second,
+		Price:   1234,
+		At:      time.Date(2020, 1, 2, 3, 4, 0, 0, time.UTC),
+		Limit:   &zero,
+		Meta:    map[string]string{"from": "web", "lang": "zh"},
+	}
+	if !reflect.DeepEqual(got, need) {
+		t.Errorf("need: %+v got: %+v", need, got)
+	}
+
+	// 未传参数时, 指针字段为 nil, 其他字段为默认值
+	got = &values{}
+	err = schema.Parse(uintptr(unsafe.Pointer(got)), &multipart.Form{Value: map[string][]string{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Page != 1 || got.Limit != nil || got.Name != nil || got.Paid != nil || got.Meta["from"] != "default" {
+		t.Errorf("need default values, got: %+v", got)
+	}
+}
+
+func TestSchema_ParseValuesError(t *testing.T) {
+	schema := param.MustNewSchema(&values{}, nil, nil)
+	cases := []struct {
+		field string
+		value string
+		kind  param.ConditionKind
+	}{
+		{"page", "0", param.ConditionNumber},
+		{"page", "-1", param.ConditionInvalidNumber},
+		{"level", "128", param.ConditionInvalidNumber},
+		{"timeout", "90", param.ConditionInvalidValue},
+		{"paid", "1.2", param.ConditionInvalidValue},
+	}
+	for _, c := range cases {
+		form := &multipart.Form{Value: map[string][]string{c.field: {c.value}}}
+		err := schema.Parse(uintptr(unsafe.Pointer(&values{})), form)
+		if ve, ok := err.(*param.ValidatorErr); !ok || ve.Field != c.field || ve.Kind != c.kind {
+			t.Errorf("%s=%s: need error kind %s, got: %v", c.field, c.value, param.Conditions[c.kind], err)
+		}
+	}
+}
+
+func TestNewSchema_Kinds(t *testing.T) {
+	schema := param.MustNewSchema(&values{}, nil, nil)
+	kinds := make(map[string]param.Kind)
+	optional := make(map[string]bool)
+	for _, field := range schema.Fields {
+		kinds[field.Name] = field.Kind
+		optional[field.Name] = field.Optional
+	}
+	need := map[string]param.Kind {
+		"page":    param.Uint,
+		"level":   param.Int8,
+		"size":    param.Uint64,
+		"timeout": param.Duration,
+		"price":   param.Text,
+		"at":      param.Time,
+		"limit":   param.Int,
+		"name":    param.String,
+		"paid":    param.Text,
+		"meta":    param.MapString,
+	}
+	if !reflect.DeepEqual(kinds, need) {
+		t.Errorf("need: %v got: %v", need, kinds)
+	}
+	if !optional["limit"] || !optional["paid"] || optional["price"] {
+		t.Errorf("need pointer fields to be optional, got: %v", optional)
+	}
+
+	type invalid struct {
+		Counts map[string]int
+	}
+	if _, err := param.NewSchema(&invalid{}, nil, nil); err == nil {
+		t.Error("need error of invalid map kind")
+	}
+}
@@ -42,6 +42,10 @@ func (r *Router) Add(method, route string, action interface{}) error {
 		return err
 	} else {
 		r.entries[method] = nodes
+		// 清除 Nodes 缓存
+		r.mu.Lock()
+		r.nodes = nil
+		r.mu.Unlock()
 		return nil
 	}
 }
@@ -0,0 +1,48 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package limiter
+
+import "time"
+
+// Limiter 为所有限制器的通用接口, 进程内的 VisitorContainer, OperationContainer 及共享存储(如 Redis)的实现都满足该接口
+type Limiter interface {
+	// 检测 session 当前是否允许访问, session 可以是 IP 地址, 用户 ID 等
+	Allow(session string) bool
+}
+
+// Result 为一次限流检测的结果
+type Result struct {
+	Allowed    bool          // 是否允许访问
+	Limit      int           // 配额总量
+	Remaining  int           // 剩余配额
+	Reset      time.Duration // 配额完全恢复所需时间
+	RetryAfter time.Duration // 被拒绝时需要等待的时间
+}
+
+// RateLimiter 为请求频率限制器, Allow 及 Take 都会消耗一次配额, Peek 只检测不消耗
+type RateLimiter interface {
+	Limiter
+	Take(session string) (*Result, error)
+	Peek(session string) (*Result, error)
+}
+
+// OperationLimiter 为操作失败限制器, 如登录失败锁定, Allow 不会改变失败记录
+type OperationLimiter interface {
+	Limiter
+
+	// 操作成功，删除失败记录
+	Success(session string)
+
+	// 添加失败记录
+	Failed(session string)
+
+	// 获得等待结束时间, 不需要等待则返回 nil
+	GetWaitTime(session string) (waitAt *time.Time)
+}
+
+var (
+	_ RateLimiter      = (*VisitorContainer)(nil)
+	_ OperationLimiter = (*OperationContainer)(nil)
+)
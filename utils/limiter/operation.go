@@ -5,6 +5,7 @@
 package limiter
 
 import (
+	"container/list"
 	"sync"
 	"time"
 )
@@ -13,6 +14,8 @@ type user struct {
 	session string
 	failed int
 	waitAt *time.Time
+	failedAt time.Time
+	elem *list.Element
 }
 
 type TimeProvider interface {
@@ -28,6 +31,12 @@ type OperationContainer struct {
 	users map[string]*user
 	timeProvider TimeProvider
 	mu sync.Mutex
+
+	// 按最近失败时间排序, 最近失败的在前
+	lru *list.List
+	maxEntries int
+	evicted uint64
+	closed chan struct{}
 }
 
 func NewOperationContainer(timeProvider TimeProvider) *OperationContainer {
@@ -35,37 +44,65 @@ func NewOperationContainer(timeProvider TimeProvider) *OperationContainer {
 		users:    make(map[string]*user, 50),
 		timeProvider: timeProvider,
 		mu:       sync.Mutex{},
+		lru:      list.New(),
 	}
 }
 
+// SetMaxEntries 设置最大记录数, 超出时删除最久未失败的记录, 小于等于 0 则不限制
+func (uc *OperationContainer) SetMaxEntries(max int) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	uc.maxEntries = max
+	uc.removeOverflow()
+}
+
+func (uc *OperationContainer) removeOverflow() {
+	for uc.maxEntries > 0 && len(uc.users) > uc.maxEntries {
+		uc.remove(uc.lru.Back().Value.(*user))
+		uc.evicted++
+	}
+}
+
+func (uc *OperationContainer) remove(usr *user) {
+	uc.lru.Remove(usr.elem)
+	delete(uc.users, usr.session)
+}
+
 // 操作成功，删除失败记录
 func (uc *OperationContainer) Success(session string) {
 	uc.mu.Lock()
 	defer uc.mu.Unlock()
-	delete(uc.users, session)
+	if usr := uc.users[session]; usr != nil {
+		uc.remove(usr)
+	}
 }
 
 // 添加失败记录，获得等待时间
 func (uc *OperationContainer) Failed(session string) {
 	uc.mu.Lock()
 	defer uc.mu.Unlock()
+	now := uc.timeProvider.GetNowTime()
 	usr := uc.users[session]
 	if usr == nil {
 		usr = &user {
 			session:session,
 			failed: 1,
 		}
+		usr.elem = uc.lru.PushFront(usr)
 		uc.users[session] = usr
 	} else {
 		usr.failed++
+		uc.lru.MoveToFront(usr.elem)
 	}
+	usr.failedAt = now
 	waitDuration := uc.timeProvider.GetWaitTime(usr.failed)
 	if waitDuration > 0 {
-		wa := uc.timeProvider.GetNowTime().Add(waitDuration)
+		wa := now.Add(waitDuration)
 		usr.waitAt = &wa
 	} else {
 		usr.waitAt = nil
 	}
+	uc.removeOverflow()
 }
 
 // 检测当前时间是否允许操作
@@ -91,3 +128,80 @@ func (uc *OperationContainer) GetWaitTime(session string) (waitAt *time.Time) {
 		return usr.waitAt
 	}
 }
+
+// EvictExpired 删除等待时间已结束, 且最后一次失败在 keep 时间之前的记录, 返回删除的数量.
+// keep 用于保留失败次数, 使连续失败的等待时间可以递增
+func (uc *OperationContainer) EvictExpired(keep time.Duration) (evicted int) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	now := uc.timeProvider.GetNowTime()
+	// 从最久未失败的记录开始检测
+	for elem := uc.lru.Back(); elem != nil; {
+		usr := elem.Value.(*user)
+		if now.Sub(usr.failedAt) <= keep {
+			break
+		}
+		elem = elem.Prev()
+		if usr.waitAt == nil || !usr.waitAt.After(now) {
+			uc.remove(usr)
+			evicted++
+		}
+	}
+	uc.evicted += uint64(evicted)
+	return evicted
+}
+
+// RunEviction 开启协程每隔 interval 时间执行 EvictExpired, 调用 Close 方法停止
+func (uc *OperationContainer) RunEviction(keep, interval time.Duration) {
+	uc.mu.Lock()
+	if uc.closed != nil {
+		uc.mu.Unlock()
+		return
+	}
+	closed := make(chan struct{})
+	uc.closed = closed
+	uc.mu.Unlock()
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				uc.EvictExpired(keep)
+			case <-closed:
+				return
+			}
+		}
+	}()
+}
+
+// Close 停止 RunEviction 开启的协程
+func (uc *OperationContainer) Close() {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	if uc.closed != nil {
+		close(uc.closed)
+		uc.closed = nil
+	}
+}
+
+// OperationStats 为失败记录统计数据
+type OperationStats struct {
+	Entries int    `json:"entries"` // 当前记录数
+	Locked  int    `json:"locked"`  // 当前处于等待状态的记录数
+	Evicted uint64 `json:"evicted"` // 累计删除的过期及超出数量限制的记录数
+}
+
+// Stats 获得统计数据, 可用于监控当前锁定数量
+func (uc *OperationContainer) Stats() OperationStats {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	now := uc.timeProvider.GetNowTime()
+	stats := OperationStats{Entries: len(uc.users), Evicted: uc.evicted}
+	for _, usr := range uc.users {
+		if usr.waitAt != nil && usr.waitAt.After(now) {
+			stats.Locked++
+		}
+	}
+	return stats
+}
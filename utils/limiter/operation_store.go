@@ -0,0 +1,112 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package limiter
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// OperationRecord 为失败记录快照
+type OperationRecord struct {
+	Session  string     `json:"session"`
+	Failed   int        `json:"failed"`
+	WaitAt   *time.Time `json:"wait_at,omitempty"`
+	FailedAt time.Time  `json:"failed_at"`
+}
+
+// OperationStore 用于保存失败记录快照, 使重启后锁定依然有效. 可以通过 service.Container 提供数据库等实现
+type OperationStore interface {
+	Save(records []*OperationRecord) error
+	Load() ([]*OperationRecord, error)
+}
+
+// FileOperationStore 将快照以 JSON 格式保存到文件中
+type FileOperationStore string
+
+func (f FileOperationStore) Save(records []*OperationRecord) error {
+	data, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+	// 先写入临时文件, 避免写入中断导致快照损坏
+	tmp := string(f) + ".tmp"
+	err = ioutil.WriteFile(tmp, data, 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, string(f))
+}
+
+// 文件不存在时返回空快照
+func (f FileOperationStore) Load() ([]*OperationRecord, error) {
+	data, err := ioutil.ReadFile(string(f))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var records []*OperationRecord
+	err = json.Unmarshal(data, &records)
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// Snapshot 获得所有失败记录, 最久未失败的记录在前
+func (uc *OperationContainer) Snapshot() []*OperationRecord {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	records := make([]*OperationRecord, 0, len(uc.users))
+	for elem := uc.lru.Back(); elem != nil; elem = elem.Prev() {
+		usr := elem.Value.(*user)
+		records = append(records, &OperationRecord {
+			Session:  usr.session,
+			Failed:   usr.failed,
+			WaitAt:   usr.waitAt,
+			FailedAt: usr.failedAt,
+		})
+	}
+	return records
+}
+
+// Restore 恢复失败记录, 已存在的记录会被覆盖
+func (uc *OperationContainer) Restore(records []*OperationRecord) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	for _, record := range records {
+		if usr := uc.users[record.Session]; usr != nil {
+			uc.remove(usr)
+		}
+		usr := &user {
+			session:  record.Session,
+			failed:   record.Failed,
+			waitAt:   record.WaitAt,
+			failedAt: record.FailedAt,
+		}
+		usr.elem = uc.lru.PushFront(usr)
+		uc.users[usr.session] = usr
+	}
+	uc.removeOverflow()
+}
+
+// SaveTo 保存失败记录快照
+func (uc *OperationContainer) SaveTo(store OperationStore) error {
+	return store.Save(uc.Snapshot())
+}
+
+// RestoreFrom 从快照中恢复失败记录
+func (uc *OperationContainer) RestoreFrom(store OperationStore) error {
+	records, err := store.Load()
+	if err != nil {
+		return err
+	}
+	uc.Restore(records)
+	return nil
+}
@@ -5,6 +5,9 @@
 package limiter
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"sync"
 	"testing"
 	"time"
@@ -67,3 +70,81 @@ func TestNewOperationContainer(t *testing.T) {
 	}
 }
 
+
+func TestOperationContainer_EvictExpired(t *testing.T) {
+	now := time.Now()
+	waitTimeProvider := &waitTime{now: now}
+	opc := NewOperationContainer(waitTimeProvider)
+	opc.Failed("a")
+	for i := 0; i < 4; i++ {
+		opc.Failed("b") // 等待 1 分钟
+	}
+	waitTimeProvider.setNowTime(now.Add(30 * time.Second))
+	opc.Failed("c")
+	if stats := opc.Stats(); stats.Entries != 3 || stats.Locked != 1 {
+		t.Errorf("need 3 entries and 1 locked, got: %+v", stats)
+	}
+	waitTimeProvider.setNowTime(now.Add(40 * time.Second))
+	if evicted := opc.EvictExpired(20 * time.Second); evicted != 1 || opc.GetWaitTime("b") == nil {
+		t.Errorf("need only a evicted, got: %d", evicted)
+	}
+	waitTimeProvider.setNowTime(now.Add(2 * time.Minute))
+	if evicted := opc.EvictExpired(20 * time.Second); evicted != 2 {
+		t.Errorf("need 2 evicted, got: %d", evicted)
+	}
+	if stats := opc.Stats(); stats.Entries != 0 || stats.Evicted != 3 {
+		t.Errorf("need 0 entries and 3 evicted, got: %+v", stats)
+	}
+}
+
+func TestOperationContainer_SetMaxEntries(t *testing.T) {
+	opc := NewOperationContainer(&waitTime{now: time.Now()})
+	opc.SetMaxEntries(2)
+	opc.Failed("a")
+	opc.Failed("b")
+	opc.Failed("a")
+	opc.Failed("c")
+	records := opc.Snapshot()
+	if len(records) != 2 || records[0].Session != "a" || records[1].Session != "c" {
+		t.Errorf("need least recently failed b evicted, got: %v", records)
+	}
+}
+
+func TestOperationContainer_Restore(t *testing.T) {
+	waitTimeProvider := &waitTime{now: time.Now()}
+	opc := NewOperationContainer(waitTimeProvider)
+	for i := 0; i < 5; i++ {
+		opc.Failed("a")
+	}
+	opc.Failed("b")
+	dir, err := ioutil.TempDir("", "limiter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store := FileOperationStore(filepath.Join(dir, "operations.json"))
+	err = opc.SaveTo(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := NewOperationContainer(waitTimeProvider)
+	err = restored.RestoreFrom(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	need, got := opc.Snapshot(), restored.Snapshot()
+	if len(need) != len(got) {
+		t.Fatalf("need %d records, got %d", len(need), len(got))
+	}
+	for i, n := range need {
+		g := got[i]
+		if n.Session != g.Session || n.Failed != g.Failed || !n.FailedAt.Equal(g.FailedAt) ||
+			(n.WaitAt == nil) != (g.WaitAt == nil) || (n.WaitAt != nil && !n.WaitAt.Equal(*g.WaitAt)) {
+			t.Errorf("need: %+v got: %+v", n, g)
+		}
+	}
+	if restored.Allow("a") {
+		t.Error("need a locked after restore")
+	}
+}
+
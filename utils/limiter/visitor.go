@@ -6,7 +6,9 @@ package limiter
 
 import (
 	"golang.org/x/time/rate"
+	"math"
 	"sync"
+	"time"
 )
 
 type RateLimiterProvider func() *rate.Limiter
@@ -14,12 +16,37 @@ type RateLimiterProvider func() *rate.Limiter
 type visitor struct {
 	session string
 	limiter *rate.Limiter
+
+	// rate.Limiter 不提供剩余令牌数, 此处同步记录令牌数用于计算剩余配额
+	tokens   float64
+	lastSeen time.Time
+}
+
+// 按照 rate.Limiter 的规则补充令牌
+func (v *visitor) advance(now time.Time) {
+	limit, burst := v.limiter.Limit(), float64(v.limiter.Burst())
+	if limit == rate.Inf {
+		v.tokens = burst
+	} else if elapsed := now.Sub(v.lastSeen); elapsed > 0 {
+		v.tokens = math.Min(burst, v.tokens+elapsed.Seconds()*float64(limit))
+	}
+	v.lastSeen = now
+}
+
+// 令牌补满所需时间
+func (v *visitor) resetDuration() time.Duration {
+	limit, burst := v.limiter.Limit(), float64(v.limiter.Burst())
+	if limit == rate.Inf || limit <= 0 || v.tokens >= burst {
+		return 0
+	}
+	return time.Duration((burst - v.tokens) / float64(limit) * float64(time.Second))
 }
 
 type VisitorContainer struct {
 	visitors map[string]*visitor
 	limiter RateLimiterProvider
 	mu sync.Mutex
+	closed chan struct{}
 }
 
 func NewVisitorContainer(limiter RateLimiterProvider) *VisitorContainer {
@@ -29,19 +56,135 @@ func NewVisitorContainer(limiter RateLimiterProvider) *VisitorContainer {
 	}
 }
 
-// session 用于保证用户唯一性，可传入 IP 地址，用户 ID 等，或者使用两个 Container 同时检测 IP 及 ID
+// session 用于保证用户唯一性，可传入 IP 地址，用户 ID 等，或者使用两个 Container 同时检测 IP 及 ID.
+// 与 Take 相同, 包括首次访问在内的每次允许的访问都消耗一个令牌
 func (vc *VisitorContainer) Allow(session string) bool {
+	return vc.TakeAt(session, time.Now()).Allowed
+}
+
+func (vc *VisitorContainer) newVisitor(session string, now time.Time) *visitor {
+	lim := vc.limiter()
+	return &visitor {
+		session:  session,
+		limiter:  lim,
+		tokens:   float64(lim.Burst()),
+		lastSeen: now,
+	}
+}
+
+// Take 消耗 session 的一个令牌, 并返回剩余配额等信息
+func (vc *VisitorContainer) Take(session string) (*Result, error) {
+	return vc.TakeAt(session, time.Now()), nil
+}
+
+// TakeAt 同 Take, 可指定当前时间
+func (vc *VisitorContainer) TakeAt(session string, now time.Time) *Result {
 	vc.mu.Lock()
 	defer vc.mu.Unlock()
 	vis := vc.visitors[session]
 	if vis == nil {
-		vis = &visitor {
-			session:          session,
-			limiter:      vc.limiter(),
-		}
+		vis = vc.newVisitor(session, now)
 		vc.visitors[session] = vis
-		return true
+	}
+	vis.advance(now)
+	res := &Result{Limit: vis.limiter.Burst()}
+	r := vis.limiter.ReserveN(now, 1)
+	if !r.OK() {
+		return res
+	}
+	if delay := r.DelayFrom(now); delay > 0 {
+		r.CancelAt(now)
+		res.RetryAfter = delay
 	} else {
-		return vis.limiter.Allow()
+		res.Allowed = true
+		vis.tokens = math.Max(0, vis.tokens-1)
+	}
+	res.Remaining = int(vis.tokens)
+	res.Reset = vis.resetDuration()
+	return res
+}
+
+// Peek 检测 session 当前是否还有令牌, 不消耗令牌
+func (vc *VisitorContainer) Peek(session string) (*Result, error) {
+	return vc.PeekAt(session, time.Now()), nil
+}
+
+// PeekAt 同 Peek, 可指定当前时间
+func (vc *VisitorContainer) PeekAt(session string, now time.Time) *Result {
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+	vis := vc.visitors[session]
+	if vis == nil {
+		// 不记录只检测过的访问者
+		vis = vc.newVisitor(session, now)
+	} else {
+		vis.advance(now)
+	}
+	res := &Result {
+		Limit:     vis.limiter.Burst(),
+		Remaining: int(vis.tokens),
+		Reset:     vis.resetDuration(),
+	}
+	limit := vis.limiter.Limit()
+	if limit == rate.Inf || vis.tokens >= 1 {
+		res.Allowed = true
+	} else if limit > 0 {
+		res.RetryAfter = time.Duration((1 - vis.tokens) / float64(limit) * float64(time.Second))
+	}
+	return res
+}
+
+// Len 返回当前记录的访问者数量
+func (vc *VisitorContainer) Len() int {
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+	return len(vc.visitors)
+}
+
+// EvictIdle 删除空闲时间超过 idle 的访问者, 返回删除的数量. 空闲时间超过令牌补满时间的访问者与新访问者没有区别,
+// 所以 idle 应不小于令牌补满所需的时间
+func (vc *VisitorContainer) EvictIdle(idle time.Duration, now time.Time) (evicted int) {
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+	for session, vis := range vc.visitors {
+		if now.Sub(vis.lastSeen) > idle {
+			delete(vc.visitors, session)
+			evicted++
+		}
+	}
+	return evicted
+}
+
+// RunEviction 开启协程每隔 interval 时间删除空闲时间超过 idle 的访问者, 调用 Close 方法停止
+func (vc *VisitorContainer) RunEviction(idle, interval time.Duration) {
+	vc.mu.Lock()
+	if vc.closed != nil {
+		vc.mu.Unlock()
+		return
+	}
+	closed := make(chan struct{})
+	vc.closed = closed
+	vc.mu.Unlock()
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				vc.EvictIdle(idle, now)
+			case <-closed:
+				return
+			}
+		}
+	}()
+}
+
+// Close 停止 RunEviction 开启的协程
+func (vc *VisitorContainer) Close() {
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+	if vc.closed != nil {
+		close(vc.closed)
+		vc.closed = nil
 	}
 }
@@ -45,3 +45,76 @@ func TestVisitorContainer_Allow(t *testing.T) {
 		t.Errorf("need ablut %d +/- %f, got %d", totalAllow, scope, allowed)
 	}
 }
+
+func TestVisitorContainer_AllowFirstVisit(t *testing.T) {
+	vis := NewVisitorContainer(func() *rate.Limiter {
+		return rate.NewLimiter(rate.Every(time.Hour), 3)
+	})
+	allowed := 0
+	for i := 0; i < 5; i++ {
+		if vis.Allow("a") {
+			allowed++
+		}
+	}
+	if allowed != 3 {
+		t.Errorf("need allowed: 3, got: %d", allowed)
+	}
+	if res, _ := vis.Peek("a"); res.Remaining != 0 {
+		t.Errorf("need remaining: 0, got: %d", res.Remaining)
+	}
+}
+
+func TestVisitorContainer_TakeAt(t *testing.T) {
+	vis := NewVisitorContainer(func() *rate.Limiter {
+		return rate.NewLimiter(rate.Every(time.Second), 3) // 每秒补充 1 个令牌, 最多 3 个
+	})
+	now := time.Now()
+	for i := 2; i >= 0; i-- {
+		res := vis.TakeAt("a", now)
+		if !res.Allowed || res.Remaining != i || res.Limit != 3 {
+			t.Errorf("need allowed with remaining %d, got: %+v", i, res)
+		}
+	}
+	res := vis.TakeAt("a", now)
+	if res.Allowed || res.RetryAfter != time.Second || res.Reset != 3*time.Second {
+		t.Errorf("need denied with retry after 1s, got: %+v", res)
+	}
+	res = vis.TakeAt("a", now.Add(time.Second))
+	if !res.Allowed || res.Remaining != 0 {
+		t.Errorf("need allowed after 1s, got: %+v", res)
+	}
+}
+
+func TestVisitorContainer_EvictIdle(t *testing.T) {
+	vis := NewVisitorContainer(func() *rate.Limiter {
+		return rate.NewLimiter(rate.Every(time.Second), 1)
+	})
+	now := time.Now()
+	vis.TakeAt("a", now)
+	vis.TakeAt("b", now.Add(time.Minute))
+	if evicted := vis.EvictIdle(30*time.Second, now.Add(time.Minute)); evicted != 1 {
+		t.Errorf("need evicted: 1, got: %d", evicted)
+	}
+	if vis.Len() != 1 {
+		t.Errorf("need len: 1, got: %d", vis.Len())
+	}
+}
+
+func TestVisitorContainer_PeekAt(t *testing.T) {
+	vis := NewVisitorContainer(func() *rate.Limiter {
+		return rate.NewLimiter(rate.Every(time.Second), 1)
+	})
+	now := time.Now()
+	if res := vis.PeekAt("a", now); !res.Allowed || res.Remaining != 1 || vis.Len() != 0 {
+		t.Errorf("need allowed without recording visitor, got: %+v", res)
+	}
+	vis.TakeAt("a", now)
+	for i := 0; i < 2; i++ {
+		if res := vis.PeekAt("a", now); res.Allowed || res.RetryAfter != time.Second {
+			t.Errorf("need denied with retry after 1s, got: %+v", res)
+		}
+	}
+	if res := vis.TakeAt("a", now.Add(time.Second)); !res.Allowed {
+		t.Errorf("peek should not consume tokens, got: %+v", res)
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package oidc
+
+import (
+	"errors"
+	"github.com/orivil/morgine/utils/session"
+	"time"
+)
+
+// 登录状态不存在, 已过期或已使用
+var ErrStateInvalid = errors.New("oidc: state is invalid or expired")
+
+// Flow 为授权码 + PKCE 登录流程, 跳转前生成的 state, nonce 及 PKCE 验证码保存在会话存储中,
+// 以 state 为会话 ID, 回调时取出并删除, 因此每个 state 只能使用一次
+type Flow struct {
+	Client *Client
+	States session.Store
+
+	// 登录状态有效期, 默认 10 分钟
+	StateTTL time.Duration
+}
+
+func NewFlow(client *Client, states session.Store) *Flow {
+	return &Flow {
+		Client:   client,
+		States:   states,
+		StateTTL: 10 * time.Minute,
+	}
+}
+
+// Start 生成并保存登录状态, 获得登录跳转地址
+func (f *Flow) Start() (string, error) {
+	_, authURL, err := f.StartState()
+	return authURL, err
+}
+
+// StartState 生成并保存登录状态, 获得 state 及登录跳转地址. 可将 state 保存在 cookie 中, 回调时与参数中的 state 比较,
+// 确保回调与发起登录的是同一浏览器
+func (f *Flow) StartState() (state, authURL string, err error) {
+	state = session.NewID()
+	nonce := randomString(32)
+	verifier, challenge := NewPKCE()
+	now := time.Now()
+	err = f.States.Save(&session.Data {
+		ID:         state,
+		Values:     map[string]string{"nonce": nonce, "verifier": verifier},
+		CreatedAt:  now,
+		AccessedAt: now,
+		ExpiresAt:  now.Add(f.StateTTL),
+	})
+	if err != nil {
+		return "", "", err
+	}
+	return state, f.Client.AuthCodeURL(state, nonce, challenge), nil
+}
+
+// Finish 验证回调中的 state, 以授权码换取令牌并验证 ID 令牌
+func (f *Flow) Finish(state, code string) (*IDToken, error) {
+	if state == "" {
+		return nil, ErrStateInvalid
+	}
+	data, err := f.States.Get(state)
+	if err != nil {
+		if err == session.ErrNotFound {
+			return nil, ErrStateInvalid
+		}
+		return nil, err
+	}
+	err = f.States.Delete(state)
+	if err != nil {
+		return nil, err
+	}
+	token, err := f.Client.Exchange(code, data.Values["verifier"])
+	if err != nil {
+		return nil, err
+	}
+	return f.Client.Verify(token.IDToken, data.Values["nonce"])
+}
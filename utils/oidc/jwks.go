@@ -0,0 +1,198 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package oidc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrKeyNotFound = errors.New("oidc: signing key not found")
+
+// JSONWebKey 为 JWKS 中的公钥, 只支持 RSA 及 EC 签名密钥
+type JSONWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// PublicKey 解析公钥, 返回 *rsa.PublicKey 或 *ecdsa.PublicKey
+func (k *JSONWebKey) PublicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("oidc: unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("oidc: invalid EC key")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("oidc: unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// KeySet 获取并缓存身份提供方的签名公钥. 缓存按响应的 Cache-Control max-age 过期,
+// 遇到未知的 kid 时重新获取, 以支持身份提供方轮换密钥
+type KeySet struct {
+	URL    string
+	Client *http.Client
+
+	// 未声明 max-age 时的缓存时间
+	DefaultTTL time.Duration
+
+	// 两次重新获取的最短间隔, 防止伪造的 kid 导致频繁请求
+	MinRefresh time.Duration
+
+	keys      map[string]interface{}
+	expiresAt time.Time
+	fetchedAt time.Time
+	mu        sync.Mutex
+	now       func() time.Time
+}
+
+func NewKeySet(url string, client *http.Client) *KeySet {
+	return &KeySet {
+		URL:        url,
+		Client:     client,
+		DefaultTTL: time.Hour,
+		MinRefresh: time.Minute,
+		now:        time.Now,
+	}
+}
+
+// Key 获得 kid 对应的公钥, kid 为空且只有一个密钥时返回该密钥
+func (ks *KeySet) Key(kid string) (interface{}, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	now := ks.now()
+	if ks.keys == nil || now.After(ks.expiresAt) {
+		err := ks.fetch(now)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if key := ks.find(kid); key != nil {
+		return key, nil
+	}
+	if now.Sub(ks.fetchedAt) < ks.MinRefresh {
+		return nil, ErrKeyNotFound
+	}
+	err := ks.fetch(now)
+	if err != nil {
+		return nil, err
+	}
+	if key := ks.find(kid); key != nil {
+		return key, nil
+	}
+	return nil, ErrKeyNotFound
+}
+
+func (ks *KeySet) find(kid string) interface{} {
+	if kid == "" && len(ks.keys) == 1 {
+		for _, key := range ks.keys {
+			return key
+		}
+	}
+	return ks.keys[kid]
+}
+
+func (ks *KeySet) fetch(now time.Time) error {
+	res, err := ks.Client.Get(ks.URL)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("oidc: fetch keys: %s", res.Status)
+	}
+	var set struct {
+		Keys []*JSONWebKey `json:"keys"`
+	}
+	err = json.NewDecoder(res.Body).Decode(&set)
+	if err != nil {
+		return err
+	}
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.PublicKey()
+		if err != nil {
+			// 忽略不支持的密钥类型
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	ks.keys = keys
+	ks.fetchedAt = now
+	ks.expiresAt = now.Add(maxAge(res.Header.Get("Cache-Control"), ks.DefaultTTL))
+	return nil
+}
+
+func maxAge(cacheControl string, def time.Duration) time.Duration {
+	for _, directive := range strings.Split(cacheControl, ",") {
+		directive = strings.TrimSpace(directive)
+		if strings.HasPrefix(directive, "max-age=") {
+			seconds, err := strconv.Atoi(directive[len("max-age="):])
+			if err == nil && seconds >= 0 {
+				return time.Duration(seconds) * time.Second
+			}
+		}
+	}
+	return def
+}
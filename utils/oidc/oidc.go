@@ -0,0 +1,327 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+// oidc 为 OpenID Connect 客户端, 实现授权码 + PKCE 登录流程. 使用方式:
+//
+//	provider, err := oidc.Discover(issuer, nil)
+//	client := provider.Client(&oidc.Config{ClientID: id, ClientSecret: secret, RedirectURL: callback})
+//	flow := oidc.NewFlow(client, session.NewMemoryStore())
+//
+//	// 登录跳转
+//	url, err := flow.Start()
+//
+//	// 回调
+//	idToken, err := flow.Finish(state, code)
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidIDToken = errors.New("oidc: invalid id token")
+	ErrNonceMismatch  = errors.New("oidc: nonce mismatch")
+)
+
+// Discovery 为身份提供方的 /.well-known/openid-configuration 文档
+type Discovery struct {
+	Issuer                        string   `json:"issuer"`
+	AuthorizationEndpoint         string   `json:"authorization_endpoint"`
+	TokenEndpoint                 string   `json:"token_endpoint"`
+	UserinfoEndpoint              string   `json:"userinfo_endpoint"`
+	JwksURI                       string   `json:"jwks_uri"`
+	ScopesSupported               []string `json:"scopes_supported"`
+	IDTokenSigningAlgs            []string `json:"id_token_signing_alg_values_supported"`
+	CodeChallengeMethodsSupported []string `json:"code_challenge_methods_supported"`
+}
+
+// Provider 为身份提供方
+type Provider struct {
+	Discovery *Discovery
+	Keys      *KeySet
+	client    *http.Client
+}
+
+// Discover 获取并解析身份提供方的发现文档, 文档中的 issuer 必须与参数一致. client 为 nil 时使用 10 秒超时的默认客户端
+func Discover(issuer string, client *http.Client) (*Provider, error) {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	issuer = strings.TrimSuffix(issuer, "/")
+	res, err := client.Get(issuer + "/.well-known/openid-configuration")
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oidc: discovery: %s", res.Status)
+	}
+	d := &Discovery{}
+	err = json.NewDecoder(res.Body).Decode(d)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: discovery: %s", err)
+	}
+	if strings.TrimSuffix(d.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("oidc: issuer mismatch, need: %s, got: %s", issuer, d.Issuer)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JwksURI == "" {
+		return nil, errors.New("oidc: discovery document is incomplete")
+	}
+	return &Provider {
+		Discovery: d,
+		Keys:      NewKeySet(d.JwksURI, client),
+		client:    client,
+	}, nil
+}
+
+// Config 为客户端配置
+type Config struct {
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+
+	// 默认为 openid, profile, email
+	Scopes []string
+
+	// 允许的 ID 令牌签名算法, 默认为 RS256
+	Algorithms []string
+
+	// 允许的时钟误差, 默认 1 分钟
+	Leeway time.Duration
+}
+
+// Client 为绑定了身份提供方的客户端
+type Client struct {
+	*Config
+	Provider *Provider
+	now      func() time.Time
+}
+
+func (p *Provider) Client(cfg *Config) *Client {
+	return &Client{Config: cfg, Provider: p, now: time.Now}
+}
+
+// NewPKCE 生成 PKCE 验证码及 S256 质询码
+func NewPKCE() (verifier, challenge string) {
+	verifier = randomString(32)
+	return verifier, S256Challenge(verifier)
+}
+
+// S256Challenge 计算验证码的 S256 质询码
+func S256Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func randomString(n int) string {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	if err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// AuthCodeURL 获得登录跳转地址
+func (c *Client) AuthCodeURL(state, nonce, challenge string) string {
+	scopes := c.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "profile", "email"}
+	}
+	v := url.Values {
+		"response_type":         {"code"},
+		"client_id":             {c.ClientID},
+		"redirect_uri":          {c.RedirectURL},
+		"scope":                 {strings.Join(scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {challenge},
+		"code_challenge_method": {"S256"},
+	}
+	endpoint := c.Provider.Discovery.AuthorizationEndpoint
+	if strings.Contains(endpoint, "?") {
+		return endpoint + "&" + v.Encode()
+	}
+	return endpoint + "?" + v.Encode()
+}
+
+// Token 为令牌端点的响应
+type Token struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+	IDToken      string `json:"id_token"`
+}
+
+// TokenError 为令牌端点返回的错误
+type TokenError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func (e *TokenError) Error() string {
+	if e.Description != "" {
+		return "oidc: " + e.Code + ": " + e.Description
+	}
+	return "oidc: " + e.Code
+}
+
+// Exchange 以授权码及 PKCE 验证码换取令牌, 设置了 ClientSecret 时使用 client_secret_basic 认证
+func (c *Client) Exchange(code, verifier string) (*Token, error) {
+	form := url.Values {
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {c.RedirectURL},
+		"code_verifier": {verifier},
+	}
+	if c.ClientSecret == "" {
+		form.Set("client_id", c.ClientID)
+	}
+	req, err := http.NewRequest("POST", c.Provider.Discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if c.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(c.ClientID), url.QueryEscape(c.ClientSecret))
+	}
+	res, err := c.Provider.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		te := &TokenError{}
+		if json.Unmarshal(body, te) == nil && te.Code != "" {
+			return nil, te
+		}
+		return nil, fmt.Errorf("oidc: exchange: %s", res.Status)
+	}
+	token := &Token{}
+	err = json.Unmarshal(body, token)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: exchange: %s", err)
+	}
+	if token.IDToken == "" {
+		return nil, errors.New("oidc: token response has no id_token")
+	}
+	return token, nil
+}
+
+// IDToken 为验证通过的 ID 令牌
+type IDToken struct {
+	Issuer   string
+	Subject  string
+	Audience []string
+	Expiry   time.Time
+	IssuedAt time.Time
+	Nonce    string
+
+	// 常用的标准声明
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+
+	// 所有声明, 用于读取自定义声明, 如角色或分组
+	Claims jwt.MapClaims
+}
+
+// Strings 获得字符串或字符串数组类型的声明
+func (t *IDToken) Strings(claim string) []string {
+	switch v := t.Claims[claim].(type) {
+	case string:
+		return strings.Fields(v)
+	case []interface{}:
+		var values []string
+		for _, value := range v {
+			if s, ok := value.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// Verify 验证 ID 令牌的签名, iss, aud, azp, exp, iat 及 nonce 声明
+func (c *Client) Verify(rawIDToken, nonce string) (*IDToken, error) {
+	algorithms := c.Algorithms
+	if len(algorithms) == 0 {
+		algorithms = []string{"RS256"}
+	}
+	claims := jwt.MapClaims{}
+	parser := &jwt.Parser{ValidMethods: algorithms, SkipClaimsValidation: true}
+	_, err := parser.ParseWithClaims(rawIDToken, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return c.Provider.Keys.Key(kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidIDToken, err)
+	}
+	t := &IDToken{Claims: claims}
+	t.Issuer, _ = claims["iss"].(string)
+	t.Subject, _ = claims["sub"].(string)
+	t.Nonce, _ = claims["nonce"].(string)
+	t.Email, _ = claims["email"].(string)
+	t.EmailVerified, _ = claims["email_verified"].(bool)
+	t.Name, _ = claims["name"].(string)
+	t.PreferredUsername, _ = claims["preferred_username"].(string)
+	t.Audience = t.Strings("aud")
+	if exp, ok := claims["exp"].(float64); ok {
+		t.Expiry = time.Unix(int64(exp), 0)
+	}
+	if iat, ok := claims["iat"].(float64); ok {
+		t.IssuedAt = time.Unix(int64(iat), 0)
+	}
+
+	leeway := c.Leeway
+	if leeway == 0 {
+		leeway = time.Minute
+	}
+	now := c.now()
+	switch {
+	case t.Issuer != c.Provider.Discovery.Issuer:
+		return nil, fmt.Errorf("%w: issuer mismatch", ErrInvalidIDToken)
+	case t.Subject == "":
+		return nil, fmt.Errorf("%w: missing subject", ErrInvalidIDToken)
+	case !containsString(t.Audience, c.ClientID):
+		return nil, fmt.Errorf("%w: audience mismatch", ErrInvalidIDToken)
+	case len(t.Audience) > 1 && claims["azp"] != c.ClientID:
+		return nil, fmt.Errorf("%w: authorized party mismatch", ErrInvalidIDToken)
+	case t.Expiry.IsZero() || now.After(t.Expiry.Add(leeway)):
+		return nil, fmt.Errorf("%w: token is expired", ErrInvalidIDToken)
+	case t.IssuedAt.After(now.Add(leeway)):
+		return nil, fmt.Errorf("%w: token used before issued", ErrInvalidIDToken)
+	case t.Nonce != nonce:
+		return nil, ErrNonceMismatch
+	}
+	return t, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,261 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package oidc_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/orivil/morgine/utils/oidc"
+	"github.com/orivil/morgine/utils/session"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+// idp 为测试用的身份提供方
+type idp struct {
+	*httptest.Server
+	key      *rsa.PrivateKey
+	kid      string
+	codes    map[string]url.Values
+	jwksHits int
+	claims   jwt.MapClaims
+	mu       sync.Mutex
+}
+
+func newIdP(t *testing.T) *idp {
+	p := &idp{codes: map[string]url.Values{}}
+	p.rotate(t)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{} {
+			"issuer":                 p.URL,
+			"authorization_endpoint": p.URL + "/authorize",
+			"token_endpoint":         p.URL + "/token",
+			"jwks_uri":               p.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.jwksHits++
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+		json.NewEncoder(w).Encode(map[string]interface{} {
+			"keys": []map[string]string {
+				{
+					"kid": p.kid,
+					"kty": "RSA",
+					"use": "sig",
+					"n":   base64.RawURLEncoding.EncodeToString(p.key.N.Bytes()),
+					"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(p.key.E)).Bytes()),
+				},
+			},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		id, secret, _ := r.BasicAuth()
+		auth := p.codes[r.PostFormValue("code")]
+		delete(p.codes, r.PostFormValue("code"))
+		if id != "client" || secret != "secret" || auth == nil ||
+			oidc.S256Challenge(r.PostFormValue("code_verifier")) != auth.Get("code_challenge") ||
+			r.PostFormValue("redirect_uri") != auth.Get("redirect_uri") {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		claims := jwt.MapClaims {
+			"iss":   p.URL,
+			"sub":   "user-1",
+			"aud":   "client",
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"iat":   time.Now().Unix(),
+			"nonce": auth.Get("nonce"),
+			"email": "user@example.com",
+		}
+		for k, v := range p.claims {
+			claims[k] = v
+		}
+		json.NewEncoder(w).Encode(map[string]string {
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"id_token":     p.sign(t, claims),
+		})
+	})
+	p.Server = httptest.NewServer(mux)
+	return p
+}
+
+func (p *idp) rotate(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.mu.Lock()
+	p.key = key
+	p.kid = session.NewID()[:8]
+	p.mu.Unlock()
+}
+
+func (p *idp) sign(t *testing.T, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = p.kid
+	s, err := token.SignedString(p.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// authorize 模拟用户在身份提供方登录并同意授权, 返回回调中的 state 及 code
+func (p *idp) authorize(t *testing.T, authURL string) (state, code string) {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" || q.Get("client_id") != "client" || q.Get("scope") != "openid profile email" {
+		t.Fatalf("got auth url: %s", authURL)
+	}
+	code = session.NewID()
+	p.mu.Lock()
+	p.codes[code] = q
+	p.mu.Unlock()
+	return q.Get("state"), code
+}
+
+func newFlow(t *testing.T, p *idp) *oidc.Flow {
+	provider, err := oidc.Discover(p.URL+"/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := provider.Client(&oidc.Config{ClientID: "client", ClientSecret: "secret", RedirectURL: "http://localhost/callback"})
+	return oidc.NewFlow(client, session.NewMemoryStore())
+}
+
+func TestFlow(t *testing.T) {
+	p := newIdP(t)
+	defer p.Close()
+	p.claims = jwt.MapClaims{"groups": []string{"admin", "ops"}}
+	flow := newFlow(t, p)
+	authURL, err := flow.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, code := p.authorize(t, authURL)
+	idToken, err := flow.Finish(state, code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idToken.Subject != "user-1" || idToken.Email != "user@example.com" || idToken.Issuer != p.URL {
+		t.Errorf("got: %+v", idToken)
+	}
+	if groups := idToken.Strings("groups"); len(groups) != 2 || groups[1] != "ops" {
+		t.Errorf("got groups: %v", groups)
+	}
+
+	// state 只能使用一次
+	if _, err = flow.Finish(state, code); err != oidc.ErrStateInvalid {
+		t.Errorf("need: %v, got: %v", oidc.ErrStateInvalid, err)
+	}
+	if _, err = flow.Finish("unknown", code); err != oidc.ErrStateInvalid {
+		t.Errorf("need: %v, got: %v", oidc.ErrStateInvalid, err)
+	}
+
+	// 跳转地址中的 state 与 StartState 返回的一致
+	started, authURL, err := flow.StartState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state, _ = p.authorize(t, authURL); state != started {
+		t.Errorf("need state: %s, got: %s", started, state)
+	}
+
+	// 授权码被截获后, 没有 PKCE 验证码无法换取令牌
+	authURL, _ = flow.Start()
+	_, code = p.authorize(t, authURL)
+	if _, err = flow.Client.Exchange(code, "guessed-verifier"); err == nil || err.Error() != "oidc: invalid_grant" {
+		t.Errorf("need invalid_grant, got: %v", err)
+	}
+}
+
+func TestFlow_KeyRotation(t *testing.T) {
+	p := newIdP(t)
+	defer p.Close()
+	flow := newFlow(t, p)
+	flow.Client.Provider.Keys.MinRefresh = 0
+	for i := 0; i < 2; i++ {
+		authURL, _ := flow.Start()
+		state, code := p.authorize(t, authURL)
+		if _, err := flow.Finish(state, code); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if p.jwksHits != 1 {
+		t.Errorf("keys need to be cached, got %d fetches", p.jwksHits)
+	}
+
+	// 未知的 kid 触发重新获取公钥
+	p.rotate(t)
+	authURL, _ := flow.Start()
+	state, code := p.authorize(t, authURL)
+	if _, err := flow.Finish(state, code); err != nil {
+		t.Fatal(err)
+	}
+	if p.jwksHits != 2 {
+		t.Errorf("need 2 fetches, got %d", p.jwksHits)
+	}
+}
+
+func TestClient_Verify(t *testing.T) {
+	p := newIdP(t)
+	defer p.Close()
+	client := newFlow(t, p).Client
+	valid := func() jwt.MapClaims {
+		return jwt.MapClaims {
+			"iss":   p.URL,
+			"sub":   "user-1",
+			"aud":   "client",
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"iat":   time.Now().Unix(),
+			"nonce": "n",
+		}
+	}
+	if _, err := client.Verify(p.sign(t, valid()), "n"); err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]func(c jwt.MapClaims) {
+		"issuer":   func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" },
+		"audience": func(c jwt.MapClaims) { c["aud"] = "other" },
+		"azp":      func(c jwt.MapClaims) { c["aud"] = []string{"client", "other"} },
+		"expired":  func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() },
+		"subject":  func(c jwt.MapClaims) { delete(c, "sub") },
+	}
+	for name, modify := range cases {
+		claims := valid()
+		modify(claims)
+		if _, err := client.Verify(p.sign(t, claims), "n"); !errors.Is(err, oidc.ErrInvalidIDToken) {
+			t.Errorf("%s: need: %v, got: %v", name, oidc.ErrInvalidIDToken, err)
+		}
+	}
+	if _, err := client.Verify(p.sign(t, valid()), "other"); err != oidc.ErrNonceMismatch {
+		t.Errorf("need: %v, got: %v", oidc.ErrNonceMismatch, err)
+	}
+
+	// 不接受其他算法签名的令牌
+	hmac, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, valid()).SignedString([]byte("secret"))
+	if _, err := client.Verify(hmac, "n"); !errors.Is(err, oidc.ErrInvalidIDToken) {
+		t.Errorf("need: %v, got: %v", oidc.ErrInvalidIDToken, err)
+	}
+}
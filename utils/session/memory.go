@@ -0,0 +1,147 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package session
+
+import (
+	"encoding/json"
+	"github.com/orivil/morgine/log"
+	"github.com/orivil/morgine/utils/cache"
+	"github.com/orivil/morgine/utils/timer"
+	"sync"
+	"time"
+)
+
+var _ Store = (*MemoryStore)(nil)
+
+// MemoryStore 将会话保存在进程内存中, 重启后会话丢失
+type MemoryStore struct {
+	container *cache.Container
+
+	// 会话 ID 到用户标识的索引, 用于列出用户会话及清理过期会话
+	users map[string]string
+	mu    sync.Mutex
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore {
+		container: cache.NewContainer(),
+		users:     make(map[string]string),
+	}
+}
+
+func (s *MemoryStore) Get(id string) (*Data, error) {
+	value, _ := s.container.Get(id).([]byte)
+	if value == nil {
+		return nil, ErrNotFound
+	}
+	data := &Data{}
+	err := json.Unmarshal(value, data)
+	if err != nil {
+		return nil, err
+	}
+	if data.IsExpired(time.Now()) {
+		return nil, ErrNotFound
+	}
+	return data, nil
+}
+
+func (s *MemoryStore) Save(data *Data) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.save(data)
+}
+
+// 保存会话, 调用方需持有 s.mu
+func (s *MemoryStore) save(data *Data) error {
+	if data.IsExpired(time.Now()) {
+		s.delete(data.ID)
+		return nil
+	}
+	// 以 JSON 保存副本, 避免调用者修改存储中的数据
+	value, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	expiresAt := data.ExpiresAt
+	s.container.Set(data.ID, value, &expiresAt)
+	s.users[data.ID] = data.UserID
+	return nil
+}
+
+func (s *MemoryStore) Update(data *Data, key, expected string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, err := s.Get(data.ID)
+	if err != nil {
+		return false, err
+	}
+	if current.Values[key] != expected {
+		return false, nil
+	}
+	return true, s.save(data)
+}
+
+func (s *MemoryStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.delete(id)
+	return nil
+}
+
+func (s *MemoryStore) delete(id string) {
+	s.container.Flash(id)
+	delete(s.users, id)
+}
+
+func (s *MemoryStore) List(userID string) ([]*Data, error) {
+	if userID == "" {
+		return nil, ErrEmptyUserID
+	}
+	s.mu.Lock()
+	var ids []string
+	for id, uid := range s.users {
+		if uid == userID {
+			ids = append(ids, id)
+		}
+	}
+	s.mu.Unlock()
+	var sessions []*Data
+	for _, id := range ids {
+		data, err := s.Get(id)
+		if err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, data)
+	}
+	return sessions, nil
+}
+
+// Expire 删除所有过期的会话
+func (s *MemoryStore) Expire() (deleted int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id := range s.users {
+		// 过期的值在读取时会被 container 删除
+		if s.container.Get(id) == nil {
+			delete(s.users, id)
+			deleted++
+		}
+	}
+	return deleted
+}
+
+// RunExpiration 定时删除过期的会话, 如:
+//
+//	store.RunExpiration(timer.NewTickerRunner(time.Hour, nil, false))
+func (s *MemoryStore) RunExpiration(runner *timer.TickerRunner) {
+	runner.AddCallback(func(now *time.Time) {
+		if deleted := s.Expire(); deleted > 0 {
+			log.Info.Printf("deleted %d expired sessions\n", deleted)
+		}
+	})
+}
@@ -0,0 +1,31 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package session_test
+
+import (
+	"github.com/orivil/morgine/utils/session"
+	"github.com/orivil/morgine/utils/session/sessiontest"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	sessiontest.TestStore(t, session.NewMemoryStore())
+}
+
+func TestMemoryStore_Expire(t *testing.T) {
+	store := session.NewMemoryStore()
+	now := time.Now()
+	for _, ttl := range []time.Duration{time.Millisecond, time.Hour} {
+		err := store.Save(&session.Data{ID: session.NewID(), ExpiresAt: now.Add(ttl)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(5 * time.Millisecond)
+	if deleted := store.Expire(); deleted != 1 {
+		t.Errorf("need 1 deleted, got: %d", deleted)
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+// session 提供服务端会话的数据结构及存储接口, 会话中间件见 xx.SessionManager.
+// 内置内存存储 MemoryStore, Redis 存储见 bundles/utils/redis, 数据库存储见 utils/sql.
+// 新的存储实现可使用 sessiontest.TestStore 进行一致性测试
+package session
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"time"
+)
+
+// 会话不存在或已过期
+var ErrNotFound = errors.New("session not found")
+
+// 列出会话时用户标识为空, 未登录的会话不属于同一用户
+var ErrEmptyUserID = errors.New("session user id is empty")
+
+// Data 为会话数据
+type Data struct {
+	ID string `json:"id"`
+
+	// 登录用户标识, 用于列出及撤销用户的所有会话, 未登录时为空
+	UserID string `json:"user_id,omitempty"`
+
+	Values  map[string]string   `json:"values,omitempty"`
+	Flashes map[string][]string `json:"flashes,omitempty"`
+
+	// 创建会话时的客户端信息, 用于展示登录设备
+	IP        string `json:"ip,omitempty"`
+	UserAgent string `json:"user_agent,omitempty"`
+
+	CreatedAt  time.Time `json:"created_at"`
+	AccessedAt time.Time `json:"accessed_at"`
+
+	// 过期时间, 存储应在过期后删除会话, 且不再返回该会话
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// IsExpired 判断会话在 now 时是否已过期
+func (d *Data) IsExpired(now time.Time) bool {
+	return !now.Before(d.ExpiresAt)
+}
+
+// Store 为会话存储, 实现需要保证并发安全, 且返回的数据与存储中的数据互不影响
+type Store interface {
+	// Get 获得会话, 会话不存在或已过期时返回 ErrNotFound
+	Get(id string) (*Data, error)
+
+	// Save 保存会话, 已存在则覆盖, ExpiresAt 不晚于当前时间时删除会话
+	Save(data *Data) error
+
+	// Update 比较并保存会话, 存储中会话的 Values[key] 等于 expected 时保存 data 并返回 true, 否则不保存并返回 false.
+	// 会话不存在或已过期时返回 ErrNotFound. 比较与保存为原子操作, 用于并发请求中只允许一个成功的场景
+	Update(data *Data, key, expected string) (bool, error)
+
+	// Delete 删除会话, 会话不存在时不返回错误
+	Delete(id string) error
+
+	// List 获得用户所有未过期的会话, userID 为空时返回 ErrEmptyUserID
+	List(userID string) ([]*Data, error)
+}
+
+// NewID 生成 256 位随机会话 ID
+func NewID() string {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,227 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+// sessiontest 为 session.Store 实现的一致性测试, 在存储实现的测试中调用:
+//
+//	func TestRedisStore(t *testing.T) {
+//		sessiontest.TestStore(t, NewSessionStore(client, "session:"))
+//	}
+package sessiontest
+
+import (
+	"github.com/orivil/morgine/utils/session"
+	"sort"
+	"testing"
+	"time"
+)
+
+func newData(userID string, ttl time.Duration) *session.Data {
+	// 存储可能只保存到秒或毫秒, 测试数据统一截断到秒
+	now := time.Now().Truncate(time.Second)
+	return &session.Data {
+		ID:         session.NewID(),
+		UserID:     userID,
+		Values:     map[string]string{"theme": "dark"},
+		Flashes:    map[string][]string{"notice": {"saved"}},
+		IP:         "127.0.0.1",
+		UserAgent:  "sessiontest",
+		CreatedAt:  now,
+		AccessedAt: now,
+		ExpiresAt:  now.Add(ttl),
+	}
+}
+
+// TestStore 测试存储是否满足 session.Store 的约定, store 应为空存储
+func TestStore(t *testing.T, store session.Store) {
+	t.Run("GetNotFound", func(t *testing.T) {
+		_, err := store.Get(session.NewID())
+		if err != session.ErrNotFound {
+			t.Errorf("need: %v, got: %v", session.ErrNotFound, err)
+		}
+	})
+
+	t.Run("SaveAndGet", func(t *testing.T) {
+		data := newData("", time.Hour)
+		err := store.Save(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := store.Get(data.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.ID != data.ID || got.Values["theme"] != "dark" || len(got.Flashes["notice"]) != 1 || got.IP != data.IP ||
+			got.UserAgent != data.UserAgent || !got.CreatedAt.Equal(data.CreatedAt) || !got.ExpiresAt.Equal(data.ExpiresAt) {
+			t.Errorf("need: %+v, got: %+v", data, got)
+		}
+
+		// 修改返回的数据不影响存储
+		got.Values["theme"] = "light"
+		again, err := store.Get(data.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if again.Values["theme"] != "dark" {
+			t.Errorf("stored data is modified by caller: %+v", again)
+		}
+
+		// 覆盖保存
+		got.Values["theme"] = "light"
+		err = store.Save(got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		again, err = store.Get(data.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if again.Values["theme"] != "light" {
+			t.Errorf("need: light, got: %s", again.Values["theme"])
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		data := newData("", time.Hour)
+		err := store.Save(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = store.Delete(data.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = store.Get(data.ID)
+		if err != session.ErrNotFound {
+			t.Errorf("need: %v, got: %v", session.ErrNotFound, err)
+		}
+		err = store.Delete(data.ID)
+		if err != nil {
+			t.Errorf("delete not exist session: %v", err)
+		}
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		data := newData("expired-user", time.Hour)
+		err := store.Save(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data.ExpiresAt = time.Now().Add(-time.Second)
+		err = store.Save(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = store.Get(data.ID)
+		if err != session.ErrNotFound {
+			t.Errorf("need: %v, got: %v", session.ErrNotFound, err)
+		}
+		sessions, err := store.List("expired-user")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(sessions) != 0 {
+			t.Errorf("need no sessions, got: %d", len(sessions))
+		}
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		data := newData("update-user", time.Hour)
+		err := store.Save(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data.Values["theme"] = "light"
+		swapped, err := store.Update(data, "theme", "light")
+		if err != nil || swapped {
+			t.Fatalf("need not swapped, got: %v, %v", swapped, err)
+		}
+		got, err := store.Get(data.ID)
+		if err != nil || got.Values["theme"] != "dark" {
+			t.Fatalf("need unchanged session, got: %+v, %v", got, err)
+		}
+		swapped, err = store.Update(data, "theme", "dark")
+		if err != nil || !swapped {
+			t.Fatalf("need swapped, got: %v, %v", swapped, err)
+		}
+		got, err = store.Get(data.ID)
+		if err != nil || got.Values["theme"] != "light" {
+			t.Fatalf("need updated session, got: %+v, %v", got, err)
+		}
+		// 已使用过的期望值不能再次更新
+		swapped, err = store.Update(data, "theme", "dark")
+		if err != nil || swapped {
+			t.Errorf("need not swapped again, got: %v, %v", swapped, err)
+		}
+		_, err = store.Update(newData("update-user", time.Hour), "theme", "dark")
+		if err != session.ErrNotFound {
+			t.Errorf("need: %v, got: %v", session.ErrNotFound, err)
+		}
+	})
+
+	t.Run("List", func(t *testing.T) {
+		var ids []string
+		for i := 0; i < 3; i++ {
+			data := newData("list-user", time.Hour)
+			err := store.Save(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids = append(ids, data.ID)
+		}
+		err := store.Save(newData("other-user", time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = store.Delete(ids[2])
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = ids[:2]
+		sessions, err := store.List("list-user")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, data := range sessions {
+			if data.UserID != "list-user" {
+				t.Errorf("need user: list-user, got: %s", data.UserID)
+			}
+			got = append(got, data.ID)
+		}
+		sort.Strings(ids)
+		sort.Strings(got)
+		if len(got) != len(ids) || got[0] != ids[0] || got[1] != ids[1] {
+			t.Errorf("need: %v, got: %v", ids, got)
+		}
+
+		// 未登录的会话不能通过空用户标识列出
+		err = store.Save(newData("", time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = store.List(""); err != session.ErrEmptyUserID {
+			t.Errorf("need ErrEmptyUserID, got: %v", err)
+		}
+	})
+
+	t.Run("ChangeUser", func(t *testing.T) {
+		data := newData("", time.Hour)
+		err := store.Save(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data.UserID = "login-user"
+		err = store.Save(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sessions, err := store.List("login-user")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(sessions) != 1 || sessions[0].ID != data.ID {
+			t.Errorf("need session %s, got: %v", data.ID, sessions)
+		}
+	})
+}
@@ -0,0 +1,153 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package sql
+
+import (
+	"encoding/json"
+	"github.com/jinzhu/gorm"
+	"github.com/orivil/morgine/log"
+	"github.com/orivil/morgine/utils/session"
+	"github.com/orivil/morgine/utils/timer"
+	"time"
+)
+
+var _ session.Store = (*SessionStore)(nil)
+
+// SessionRecord 为会话数据表, 会话数据以 JSON 保存在 Data 字段中
+type SessionRecord struct {
+	ID        string    `gorm:"primary_key;size:64"`
+	UserID    string    `gorm:"index;size:64"`
+	Data      string    `gorm:"type:text"`
+	ExpiresAt time.Time `gorm:"index"`
+}
+
+func (SessionRecord) TableName() string {
+	return "sessions"
+}
+
+// SessionStore 将会话保存在数据库中. 数据库不会自动删除过期的会话, 需通过 RunExpiration 定时清理
+type SessionStore struct {
+	db *gorm.DB
+}
+
+// NewSessionStore 新建数据库会话存储, 并自动迁移会话数据表
+func NewSessionStore(db *gorm.DB) (*SessionStore, error) {
+	err := db.AutoMigrate(&SessionRecord{}).Error
+	if err != nil {
+		return nil, err
+	}
+	return &SessionStore{db: db}, nil
+}
+
+func decodeSession(record *SessionRecord) (*session.Data, error) {
+	data := &session.Data{}
+	err := json.Unmarshal([]byte(record.Data), data)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (s *SessionStore) Get(id string) (*session.Data, error) {
+	record := &SessionRecord{}
+	err := s.db.Where("id = ? AND expires_at > ?", id, time.Now()).First(record).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, session.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeSession(record)
+}
+
+func (s *SessionStore) Save(data *session.Data) error {
+	if data.IsExpired(time.Now()) {
+		return s.Delete(data.ID)
+	}
+	value, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return s.db.Save(&SessionRecord {
+		ID:        data.ID,
+		UserID:    data.UserID,
+		Data:      string(value),
+		ExpiresAt: data.ExpiresAt,
+	}).Error
+}
+
+// Update 以读取时的会话数据作为更新条件, 期间会话被其他请求修改时不更新
+func (s *SessionStore) Update(data *session.Data, key, expected string) (bool, error) {
+	record := &SessionRecord{}
+	err := s.db.Where("id = ? AND expires_at > ?", data.ID, time.Now()).First(record).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return false, session.ErrNotFound
+	}
+	if err != nil {
+		return false, err
+	}
+	current, err := decodeSession(record)
+	if err != nil {
+		return false, err
+	}
+	if current.Values[key] != expected {
+		return false, nil
+	}
+	value, err := json.Marshal(data)
+	if err != nil {
+		return false, err
+	}
+	db := s.db.Model(&SessionRecord{}).Where("id = ? AND data = ?", data.ID, record.Data).Updates(map[string]interface{} {
+		"user_id":    data.UserID,
+		"data":       string(value),
+		"expires_at": data.ExpiresAt,
+	})
+	if db.Error != nil {
+		return false, db.Error
+	}
+	return db.RowsAffected == 1, nil
+}
+
+func (s *SessionStore) Delete(id string) error {
+	return s.db.Where("id = ?", id).Delete(&SessionRecord{}).Error
+}
+
+func (s *SessionStore) List(userID string) ([]*session.Data, error) {
+	if userID == "" {
+		return nil, session.ErrEmptyUserID
+	}
+	var records []*SessionRecord
+	err := s.db.Where("user_id = ? AND expires_at > ?", userID, time.Now()).Find(&records).Error
+	if err != nil {
+		return nil, err
+	}
+	sessions := make([]*session.Data, 0, len(records))
+	for _, record := range records {
+		data, err := decodeSession(record)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, data)
+	}
+	return sessions, nil
+}
+
+// Expire 删除所有在 now 之前过期的会话
+func (s *SessionStore) Expire(now time.Time) (deleted int64, err error) {
+	db := s.db.Where("expires_at <= ?", now).Delete(&SessionRecord{})
+	return db.RowsAffected, db.Error
+}
+
+// RunExpiration 定时删除过期的会话, 如:
+//
+//	store.RunExpiration(timer.NewTickerRunner(time.Hour, nil, false))
+func (s *SessionStore) RunExpiration(runner *timer.TickerRunner) {
+	runner.AddCallback(func(now *time.Time) {
+		_, err := s.Expire(*now)
+		if err != nil {
+			log.Error.Println(err)
+		}
+	})
+}
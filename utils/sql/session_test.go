@@ -0,0 +1,37 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package sql_test
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/orivil/morgine/utils/session/sessiontest"
+	"github.com/orivil/morgine/utils/sql"
+	"os"
+	"testing"
+)
+
+// 需要设置环境变量 DB_DIALECT(mysql 或 postgres) 及 DB_DSN, 如:
+//
+//	DB_DIALECT=postgres DB_DSN="user=postgres dbname=test sslmode=disable" go test
+func TestSessionStore(t *testing.T) {
+	dialect, dsn := os.Getenv("DB_DIALECT"), os.Getenv("DB_DSN")
+	if dialect == "" || dsn == "" {
+		t.Skip("DB_DIALECT or DB_DSN is not set")
+	}
+	db, err := gorm.Open(dialect, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	err = db.DropTableIfExists(&sql.SessionRecord{}).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := sql.NewSessionStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessiontest.TestStore(t, store)
+}
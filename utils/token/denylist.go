@@ -0,0 +1,36 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package token
+
+import (
+	"github.com/orivil/morgine/utils/cache"
+	"time"
+)
+
+// Denylist 保存已撤销的令牌 ID 及会话 ID, 到期后自动删除. Redis 实现见 bundles/utils/redis
+type Denylist interface {
+	Add(id string, expiresAt time.Time) error
+	Contains(id string) (bool, error)
+}
+
+var _ Denylist = (*MemoryDenylist)(nil)
+
+// MemoryDenylist 将黑名单保存在进程内存中
+type MemoryDenylist struct {
+	container *cache.Container
+}
+
+func NewMemoryDenylist() *MemoryDenylist {
+	return &MemoryDenylist{container: cache.NewContainer()}
+}
+
+func (d *MemoryDenylist) Add(id string, expiresAt time.Time) error {
+	d.container.Set(id, true, &expiresAt)
+	return nil
+}
+
+func (d *MemoryDenylist) Contains(id string) (bool, error) {
+	return d.container.Get(id) != nil, nil
+}
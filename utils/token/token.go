@@ -0,0 +1,299 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+// token 提供短期访问令牌(JWT)及可轮换的刷新令牌.
+//
+// 每次登录创建一个令牌会话(session.Data), 刷新令牌绑定到会话, 每次刷新都会更换刷新令牌,
+// 最近使用过的刷新令牌再次使用或被并发使用时视为被盗用, 整个会话将被撤销. 退出登录时访问令牌加入黑名单,
+// 直到其自然过期
+package token
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/orivil/morgine/utils/session"
+	"strings"
+	"time"
+)
+
+var (
+	ErrTokenInvalid  = errors.New("token is invalid")
+	ErrTokenExpired  = errors.New("token is expired")
+	ErrTokenRevoked  = errors.New("token is revoked")
+	ErrRefreshReused = errors.New("refresh token is reused, the session is revoked")
+)
+
+// 会话中保存刷新令牌哈希值的键, usedRefreshKey 中为最近使用过的刷新令牌哈希值, 以空格分隔
+const (
+	refreshKey     = "refresh"
+	usedRefreshKey = "refresh_used"
+)
+
+// 保留最近使用过的刷新令牌个数, 再次使用其中任一令牌时撤销会话
+const refreshHistory = 10
+
+// Claims 为访问令牌声明, Subject 为用户标识, Id 为令牌 ID
+type Claims struct {
+	SessionID string `json:"sid"`
+	jwt.StandardClaims
+}
+
+// 访问令牌的过期时间
+func (c *Claims) ExpiresTime() time.Time {
+	return time.Unix(c.ExpiresAt, 0)
+}
+
+// Pair 为登录或刷新后返回给客户端的令牌
+type Pair struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in" desc:"访问令牌有效秒数"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Manager 签发及验证令牌
+type Manager struct {
+	// HMAC-SHA256 签名密钥
+	Key []byte
+
+	// 访问令牌有效期, 撤销的令牌在黑名单中最多保留该时间
+	AccessTTL time.Duration
+
+	// 刷新令牌有效期, 每次刷新后重新计算, 超过该时间未刷新则需要重新登录
+	RefreshTTL time.Duration
+
+	// 令牌会话存储, 可使用 session 包中的任意存储
+	Store session.Store
+
+	// 已撤销的访问令牌及会话
+	Denylist Denylist
+}
+
+// NewManager 新建令牌管理器, 默认访问令牌有效期 15 分钟, 刷新令牌有效期 7 天
+func NewManager(key []byte, store session.Store, denylist Denylist) *Manager {
+	return &Manager {
+		Key:        key,
+		AccessTTL:  15 * time.Minute,
+		RefreshTTL: 7 * 24 * time.Hour,
+		Store:      store,
+		Denylist:   denylist,
+	}
+}
+
+func randomString(n int) string {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	if err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// 签发访问令牌并更换刷新令牌, 会话数据由调用方保存
+func (m *Manager) sign(data *session.Data, now time.Time) (*Pair, error) {
+	claims := &Claims {
+		SessionID: data.ID,
+		StandardClaims: jwt.StandardClaims {
+			Subject:   data.UserID,
+			Id:        randomString(16),
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(m.AccessTTL).Unix(),
+		},
+	}
+	access, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(m.Key)
+	if err != nil {
+		return nil, err
+	}
+	secret := randomString(32)
+	if data.Values == nil {
+		data.Values = make(map[string]string)
+	}
+	if current := data.Values[refreshKey]; current != "" {
+		used := append([]string{current}, strings.Fields(data.Values[usedRefreshKey])...)
+		if len(used) > refreshHistory {
+			used = used[:refreshHistory]
+		}
+		data.Values[usedRefreshKey] = strings.Join(used, " ")
+	}
+	data.Values[refreshKey] = hashSecret(secret)
+	data.AccessedAt = now
+	data.ExpiresAt = now.Add(m.RefreshTTL)
+	return &Pair {
+		AccessToken:  access,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(m.AccessTTL.Seconds()),
+		RefreshToken: data.ID + "." + secret,
+	}, nil
+}
+
+// Issue 登录成功后签发令牌, ip 及 userAgent 用于展示登录设备
+func (m *Manager) Issue(userID, ip, userAgent string) (*Pair, error) {
+	now := time.Now()
+	data := &session.Data {
+		ID:        session.NewID(),
+		UserID:    userID,
+		IP:        ip,
+		UserAgent: userAgent,
+		CreatedAt: now,
+	}
+	pair, err := m.sign(data, now)
+	if err != nil {
+		return nil, err
+	}
+	err = m.Store.Save(data)
+	if err != nil {
+		return nil, err
+	}
+	return pair, nil
+}
+
+// Verify 验证访问令牌, 令牌过期时返回 ErrTokenExpired, 已撤销时返回 ErrTokenRevoked
+func (m *Manager) Verify(access string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(access, claims, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, ErrTokenInvalid
+		}
+		return m.Key, nil
+	})
+	if err != nil {
+		if ve, ok := err.(*jwt.ValidationError); ok && ve.Errors == jwt.ValidationErrorExpired {
+			return nil, ErrTokenExpired
+		}
+		return nil, ErrTokenInvalid
+	}
+	if claims.Id == "" || claims.SessionID == "" {
+		return nil, ErrTokenInvalid
+	}
+	for _, id := range []string{claims.Id, claims.SessionID} {
+		revoked, err := m.Denylist.Contains(id)
+		if err != nil {
+			return nil, err
+		}
+		if revoked {
+			return nil, ErrTokenRevoked
+		}
+	}
+	return claims, nil
+}
+
+// Refresh 使用刷新令牌换取新的令牌, 旧的刷新令牌随即失效. 最近使用过的令牌被再次使用, 或同一令牌被并发使用时,
+// 撤销整个会话并返回 ErrRefreshReused
+func (m *Manager) Refresh(refresh string) (*Pair, error) {
+	idx := strings.IndexByte(refresh, '.')
+	if idx < 0 {
+		return nil, ErrTokenInvalid
+	}
+	id, secret := refresh[:idx], refresh[idx+1:]
+	data, err := m.Store.Get(id)
+	if err == session.ErrNotFound {
+		return nil, ErrTokenExpired
+	}
+	if err != nil {
+		return nil, err
+	}
+	hash := hashSecret(secret)
+	expected := data.Values[refreshKey]
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(expected)) != 1 {
+		for _, used := range strings.Fields(data.Values[usedRefreshKey]) {
+			if subtle.ConstantTimeCompare([]byte(hash), []byte(used)) == 1 {
+				return nil, m.reused(data.ID)
+			}
+		}
+		return nil, ErrTokenInvalid
+	}
+	pair, err := m.sign(data, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	// 只有存储中的刷新令牌仍为当前令牌时才保存, 否则令牌已被其他请求使用
+	swapped, err := m.Store.Update(data, refreshKey, expected)
+	if err == session.ErrNotFound {
+		return nil, ErrTokenExpired
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !swapped {
+		return nil, m.reused(data.ID)
+	}
+	return pair, nil
+}
+
+// 撤销刷新令牌被重复使用的会话
+func (m *Manager) reused(id string) error {
+	err := m.revokeSession(id)
+	if err != nil {
+		return err
+	}
+	return ErrRefreshReused
+}
+
+// 删除令牌会话, 并将会话加入黑名单使已签发的访问令牌失效
+func (m *Manager) revokeSession(id string) error {
+	err := m.Store.Delete(id)
+	if err != nil {
+		return err
+	}
+	return m.Denylist.Add(id, time.Now().Add(m.AccessTTL))
+}
+
+// Revoke 退出登录, 撤销访问令牌及其所属的会话
+func (m *Manager) Revoke(claims *Claims) error {
+	err := m.Denylist.Add(claims.Id, claims.ExpiresTime())
+	if err != nil {
+		return err
+	}
+	return m.revokeSession(claims.SessionID)
+}
+
+// RevokeUser 退出用户所有设备, except 中的会话除外
+func (m *Manager) RevokeUser(userID string, except ...string) error {
+	sessions, err := m.Store.List(userID)
+	if err != nil {
+		return err
+	}
+	for _, data := range sessions {
+		if containsString(except, data.ID) {
+			continue
+		}
+		err = m.revokeSession(data.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Sessions 获得用户所有已登录的会话, 会话数据中不包含刷新令牌
+func (m *Manager) Sessions(userID string) ([]*session.Data, error) {
+	sessions, err := m.Store.List(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, data := range sessions {
+		delete(data.Values, refreshKey)
+		delete(data.Values, usedRefreshKey)
+	}
+	return sessions, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,167 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package token_test
+
+import (
+	"github.com/orivil/morgine/utils/session"
+	"github.com/orivil/morgine/utils/token"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newManager() *token.Manager {
+	return token.NewManager([]byte("secret key"), session.NewMemoryStore(), token.NewMemoryDenylist())
+}
+
+func mustVerify(t *testing.T, m *token.Manager, access string, need error) *token.Claims {
+	claims, err := m.Verify(access)
+	if err != need {
+		t.Fatalf("need: %v, got: %v", need, err)
+	}
+	return claims
+}
+
+func TestManager_Refresh(t *testing.T) {
+	m := newManager()
+	pair, err := m.Issue("1", "127.0.0.1", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := mustVerify(t, m, pair.AccessToken, nil)
+	if claims.Subject != "1" || claims.SessionID == "" || pair.ExpiresIn != 900 {
+		t.Errorf("got claims: %+v, pair: %+v", claims, pair)
+	}
+	mustVerify(t, m, pair.AccessToken+"x", token.ErrTokenInvalid)
+
+	refreshed, err := m.Refresh(pair.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refreshed.RefreshToken == pair.RefreshToken {
+		t.Error("refresh token is not rotated")
+	}
+	if c := mustVerify(t, m, refreshed.AccessToken, nil); c.SessionID != claims.SessionID {
+		t.Errorf("need session: %s, got: %s", claims.SessionID, c.SessionID)
+	}
+
+	// 重复使用旧的刷新令牌, 整个会话被撤销
+	_, err = m.Refresh(pair.RefreshToken)
+	if err != token.ErrRefreshReused {
+		t.Fatalf("need: %v, got: %v", token.ErrRefreshReused, err)
+	}
+	mustVerify(t, m, refreshed.AccessToken, token.ErrTokenRevoked)
+	_, err = m.Refresh(refreshed.RefreshToken)
+	if err != token.ErrTokenExpired {
+		t.Errorf("need: %v, got: %v", token.ErrTokenExpired, err)
+	}
+	_, err = m.Refresh("invalid")
+	if err != token.ErrTokenInvalid {
+		t.Errorf("need: %v, got: %v", token.ErrTokenInvalid, err)
+	}
+}
+
+func TestManager_RefreshReusedHistory(t *testing.T) {
+	m := newManager()
+	first, err := m.Issue("1", "127.0.0.1", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := m.Refresh(first.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	third, err := m.Refresh(second.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 重复使用更早的刷新令牌同样撤销会话
+	_, err = m.Refresh(first.RefreshToken)
+	if err != token.ErrRefreshReused {
+		t.Fatalf("need: %v, got: %v", token.ErrRefreshReused, err)
+	}
+	mustVerify(t, m, third.AccessToken, token.ErrTokenRevoked)
+}
+
+func TestManager_RefreshConcurrent(t *testing.T) {
+	m := newManager()
+	pair, err := m.Issue("1", "127.0.0.1", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var succeeded int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := m.Refresh(pair.RefreshToken); err == nil {
+				atomic.AddInt32(&succeeded, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	if succeeded != 1 {
+		t.Errorf("need exactly one refresh succeeded, got: %d", succeeded)
+	}
+}
+
+func TestManager_Revoke(t *testing.T) {
+	m := newManager()
+	var pairs []*token.Pair
+	for i := 0; i < 3; i++ {
+		pair, err := m.Issue("1", "127.0.0.1", "test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		pairs = append(pairs, pair)
+	}
+	sessions, err := m.Sessions("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 3 || sessions[0].Values["refresh"] != "" {
+		t.Errorf("got sessions: %+v", sessions)
+	}
+
+	// 退出登录
+	claims := mustVerify(t, m, pairs[0].AccessToken, nil)
+	err = m.Revoke(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustVerify(t, m, pairs[0].AccessToken, token.ErrTokenRevoked)
+	if _, err = m.Refresh(pairs[0].RefreshToken); err != token.ErrTokenExpired {
+		t.Errorf("need: %v, got: %v", token.ErrTokenExpired, err)
+	}
+
+	// 退出其他设备
+	current := mustVerify(t, m, pairs[1].AccessToken, nil)
+	err = m.RevokeUser("1", current.SessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustVerify(t, m, pairs[1].AccessToken, nil)
+	mustVerify(t, m, pairs[2].AccessToken, token.ErrTokenRevoked)
+}
+
+func TestManager_Expired(t *testing.T) {
+	m := newManager()
+	m.AccessTTL = -time.Minute
+	pair, err := m.Issue("1", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustVerify(t, m, pair.AccessToken, token.ErrTokenExpired)
+
+	other := newManager()
+	other.Key = []byte("other key")
+	pair, err = other.Issue("1", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustVerify(t, m, pair.AccessToken, token.ErrTokenInvalid)
+}
@@ -0,0 +1,115 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+// totp 实现 RFC 6238 基于时间的一次性密码, 兼容 Google Authenticator 等验证器应用
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// TOTP 为一次性密码参数, 验证器应用普遍只支持 SHA1 算法
+type TOTP struct {
+	// 密码位数
+	Digits int
+
+	// 密码有效时长
+	Period time.Duration
+
+	// 允许前后偏差的时间窗口数, 用于容忍客户端时钟误差
+	Skew int
+}
+
+// Default 为 6 位, 30 秒有效, 允许前后各偏差 1 个时间窗口
+var Default = &TOTP{Digits: 6, Period: 30 * time.Second, Skew: 1}
+
+// GenerateSecret 生成 160 位 base32 编码的随机密钥
+func GenerateSecret() string {
+	b := make([]byte, 20)
+	_, err := rand.Read(b)
+	if err != nil {
+		panic(err)
+	}
+	return encoding.EncodeToString(b)
+}
+
+func decodeSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.Replace(secret, " ", "", -1))
+	return encoding.DecodeString(strings.TrimRight(secret, "="))
+}
+
+// Counter 获得 at 时的时间窗口序号
+func (t *TOTP) Counter(at time.Time) int64 {
+	return at.Unix() / int64(t.Period/time.Second)
+}
+
+// Code 获得 at 时的密码
+func (t *TOTP) Code(secret string, at time.Time) (string, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return t.hotp(key, t.Counter(at)), nil
+}
+
+func (t *TOTP) hotp(key []byte, counter int64) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(counter))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := int64(binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff)
+	mod := int64(1)
+	for i := 0; i < t.Digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", t.Digits, value%mod)
+}
+
+// Validate 验证密码, 成功时返回密码所在的时间窗口序号. 调用方应保存最后使用的序号,
+// 并拒绝序号不大于该值的密码, 防止同一密码被重放
+func (t *TOTP) Validate(secret, code string, at time.Time) (counter int64, ok bool) {
+	code = strings.Replace(code, " ", "", -1)
+	if len(code) != t.Digits {
+		return 0, false
+	}
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return 0, false
+	}
+	now := t.Counter(at)
+	for i := -t.Skew; i <= t.Skew; i++ {
+		c := now + int64(i)
+		if subtle.ConstantTimeCompare([]byte(t.hotp(key, c)), []byte(code)) == 1 {
+			return c, true
+		}
+	}
+	return 0, false
+}
+
+// URI 获得用于生成二维码的 otpauth:// 地址, issuer 为服务名称, account 为账号名
+func (t *TOTP) URI(issuer, account, secret string) string {
+	v := url.Values {
+		"secret":    {secret},
+		"issuer":    {issuer},
+		"algorithm": {"SHA1"},
+		"digits":    {strconv.Itoa(t.Digits)},
+		"period":    {strconv.Itoa(int(t.Period / time.Second))},
+	}
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+	return "otpauth://totp/" + label + "?" + v.Encode()
+}
@@ -0,0 +1,77 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package totp_test
+
+import (
+	"encoding/base32"
+	"github.com/orivil/morgine/utils/totp"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// RFC 6238 附录 B 中 SHA1 算法的测试向量
+func TestTOTP_Code(t *testing.T) {
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+	otp := &totp.TOTP{Digits: 8, Period: 30 * time.Second}
+	vectors := map[int64]string {
+		59:          "94287082",
+		1111111109:  "07081804",
+		1111111111:  "14050471",
+		1234567890:  "89005924",
+		2000000000:  "69279037",
+		20000000000: "65353130",
+	}
+	for unix, need := range vectors {
+		got, err := otp.Code(secret, time.Unix(unix, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != need {
+			t.Errorf("%d: need: %s, got: %s", unix, need, got)
+		}
+	}
+}
+
+func TestTOTP_Validate(t *testing.T) {
+	secret := totp.GenerateSecret()
+	now := time.Unix(1600000000, 0)
+	code, _ := totp.Default.Code(secret, now)
+	for _, c := range []struct {
+		at time.Time
+		ok bool
+	}{
+		{now, true},
+		{now.Add(30 * time.Second), true},
+		{now.Add(-30 * time.Second), true},
+		{now.Add(90 * time.Second), false},
+	} {
+		counter, ok := totp.Default.Validate(secret, code, c.at)
+		if ok != c.ok || (ok && counter != totp.Default.Counter(now)) {
+			t.Errorf("%v: need: %v, got: %v %d", c.at.Sub(now), c.ok, ok, counter)
+		}
+	}
+	if _, ok := totp.Default.Validate(secret, "12345", now); ok {
+		t.Error("need invalid length")
+	}
+	if _, ok := totp.Default.Validate("not base32!", code, now); ok {
+		t.Error("need invalid secret")
+	}
+}
+
+func TestTOTP_URI(t *testing.T) {
+	uri := totp.Default.URI("Morgine Admin", "root", "JBSWY3DPEHPK3PXP")
+	u, err := url.Parse(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if u.Scheme != "otpauth" || u.Host != "totp" || u.Path != "/Morgine Admin:root" {
+		t.Errorf("got: %s", uri)
+	}
+	if q.Get("secret") != "JBSWY3DPEHPK3PXP" || q.Get("issuer") != "Morgine Admin" || q.Get("digits") != "6" || q.Get("period") != "30" {
+		t.Errorf("got: %s", uri)
+	}
+}
@@ -10,13 +10,19 @@ import (
 	"github.com/orivil/morgine/param"
 	"runtime"
 	"sort"
+	"time"
 	"unsafe"
 )
 
 type ApiDoc struct {
-	Tags    ApiTags
-	Middles map[uintptr]*ApiMiddle
-	Actions map[uintptr][]*ApiAction
+	Tags     ApiTags
+	Versions []string
+	Middles  map[uintptr]*ApiMiddle
+	Actions  map[uintptr][]*ApiAction
+	Rules    []*param.Rule // 已注册的自定义验证规则
+
+	// 所有认证方式, 键为认证方式名称
+	SecuritySchemes map[string]*SecurityScheme `json:",omitempty"`
 }
 
 func newApiDoc() *ApiDoc {
@@ -25,7 +31,7 @@ func newApiDoc() *ApiDoc {
 		Actions: map[uintptr][]*ApiAction{},
 	}
 }
-func (doc *ApiDoc) add(depth int, tag TagName, method, route string, d *Doc, middles []*Handler) {
+func (doc *ApiDoc) add(depth int, tag TagName, version, method, route string, d *Doc, middles []*Handler, rateLimits []*RateLimit) {
 	for _, middle := range middles {
 		ptr := uintptr(unsafe.Pointer(middle))
 		if _, ok := doc.Middles[ptr]; !ok {
@@ -34,6 +40,7 @@ func (doc *ApiDoc) add(depth int, tag TagName, method, route string, d *Doc, mid
 				Desc:      middle.Doc.Desc,
 				Params:    initApiParams(middle.Doc.parser),
 				Responses: middle.Doc.Responses,
+				Security:  doc.addSecurity(middle.Doc.Security),
 			}
 		}
 	}
@@ -43,13 +50,19 @@ func (doc *ApiDoc) add(depth int, tag TagName, method, route string, d *Doc, mid
 		Trace:       initTrace(depth + 1),
 		Method:      method,
 		Route:       route,
+		Version:     version,
+		Deprecated:  d.Deprecated,
+		Sunset:      d.Sunset,
 		Params:      initApiParams(d.parser),
 		ContentType: getActionContentType(d.parser),
 		Responses:   d.Responses,
+		RateLimits:  initApiRateLimits(rateLimits),
 	}
 	for _, middle := range middles {
 		act.Middles = append(act.Middles, uintptr(unsafe.Pointer(middle)))
+		act.Security = append(act.Security, doc.Middles[uintptr(unsafe.Pointer(middle))].Security...)
 	}
+	act.Security = append(act.Security, doc.addSecurity(d.Security)...)
 	ptr := uintptr(unsafe.Pointer(tag))
 	doc.Actions[ptr] = append(doc.Actions[ptr], act)
 }
@@ -64,11 +77,13 @@ type ApiMiddle struct {
 	Desc      string
 	Params    []*ApiParam
 	Responses Responses
+	Security  []string `json:",omitempty"` // 认证方式名称
 }
 
 type ApiParam struct {
 	Type   ParamType
 	Fields []*param.Field
+	Rules  []string `json:",omitempty"` // 结构体验证描述
 }
 
 type apiParams []*ApiParam
@@ -94,17 +109,32 @@ func getActionContentType(p *parser) param.EncodeType {
 	return param.UrlEncodeType
 }
 
+// 按参数来源分组文档字段, 通过 in 标签声明来源的字段归入对应来源的参数中
 func initApiParams(p *parser) apiParams {
 	var params apiParams
 	for key, typ := range p.types {
 		schema := p.schemas[key]
-		p := &ApiParam{
-			Type:   typ,
-			Fields: schema.Fields,
+		ap := &ApiParam{
+			Type:  typ,
+			Rules: schema.Rules,
+		}
+		sources := map[ParamType]*ApiParam{typ: ap}
+		for _, field := range schema.Fields {
+			source := fieldSource(typ, field)
+			sp, ok := sources[source]
+			if !ok {
+				sp = &ApiParam{Type: source}
+				sources[source] = sp
+				params = append(params, sp)
+			}
+			sp.Fields = append(sp.Fields, field)
+		}
+		// 所有字段都声明了其他来源时, 只在有结构体验证描述时保留
+		if len(ap.Fields) > 0 || len(ap.Rules) > 0 || len(schema.Fields) == 0 {
+			params = append(params, ap)
 		}
-		params = append(params, p)
 	}
-	sort.Sort(params)
+	sort.Stable(params)
 	return params
 }
 
@@ -114,10 +144,15 @@ type ApiAction struct {
 	Trace       string           // 注册地址(runtime file:line)
 	Method      string           // 请求方法
 	Route       string           // 请求路由
+	Version     string           // 接口版本, 为空则属于所有版本
+	Deprecated  bool             // 是否已弃用
+	Sunset      *time.Time       // 停止服务时间
 	Middles     []uintptr        // 中间件
 	Params      []*ApiParam      // 参数
 	ContentType param.EncodeType // 参数编码类型
 	Responses   Responses        // 响应列表
+	RateLimits  []*ApiRateLimit  // 限流规则
+	Security    []string         `json:",omitempty"` // 认证方式名称
 }
 
 type TagName *string
@@ -0,0 +1,182 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package xx
+
+import (
+	"github.com/orivil/morgine/log"
+	"github.com/orivil/morgine/param"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// 审计日志中敏感参数的替代值
+const Redacted = "[REDACTED]"
+
+// 名称包含这些单词(不区分大小写)的参数同样视为敏感参数, 如: password, new_password, refresh_token
+var SensitiveParamNames = []string{"password", "secret", "token"}
+
+// AuditEntry 为一次接口调用的审计记录
+type AuditEntry struct {
+	Actor  *Principal // 访问者, 未认证时为 nil
+	Method string     // 请求方法
+	Route  string     // 注册的路由, 如: /roles/{id}
+	Params url.Values // 请求参数, 敏感参数已被替换为 Redacted
+	Status int        // 响应状态码
+	IP     string
+	Time   time.Time
+}
+
+// Auditor 为审计中间件, 在后续处理结束后记录审计日志, 需要在认证中间件之后使用才能记录访问者:
+//
+//	auditor := xx.NewAuditor(func(entry *xx.AuditEntry) error {
+//		return db.Create(newLog(entry)).Error
+//	})
+//	controller := group.Use(authenticate, auditor.Handler()).Controller(tag)
+type Auditor struct {
+	// 保存审计记录, 保存失败时记录到 log.Error, 不影响响应
+	Record func(entry *AuditEntry) error
+
+	// 需要记录的请求方法, 默认只记录会修改数据的请求
+	Methods []string
+}
+
+func NewAuditor(record func(entry *AuditEntry) error) *Auditor {
+	return &Auditor {
+		Record:  record,
+		Methods: []string{http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete},
+	}
+}
+
+func (a *Auditor) Handler() *Handler {
+	return &Handler {
+		Doc: &Doc {
+			Title: "审计",
+			Desc:  "记录访问者, 接口, 请求参数, 响应状态码及客户端 IP, 敏感参数不记录原值",
+		},
+		HandleFunc: func(ctx *Context) {
+			if !containsString(a.Methods, ctx.Request.Method) {
+				return
+			}
+			res := &response{ResponseWriter: ctx.Writer}
+			ctx.Writer = res
+			start := time.Now()
+			ctx.HandleNext()
+			status := res.statusCode
+			if ctx.err != nil {
+				status = http.StatusInternalServerError
+			} else if status == 0 {
+				status = http.StatusOK
+			}
+			err := a.Record(&AuditEntry {
+				Actor:  ctx.Principal(),
+				Method: ctx.handler.method,
+				Route:  ctx.handler.route,
+				Params: ctx.AuditParams(),
+				Status: status,
+				IP:     ctx.ClientIP(),
+				Time:   start,
+			})
+			if err != nil {
+				log.Error.Println(err)
+			}
+		},
+	}
+}
+
+// AuditParams 获得接口声明的请求参数, 敏感参数的值被替换为 Redacted, 上传的文件只记录文件名.
+// 流式上传的表单不会被读取
+func (c *Context) AuditParams() url.Values {
+	values := make(url.Values)
+	if c.handler.Doc == nil || c.handler.Doc.parser == nil {
+		return values
+	}
+	par := c.handler.Doc.parser
+	for t, schema := range par.schemas {
+		if par.streams[t] {
+			continue
+		}
+		typ := par.types[t]
+		form := &multipart.Form {
+			Value: make(map[string][]string),
+			File:  make(map[string][]*multipart.FileHeader),
+		}
+		for _, field := range schema.Fields {
+			source := fieldSource(typ, field)
+			m, err := newMarshaler(source, schema.EncodeType())
+			if err != nil {
+				continue
+			}
+			if src := m(c); src != nil {
+				copyField(form, src, source, field.Name)
+			}
+		}
+		for key, vs := range form.Value {
+			values[key] = vs
+		}
+		for key, files := range form.File {
+			for _, file := range files {
+				values.Add(key, file.Filename)
+			}
+		}
+		redactParams(values, "", schema.Fields)
+	}
+	return values
+}
+
+// 将敏感字段及其嵌套字段的值替换为 Redacted, 参数名中的下标在匹配时被忽略, 如: items[0].password
+func redactParams(values url.Values, prefix string, fields []*param.Field) {
+	for _, field := range fields {
+		name := prefix + field.Name
+		if isSensitiveParam(field) {
+			for key, vs := range values {
+				if isFieldKey(trimIndexes(key), name) {
+					// 参数值与请求中的表单共用, 不能直接修改
+					redacted := make([]string, len(vs))
+					for i := range redacted {
+						redacted[i] = Redacted
+					}
+					values[key] = redacted
+				}
+			}
+		} else if len(field.Fields) > 0 {
+			redactParams(values, name+".", field.Fields)
+		}
+	}
+}
+
+func isSensitiveParam(field *param.Field) bool {
+	if field.Sensitive {
+		return true
+	}
+	name := strings.ToLower(field.Name)
+	for _, word := range SensitiveParamNames {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
+
+func trimIndexes(key string) string {
+	if !strings.Contains(key, "[") {
+		return key
+	}
+	var b strings.Builder
+	depth := 0
+	for _, r := range key {
+		switch {
+		case r == '[':
+			depth++
+		case r == ']' && depth > 0:
+			depth--
+		case depth == 0:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,121 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package xx_test
+
+import (
+	"errors"
+	"github.com/orivil/morgine/xx"
+	"github.com/orivil/morgine/xx/xxtest"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestAuditor(t *testing.T) {
+	var entries []*xx.AuditEntry
+	auditor := xx.NewAuditor(func(entry *xx.AuditEntry) error {
+		entries = append(entries, entry)
+		return nil
+	})
+	authenticate := &xx.Handler {
+		HandleFunc: func(ctx *xx.Context) {
+			ctx.SetPrincipal(&xx.Principal{ID: "1", Name: "root"})
+		},
+	}
+	mux, controller := xxtest.NewMux("admins", authenticate, auditor.Handler())
+	type account struct {
+		Username string `param:"username"`
+		Secret   string `param:"pin" sensitive:""`
+	}
+	type params struct {
+		ID          int       `param:"id" in:"query"`
+		Username    string    `param:"username"`
+		NewPassword string    `param:"new_password"`
+		Code        string    `param:"code" sensitive:""`
+		Accounts    []account `param:"accounts"`
+	}
+	doc := &xx.Doc {
+		Params: xx.Params {
+			{
+				Type:   xx.Form,
+				Schema: &params{},
+			},
+		},
+	}
+	controller.Handle("PUT", "/admins", doc, func(ctx *xx.Context) {
+		p := &params{}
+		err := ctx.Unmarshal(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Username == "fail" {
+			ctx.Error(errors.New("failed"))
+			return
+		}
+		ctx.Writer.WriteHeader(http.StatusAccepted)
+		ctx.SendJsonMessage(xx.MsgSuccess, "ok")
+	})
+	controller.Handle("GET", "/admins", nil, func(ctx *xx.Context) {
+		ctx.WriteString("ok")
+	})
+
+	form := url.Values {
+		"username":             {"bob"},
+		"new_password":         {"123456"},
+		"code":                 {"654321"},
+		"accounts[0].username": {"alice"},
+		"accounts[0].pin":      {"1234"},
+		"ignored":              {"x"},
+	}
+	req := httptest.NewRequest("PUT", "/admins?id=5", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	xxtest.Serve(mux, req)
+
+	if len(entries) != 1 {
+		t.Fatalf("need 1 entry, got %d", len(entries))
+	}
+	entry := entries[0]
+	if entry.Actor == nil || entry.Actor.ID != "1" {
+		t.Errorf("actor: got %v", entry.Actor)
+	}
+	if entry.Method != "PUT" || entry.Route != "/admins" {
+		t.Errorf("route: got %s %s", entry.Method, entry.Route)
+	}
+	if entry.Status != http.StatusAccepted {
+		t.Errorf("status: need %d, got %d", http.StatusAccepted, entry.Status)
+	}
+	want := map[string]string {
+		"id":                   "5",
+		"username":             "bob",
+		"new_password":         xx.Redacted,
+		"code":                 xx.Redacted,
+		"accounts[0].username": "alice",
+		"accounts[0].pin":      xx.Redacted,
+	}
+	for key, value := range want {
+		if got := entry.Params.Get(key); got != value {
+			t.Errorf("param %s: need %q, got %q", key, value, got)
+		}
+	}
+	if _, ok := entry.Params["ignored"]; ok {
+		t.Error("undeclared param should not be recorded")
+	}
+
+	// 服务器错误
+	req = httptest.NewRequest("PUT", "/admins", strings.NewReader("username=fail"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	xxtest.Serve(mux, req)
+	if len(entries) != 2 || entries[1].Status != http.StatusInternalServerError {
+		t.Fatalf("need status 500 entry, got %d entries", len(entries))
+	}
+
+	// 默认不记录 GET 请求
+	xxtest.Serve(mux, httptest.NewRequest("GET", "/admins", nil))
+	if len(entries) != 2 {
+		t.Errorf("GET request should not be recorded")
+	}
+}
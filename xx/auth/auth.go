@@ -0,0 +1,162 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+// auth 为 xx 提供可插拔的认证中间件. 使用方式:
+//
+//	jwtAuth, err := auth.NewJWT(jwt.SigningMethodRS256, publicKey)
+//	apiKey := &auth.APIKey{In: xx.Header, ParamName: "X-Api-Key", Lookup: auth.StaticKeys(keys)}
+//	controller := group.Use(auth.Required(jwtAuth, apiKey)).Controller(tag)
+//
+//	// action 中
+//	principal := ctx.Principal()
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"github.com/orivil/morgine/xx"
+	"net/http"
+	"strings"
+)
+
+var (
+	// 请求中未提供该认证方式的凭证, 将继续尝试下一种认证方式
+	ErrNoCredentials = errors.New("no credentials")
+
+	// 凭证无效, 认证方式返回的错误包含该错误时响应 401, 其他错误响应 500
+	ErrInvalidCredentials = errors.New("invalid credentials")
+
+	ErrTokenExpired = fmt.Errorf("%w: token is expired", ErrInvalidCredentials)
+)
+
+// Authenticator 为认证方式
+type Authenticator interface {
+	// Authenticate 从请求中读取并验证凭证, 未提供凭证时返回 ErrNoCredentials
+	Authenticate(ctx *xx.Context) (*xx.Principal, error)
+
+	// Scheme 获得文档中的认证方式
+	Scheme() *xx.SecurityScheme
+}
+
+// Challenger 为认证失败时的 WWW-Authenticate 响应头, 如: Basic realm="admin"
+type Challenger interface {
+	Challenge() string
+}
+
+// Auth 依次尝试多种认证方式, 任意一种认证成功即可
+type Auth struct {
+	Authenticators []Authenticator
+
+	// 为 true 时未提供任何凭证的请求也可通过, 但提供了无效凭证时依然拒绝
+	Optional bool
+
+	// 认证失败时的处理函数, err 为 ErrNoCredentials 或认证方式返回的错误, 默认响应 401
+	Unauthorized func(ctx *xx.Context, err error)
+}
+
+func New(authenticators ...Authenticator) *Auth {
+	return &Auth {
+		Authenticators: authenticators,
+		Unauthorized:   Unauthorized,
+	}
+}
+
+// Required 获得必须认证的中间件
+func Required(authenticators ...Authenticator) *xx.Handler {
+	return New(authenticators...).Handler()
+}
+
+// Optional 获得可选认证的中间件, 未认证时 ctx.Principal() 为 nil
+func Optional(authenticators ...Authenticator) *xx.Handler {
+	a := New(authenticators...)
+	a.Optional = true
+	return a.Handler()
+}
+
+// Unauthorized 为默认的认证失败处理函数, 响应 401. 调用前已设置 WWW-Authenticate 响应头
+func Unauthorized(ctx *xx.Context, err error) {
+	ctx.HttpError(http.StatusUnauthorized)
+}
+
+// Handler 获得认证中间件, 认证成功后通过 ctx.Principal() 获得访问者
+func (a *Auth) Handler() *xx.Handler {
+	if len(a.Authenticators) == 0 {
+		panic("auth: no authenticator")
+	}
+	var names []string
+	var schemes []*xx.SecurityScheme
+	for _, authenticator := range a.Authenticators {
+		scheme := authenticator.Scheme()
+		names = append(names, scheme.Name)
+		schemes = append(schemes, scheme)
+	}
+	desc := "认证方式: " + strings.Join(names, ", ")
+	if a.Optional {
+		desc += ", 未提供凭证时以匿名身份访问"
+	}
+	return &xx.Handler {
+		Doc: &xx.Doc {
+			Title:    "Authentication",
+			Desc:     desc,
+			Security: schemes,
+			Responses: xx.Responses {
+				xx.HttpErrorResponse("认证失败", http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized),
+			},
+		},
+		HandleFunc: a.handle,
+	}
+}
+
+func (a *Auth) handle(ctx *xx.Context) {
+	// 多种认证方式可能读取同一凭证(如 JWT 及不透明令牌), 所以凭证无效时继续尝试其他认证方式
+	failure := ErrNoCredentials
+	for _, authenticator := range a.Authenticators {
+		principal, err := authenticator.Authenticate(ctx)
+		if err == ErrNoCredentials {
+			continue
+		}
+		if err == nil && principal == nil {
+			err = ErrInvalidCredentials
+		}
+		if err != nil {
+			if !errors.Is(err, ErrInvalidCredentials) {
+				ctx.Error(err)
+				return
+			}
+			if failure == ErrNoCredentials {
+				failure = err
+			}
+			continue
+		}
+		if principal.Scheme == "" {
+			principal.Scheme = authenticator.Scheme().Name
+		}
+		ctx.SetPrincipal(principal)
+		return
+	}
+	if failure == ErrNoCredentials && a.Optional {
+		return
+	}
+	header := ctx.Writer.Header()
+	for _, authenticator := range a.Authenticators {
+		if c, ok := authenticator.(Challenger); ok {
+			header.Add("WWW-Authenticate", c.Challenge())
+		}
+	}
+	a.Unauthorized(ctx, failure)
+}
+
+// BearerToken 获得 Authorization 请求头中的 Bearer 令牌
+func BearerToken(ctx *xx.Context) (string, bool) {
+	return authorization(ctx, "Bearer")
+}
+
+func authorization(ctx *xx.Context, scheme string) (string, bool) {
+	value := ctx.Request.Header.Get("Authorization")
+	if len(value) <= len(scheme) || !strings.EqualFold(value[:len(scheme)], scheme) || value[len(scheme)] != ' ' {
+		return "", false
+	}
+	credentials := strings.TrimSpace(value[len(scheme)+1:])
+	return credentials, credentials != ""
+}
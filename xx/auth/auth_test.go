@@ -0,0 +1,208 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package auth_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/orivil/morgine/xx"
+	"github.com/orivil/morgine/xx/auth"
+	"github.com/orivil/morgine/xx/xxtest"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newAuthMux(middle *xx.Handler) *xx.ServeMux {
+	mux, controller := xxtest.NewMux("users", middle)
+	controller.Handle("GET", "/me", nil, func(ctx *xx.Context) {
+		p := ctx.Principal()
+		if p == nil {
+			ctx.WriteString("anonymous")
+		} else {
+			ctx.WriteString(fmt.Sprintf("%s %s %s %v", p.Scheme, p.ID, p.Name, p.Roles))
+		}
+	})
+	return mux
+}
+
+func serve(mux *xx.ServeMux, setup func(req *http.Request)) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/me?api_key=query-key", nil)
+	if setup != nil {
+		setup(req)
+	}
+	return xxtest.Serve(mux, req)
+}
+
+func bearer(token string) func(req *http.Request) {
+	return func(req *http.Request) {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, key interface{}, claims jwt.MapClaims) string {
+	token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestJWT(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := jwt.MapClaims {
+		"sub":   "1",
+		"name":  "root",
+		"roles": []string{"admin"},
+		"aud":   []string{"api", "web"},
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	}
+	hmacKey := []byte("secret")
+	for _, c := range []struct {
+		method   jwt.SigningMethod
+		signKey  interface{}
+		verifyBy interface{}
+	}{
+		{jwt.SigningMethodHS256, hmacKey, hmacKey},
+		{jwt.SigningMethodRS256, rsaKey, &rsaKey.PublicKey},
+		{jwt.SigningMethodES256, ecKey, &ecKey.PublicKey},
+	} {
+		j, err := auth.NewJWT(c.method, c.verifyBy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		j.Audience = "api"
+		mux := newAuthMux(auth.Required(j))
+		res := serve(mux, bearer(sign(t, c.method, c.signKey, claims)))
+		if need := "jwt 1 root [admin]"; res.Body.String() != need {
+			t.Errorf("%s: need: %s, got: %s", c.method.Alg(), need, res.Body.String())
+		}
+	}
+
+	// 算法必须与声明的一致, 防止以 HMAC 伪造 RSA 签名
+	j, _ := auth.NewJWT(jwt.SigningMethodRS256, &rsaKey.PublicKey)
+	res := serve(newAuthMux(auth.Required(j)), bearer(sign(t, jwt.SigningMethodHS256, hmacKey, claims)))
+	if res.Code != http.StatusUnauthorized || res.Header().Get("WWW-Authenticate") != `Bearer realm="jwt"` {
+		t.Errorf("need 401 with challenge, got: %d %v", res.Code, res.Header())
+	}
+
+	if _, err = auth.NewJWT(jwt.SigningMethodRS256, hmacKey); err == nil {
+		t.Error("need key type error")
+	}
+}
+
+func TestJWT_Expired(t *testing.T) {
+	key := []byte("secret")
+	j, _ := auth.NewJWT(jwt.SigningMethodHS256, key)
+	a := auth.New(j)
+	var got error
+	a.Unauthorized = func(ctx *xx.Context, err error) {
+		got = err
+		auth.Unauthorized(ctx, err)
+	}
+	token := sign(t, jwt.SigningMethodHS256, key, jwt.MapClaims{"sub": "1", "exp": time.Now().Add(-time.Minute).Unix()})
+	res := serve(newAuthMux(a.Handler()), bearer(token))
+	if res.Code != http.StatusUnauthorized || got != auth.ErrTokenExpired {
+		t.Errorf("need 401 and %v, got: %d %v", auth.ErrTokenExpired, res.Code, got)
+	}
+}
+
+func TestAuthenticators(t *testing.T) {
+	tokens := &auth.Bearer {
+		Format: "opaque",
+		Verify: func(token string) (*xx.Principal, error) {
+			if token != "opaque-token" {
+				return nil, auth.ErrInvalidCredentials
+			}
+			return &xx.Principal{ID: "2"}, nil
+		},
+	}
+	basic := &auth.Basic {
+		Realm: "admin",
+		Verify: func(username, password string) (*xx.Principal, error) {
+			if username != "root" || password != "123456" {
+				return nil, auth.ErrInvalidCredentials
+			}
+			return &xx.Principal{ID: "3", Name: username}, nil
+		},
+	}
+	headerKey := &auth.APIKey {
+		Name:      "headerKey",
+		In:        xx.Header,
+		ParamName: "X-Api-Key",
+		Lookup:    auth.StaticKeys(map[string]*xx.Principal{"header-key": {ID: "4"}}),
+	}
+	queryKey := &auth.APIKey {
+		Name:      "queryKey",
+		In:        xx.Query,
+		ParamName: "api_key",
+		Lookup:    auth.StaticKeys(map[string]*xx.Principal{"query-key": {ID: "5"}}),
+	}
+	required := newAuthMux(auth.Required(tokens, basic, headerKey))
+	cases := []struct {
+		setup func(req *http.Request)
+		code  int
+		body  string
+	}{
+		{bearer("opaque-token"), 200, "bearer 2  []"},
+		{bearer("other-token"), 401, ""},
+		{func(req *http.Request) { req.SetBasicAuth("root", "123456") }, 200, "basic 3 root []"},
+		{func(req *http.Request) { req.SetBasicAuth("root", "000000") }, 401, ""},
+		{func(req *http.Request) { req.Header.Set("X-Api-Key", "header-key") }, 200, "headerKey 4  []"},
+		{func(req *http.Request) { req.Header.Set("X-Api-Key", "other-key") }, 401, ""},
+		{nil, 401, ""},
+	}
+	for i, c := range cases {
+		res := serve(required, c.setup)
+		if res.Code != c.code || (c.code == 200 && res.Body.String() != c.body) {
+			t.Errorf("case %d: need: %d %s, got: %d %s", i, c.code, c.body, res.Code, res.Body.String())
+		}
+		if c.code == 401 && len(res.Header()["Www-Authenticate"]) != 2 {
+			t.Errorf("case %d: need 2 challenges, got: %v", i, res.Header()["Www-Authenticate"])
+		}
+	}
+
+	optional := newAuthMux(auth.Optional(tokens))
+	if res := serve(optional, nil); res.Body.String() != "anonymous" {
+		t.Errorf("need anonymous, got: %s", res.Body.String())
+	}
+	if res := serve(optional, bearer("other-token")); res.Code != 401 {
+		t.Errorf("invalid credentials need 401, got: %d", res.Code)
+	}
+	if res := serve(newAuthMux(auth.Required(queryKey)), nil); res.Body.String() != "queryKey 5  []" {
+		t.Errorf("got: %s", res.Body.String())
+	}
+}
+
+func TestAuth_ApiDoc(t *testing.T) {
+	j, _ := auth.NewJWT(jwt.SigningMethodHS256, []byte("secret"))
+	key := &auth.APIKey{In: xx.Cookie, ParamName: "key", Lookup: auth.StaticKeys(nil)}
+	mux := newAuthMux(auth.Required(j, key))
+	doc := mux.ApiDoc()
+	if s := doc.SecuritySchemes["jwt"]; s == nil || s.Type != "http" || s.Scheme != "bearer" || s.BearerFormat != "JWT" {
+		t.Errorf("got jwt scheme: %+v", s)
+	}
+	if s := doc.SecuritySchemes["apiKey"]; s == nil || s.Type != "apiKey" || s.In != xx.Cookie || s.ParamName != "key" {
+		t.Errorf("got api key scheme: %+v", s)
+	}
+	for _, acts := range doc.Actions {
+		if got := strings.Join(acts[0].Security, ","); got != "jwt,apiKey" {
+			t.Errorf("need: jwt,apiKey, got: %s", got)
+		}
+	}
+}
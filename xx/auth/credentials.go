@@ -0,0 +1,172 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"github.com/orivil/morgine/xx"
+)
+
+var (
+	_ Authenticator = (*Bearer)(nil)
+	_ Authenticator = (*Basic)(nil)
+	_ Authenticator = (*APIKey)(nil)
+)
+
+// Bearer 验证 Authorization 请求头中的不透明令牌, 令牌由 Verify 查询或解析, 如 utils/token 签发的令牌
+type Bearer struct {
+	// 认证方式名称, 默认为 "bearer"
+	Name string
+
+	// 文档中的令牌格式说明
+	Format string
+
+	// 验证令牌, 令牌无效时返回包含 ErrInvalidCredentials 的错误
+	Verify func(token string) (*xx.Principal, error)
+}
+
+func (b *Bearer) name() string {
+	if b.Name != "" {
+		return b.Name
+	}
+	return "bearer"
+}
+
+func (b *Bearer) Scheme() *xx.SecurityScheme {
+	return &xx.SecurityScheme {
+		Name:         b.name(),
+		Type:         "http",
+		Scheme:       "bearer",
+		BearerFormat: b.Format,
+	}
+}
+
+func (b *Bearer) Challenge() string {
+	return `Bearer realm="` + b.name() + `"`
+}
+
+func (b *Bearer) Authenticate(ctx *xx.Context) (*xx.Principal, error) {
+	token, ok := BearerToken(ctx)
+	if !ok {
+		return nil, ErrNoCredentials
+	}
+	return b.Verify(token)
+}
+
+// Basic 为 HTTP Basic 认证, 只应在 HTTPS 下使用
+type Basic struct {
+	// 认证方式名称, 默认为 "basic"
+	Name string
+
+	// 浏览器弹出的登录框中显示的域
+	Realm string
+
+	// 验证用户名及密码, 验证失败时返回包含 ErrInvalidCredentials 的错误
+	Verify func(username, password string) (*xx.Principal, error)
+}
+
+func (b *Basic) name() string {
+	if b.Name != "" {
+		return b.Name
+	}
+	return "basic"
+}
+
+func (b *Basic) Scheme() *xx.SecurityScheme {
+	return &xx.SecurityScheme {
+		Name:   b.name(),
+		Type:   "http",
+		Scheme: "basic",
+	}
+}
+
+func (b *Basic) Challenge() string {
+	return `Basic realm="` + b.Realm + `", charset="UTF-8"`
+}
+
+func (b *Basic) Authenticate(ctx *xx.Context) (*xx.Principal, error) {
+	username, password, ok := ctx.Request.BasicAuth()
+	if !ok {
+		return nil, ErrNoCredentials
+	}
+	return b.Verify(username, password)
+}
+
+// APIKey 验证请求头, 查询参数或 cookie 中的 API 密钥
+type APIKey struct {
+	// 认证方式名称, 默认为 "apiKey"
+	Name string
+
+	// 密钥所在位置, 可以是 xx.Header, xx.Query 或 xx.Cookie
+	In xx.ParamType
+
+	// 密钥参数名, 如: X-Api-Key
+	ParamName string
+
+	// 查询密钥对应的访问者, 密钥无效时返回包含 ErrInvalidCredentials 的错误
+	Lookup func(key string) (*xx.Principal, error)
+}
+
+func (k *APIKey) name() string {
+	if k.Name != "" {
+		return k.Name
+	}
+	return "apiKey"
+}
+
+func (k *APIKey) Scheme() *xx.SecurityScheme {
+	return &xx.SecurityScheme {
+		Name:      k.name(),
+		Type:      "apiKey",
+		In:        k.In,
+		ParamName: k.ParamName,
+	}
+}
+
+func (k *APIKey) Authenticate(ctx *xx.Context) (*xx.Principal, error) {
+	var key string
+	switch k.In {
+	case xx.Header:
+		key = ctx.Request.Header.Get(k.ParamName)
+	case xx.Query:
+		key = ctx.Query().Get(k.ParamName)
+	case xx.Cookie:
+		key, _ = ctx.Cookie(k.ParamName)
+	default:
+		panic("auth: API key must be in header, query or cookie")
+	}
+	if key == "" {
+		return nil, ErrNoCredentials
+	}
+	return k.Lookup(key)
+}
+
+// StaticKeys 获得固定密钥的查询函数, 比较密钥哈希值以避免时序攻击, 适用于服务间调用等少量密钥的场景
+func StaticKeys(keys map[string]*xx.Principal) func(key string) (*xx.Principal, error) {
+	type entry struct {
+		hash      [sha256.Size]byte
+		principal *xx.Principal
+	}
+	entries := make([]entry, 0, len(keys))
+	for key, p := range keys {
+		entries = append(entries, entry{hash: sha256.Sum256([]byte(key)), principal: p})
+	}
+	return func(key string) (*xx.Principal, error) {
+		hash := sha256.Sum256([]byte(key))
+		var found *xx.Principal
+		for _, e := range entries {
+			if subtle.ConstantTimeCompare(hash[:], e.hash[:]) == 1 {
+				found = e.principal
+			}
+		}
+		if found == nil {
+			return nil, ErrInvalidCredentials
+		}
+		// 返回副本, 避免中间件修改共享的访问者
+		p := *found
+		return &p, nil
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/orivil/morgine/xx"
+)
+
+var _ Authenticator = (*JWT)(nil)
+
+// JWT 验证 Authorization 请求头中的 Bearer JWT 令牌
+type JWT struct {
+	// 认证方式名称, 默认为 "jwt"
+	Name string
+
+	// 签名算法, 只接受该算法签名的令牌
+	Method jwt.SigningMethod
+
+	// 验证密钥, HS 算法为 []byte, RS 及 PS 算法为 *rsa.PublicKey, ES 算法为 *ecdsa.PublicKey
+	Key interface{}
+
+	// 不为空时验证 iss 及 aud 声明
+	Issuer   string
+	Audience string
+
+	// 将令牌声明转换为访问者, 默认 sub 为 ID, name 为名称, roles 为角色列表
+	Principal func(claims jwt.MapClaims) (*xx.Principal, error)
+}
+
+// NewJWT 新建 JWT 认证方式, 并检查密钥与签名算法是否匹配
+func NewJWT(method jwt.SigningMethod, key interface{}) (*JWT, error) {
+	var ok bool
+	switch method.(type) {
+	case *jwt.SigningMethodHMAC:
+		_, ok = key.([]byte)
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		_, ok = key.(*rsa.PublicKey)
+	case *jwt.SigningMethodECDSA:
+		_, ok = key.(*ecdsa.PublicKey)
+	}
+	if !ok {
+		return nil, fmt.Errorf("auth: key type %T does not match signing method %s", key, method.Alg())
+	}
+	return &JWT{Method: method, Key: key}, nil
+}
+
+func (j *JWT) name() string {
+	if j.Name != "" {
+		return j.Name
+	}
+	return "jwt"
+}
+
+func (j *JWT) Scheme() *xx.SecurityScheme {
+	return &xx.SecurityScheme {
+		Name:         j.name(),
+		Type:         "http",
+		Scheme:       "bearer",
+		BearerFormat: "JWT",
+		Desc:         "签名算法: " + j.Method.Alg(),
+	}
+}
+
+func (j *JWT) Challenge() string {
+	return `Bearer realm="` + j.name() + `"`
+}
+
+func (j *JWT) Authenticate(ctx *xx.Context) (*xx.Principal, error) {
+	tokenString, ok := BearerToken(ctx)
+	if !ok {
+		return nil, ErrNoCredentials
+	}
+	claims, err := j.Parse(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if j.Principal != nil {
+		return j.Principal(claims)
+	}
+	return ClaimsPrincipal(claims), nil
+}
+
+// Parse 验证令牌签名, 有效期及 iss, aud 声明
+func (j *JWT) Parse(tokenString string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		// 防止使用其他算法伪造签名, 如以公钥作为 HMAC 密钥
+		if t.Method.Alg() != j.Method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %s", t.Method.Alg())
+		}
+		return j.Key, nil
+	})
+	if err != nil {
+		if ve, ok := err.(*jwt.ValidationError); ok && ve.Errors == jwt.ValidationErrorExpired {
+			return nil, ErrTokenExpired
+		}
+		return nil, fmt.Errorf("%w: %s", ErrInvalidCredentials, err)
+	}
+	if j.Issuer != "" && !claims.VerifyIssuer(j.Issuer, true) {
+		return nil, fmt.Errorf("%w: issuer mismatch", ErrInvalidCredentials)
+	}
+	if j.Audience != "" && !hasAudience(claims["aud"], j.Audience) {
+		return nil, fmt.Errorf("%w: audience mismatch", ErrInvalidCredentials)
+	}
+	return claims, nil
+}
+
+// aud 可以是字符串或字符串数组
+func hasAudience(aud interface{}, audience string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == audience
+	case []interface{}:
+		for _, a := range v {
+			if s, ok := a.(string); ok && s == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ClaimsPrincipal 将令牌声明转换为访问者, sub 为 ID, name 为名称, roles 为角色列表
+func ClaimsPrincipal(claims jwt.MapClaims) *xx.Principal {
+	p := &xx.Principal{Claims: claims}
+	p.ID, _ = claims["sub"].(string)
+	p.Name, _ = claims["name"].(string)
+	if roles, ok := claims["roles"].([]interface{}); ok {
+		for _, role := range roles {
+			if r, ok := role.(string); ok {
+				p.Roles = append(p.Roles, r)
+			}
+		}
+	}
+	return p
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/orivil/morgine/router"
 	"net/http"
 	"strings"
+	"time"
 )
 
 // controller document 过滤器, 可用于设置默认参数, 默认响应等, 该方法不会过滤中间件的 document
@@ -57,19 +58,33 @@ type Condition struct {
 	tagName TagName
 	ApiDoc  *ApiDoc
 	router  *router.Router
+
+	// API 版本, 为空则不区分版本
+	version string
+
+	// 弃用标记及停止服务时间
+	deprecated bool
+	sunset     *time.Time
+
+	// 限流规则
+	rateLimits []*RateLimit
 }
 
 func (g *Condition) copy() *Condition {
 	nc := &Condition{
-		router:  g.router,
-		tagName: g.tagName,
-		tags:    g.tags,
-		ApiDoc:  g.ApiDoc,
+		router:     g.router,
+		tagName:    g.tagName,
+		tags:       g.tags,
+		ApiDoc:     g.ApiDoc,
+		version:    g.version,
+		deprecated: g.deprecated,
+		sunset:     g.sunset,
 	}
 	nc.middles = make([]*Handler, len(g.middles))
 	for key, value := range g.middles {
 		nc.middles[key] = value
 	}
+	nc.rateLimits = append([]*RateLimit(nil), g.rateLimits...)
 	return nc
 }
 
@@ -118,11 +133,24 @@ func (g *Condition) handle(depth int, method, route string, doc *Doc, handleFunc
 	if g.tagName == nil {
 		panic("controller name is nil")
 	}
+	if g.deprecated {
+		doc.Deprecated = true
+		if doc.Sunset == nil {
+			doc.Sunset = g.sunset
+		}
+	}
+	if g.version != "" {
+		route = versionPrefix(g.version) + route
+	}
 	middles := append(globalMiddles, g.middles...)
 	handler := &Handler {
 		Doc:        doc,
 		middles:    middles,
 		HandleFunc: handleFunc,
+		version:    g.version,
+		rateLimits: newRateLimiters(method, route, g.rateLimits),
+		method:     method,
+		route:      route,
 	}
 	initParser(handler)
 	mustCheckParams(doc.parser, method)
@@ -130,7 +158,7 @@ func (g *Condition) handle(depth int, method, route string, doc *Doc, handleFunc
 	if err != nil {
 		panic(err)
 	}
-	g.ApiDoc.add(depth+1, g.tagName, method, route, doc, middles)
+	g.ApiDoc.add(depth+1, g.tagName, g.version, method, route, doc, middles, g.rateLimits)
 }
 
 func Handle(method, route string, doc *Doc, handleFunc HandleFunc) {
@@ -147,14 +175,14 @@ func mustCheckParams(pr *parser, method string) {
 			ParamType:   typ,
 			Method:      method,
 		}
+		sources := schemaSources(typ, schema)
 		switch ct {
 		case param.FormDataEncodeType:
-			if typ != Form {
+			if !sources[Form] {
 				panic(err)
 			}
 		}
-		switch typ {
-		case Form:
+		if sources[Form] {
 			switch method {
 			case http.MethodPost, http.MethodPut, http.MethodPatch:
 			default:
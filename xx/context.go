@@ -35,6 +35,8 @@ type Context struct {
 	multipartForm *multipart.Form
 	handler       *Handler
 	mux           *ServeMux
+	session       *Session
+	principal     *Principal
 	err           error
 	idx           int
 }
@@ -50,6 +52,9 @@ func initContext(ctx *Context, res http.ResponseWriter, req *http.Request, rvs r
 	ctx.multipartForm = nil
 	ctx.handler = h
 	ctx.mux = mux
+	ctx.session = nil
+	ctx.principal = nil
+	ctx.err = nil
 	ctx.idx = 0
 	return ctx
 }
@@ -57,7 +62,9 @@ func initContext(ctx *Context, res http.ResponseWriter, req *http.Request, rvs r
 func (c *Context) handle() {
 	if ln := len(c.handler.middles); c.idx == ln {
 		c.Writer.Header().Del("Middleware")
-		c.handler.HandleFunc(c)
+		if c.handler.allowRequest(c) {
+			c.handler.HandleFunc(c)
+		}
 	} else if c.idx < ln {
 		middle := c.handler.middles[c.idx]
 		c.Writer.Header().Set("Middleware", strconv.Itoa(int(uintptr(unsafe.Pointer(middle)))))
@@ -171,6 +178,12 @@ func (c *Context) parseMultipartForm() (*multipart.Form, error) {
 	return c.multipartForm, nil
 }
 
+// MultipartReader 获得流式读取 multipart/form-data 请求体的 Reader, 上传文件不会缓存到内存或临时文件中.
+// 请求体只能读取一次, 不能与 Form 及 MultipartForm 方法同时使用
+func (c *Context) MultipartReader() (*multipart.Reader, error) {
+	return c.Request.MultipartReader()
+}
+
 func (c *Context) NotFound() {
 	c.mux.NotFoundHandler(c.Writer, c.Request)
 	c.Abort()
@@ -0,0 +1,228 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package xx
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"github.com/orivil/morgine/utils/crypto"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrCookieInvalid      = errors.New("cookie value is invalid")
+	ErrCookieExpired      = errors.New("cookie value is expired")
+	ErrSecureCookieNotSet = errors.New("ServeMux.SecureCookie is not set")
+)
+
+// CookieKey 为 secure cookie 密钥
+type CookieKey struct {
+	// HMAC-SHA256 签名密钥, 建议 32 或 64 字节
+	HashKey []byte
+
+	// AES 加密密钥, 长度为 16, 24 或 32 字节, 为空时只签名不加密
+	BlockKey []byte
+
+	crypto crypto.Interface
+}
+
+// SecureCookie 对 cookie 值进行签名(HMAC-SHA256)及加密(AES-CFB), 防止客户端篡改或读取.
+// Keys[0] 用于编码, 所有密钥都可用于解码, 更换密钥时将新密钥放在最前面, 旧密钥在 cookie 过期后再移除
+type SecureCookie struct {
+	Keys []*CookieKey
+
+	// 签名有效期, 用于会话 cookie(未设置 MaxAge), 为 0 时不限制
+	MaxAge time.Duration
+
+	// cookie 默认属性
+	Path     string
+	Domain   string
+	Secure   bool
+	HttpOnly bool
+	SameSite http.SameSite
+}
+
+// NewSecureCookie 新建 secure cookie 编码器, 默认 Path 为 "/", 并启用 Secure, HttpOnly 及 SameSite=Lax,
+// 本地以 http 调试时需要将 Secure 设为 false
+func NewSecureCookie(keys ...*CookieKey) (*SecureCookie, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("secure cookie needs at least one key")
+	}
+	for _, key := range keys {
+		if len(key.HashKey) == 0 {
+			return nil, errors.New("secure cookie hash key is empty")
+		}
+		if len(key.BlockKey) > 0 {
+			c, err := crypto.NewCFBCrypto(string(key.BlockKey))
+			if err != nil {
+				return nil, err
+			}
+			key.crypto = c
+		}
+	}
+	return &SecureCookie {
+		Keys:     keys,
+		Path:     "/",
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}, nil
+}
+
+func (key *CookieKey) mac(name, expires, data string) string {
+	h := hmac.New(sha256.New, key.HashKey)
+	h.Write([]byte(name + "|" + expires + "|" + data))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// Encode 编码 cookie 值, 签名中包含 cookie 名称, 不能用于其他 cookie. expires 为零值时永不过期
+func (s *SecureCookie) Encode(name, value string, expires time.Time) (string, error) {
+	key := s.Keys[0]
+	data := []byte(value)
+	if key.crypto != nil {
+		var err error
+		data, err = key.crypto.Encrypt(data)
+		if err != nil {
+			return "", err
+		}
+	} else {
+		data = []byte(base64.RawURLEncoding.EncodeToString(data))
+	}
+	var exp string
+	if !expires.IsZero() {
+		exp = strconv.FormatInt(expires.Unix(), 10)
+	}
+	return exp + "|" + string(data) + "|" + key.mac(name, exp, string(data)), nil
+}
+
+// Decode 验证签名及有效期并解码 cookie 值
+func (s *SecureCookie) Decode(name, encoded string) (string, error) {
+	parts := strings.Split(encoded, "|")
+	if len(parts) != 3 {
+		return "", ErrCookieInvalid
+	}
+	exp, data, mac := parts[0], parts[1], parts[2]
+	for _, key := range s.Keys {
+		if !hmac.Equal([]byte(mac), []byte(key.mac(name, exp, data))) {
+			continue
+		}
+		if exp != "" {
+			unix, err := strconv.ParseInt(exp, 10, 64)
+			if err != nil {
+				return "", ErrCookieInvalid
+			}
+			if time.Now().Unix() > unix {
+				return "", ErrCookieExpired
+			}
+		}
+		var value []byte
+		var err error
+		if key.crypto != nil {
+			value, err = key.crypto.Decrypt([]byte(data))
+		} else {
+			value, err = base64.RawURLEncoding.DecodeString(data)
+		}
+		if err != nil {
+			return "", ErrCookieInvalid
+		}
+		return string(value), nil
+	}
+	return "", ErrCookieInvalid
+}
+
+// Cookies 获得 cookie 参数
+func (c *Context) Cookies() url.Values {
+	values := make(url.Values)
+	for _, cookie := range c.Request.Cookies() {
+		values.Add(cookie.Name, cookie.Value)
+	}
+	return values
+}
+
+// Cookie 获得 cookie 值
+func (c *Context) Cookie(name string) (string, bool) {
+	cookie, err := c.Request.Cookie(name)
+	if err != nil {
+		return "", false
+	}
+	return cookie.Value, true
+}
+
+// SetCookie 设置 cookie
+func (c *Context) SetCookie(cookie *http.Cookie) {
+	http.SetCookie(c.Writer, cookie)
+}
+
+func (c *Context) secureCookie() (*SecureCookie, error) {
+	if c.mux == nil || c.mux.SecureCookie == nil {
+		return nil, ErrSecureCookieNotSet
+	}
+	return c.mux.SecureCookie, nil
+}
+
+// SetSecureCookie 设置签名及加密的 cookie, 使用 ServeMux.SecureCookie 的默认属性.
+// maxAge 大于 0 时为持久 cookie, 签名同时过期; 为 0 时为会话 cookie, 签名按 SecureCookie.MaxAge 过期; 小于 0 时删除 cookie
+func (c *Context) SetSecureCookie(name, value string, maxAge time.Duration) error {
+	sc, err := c.secureCookie()
+	if err != nil {
+		return err
+	}
+	cookie := &http.Cookie {
+		Name:     name,
+		Path:     sc.Path,
+		Domain:   sc.Domain,
+		Secure:   sc.Secure,
+		HttpOnly: sc.HttpOnly,
+		SameSite: sc.SameSite,
+	}
+	if maxAge < 0 {
+		cookie.MaxAge = -1
+		c.SetCookie(cookie)
+		return nil
+	}
+	var expires time.Time
+	if maxAge > 0 {
+		expires = time.Now().Add(maxAge)
+		cookie.MaxAge = int(maxAge.Seconds())
+		cookie.Expires = expires
+	} else if sc.MaxAge > 0 {
+		expires = time.Now().Add(sc.MaxAge)
+	}
+	cookie.Value, err = sc.Encode(name, value, expires)
+	if err != nil {
+		return err
+	}
+	c.SetCookie(cookie)
+	return nil
+}
+
+// SecureCookie 获得通过 SetSecureCookie 设置的 cookie 值, cookie 不存在时返回 http.ErrNoCookie,
+// 签名错误或已过期时返回 ErrCookieInvalid 或 ErrCookieExpired
+func (c *Context) SecureCookie(name string) (string, error) {
+	sc, err := c.secureCookie()
+	if err != nil {
+		return "", err
+	}
+	cookie, err := c.Request.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+	return sc.Decode(name, cookie.Value)
+}
+
+// DelCookie 删除 cookie, path 及 domain 需要与设置时一致
+func (c *Context) DelCookie(name string) {
+	cookie := &http.Cookie{Name: name, Path: "/", MaxAge: -1}
+	if sc, err := c.secureCookie(); err == nil {
+		cookie.Path, cookie.Domain = sc.Path, sc.Domain
+	}
+	c.SetCookie(cookie)
+}
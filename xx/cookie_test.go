@@ -0,0 +1,150 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package xx_test
+
+import (
+	"github.com/orivil/morgine/xx"
+	"github.com/orivil/morgine/xx/xxtest"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newCookieMux(t *testing.T, keys ...*xx.CookieKey) *xx.ServeMux {
+	mux, controller := xxtest.NewMux("cookies")
+	sc, err := xx.NewSecureCookie(keys...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux.SecureCookie = sc
+	controller.Handle("POST", "/set", nil, func(ctx *xx.Context) {
+		err := ctx.SetSecureCookie("uid", "10086", time.Hour)
+		if err != nil {
+			ctx.WriteString(err.Error())
+		}
+	})
+	controller.Handle("GET", "/get", nil, func(ctx *xx.Context) {
+		value, err := ctx.SecureCookie("uid")
+		if err != nil {
+			ctx.WriteString(err.Error())
+		} else {
+			ctx.WriteString(value)
+		}
+	})
+	return mux
+}
+
+func setCookie(t *testing.T, mux *xx.ServeMux) *http.Cookie {
+	res := xxtest.Serve(mux, httptest.NewRequest("POST", "/set", nil))
+	cookies := res.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("need 1 cookie, got: %d %s", len(cookies), res.Body.String())
+	}
+	return cookies[0]
+}
+
+func getCookie(mux *xx.ServeMux, cookie *http.Cookie) string {
+	req := httptest.NewRequest("GET", "/get", nil)
+	req.AddCookie(cookie)
+	res := xxtest.Serve(mux, req)
+	return res.Body.String()
+}
+
+func TestContext_SecureCookie(t *testing.T) {
+	hashKey := []byte("0123456789abcdef0123456789abcdef")
+	for name, key := range map[string]*xx.CookieKey {
+		"signed":    {HashKey: hashKey},
+		"encrypted": {HashKey: hashKey, BlockKey: []byte("abcdef0123456789")},
+	} {
+		mux := newCookieMux(t, key)
+		cookie := setCookie(t, mux)
+		if !cookie.HttpOnly || !cookie.Secure || cookie.SameSite != http.SameSiteLaxMode || cookie.Path != "/" || cookie.MaxAge != 3600 {
+			t.Errorf("%s: got cookie attributes: %+v", name, cookie)
+		}
+		if strings.Contains(cookie.Value, "10086") {
+			t.Errorf("%s: value is not encoded: %s", name, cookie.Value)
+		}
+		if got := getCookie(mux, cookie); got != "10086" {
+			t.Errorf("%s: need: 10086, got: %s", name, got)
+		}
+		tampered := *cookie
+		tampered.Value = "1" + cookie.Value
+		if got := getCookie(mux, &tampered); got != xx.ErrCookieInvalid.Error() {
+			t.Errorf("%s: need: %s, got: %s", name, xx.ErrCookieInvalid, got)
+		}
+	}
+}
+
+func TestSecureCookie_KeyRotation(t *testing.T) {
+	oldKey := &xx.CookieKey{HashKey: []byte("old hash key"), BlockKey: []byte("old block key 16")}
+	newKey := &xx.CookieKey{HashKey: []byte("new hash key"), BlockKey: []byte("new block key 16")}
+	cookie := setCookie(t, newCookieMux(t, oldKey))
+
+	mux := newCookieMux(t, newKey, oldKey)
+	if got := getCookie(mux, cookie); got != "10086" {
+		t.Errorf("old key: need: 10086, got: %s", got)
+	}
+	if got := getCookie(newCookieMux(t, newKey), cookie); got != xx.ErrCookieInvalid.Error() {
+		t.Errorf("removed key: need: %s, got: %s", xx.ErrCookieInvalid, got)
+	}
+}
+
+func TestSecureCookie_Expires(t *testing.T) {
+	sc, err := xx.NewSecureCookie(&xx.CookieKey{HashKey: []byte("hash key")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := sc.Encode("uid", "10086", time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = sc.Decode("uid", encoded); err != xx.ErrCookieExpired {
+		t.Errorf("need: %v, got: %v", xx.ErrCookieExpired, err)
+	}
+	encoded, err = sc.Encode("uid", "10086", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = sc.Decode("name", encoded); err != xx.ErrCookieInvalid {
+		t.Errorf("other cookie name: need: %v, got: %v", xx.ErrCookieInvalid, err)
+	}
+	if value, err := sc.Decode("uid", encoded); err != nil || value != "10086" {
+		t.Errorf("need: 10086, got: %s %v", value, err)
+	}
+}
+
+func TestCookieParam(t *testing.T) {
+	mux, controller := xxtest.NewMux("settings")
+	type params struct {
+		Theme string `param:"theme" required:"theme required"`
+	}
+	doc := &xx.Doc{Params: xx.Params{{Type: xx.Cookie, Schema: &params{}}}}
+	controller.Handle("GET", "/settings", doc, func(ctx *xx.Context) {
+		p := &params{}
+		err := ctx.Unmarshal(p)
+		if err != nil {
+			ctx.WriteString(err.Error())
+		} else {
+			ctx.WriteString(p.Theme)
+		}
+	})
+	for cookie, need := range map[string]string{"dark": "dark", "": "theme: theme required"} {
+		req := httptest.NewRequest("GET", "/settings", nil)
+		if cookie != "" {
+			req.AddCookie(&http.Cookie{Name: "theme", Value: cookie})
+		}
+		res := xxtest.Serve(mux, req)
+		if res.Body.String() != need {
+			t.Errorf("need: %s, got: %s", need, res.Body.String())
+		}
+	}
+	for _, acts := range mux.ApiDoc().Actions {
+		if p := acts[0].Params; len(p) != 1 || p[0].Type != xx.Cookie {
+			t.Errorf("need cookie param, got: %+v", p)
+		}
+	}
+}
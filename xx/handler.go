@@ -10,6 +10,7 @@ import (
 	"mime/multipart"
 	"net/http"
 	"reflect"
+	"time"
 )
 
 type ParamType string
@@ -19,6 +20,7 @@ const (
 	Path   ParamType = "path"
 	Form   ParamType = "form"
 	Header ParamType = "header"
+	Cookie ParamType = "cookie"
 )
 
 type Param struct {
@@ -42,13 +44,28 @@ type Doc struct {
 	Desc      string
 	Params    Params
 	Responses Responses
-	parser    *parser
+
+	// 标记接口已弃用, 响应中会加入 "Deprecation: true" 头信息
+	Deprecated bool
+
+	// 接口停止服务时间, 仅在 Deprecated 为 true 时生效, 响应中会加入 Sunset 头信息
+	Sunset *time.Time
+
+	// 认证方式, 在认证中间件中声明, 使用该中间件的接口文档会自动标注
+	Security []*SecurityScheme
+
+	parser *parser
 }
 
 type parser struct {
 	schemas   map[reflect.Type]*param.Schema
 	types     map[reflect.Type]ParamType
 	marshaler map[reflect.Type]marshaler
+	// 包含 param.StreamHandler 字段的表单参数, 通过 multipart.Reader 流式解析.
+	// param.FileHandler 需要先由 ParseMultipartForm 缓存整个请求体, 可同时获得所有字段及文件, 可多次读取文件;
+	// param.StreamHandler 边读取请求体边处理, 只能按提交顺序读取一次, 文件之后的字段在处理时还未解析.
+	// 两者的调用时机及可用信息不同, 因此分为两种类型, 由参数结构体的字段类型选择解析方式
+	streams map[reflect.Type]bool
 }
 
 type marshaler func(ctx *Context) *multipart.Form
@@ -58,6 +75,7 @@ func newParser(ps Params) (par *parser, err error) {
 		schemas:   make(map[reflect.Type]*param.Schema, len(ps)),
 		types:     make(map[reflect.Type]ParamType, len(ps)),
 		marshaler: make(map[reflect.Type]marshaler, len(ps)),
+		streams:   make(map[reflect.Type]bool),
 	}
 	for _, p := range ps {
 		schema, ok := p.Schema.(*param.Schema)
@@ -70,38 +88,53 @@ func newParser(ps Params) (par *parser, err error) {
 		par.schemas[schema.Type] = schema
 		par.types[schema.Type] = p.Type
 		var m marshaler
-		switch p.Type {
-		case Query:
-			m = func(ctx *Context) *multipart.Form {
-				return &multipart.Form{Value: ctx.Query()}
-			}
-		case Path:
-			m = func(ctx *Context) *multipart.Form {
-				return &multipart.Form{Value: ctx.Path()}
-			}
-		case Form:
-			switch schema.EncodeType() {
-			case param.UrlEncodeType:
-				m = func(ctx *Context) *multipart.Form {
-					return &multipart.Form{Value: ctx.Form()}
-				}
-			case param.FormDataEncodeType:
-				m = func(ctx *Context) *multipart.Form {
-					return ctx.MultipartForm()
-				}
-			}
-		case Header:
-			m = func(ctx *Context) *multipart.Form {
-				return &multipart.Form{Value: ctx.Request.Header}
-			}
-		default:
-			return nil, fmt.Errorf("parameter type '%s' is not allowed", p.Type)
+		if isMixed(p.Type, schema) {
+			m, err = newMixedMarshaler(p.Type, schema)
+		} else if p.Type == Form && schema.IsStream() {
+			par.streams[schema.Type] = true
+		} else {
+			m, err = newMarshaler(p.Type, schema.EncodeType())
+		}
+		if err != nil {
+			return nil, err
 		}
 		par.marshaler[schema.Type] = m
 	}
 	return par, nil
 }
 
+func newMarshaler(typ ParamType, encode param.EncodeType) (marshaler, error) {
+	switch typ {
+	case Query:
+		return func(ctx *Context) *multipart.Form {
+			return &multipart.Form{Value: ctx.Query()}
+		}, nil
+	case Path:
+		return func(ctx *Context) *multipart.Form {
+			return &multipart.Form{Value: ctx.Path()}
+		}, nil
+	case Form:
+		if encode == param.FormDataEncodeType {
+			return func(ctx *Context) *multipart.Form {
+				return ctx.MultipartForm()
+			}, nil
+		}
+		return func(ctx *Context) *multipart.Form {
+			return &multipart.Form{Value: ctx.Form()}
+		}, nil
+	case Header:
+		return func(ctx *Context) *multipart.Form {
+			return &multipart.Form{Value: ctx.Request.Header}
+		}, nil
+	case Cookie:
+		return func(ctx *Context) *multipart.Form {
+			return &multipart.Form{Value: ctx.Cookies()}
+		}, nil
+	default:
+		return nil, fmt.Errorf("parameter type '%s' is not allowed", typ)
+	}
+}
+
 func (p *parser) unmarshal(vs []interface{}, ctx *Context) (err error) {
 	for _, value := range vs {
 		rv := reflect.ValueOf(value)
@@ -110,6 +143,18 @@ func (p *parser) unmarshal(vs []interface{}, ctx *Context) (err error) {
 		if schema == nil {
 			return fmt.Errorf("parameter '%s' is not registered", rt)
 		}
+		if p.streams[rt] {
+			var reader *multipart.Reader
+			reader, err = ctx.MultipartReader()
+			if err != nil {
+				return err
+			}
+			err = schema.ParseStream(rv.Pointer(), reader)
+			if err != nil {
+				return err
+			}
+			continue
+		}
 		fv := p.marshaler[rt](ctx)
 		err = schema.Parse(rv.Pointer(), fv)
 		if err != nil {
@@ -123,6 +168,12 @@ type Handler struct {
 	Doc        *Doc
 	HandleFunc HandleFunc
 	middles    []*Handler
+	version    string
+	rateLimits []*rateLimiter
+
+	// 注册的请求方法及路由
+	method string
+	route  string
 }
 
 type HandleFunc func(ctx *Context)
@@ -0,0 +1,86 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package xx
+
+import (
+	"github.com/orivil/morgine/param"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 客户端通过查询参数选择语言, 优先于 Accept-Language 头信息
+const LocaleQueryKey = "lang"
+
+// Locales 获得客户端接受的语言, 按优先级排序, 如: "zh-CN,zh;q=0.9,en;q=0.8" => [zh-CN zh en]
+func (c *Context) Locales() []string {
+	var locales []string
+	if lang := c.Query().Get(LocaleQueryKey); lang != "" {
+		locales = append(locales, lang)
+	}
+	return append(locales, ParseAcceptLanguage(c.Request.Header.Get("Accept-Language"))...)
+}
+
+// ParseAcceptLanguage 解析 Accept-Language 头信息, 按权重排序, 忽略 "*" 及权重为 0 的语言
+func ParseAcceptLanguage(header string) []string {
+	type lang struct {
+		name string
+		q    float64
+	}
+	var langs []lang
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, q := part, 1.0
+		if idx := strings.Index(part, ";"); idx >= 0 {
+			name = strings.TrimSpace(part[:idx])
+			param := strings.TrimSpace(part[idx+1:])
+			if strings.HasPrefix(param, "q=") {
+				if f, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = f
+				}
+			}
+		}
+		if name == "*" || q <= 0 {
+			continue
+		}
+		langs = append(langs, lang{name: name, q: q})
+	}
+	sort.SliceStable(langs, func(i, j int) bool {
+		return langs[i].q > langs[j].q
+	})
+	locales := make([]string, len(langs))
+	for i, l := range langs {
+		locales[i] = l.name
+	}
+	return locales
+}
+
+func (c *Context) messages() *param.Catalog {
+	if c.mux != nil && c.mux.Messages != nil {
+		return c.mux.Messages
+	}
+	return param.Messages
+}
+
+// Translate 按客户端语言翻译信息, 信息不存在则将 id 作为信息模板
+func (c *Context) Translate(id string, args map[string]string) string {
+	return c.messages().Message(c.Locales(), id, args)
+}
+
+// Localize 按客户端语言翻译错误信息, 只翻译 param.ValidatorErr 错误, 不包含字段名前缀, 如:
+//
+//	err := ctx.Unmarshal(p)
+//	if err != nil {
+//		ctx.SendJsonMessage(xx.MsgWarning, ctx.Localize(err))
+//	}
+func (c *Context) Localize(err error) string {
+	if ve, ok := err.(*param.ValidatorErr); ok {
+		return c.messages().Translate(c.Locales(), ve)
+	}
+	return err.Error()
+}
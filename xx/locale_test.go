@@ -0,0 +1,53 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package xx_test
+
+import (
+	"github.com/orivil/morgine/param"
+	"github.com/orivil/morgine/xx"
+	"github.com/orivil/morgine/xx/xxtest"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestParseAcceptLanguage(t *testing.T) {
+	got := xx.ParseAcceptLanguage("en;q=0.8, zh-CN,zh;q=0.9, *;q=0.5, fr;q=0")
+	need := []string{"zh-CN", "zh", "en"}
+	if !reflect.DeepEqual(got, need) {
+		t.Errorf("need: %v got: %v", need, got)
+	}
+}
+
+func TestContext_Localize(t *testing.T) {
+	mux, controller := xxtest.NewMux("users")
+	mux.Messages = param.NewCatalog("zh")
+	mux.Messages.Add("zh", map[string]string{"name.required": "用户名不能为空"})
+	mux.Messages.Add("en", map[string]string{"name.required": "{field} is required"})
+	type user struct {
+		Name string `param:"name" required:"name.required"`
+	}
+	doc := &xx.Doc{Params: xx.Params{{Type: xx.Query, Schema: &user{}}}}
+	controller.Handle("GET", "/users", doc, func(ctx *xx.Context) {
+		err := ctx.Unmarshal(&user{})
+		ctx.WriteString(ctx.Localize(err))
+	})
+	cases := map[string]string {
+		"/users":         "用户名不能为空",
+		"/users?lang=en": "name is required",
+	}
+	for path, need := range cases {
+		w := xxtest.Serve(mux, httptest.NewRequest("GET", path, nil))
+		if got := w.Body.String(); got != need {
+			t.Errorf("%s need: %s got: %s", path, need, got)
+		}
+	}
+	req := httptest.NewRequest("GET", "/users", nil)
+	req.Header.Set("Accept-Language", "en-US,en;q=0.9")
+	w := xxtest.Serve(mux, req)
+	if got := w.Body.String(); got != "name is required" {
+		t.Errorf("need english message, got: %s", got)
+	}
+}
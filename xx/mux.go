@@ -7,6 +7,7 @@ package xx
 import (
 	"fmt"
 	"github.com/orivil/morgine/log"
+	"github.com/orivil/morgine/param"
 	"github.com/orivil/morgine/router"
 	"github.com/orivil/morgine/utils/ip"
 	"net/http"
@@ -22,7 +23,14 @@ type ServeMux struct {
 	ErrHandler      func(w http.ResponseWriter, error string, code int)
 	RequestLogger   RequestLogger
 	NotFoundHandler http.HandlerFunc
+	// 客户端未选择版本且请求路径未匹配时使用的默认版本, 为空则不使用默认版本
+	DefaultVersion  string
+	// 用于翻译验证信息的信息目录, 默认为 param.Messages
+	Messages        *param.Catalog
+	// 用于 Context.SetSecureCookie 及 Context.SecureCookie 的签名及加密密钥, 为 nil 时不可使用
+	SecureCookie    *SecureCookie
 	apiDoc          *ApiDoc
+	rulesOnce       sync.Once
 }
 
 func NewServeMux(r *router.Router) *ServeMux {
@@ -35,6 +43,7 @@ func NewServeMux(r *router.Router) *ServeMux {
 		NotFoundHandler: func(writer http.ResponseWriter, request *http.Request) {
 			http.NotFound(writer, request)
 		},
+		Messages: param.Messages,
 		apiDoc: newApiDoc(),
 	}
 }
@@ -45,7 +54,11 @@ var contextPool = sync.Pool {
 	},
 }
 
+// 首次获取文档时生成已注册的验证规则列表, 所以规则需在服务启动前注册
 func (mux *ServeMux) ApiDoc() *ApiDoc {
+	mux.rulesOnce.Do(func() {
+		mux.apiDoc.Rules = param.Rules()
+	})
 	return mux.apiDoc
 }
 
@@ -68,7 +81,7 @@ func (mux *ServeMux) ServeHTTP(writer http.ResponseWriter, req *http.Request) {
 			mux.RequestLogger(req, cost, res.statusCode)
 		}()
 	}
-	vs, act := mux.r.Match(req.Method, req.URL.Path)
+	vs, act := mux.match(req)
 	if act != nil {
 		ctx := contextPool.Get().(*Context)
 		defer func() {
@@ -85,13 +98,32 @@ func (mux *ServeMux) ServeHTTP(writer http.ResponseWriter, req *http.Request) {
 			}
 			contextPool.Put(ctx)
 		}()
-		ctx = initContext(ctx, writer, req, vs, act.(*Handler), mux)
+		handler := act.(*Handler)
+		handler.writeVersionHeaders(writer.Header())
+		ctx = initContext(ctx, writer, req, vs, handler, mux)
 		ctx.handle()
 	} else {
 		mux.NotFoundHandler(writer, req)
 	}
 }
 
+// 匹配路由, 优先匹配客户端通过请求头选择的版本, 其次匹配请求路径, 最后匹配默认版本
+func (mux *ServeMux) match(req *http.Request) (router.Values, interface{}) {
+	path := req.URL.Path
+	version := mux.apiDoc.requestVersion(req)
+	if version != "" {
+		vs, act := mux.r.Match(req.Method, versionPrefix(version)+path)
+		if act != nil {
+			return vs, act
+		}
+	}
+	vs, act := mux.r.Match(req.Method, path)
+	if act == nil && version == "" && mux.DefaultVersion != "" {
+		return mux.r.Match(req.Method, versionPrefix(mux.DefaultVersion)+path)
+	}
+	return vs, act
+}
+
 func GetRequestInfo(r *http.Request) string {
 	return fmt.Sprintf("| %16s | %8s | %s", ip.GetHttpRequestIP(r), r.Method, r.Host+r.URL.Path)
 }
@@ -0,0 +1,219 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package xx
+
+import (
+	"fmt"
+	"github.com/orivil/morgine/log"
+	"github.com/orivil/morgine/utils/limiter"
+	"golang.org/x/time/rate"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 限流响应头
+const (
+	headerKeyRateLimitLimit     = "RateLimit-Limit"
+	headerKeyRateLimitRemaining = "RateLimit-Remaining"
+	headerKeyRateLimitReset     = "RateLimit-Reset"
+	headerKeyRetryAfter         = "Retry-After"
+)
+
+// 获得限流对象的唯一标识, 返回空字符串则不限流
+type RateLimitKey func(ctx *Context) string
+
+// 按客户端 IP 限流
+func RateLimitByIP(ctx *Context) string {
+	return ctx.ClientIP()
+}
+
+// 按上下文中的值限流, 如授权中间件中设置的管理员 ID, 值不存在时按客户端 IP 限流
+func RateLimitByValue(key string) RateLimitKey {
+	return func(ctx *Context) string {
+		if value := ctx.Get(key); value != nil {
+			return key + ":" + fmt.Sprint(value)
+		}
+		return RateLimitByIP(ctx)
+	}
+}
+
+// 默认的限流器, 每个规则使用一个进程内的 VisitorContainer, 并定期删除空闲的访问者,
+// 调用 RateLimit.Close 停止清理
+var RateLimitBackend = func(rule *RateLimit) limiter.RateLimiter {
+	limit := rate.Limit(float64(rule.Rate) / rule.Period.Seconds())
+	burst := rule.burst()
+	vc := limiter.NewVisitorContainer(func() *rate.Limiter {
+		return rate.NewLimiter(limit, burst)
+	})
+	idle := time.Duration(float64(burst) / float64(limit) * float64(time.Second))
+	if idle < rule.Period {
+		idle = rule.Period
+	}
+	vc.RunEviction(idle, idle)
+	return vc
+}
+
+// RateLimit 为限流规则, 通过 Condition.RateLimit 声明. 限流在所有中间件之后, action 之前执行,
+// 所以 Key 可以使用中间件中设置的值(如管理员 ID)
+type RateLimit struct {
+	Name   string        // 规则名称
+	Rate   int           // 每个周期允许的请求次数
+	Period time.Duration // 统计周期
+	Burst  int           // 允许的突发请求数, 默认等于 Rate
+	Key    RateLimitKey  // 限流对象, 默认按客户端 IP 限流
+
+	// 为 true 时所有使用该规则的路由共享计数, 可用于分组限流, 否则每个路由单独计数
+	Shared bool
+
+	// 限流器, 为 nil 时使用 RateLimitBackend 创建
+	Backend limiter.RateLimiter
+
+	once    sync.Once
+	created bool
+}
+
+func (rl *RateLimit) burst() int {
+	if rl.Burst > 0 {
+		return rl.Burst
+	}
+	return rl.Rate
+}
+
+func (rl *RateLimit) init() {
+	rl.once.Do(func() {
+		if rl.Rate <= 0 || rl.Period <= 0 {
+			panic(fmt.Sprintf("rate limit %q: rate and period must be positive", rl.Name))
+		}
+		if rl.Key == nil {
+			rl.Key = RateLimitByIP
+		}
+		if rl.Backend == nil {
+			rl.Backend = RateLimitBackend(rl)
+			rl.created = true
+		}
+	})
+}
+
+// Close 关闭由 RateLimitBackend 创建的限流器, 如停止 VisitorContainer 的清理协程.
+// 自行设置的 Backend 由设置者关闭
+func (rl *RateLimit) Close() {
+	if !rl.created {
+		return
+	}
+	if c, ok := rl.Backend.(interface{ Close() }); ok {
+		c.Close()
+	}
+}
+
+// 路由绑定的限流规则
+type rateLimiter struct {
+	rule  *RateLimit
+	scope string
+}
+
+// 检测所有限流规则, 被拒绝时返回 429 状态码并结束处理链. 所有规则都允许时才消耗配额,
+// 避免被后面的规则拒绝的请求消耗前面规则的配额. 限流器出错时记录错误并放行请求
+func (h *Handler) allowRequest(ctx *Context) bool {
+	keys := make([]string, len(h.rateLimits))
+	for i, l := range h.rateLimits {
+		key := l.rule.Key(ctx)
+		if key == "" {
+			continue
+		}
+		keys[i] = l.scope + key
+		res, err := l.rule.Backend.Peek(keys[i])
+		if err != nil {
+			log.Error.Printf("rate limit %q: %s\n", l.rule.Name, err)
+			continue
+		}
+		if !res.Allowed {
+			return denyRequest(ctx, res)
+		}
+	}
+	for i, l := range h.rateLimits {
+		if keys[i] == "" {
+			continue
+		}
+		res, err := l.rule.Backend.Take(keys[i])
+		if err != nil {
+			log.Error.Printf("rate limit %q: %s\n", l.rule.Name, err)
+			continue
+		}
+		// 检测之后配额被并发请求用完
+		if !res.Allowed {
+			return denyRequest(ctx, res)
+		}
+		setRateLimitHeader(ctx, res)
+	}
+	return true
+}
+
+func setRateLimitHeader(ctx *Context, res *limiter.Result) {
+	header := ctx.Writer.Header()
+	header.Set(headerKeyRateLimitLimit, strconv.Itoa(res.Limit))
+	header.Set(headerKeyRateLimitRemaining, strconv.Itoa(res.Remaining))
+	header.Set(headerKeyRateLimitReset, ceilSeconds(res.Reset))
+}
+
+func denyRequest(ctx *Context, res *limiter.Result) bool {
+	setRateLimitHeader(ctx, res)
+	ctx.Writer.Header().Set(headerKeyRetryAfter, ceilSeconds(res.RetryAfter))
+	ctx.mux.ErrHandler(ctx.Writer, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+	ctx.Abort()
+	return false
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// RateLimit 为通过该 Condition 注册的路由添加限流规则, 多个规则需同时满足.
+// 规则的 Shared 为 false 时每个路由单独计数, 为 true 时所有使用该规则的路由共享计数
+func (g *Condition) RateLimit(rules ...*RateLimit) *Condition {
+	nc := g.copy()
+	for _, rule := range rules {
+		rule.init()
+		nc.rateLimits = append(nc.rateLimits, rule)
+	}
+	return nc
+}
+
+func newRateLimiters(method, route string, rules []*RateLimit) []*rateLimiter {
+	var ls []*rateLimiter
+	for _, rule := range rules {
+		l := &rateLimiter{rule: rule}
+		if !rule.Shared {
+			l.scope = method + " " + route + "|"
+		}
+		ls = append(ls, l)
+	}
+	return ls
+}
+
+// ApiRateLimit 为接口文档中的限流规则
+type ApiRateLimit struct {
+	Name   string // 规则名称
+	Rate   int    // 每个周期允许的请求次数
+	Period string // 统计周期
+	Burst  int    // 允许的突发请求数
+	Shared bool   // 是否与其他路由共享计数
+}
+
+func initApiRateLimits(rules []*RateLimit) []*ApiRateLimit {
+	var ls []*ApiRateLimit
+	for _, rule := range rules {
+		ls = append(ls, &ApiRateLimit{
+			Name:   rule.Name,
+			Rate:   rule.Rate,
+			Period: rule.Period.String(),
+			Burst:  rule.burst(),
+			Shared: rule.Shared,
+		})
+	}
+	return ls
+}
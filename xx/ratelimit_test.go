@@ -0,0 +1,96 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package xx_test
+
+import (
+	"github.com/orivil/morgine/xx"
+	"github.com/orivil/morgine/xx/xxtest"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCondition_RateLimit(t *testing.T) {
+	mux, group := xxtest.NewMux("limit")
+	perRoute := &xx.RateLimit{Name: "per-route", Rate: 2, Period: time.Minute}
+	shared := &xx.RateLimit{Name: "shared", Rate: 3, Period: time.Minute, Shared: true}
+	limited := group.RateLimit(perRoute, shared)
+	ok := func(ctx *xx.Context) {
+		ctx.WriteString("ok")
+	}
+	limited.Handle("GET", "/a", nil, ok)
+	limited.Handle("GET", "/b", nil, ok)
+	group.Handle("GET", "/c", nil, ok)
+
+	do := func(path, ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = ip + ":1234"
+		return xxtest.Serve(mux, req)
+	}
+	cases := []struct {
+		path, ip  string
+		code      int
+		remaining string
+	}{
+		{"/a", "10.0.0.1", http.StatusOK, "2"},
+		{"/a", "10.0.0.1", http.StatusOK, "1"},
+		{"/a", "10.0.0.1", http.StatusTooManyRequests, "0"}, // 超出路由限制
+		{"/b", "10.0.0.1", http.StatusOK, "0"},
+		{"/b", "10.0.0.1", http.StatusTooManyRequests, "0"}, // 超出共享限制
+		{"/a", "10.0.0.2", http.StatusOK, "2"},
+		{"/c", "10.0.0.1", http.StatusOK, ""},
+	}
+	for i, c := range cases {
+		w := do(c.path, c.ip)
+		if w.Code != c.code {
+			t.Errorf("%d: need code %d, got %d", i, c.code, w.Code)
+		}
+		if got := w.Header().Get("RateLimit-Remaining"); got != c.remaining {
+			t.Errorf("%d: need remaining %q, got %q", i, c.remaining, got)
+		}
+		if c.code == http.StatusTooManyRequests && w.Header().Get("Retry-After") == "" {
+			t.Errorf("%d: need Retry-After header", i)
+		}
+	}
+
+	var rules []*xx.ApiRateLimit
+	for _, acts := range mux.ApiDoc().Actions {
+		for _, act := range acts {
+			if act.Route == "/a" {
+				rules = act.RateLimits
+			}
+		}
+	}
+	if len(rules) != 2 || rules[0].Name != "per-route" || rules[1].Burst != 3 {
+		t.Errorf("need 2 documented rate limits, got: %v", rules)
+	}
+}
+
+func TestCondition_RateLimitDeniedDoesNotConsume(t *testing.T) {
+	mux, group := xxtest.NewMux("limit")
+	shared := &xx.RateLimit{Name: "shared", Rate: 5, Period: time.Minute, Shared: true}
+	strict := &xx.RateLimit{Name: "strict", Rate: 1, Period: time.Minute}
+	defer shared.Close()
+	defer strict.Close()
+	ok := func(ctx *xx.Context) {
+		ctx.WriteString("ok")
+	}
+	group.RateLimit(shared, strict).Handle("GET", "/a", nil, ok)
+	group.RateLimit(shared).Handle("GET", "/b", nil, ok)
+
+	codes := []int{http.StatusOK, http.StatusTooManyRequests, http.StatusTooManyRequests}
+	for i, code := range codes {
+		w := xxtest.Serve(mux, httptest.NewRequest("GET", "/a", nil))
+		if w.Code != code {
+			t.Errorf("%d: need code %d, got %d", i, code, w.Code)
+		}
+	}
+	// 被 strict 拒绝的请求不消耗 shared 的配额
+	w := xxtest.Serve(mux, httptest.NewRequest("GET", "/b", nil))
+	if got := w.Header().Get("RateLimit-Remaining"); got != "3" {
+		t.Errorf("need remaining 3, got %q", got)
+	}
+}
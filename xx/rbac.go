@@ -0,0 +1,246 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package xx
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Permission 为接口权限, 由请求方法及注册的路由组成, 如: "GET /admins/{id}"
+type Permission string
+
+// 拥有该权限的角色可访问所有接口
+const AllPermissions Permission = "*"
+
+func NewPermission(method, route string) Permission {
+	return Permission(strings.ToUpper(method) + " " + route)
+}
+
+// Permission 获得当前接口的权限
+func (c *Context) Permission() Permission {
+	return NewPermission(c.handler.method, c.handler.route)
+}
+
+// PermissionInfo 为权限列表中的权限, 用于角色编辑器
+type PermissionInfo struct {
+	Permission Permission `json:"permission"`
+	Method     string     `json:"method"`
+	Route      string     `json:"route"`
+	Title      string     `json:"title"`
+}
+
+// Policy 为角色权限策略, 实现需要保证并发安全
+type Policy interface {
+	// Allowed 判断角色中是否有任意一个拥有该权限
+	Allowed(roles []string, perm Permission) (bool, error)
+}
+
+// Authorizer 为基于角色的授权中间件, 需要在认证中间件之后使用. 未被授予权限的请求一律拒绝:
+//
+//	policy := xx.NewMemoryPolicy()
+//	policy.SetRolePermissions("editor", xx.NewPermission("GET", "/articles"))
+//	authorizer := xx.NewAuthorizer(policy)
+//	controller := group.Use(auth.Required(jwtAuth), authorizer.Handler()).Controller(tag)
+type Authorizer struct {
+	Policy Policy
+
+	// 拥有这些角色的访问者可访问所有接口
+	SuperRoles []string
+
+	// 获得访问者的角色, 默认为 Principal.Roles
+	Roles func(p *Principal) ([]string, error)
+
+	// 拒绝访问时的处理函数, 默认响应 403 及 StatusForbidden 状态码
+	Forbidden func(ctx *Context)
+
+	handler *Handler
+	once    sync.Once
+}
+
+func NewAuthorizer(policy Policy) *Authorizer {
+	return &Authorizer {
+		Policy:    policy,
+		Forbidden: Forbidden,
+	}
+}
+
+// Forbidden 为默认的拒绝访问处理函数, 响应 403 及 StatusForbidden 状态码
+func Forbidden(ctx *Context) {
+	ctx.Writer.Header().Set("Content-Type", DataTypeJson.contentType())
+	ctx.Writer.WriteHeader(http.StatusForbidden)
+	ctx.SendStatusJsonData(StatusForbidden, nil)
+}
+
+// Handler 获得授权中间件, 多次调用返回同一个中间件, 以便列出受其保护的接口
+func (a *Authorizer) Handler() *Handler {
+	a.once.Do(func() {
+		a.handler = &Handler {
+			Doc: &Doc {
+				Title: "Authorization",
+				Desc:  "接口权限为 \"请求方法 路由\", 访问者的角色未被授予该权限时拒绝访问",
+				Responses: Responses {
+					{
+						Code:        http.StatusUnauthorized,
+						Description: "未认证",
+						Body:        StatusJsonData(StatusUnauthorized, nil),
+					},
+					{
+						Code:        http.StatusForbidden,
+						Description: "没有访问权限",
+						Body:        StatusJsonData(StatusForbidden, nil),
+					},
+				},
+			},
+			HandleFunc: a.handle,
+		}
+	})
+	return a.handler
+}
+
+func (a *Authorizer) handle(ctx *Context) {
+	p := ctx.Principal()
+	if p == nil {
+		ctx.Writer.Header().Set("Content-Type", DataTypeJson.contentType())
+		ctx.Writer.WriteHeader(http.StatusUnauthorized)
+		ctx.SendStatusJsonData(StatusUnauthorized, nil)
+		return
+	}
+	ok, err := a.Allowed(p, ctx.Permission())
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	if !ok {
+		a.Forbidden(ctx)
+	}
+}
+
+// Allowed 判断访问者是否拥有权限
+func (a *Authorizer) Allowed(p *Principal, perm Permission) (bool, error) {
+	roles := p.Roles
+	if a.Roles != nil {
+		var err error
+		roles, err = a.Roles(p)
+		if err != nil {
+			return false, err
+		}
+	}
+	if len(roles) == 0 {
+		return false, nil
+	}
+	for _, role := range roles {
+		if containsString(a.SuperRoles, role) {
+			return true, nil
+		}
+	}
+	return a.Policy.Allowed(roles, perm)
+}
+
+// Permissions 获得 mux 中受该授权中间件保护的所有接口权限, 按路由排序
+func (a *Authorizer) Permissions(mux *ServeMux) []*PermissionInfo {
+	h := a.Handler()
+	var perms []*PermissionInfo
+	for _, node := range mux.r.Nodes() {
+		handler, ok := node.Action.(*Handler)
+		if !ok || !handler.uses(h) {
+			continue
+		}
+		perms = append(perms, &PermissionInfo {
+			Permission: NewPermission(handler.method, handler.route),
+			Method:     handler.method,
+			Route:      handler.route,
+			Title:      handler.Doc.Title,
+		})
+	}
+	sort.Slice(perms, func(i, j int) bool {
+		if perms[i].Route != perms[j].Route {
+			return perms[i].Route < perms[j].Route
+		}
+		return perms[i].Method < perms[j].Method
+	})
+	return perms
+}
+
+func (h *Handler) uses(middle *Handler) bool {
+	for _, m := range h.middles {
+		if m == middle {
+			return true
+		}
+	}
+	return false
+}
+
+var ErrUnknownPermission = errors.New("unknown permission")
+
+// MemoryPolicy 为内存中的角色权限策略
+type MemoryPolicy struct {
+	roles map[string]map[Permission]struct{}
+	mu    sync.RWMutex
+}
+
+func NewMemoryPolicy() *MemoryPolicy {
+	return &MemoryPolicy{roles: make(map[string]map[Permission]struct{})}
+}
+
+// SetRolePermissions 设置角色的全部权限, 没有权限时删除角色
+func (m *MemoryPolicy) SetRolePermissions(role string, perms ...Permission) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(perms) == 0 {
+		delete(m.roles, role)
+		return
+	}
+	set := make(map[Permission]struct{}, len(perms))
+	for _, perm := range perms {
+		set[perm] = struct{}{}
+	}
+	m.roles[role] = set
+}
+
+// RolePermissions 获得角色的全部权限
+func (m *MemoryPolicy) RolePermissions(role string) []Permission {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	perms := make([]Permission, 0, len(m.roles[role]))
+	for perm := range m.roles[role] {
+		perms = append(perms, perm)
+	}
+	sort.Slice(perms, func(i, j int) bool { return perms[i] < perms[j] })
+	return perms
+}
+
+func (m *MemoryPolicy) Allowed(roles []string, perm Permission) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, role := range roles {
+		set := m.roles[role]
+		if _, ok := set[perm]; ok {
+			return true, nil
+		}
+		if _, ok := set[AllPermissions]; ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// CheckPermissions 检查权限是否都属于 known, 用于保存角色权限前校验客户端提交的数据
+func CheckPermissions(known []*PermissionInfo, perms []Permission) error {
+	set := make(map[Permission]struct{}, len(known))
+	for _, info := range known {
+		set[info.Permission] = struct{}{}
+	}
+	for _, perm := range perms {
+		if _, ok := set[perm]; !ok && perm != AllPermissions {
+			return fmt.Errorf("%w: %s", ErrUnknownPermission, perm)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package xx_test
+
+import (
+	"github.com/orivil/morgine/xx"
+	"github.com/orivil/morgine/xx/xxtest"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newRBACMux(authorizer *xx.Authorizer) *xx.ServeMux {
+	// 以请求头中的角色模拟认证中间件
+	authenticate := &xx.Handler {
+		HandleFunc: func(ctx *xx.Context) {
+			if roles := ctx.Request.Header.Get("X-Roles"); roles != "" {
+				ctx.SetPrincipal(&xx.Principal{ID: "1", Roles: strings.Split(roles, ",")})
+			}
+		},
+	}
+	mux, public := xxtest.NewMux("articles", authenticate)
+	protected := public.Use(authorizer.Handler())
+	ok := func(ctx *xx.Context) {
+		ctx.WriteString(string(ctx.Permission()))
+	}
+	public.Handle("GET", "/public", nil, ok)
+	protected.Handle("GET", "/articles", &xx.Doc{Title: "文章列表"}, ok)
+	protected.Handle("DELETE", "/articles/{id}", &xx.Doc{Title: "删除文章"}, ok)
+	protected.Handle("POST", "/articles", &xx.Doc{Title: "创建文章"}, ok)
+	return mux
+}
+
+func TestAuthorizer(t *testing.T) {
+	policy := xx.NewMemoryPolicy()
+	policy.SetRolePermissions("reader", xx.NewPermission("get", "/articles"))
+	policy.SetRolePermissions("editor", xx.NewPermission("GET", "/articles"), xx.NewPermission("DELETE", "/articles/{id}"))
+	policy.SetRolePermissions("owner", xx.AllPermissions)
+	authorizer := xx.NewAuthorizer(policy)
+	authorizer.SuperRoles = []string{"root"}
+	mux := newRBACMux(authorizer)
+	cases := []struct {
+		method, path, roles string
+		code                int
+	}{
+		{"GET", "/public", "", 200},
+		{"GET", "/articles", "", 401},
+		{"GET", "/articles", "guest", 403},
+		{"GET", "/articles", "reader", 200},
+		{"DELETE", "/articles/1", "reader", 403},
+		{"DELETE", "/articles/1", "guest,editor", 200},
+		{"POST", "/articles", "editor", 403},
+		{"POST", "/articles", "owner", 200},
+		{"POST", "/articles", "root", 200},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(c.method, c.path, nil)
+		if c.roles != "" {
+			req.Header.Set("X-Roles", c.roles)
+		}
+		res := xxtest.Serve(mux, req)
+		if res.Code != c.code {
+			t.Errorf("%s %s %s: need: %d, got: %d", c.method, c.path, c.roles, c.code, res.Code)
+		}
+		if c.code == http.StatusForbidden && strings.TrimSpace(res.Body.String()) != `{"code":2403,"data":null}` {
+			t.Errorf("got forbidden body: %s", res.Body.String())
+		}
+	}
+
+	// 接口中可获得注册时的路由
+	req := httptest.NewRequest("DELETE", "/articles/2", nil)
+	req.Header.Set("X-Roles", "editor")
+	res := xxtest.Serve(mux, req)
+	if res.Body.String() != "DELETE /articles/{id}" {
+		t.Errorf("got: %s", res.Body.String())
+	}
+}
+
+func TestAuthorizer_Permissions(t *testing.T) {
+	authorizer := xx.NewAuthorizer(xx.NewMemoryPolicy())
+	mux := newRBACMux(authorizer)
+	var got []string
+	for _, p := range authorizer.Permissions(mux) {
+		got = append(got, string(p.Permission)+" "+p.Title)
+	}
+	need := "GET /articles 文章列表,POST /articles 创建文章,DELETE /articles/{id} 删除文章"
+	if strings.Join(got, ",") != need {
+		t.Errorf("need: %s, got: %s", need, strings.Join(got, ","))
+	}
+	known := authorizer.Permissions(mux)
+	if err := xx.CheckPermissions(known, []xx.Permission{"GET /articles", xx.AllPermissions}); err != nil {
+		t.Error(err)
+	}
+	if err := xx.CheckPermissions(known, []xx.Permission{"GET /public"}); err == nil {
+		t.Error("need unknown permission error")
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package xx
+
+import (
+	"github.com/orivil/morgine/param"
+	"net/http"
+	"strings"
+)
+
+// 查找已注册的接口, route 为注册时的路由(包含版本前缀)
+func (doc *ApiDoc) FindAction(method, route string) *ApiAction {
+	method = strings.ToUpper(method)
+	for _, acts := range doc.Actions {
+		for _, act := range acts {
+			if act.Method == method && act.Route == route {
+				return act
+			}
+		}
+	}
+	return nil
+}
+
+// JSONSchema 导出接口参数的 JSON Schema, 键为参数类型, 如: query, form
+func (act *ApiAction) JSONSchema(catalog *param.Catalog, locales ...string) map[ParamType]*param.JSONSchema {
+	schemas := make(map[ParamType]*param.JSONSchema, len(act.Params))
+	for _, p := range act.Params {
+		js := param.NewJSONSchema(p.Fields, catalog, locales...)
+		// 同一来源的多个参数合并为一个对象
+		if exist, ok := schemas[p.Type]; ok {
+			exist.Properties = append(exist.Properties, js.Properties...)
+			exist.Required = append(exist.Required, js.Required...)
+			exist.AllOf = append(exist.AllOf, js.AllOf...)
+			for key, values := range js.DependentRequired {
+				if exist.DependentRequired == nil {
+					exist.DependentRequired = make(map[string][]string)
+				}
+				exist.DependentRequired[key] = append(exist.DependentRequired[key], values...)
+			}
+			exist.StructRules = append(exist.StructRules, p.Rules...)
+			continue
+		}
+		js.Schema = param.JSONSchemaDraft
+		js.Title = act.Name
+		js.StructRules = p.Rules
+		schemas[p.Type] = js
+	}
+	return schemas
+}
+
+// FormSchema 获得接口参数的 JSON Schema, 前端可据此渲染表单并在提交前验证, 提示信息按客户端语言翻译
+var FormSchema Action = func(method, route string, controller *Condition) {
+	type query struct {
+		Method string `param:"method" required:"" desc:"接口请求方法, 如: POST"`
+		Route  string `param:"route" required:"" desc:"接口路由, 如: /admin/login"`
+	}
+	doc := &Doc {
+		Title: "获得接口参数的 JSON Schema",
+		Desc:  "JSON Schema 版本为 draft 2020-12, 以 \"x-\" 开头的关键字为扩展条件",
+		Params: Params {
+			{Type: Query, Schema: &query{}},
+		},
+		Responses: Responses {
+			{
+				Body: MAP {
+					"form": MAP {
+						"$schema":    param.JSONSchemaDraft,
+						"type":       "object",
+						"properties": MAP{"username": MAP{"type": "string", "minLength": 6}},
+						"required":   []string{"username"},
+					},
+				},
+			},
+			HttpErrorResponse("接口不存在", http.StatusText(http.StatusNotFound), http.StatusNotFound),
+		},
+	}
+	controller.Handle(method, route, doc, func(ctx *Context) {
+		q := &query{}
+		err := ctx.Unmarshal(q)
+		if err != nil {
+			ctx.SendJsonMessage(MsgWarning, ctx.Localize(err))
+			return
+		}
+		act := controller.ApiDoc.FindAction(q.Method, q.Route)
+		if act == nil {
+			http.Error(ctx.Writer, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			ctx.Abort()
+			return
+		}
+		ctx.SendJSON(act.JSONSchema(ctx.messages(), ctx.Locales()...))
+	})
+}
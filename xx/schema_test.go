@@ -0,0 +1,50 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package xx_test
+
+import (
+	"encoding/json"
+	"github.com/orivil/morgine/param"
+	"github.com/orivil/morgine/xx"
+	"github.com/orivil/morgine/xx/xxtest"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFormSchema(t *testing.T) {
+	mux, controller := xxtest.NewMux("users")
+	mux.Messages = param.NewCatalog("zh")
+	mux.Messages.Add("en", map[string]string{"name.required": "{field} is required"})
+	type user struct {
+		Name string `param:"name" required:"name.required"`
+	}
+	doc := &xx.Doc{Title: "创建用户", Params: xx.Params{{Type: xx.Form, Schema: &user{}}}}
+	controller.Handle("POST", "/users", doc, func(ctx *xx.Context) {})
+	xx.FormSchema("GET", "/schemas", controller)
+
+	req := httptest.NewRequest("GET", "/schemas?method=post&route=/users", nil)
+	req.Header.Set("Accept-Language", "en")
+	res := xxtest.Serve(mux, req)
+	schemas := map[xx.ParamType]*struct {
+		Title      string
+		Required   []string
+		Properties map[string]struct {
+			Messages map[string]string `json:"x-messages"`
+		}
+	}{}
+	err := json.Unmarshal(res.Body.Bytes(), &schemas)
+	if err != nil {
+		t.Fatal(err, res.Body.String())
+	}
+	form := schemas[xx.Form]
+	if form == nil || form.Title != "创建用户" || form.Required[0] != "name" || form.Properties["name"].Messages["required"] != "name is required" {
+		t.Errorf("got schema: %s", res.Body.String())
+	}
+
+	res = xxtest.Serve(mux, httptest.NewRequest("GET", "/schemas?method=GET&route=/users", nil))
+	if res.Code != 404 {
+		t.Errorf("need 404, got: %d", res.Code)
+	}
+}
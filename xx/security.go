@@ -0,0 +1,70 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package xx
+
+import (
+	"net/http"
+)
+
+// SecurityScheme 为接口文档中的认证方式, 字段与 OpenAPI 3 的 securitySchemes 对应.
+// 在中间件的 Doc.Security 中声明后, 使用该中间件的接口会自动标注认证方式, 实现见 xx/auth
+type SecurityScheme struct {
+	Name         string                       // 认证方式名称, 如: bearerAuth
+	Type         string                       // http 或 apiKey
+	Scheme       string    `json:",omitempty"` // Type 为 http 时的认证方案, 如: bearer, basic
+	BearerFormat string    `json:",omitempty"` // 令牌格式, 如: JWT
+	In           ParamType `json:",omitempty"` // Type 为 apiKey 时密钥所在位置: header, query 或 cookie
+	ParamName    string    `json:",omitempty"` // Type 为 apiKey 时密钥参数名
+	Desc         string    `json:",omitempty"`
+}
+
+// Principal 为通过认证的访问者, 由认证中间件通过 Context.SetPrincipal 保存
+type Principal struct {
+	ID     string      // 访问者标识, 如管理员 ID
+	Name   string      // 访问者名称, 如用户名
+	Scheme string      // 认证方式名称, 对应 SecurityScheme.Name
+	Roles  []string    // 角色列表
+	Claims interface{} // 认证方式提供的附加信息, 如 JWT 声明
+}
+
+// HasRole 判断访问者是否拥有某个角色
+func (p *Principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Principal 获得通过认证的访问者, 未认证时返回 nil
+func (c *Context) Principal() *Principal {
+	return c.principal
+}
+
+// SetPrincipal 保存通过认证的访问者
+func (c *Context) SetPrincipal(p *Principal) {
+	c.principal = p
+}
+
+// HttpError 通过 ServeMux.ErrHandler 返回 HTTP 错误, 并结束处理链
+func (c *Context) HttpError(code int) {
+	c.mux.ErrHandler(c.Writer, http.StatusText(code), code)
+	c.Abort()
+}
+
+// 获得中间件及接口声明的认证方式名称, 并将认证方式加入文档
+func (doc *ApiDoc) addSecurity(schemes []*SecurityScheme) (names []string) {
+	for _, scheme := range schemes {
+		if doc.SecuritySchemes == nil {
+			doc.SecuritySchemes = make(map[string]*SecurityScheme)
+		}
+		doc.SecuritySchemes[scheme.Name] = scheme
+		if !containsString(names, scheme.Name) {
+			names = append(names, scheme.Name)
+		}
+	}
+	return names
+}
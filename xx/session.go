@@ -0,0 +1,334 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package xx
+
+import (
+	"github.com/orivil/morgine/log"
+	"github.com/orivil/morgine/utils/session"
+	"net/http"
+	"time"
+)
+
+// SessionManager 管理服务端会话, 会话 ID 保存在 cookie 中, 会话数据保存在 Store 中. 使用方式:
+//
+//	sessions := xx.NewSessionManager(session.NewMemoryStore())
+//	controller := mux.NewGroup(tags).Use(sessions.Handler()).Controller(tag)
+//
+//	// action 中
+//	err := ctx.Session().Login(strconv.Itoa(admin.ID))
+type SessionManager struct {
+	Store session.Store
+
+	// 保存会话 ID 的 cookie 名称
+	CookieName string
+
+	// 空闲超时, 超过该时间未访问则会话过期, 为 0 时不限制
+	IdleTimeout time.Duration
+
+	// 绝对超时, 从会话创建或登录时开始计算, 为 0 时不限制. IdleTimeout 及 AbsoluteTimeout 至少需要设置一个
+	AbsoluteTimeout time.Duration
+
+	// 会话未修改时, 间隔多久更新一次访问时间, 用于减少存储的写入次数
+	TouchInterval time.Duration
+
+	// cookie 属性
+	Path     string
+	Domain   string
+	Secure   bool
+	HttpOnly bool
+	SameSite http.SameSite
+}
+
+// NewSessionManager 新建会话管理器, 默认空闲超时 30 分钟, 绝对超时 24 小时, cookie 默认启用 Secure,
+// HttpOnly 及 SameSite=Lax, 本地以 http 调试时需要将 Secure 设为 false
+func NewSessionManager(store session.Store) *SessionManager {
+	return &SessionManager {
+		Store:           store,
+		CookieName:      "session",
+		IdleTimeout:     30 * time.Minute,
+		AbsoluteTimeout: 24 * time.Hour,
+		TouchInterval:   time.Minute,
+		Path:            "/",
+		Secure:          true,
+		HttpOnly:        true,
+		SameSite:        http.SameSiteLaxMode,
+	}
+}
+
+// 计算会话的过期时间, 取空闲超时与绝对超时中较早的一个
+func (m *SessionManager) expiresAt(data *session.Data) time.Time {
+	var expires time.Time
+	if m.IdleTimeout > 0 {
+		expires = data.AccessedAt.Add(m.IdleTimeout)
+	}
+	if m.AbsoluteTimeout > 0 {
+		absolute := data.CreatedAt.Add(m.AbsoluteTimeout)
+		if expires.IsZero() || absolute.Before(expires) {
+			expires = absolute
+		}
+	}
+	return expires
+}
+
+func (m *SessionManager) cookie(value string, maxAge int) *http.Cookie {
+	return &http.Cookie {
+		Name:     m.CookieName,
+		Value:    value,
+		Path:     m.Path,
+		Domain:   m.Domain,
+		MaxAge:   maxAge,
+		Secure:   m.Secure,
+		HttpOnly: m.HttpOnly,
+		SameSite: m.SameSite,
+	}
+}
+
+// Handler 获得会话中间件, 中间件在请求开始时读取会话, 在后续处理结束后保存会话
+func (m *SessionManager) Handler() *Handler {
+	if m.IdleTimeout <= 0 && m.AbsoluteTimeout <= 0 {
+		panic("session manager: IdleTimeout or AbsoluteTimeout must be positive")
+	}
+	return &Handler {
+		Doc: &Doc {
+			Title: "会话",
+			Desc:  "读取 cookie 中的会话 ID 并加载服务端会话, 会话不存在或已过期时创建新会话",
+		},
+		HandleFunc: func(ctx *Context) {
+			sess, err := m.load(ctx)
+			if err != nil {
+				ctx.Error(err)
+				return
+			}
+			ctx.session = sess
+			ctx.HandleNext()
+			err = sess.save()
+			if err != nil {
+				log.Error.Println(err)
+			}
+		},
+	}
+}
+
+func (m *SessionManager) load(ctx *Context) (*Session, error) {
+	now := time.Now()
+	sess := &Session{m: m, ctx: ctx}
+	if id, ok := ctx.Cookie(m.CookieName); ok && id != "" {
+		data, err := m.Store.Get(id)
+		if err != nil && err != session.ErrNotFound {
+			return nil, err
+		}
+		if data != nil && !m.expiresAt(data).After(now) {
+			// 超时设置变短时, 存储中的会话可能已按新设置过期
+			data = nil
+		}
+		if data != nil {
+			sess.data = data
+			sess.touched = now.Sub(data.AccessedAt) >= m.TouchInterval
+			if sess.touched {
+				data.AccessedAt = now
+			}
+			return sess, nil
+		}
+	}
+	sess.data = &session.Data {
+		ID:         session.NewID(),
+		IP:         ctx.ClientIP(),
+		UserAgent:  ctx.Request.UserAgent(),
+		CreatedAt:  now,
+		AccessedAt: now,
+	}
+	sess.isNew = true
+	return sess, nil
+}
+
+// Sessions 获得用户所有未过期的会话, 可用于展示登录设备
+func (m *SessionManager) Sessions(userID string) ([]*session.Data, error) {
+	return m.Store.List(userID)
+}
+
+// Revoke 撤销会话, 用户下次请求时会话已不存在
+func (m *SessionManager) Revoke(id string) error {
+	return m.Store.Delete(id)
+}
+
+// RevokeUser 撤销用户的所有会话, except 中的会话除外, 如: 修改密码后退出其他设备
+func (m *SessionManager) RevokeUser(userID string, except ...string) error {
+	sessions, err := m.Store.List(userID)
+	if err != nil {
+		return err
+	}
+	for _, data := range sessions {
+		if containsString(except, data.ID) {
+			continue
+		}
+		err = m.Store.Delete(data.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Session 为当前请求的会话, 修改在请求处理结束后保存. 新会话在第一次修改时才会保存并设置 cookie.
+// 由于 cookie 通过响应头设置, 修改会话需要在写入响应内容之前进行
+type Session struct {
+	m         *SessionManager
+	ctx       *Context
+	data      *session.Data
+	isNew     bool
+	dirty     bool
+	touched   bool
+	destroyed bool
+
+	// 更换 ID 前的会话 ID, 保存时删除
+	oldIDs []string
+}
+
+// Session 获得当前请求的会话, 需要使用 SessionManager.Handler 中间件
+func (c *Context) Session() *Session {
+	if c.session == nil {
+		panic("xx: session middleware is not used, see SessionManager.Handler")
+	}
+	return c.session
+}
+
+// ID 获得会话 ID
+func (s *Session) ID() string {
+	return s.data.ID
+}
+
+// UserID 获得登录用户标识, 未登录时为空
+func (s *Session) UserID() string {
+	return s.data.UserID
+}
+
+// Data 获得会话数据的副本
+func (s *Session) Data() session.Data {
+	return *s.data
+}
+
+// IsNew 判断是否为本次请求创建的会话
+func (s *Session) IsNew() bool {
+	return s.isNew
+}
+
+func (s *Session) modified() {
+	if s.isNew && !s.dirty {
+		s.setCookie()
+	}
+	s.dirty = true
+	s.destroyed = false
+}
+
+func (s *Session) setCookie() {
+	maxAge := 0
+	if s.m.AbsoluteTimeout > 0 {
+		maxAge = int(s.m.AbsoluteTimeout.Seconds())
+	}
+	s.ctx.SetCookie(s.m.cookie(s.data.ID, maxAge))
+}
+
+// Get 获得会话值
+func (s *Session) Get(key string) string {
+	return s.data.Values[key]
+}
+
+// Set 设置会话值
+func (s *Session) Set(key, value string) {
+	if s.data.Values == nil {
+		s.data.Values = make(map[string]string)
+	}
+	s.data.Values[key] = value
+	s.modified()
+}
+
+// Del 删除会话值
+func (s *Session) Del(key string) {
+	if _, ok := s.data.Values[key]; ok {
+		delete(s.data.Values, key)
+		s.modified()
+	}
+}
+
+// AddFlash 添加一次性消息, 消息在下次通过 Flashes 读取后删除, 常用于重定向后显示提示
+func (s *Session) AddFlash(key, msg string) {
+	if s.data.Flashes == nil {
+		s.data.Flashes = make(map[string][]string)
+	}
+	s.data.Flashes[key] = append(s.data.Flashes[key], msg)
+	s.modified()
+}
+
+// Flashes 读取并删除一次性消息
+func (s *Session) Flashes(key string) []string {
+	msgs, ok := s.data.Flashes[key]
+	if ok {
+		delete(s.data.Flashes, key)
+		s.modified()
+	}
+	return msgs
+}
+
+// Regenerate 更换会话 ID 并保留会话数据, 权限变化时应更换 ID, 防止会话固定攻击
+func (s *Session) Regenerate() {
+	if !s.isNew {
+		s.oldIDs = append(s.oldIDs, s.data.ID)
+	}
+	s.data.ID = session.NewID()
+	s.isNew = true
+	s.dirty = false
+	s.modified()
+}
+
+// Login 登录用户, 会更换会话 ID 并重新计算绝对超时时间
+func (s *Session) Login(userID string) {
+	s.data.UserID = userID
+	s.data.CreatedAt = time.Now()
+	s.data.AccessedAt = s.data.CreatedAt
+	s.Regenerate()
+}
+
+// Destroy 删除会话及 cookie, 用于退出登录
+func (s *Session) Destroy() {
+	if !s.isNew {
+		s.oldIDs = append(s.oldIDs, s.data.ID)
+	}
+	s.ctx.SetCookie(s.m.cookie("", -1))
+	s.data = &session.Data {
+		ID:         session.NewID(),
+		IP:         s.data.IP,
+		UserAgent:  s.data.UserAgent,
+		CreatedAt:  time.Now(),
+		AccessedAt: time.Now(),
+	}
+	s.isNew = true
+	s.dirty = false
+	s.destroyed = true
+}
+
+// 保存会话, 未修改的会话只在需要更新访问时间时保存
+func (s *Session) save() error {
+	for _, id := range s.oldIDs {
+		err := s.m.Store.Delete(id)
+		if err != nil {
+			return err
+		}
+	}
+	s.oldIDs = nil
+	if s.destroyed || !(s.dirty || s.touched) {
+		return nil
+	}
+	s.data.ExpiresAt = s.m.expiresAt(s.data)
+	return s.m.Store.Save(s.data)
+}
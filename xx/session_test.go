@@ -0,0 +1,166 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package xx_test
+
+import (
+	"github.com/orivil/morgine/utils/session"
+	"github.com/orivil/morgine/xx"
+	"github.com/orivil/morgine/xx/xxtest"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type sessionClient struct {
+	mux    *xx.ServeMux
+	cookie *http.Cookie
+}
+
+func (c *sessionClient) do(method, path string) (body string, cookie *http.Cookie) {
+	req := httptest.NewRequest(method, path, nil)
+	if c.cookie != nil {
+		req.AddCookie(c.cookie)
+	}
+	res := xxtest.Serve(c.mux, req)
+	for _, ck := range res.Result().Cookies() {
+		cookie = ck
+	}
+	if cookie != nil {
+		if cookie.MaxAge < 0 {
+			c.cookie = nil
+		} else {
+			c.cookie = cookie
+		}
+	}
+	return res.Body.String(), cookie
+}
+
+func newSessionMux(manager *xx.SessionManager) *xx.ServeMux {
+	mux, controller := xxtest.NewMux("sessions", manager.Handler())
+	controller.Handle("GET", "/visit", nil, func(ctx *xx.Context) {
+		sess := ctx.Session()
+		sess.Set("visits", sess.Get("visits")+"x")
+		ctx.WriteString(sess.Get("visits"))
+	})
+	controller.Handle("GET", "/read", nil, func(ctx *xx.Context) {
+		ctx.WriteString(ctx.Session().Get("visits"))
+	})
+	controller.Handle("POST", "/login", nil, func(ctx *xx.Context) {
+		ctx.Session().Login("42")
+		ctx.WriteString(ctx.Session().ID())
+	})
+	controller.Handle("POST", "/logout", nil, func(ctx *xx.Context) {
+		ctx.Session().Destroy()
+	})
+	controller.Handle("POST", "/flash", nil, func(ctx *xx.Context) {
+		ctx.Session().AddFlash("notice", "saved")
+	})
+	controller.Handle("GET", "/flash", nil, func(ctx *xx.Context) {
+		ctx.WriteString(strings.Join(ctx.Session().Flashes("notice"), ","))
+	})
+	return mux
+}
+
+func TestSessionManager(t *testing.T) {
+	store := session.NewMemoryStore()
+	manager := xx.NewSessionManager(store)
+	c := &sessionClient{mux: newSessionMux(manager)}
+
+	// 未修改的新会话不设置 cookie
+	if _, cookie := c.do("GET", "/read"); cookie != nil {
+		t.Errorf("need no cookie, got: %v", cookie)
+	}
+	body, cookie := c.do("GET", "/visit")
+	if body != "x" || cookie == nil {
+		t.Fatalf("need session cookie, got: %s %v", body, cookie)
+	}
+	if cookie.Name != "session" || !cookie.HttpOnly || !cookie.Secure || cookie.SameSite != http.SameSiteLaxMode {
+		t.Errorf("got cookie attributes: %+v", cookie)
+	}
+	if body, _ = c.do("GET", "/visit"); body != "xx" {
+		t.Errorf("need: xx, got: %s", body)
+	}
+
+	// 登录时更换会话 ID 并保留数据
+	oldID := c.cookie.Value
+	newID, _ := c.do("POST", "/login")
+	if newID == oldID || c.cookie.Value != newID {
+		t.Errorf("session id is not rotated: %s %s", oldID, newID)
+	}
+	if _, err := store.Get(oldID); err != session.ErrNotFound {
+		t.Errorf("old session need be deleted, got: %v", err)
+	}
+	if body, _ = c.do("GET", "/read"); body != "xx" {
+		t.Errorf("need: xx, got: %s", body)
+	}
+	sessions, err := manager.Sessions("42")
+	if err != nil || len(sessions) != 1 || sessions[0].ID != newID {
+		t.Errorf("need session %s, got: %v %v", newID, sessions, err)
+	}
+
+	c.do("POST", "/flash")
+	if body, _ = c.do("GET", "/flash"); body != "saved" {
+		t.Errorf("need: saved, got: %s", body)
+	}
+	if body, _ = c.do("GET", "/flash"); body != "" {
+		t.Errorf("flash need be deleted, got: %s", body)
+	}
+
+	if _, cookie = c.do("POST", "/logout"); cookie == nil || cookie.MaxAge >= 0 {
+		t.Errorf("need delete cookie, got: %v", cookie)
+	}
+	if _, err := store.Get(newID); err != session.ErrNotFound {
+		t.Errorf("session need be deleted, got: %v", err)
+	}
+}
+
+func TestSessionManager_Expires(t *testing.T) {
+	for name, set := range map[string]func(m *xx.SessionManager) {
+		"idle":     func(m *xx.SessionManager) { m.IdleTimeout, m.AbsoluteTimeout = 100*time.Millisecond, 0 },
+		"absolute": func(m *xx.SessionManager) { m.IdleTimeout, m.AbsoluteTimeout = 0, 160*time.Millisecond },
+	} {
+		manager := xx.NewSessionManager(session.NewMemoryStore())
+		manager.TouchInterval = 0
+		set(manager)
+		c := &sessionClient{mux: newSessionMux(manager)}
+		c.do("GET", "/visit")
+		// 访问会延长空闲超时, 但不会延长绝对超时
+		for i := 0; i < 3; i++ {
+			time.Sleep(60 * time.Millisecond)
+			body, _ := c.do("GET", "/read")
+			if need := name == "idle" || i < 2; need != (body == "x") {
+				t.Errorf("%s: %d visit got: %q", name, i, body)
+			}
+		}
+		time.Sleep(120 * time.Millisecond)
+		if body, _ := c.do("GET", "/read"); body != "" {
+			t.Errorf("%s: session need be expired, got: %s", name, body)
+		}
+	}
+}
+
+func TestSessionManager_RevokeUser(t *testing.T) {
+	manager := xx.NewSessionManager(session.NewMemoryStore())
+	mux := newSessionMux(manager)
+	var clients []*sessionClient
+	for i := 0; i < 3; i++ {
+		c := &sessionClient{mux: mux}
+		c.do("POST", "/login")
+		c.do("GET", "/visit")
+		clients = append(clients, c)
+	}
+	err := manager.RevokeUser("42", clients[0].cookie.Value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, c := range clients {
+		body, _ := c.do("GET", "/read")
+		if need := i == 0; need != (body == "x") {
+			t.Errorf("client %d got: %q", i, body)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package xx
+
+import (
+	"fmt"
+	"github.com/orivil/morgine/param"
+	"mime/multipart"
+	"net/textproto"
+	"strings"
+)
+
+// 获得字段的参数来源, 字段未设置 in 标签时使用参数声明的来源
+func fieldSource(typ ParamType, field *param.Field) ParamType {
+	if field.In != "" {
+		return ParamType(field.In)
+	}
+	return typ
+}
+
+// 获得参数所有字段的来源, 没有字段时为参数声明的来源
+func schemaSources(typ ParamType, schema *param.Schema) map[ParamType]bool {
+	if len(schema.Fields) == 0 {
+		return map[ParamType]bool{typ: true}
+	}
+	sources := make(map[ParamType]bool)
+	for _, field := range schema.Fields {
+		sources[fieldSource(typ, field)] = true
+	}
+	return sources
+}
+
+// 是否有字段通过 in 标签声明了不同的来源
+func isMixed(typ ParamType, schema *param.Schema) bool {
+	for _, field := range schema.Fields {
+		if fieldSource(typ, field) != typ {
+			return true
+		}
+	}
+	return false
+}
+
+// 按字段来源分别读取参数, 再合并为一个表单
+func newMixedMarshaler(typ ParamType, schema *param.Schema) (marshaler, error) {
+	if schema.IsStream() {
+		return nil, fmt.Errorf("parameter '%s': stream fields can not be mixed with other sources", schema.Type)
+	}
+	fields := make(map[ParamType][]*param.Field)
+	marshalers := make(map[ParamType]marshaler)
+	for _, field := range schema.Fields {
+		source := fieldSource(typ, field)
+		if _, ok := marshalers[source]; !ok {
+			m, err := newMarshaler(source, schema.EncodeType())
+			if err != nil {
+				return nil, fmt.Errorf("field '%s': %s", field.Name, err)
+			}
+			marshalers[source] = m
+		}
+		fields[source] = append(fields[source], field)
+	}
+	return func(ctx *Context) *multipart.Form {
+		form := &multipart.Form {
+			Value: make(map[string][]string),
+			File:  make(map[string][]*multipart.FileHeader),
+		}
+		for source, fs := range fields {
+			src := marshalers[source](ctx)
+			if src == nil {
+				continue
+			}
+			for _, field := range fs {
+				copyField(form, src, source, field.Name)
+			}
+		}
+		return form
+	}, nil
+}
+
+// 复制字段的值, 包括嵌套字段(name.sub, name[0].sub, name[key])及上传的文件
+func copyField(dst, src *multipart.Form, source ParamType, name string) {
+	if source == Header {
+		if values, ok := src.Value[textproto.CanonicalMIMEHeaderKey(name)]; ok {
+			dst.Value[name] = values
+		}
+		return
+	}
+	for key, values := range src.Value {
+		if isFieldKey(key, name) {
+			dst.Value[key] = values
+		}
+	}
+	for key, files := range src.File {
+		if isFieldKey(key, name) {
+			dst.File[key] = files
+		}
+	}
+}
+
+func isFieldKey(key, name string) bool {
+	if !strings.HasPrefix(key, name) {
+		return false
+	}
+	return len(key) == len(name) || key[len(name)] == '.' || key[len(name)] == '['
+}
@@ -0,0 +1,90 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package xx_test
+
+import (
+	"fmt"
+	"github.com/orivil/morgine/xx"
+	"github.com/orivil/morgine/xx/xxtest"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestContext_UnmarshalSources(t *testing.T) {
+	mux, controller := xxtest.NewMux("articles")
+	type params struct {
+		ID      int      `param:"id" in:"path"`
+		Status  []int    `param:"status" in:"query"`
+		Keyword string   `param:"keyword" in:"query"`
+		Token   string   `param:"X-Token" in:"header" required:"token required"`
+		Session string   `param:"session" in:"cookie"`
+		Name    string   `param:"name"`
+		Filter  struct {
+			Tag string `param:"tag"`
+		} `param:"filter" in:"query"`
+	}
+	doc := &xx.Doc{Params: xx.Params{{Type: xx.Form, Schema: &params{}}}}
+	controller.Handle("PUT", "/articles/{id}", doc, func(ctx *xx.Context) {
+		p := &params{}
+		err := ctx.Unmarshal(p)
+		if err != nil {
+			ctx.WriteString(err.Error())
+		} else {
+			ctx.WriteString(fmt.Sprintf("%d %v %s %s %s %s %s", p.ID, p.Status, p.Keyword, p.Token, p.Session, p.Name, p.Filter.Tag))
+		}
+	})
+
+	form := url.Values{"name": {"golang"}, "keyword": {"form value is ignored"}}
+	req := httptest.NewRequest("PUT", "/articles/5?status=1&status=2&keyword=go&filter.tag=news&name=ignored", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("x-token", "abc")
+	req.AddCookie(&http.Cookie{Name: "session", Value: "s1"})
+	res := xxtest.Serve(mux, req)
+	if need := "5 [1 2] go abc s1 golang news"; res.Body.String() != need {
+		t.Errorf("need: %s got: %s", need, res.Body.String())
+	}
+
+	var types []xx.ParamType
+	for _, acts := range mux.ApiDoc().Actions {
+		for _, p := range acts[0].Params {
+			var names []string
+			for _, field := range p.Fields {
+				names = append(names, field.Name)
+			}
+			types = append(types, xx.ParamType(string(p.Type)+":"+strings.Join(names, ",")))
+		}
+	}
+	need := "[cookie:session form:name header:X-Token path:id query:status,keyword,filter]"
+	if fmt.Sprint(types) != need {
+		t.Errorf("need: %s got: %v", need, types)
+	}
+}
+
+func TestHandle_InvalidSource(t *testing.T) {
+	_, controller := xxtest.NewMux("users")
+	type params struct {
+		Name string `param:"name" in:"form"`
+	}
+	mustPanic := func(name string, fn func()) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("%s: need panic", name)
+			}
+		}()
+		fn()
+	}
+	mustPanic("form field with GET", func() {
+		controller.Handle("GET", "/users", &xx.Doc{Params: xx.Params{{Type: xx.Query, Schema: &params{}}}}, nil)
+	})
+	type unknown struct {
+		Name string `param:"name" in:"body"`
+	}
+	mustPanic("unknown source", func() {
+		controller.Handle("GET", "/users", &xx.Doc{Params: xx.Params{{Type: xx.Query, Schema: &unknown{}}}}, nil)
+	})
+}
@@ -0,0 +1,58 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package xx_test
+
+import (
+	"bytes"
+	"github.com/orivil/morgine/param"
+	"github.com/orivil/morgine/xx"
+	"github.com/orivil/morgine/xx/xxtest"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestContext_UnmarshalStream(t *testing.T) {
+	mux, controller := xxtest.NewMux("files")
+	type form struct {
+		Name string              `param:"name"`
+		File param.StreamHandler `param:"file" size-Byte:"1-10"`
+	}
+	doc := &xx.Doc{Params: xx.Params{{Type: xx.Form, Schema: &form{}}}}
+	controller.Handle("POST", "/files", doc, func(ctx *xx.Context) {
+		var content []byte
+		f := &form {
+			File: func(field string, file *param.StreamFile) (err error) {
+				content, err = ioutil.ReadAll(file)
+				return err
+			},
+		}
+		err := ctx.Unmarshal(f)
+		if err != nil {
+			ctx.WriteString(err.Error())
+		} else {
+			ctx.WriteString(f.Name + ":" + string(content))
+		}
+	})
+	cases := map[string]string {
+		"hello":       "a:hello",
+		"hello world": "file: 1-10Byte",
+	}
+	for content, need := range cases {
+		body := &bytes.Buffer{}
+		w := multipart.NewWriter(body)
+		w.WriteField("name", "a")
+		fw, _ := w.CreateFormFile("file", "a.txt")
+		fw.Write([]byte(content))
+		w.Close()
+		req := httptest.NewRequest("POST", "/files", body)
+		req.Header.Set("Content-Type", w.FormDataContentType())
+		res := xxtest.Serve(mux, req)
+		if got := res.Body.String(); got != need {
+			t.Errorf("need: %s got: %s", need, got)
+		}
+	}
+}
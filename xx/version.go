@@ -0,0 +1,120 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package xx
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// 版本请求头, 如: "Api-Version: v2", 响应中也会通过该头信息返回实际处理请求的版本
+var VersionHeader = "Api-Version"
+
+// Accept 媒体类型中的版本参数名, 如: "Accept: application/json; version=v2"
+var VersionMediaParam = "version"
+
+// 弃用接口的响应头
+const (
+	headerKeyDeprecation = "Deprecation"
+	headerKeySunset      = "Sunset"
+)
+
+// 获得版本路由前缀, 如: "v1" => "/v1"
+func versionPrefix(version string) string {
+	return "/" + strings.Trim(version, "/")
+}
+
+// 获得客户端通过请求头或 Accept 媒体类型参数选择的版本, 版本必须已经注册, 否则返回空字符串.
+// 客户端可以省略版本前缀 "v", 如 "Api-Version: 2" 等同于 "Api-Version: v2"
+func (doc *ApiDoc) requestVersion(req *http.Request) string {
+	version := req.Header.Get(VersionHeader)
+	if version == "" {
+		for _, accept := range strings.Split(req.Header.Get("Accept"), ",") {
+			_, params, err := mime.ParseMediaType(accept)
+			if err == nil && params[VersionMediaParam] != "" {
+				version = params[VersionMediaParam]
+				break
+			}
+		}
+	}
+	if version == "" {
+		return ""
+	}
+	for _, v := range doc.Versions {
+		if v == version || v == "v"+version {
+			return v
+		}
+	}
+	return ""
+}
+
+func (doc *ApiDoc) addVersion(version string) {
+	for _, v := range doc.Versions {
+		if v == version {
+			return
+		}
+	}
+	doc.Versions = append(doc.Versions, version)
+}
+
+// Version 导出指定版本的接口文档, 未设置版本的接口属于所有版本
+func (doc *ApiDoc) Version(version string) *ApiDoc {
+	vd := newApiDoc()
+	vd.Tags = doc.Tags
+	vd.Versions = []string{version}
+	for tag, acts := range doc.Actions {
+		for _, act := range acts {
+			if act.Version == "" || act.Version == version {
+				vd.Actions[tag] = append(vd.Actions[tag], act)
+				for _, middle := range act.Middles {
+					vd.Middles[middle] = doc.Middles[middle]
+				}
+			}
+		}
+	}
+	return vd
+}
+
+// 设置版本及弃用信息响应头
+func (h *Handler) writeVersionHeaders(header http.Header) {
+	if h.version != "" {
+		header.Set(VersionHeader, h.version)
+	}
+	if h.Doc.Deprecated {
+		header.Set(headerKeyDeprecation, "true")
+		if h.Doc.Sunset != nil {
+			header.Set(headerKeySunset, h.Doc.Sunset.UTC().Format(http.TimeFormat))
+		}
+	}
+}
+
+// Version 返回指定 API 版本的 Condition, 通过该 Condition 注册的路由会自动加上版本前缀,
+// 如: 版本 "v1" 中注册的路由 "/login" 实际路由为 "/v1/login".
+//
+// 客户端可以通过以下 3 种方式选择版本:
+// 1. 路径前缀: "/v1/login"
+// 2. 请求头: "Api-Version: v1"
+// 3. Accept 媒体类型参数: "Accept: application/json; version=v1"
+func (g *Condition) Version(version string) *Condition {
+	version = strings.Trim(version, "/")
+	if version == "" {
+		panic("version is empty")
+	}
+	nc := g.copy()
+	nc.version = version
+	nc.ApiDoc.addVersion(version)
+	return nc
+}
+
+// Deprecate 将通过该 Condition 注册的所有接口标记为弃用, 通常用于弃用整个旧版本.
+// sunset 为接口停止服务的时间, 可以为 nil. 接口 Doc 中已设置的 Sunset 不会被覆盖.
+func (g *Condition) Deprecate(sunset *time.Time) *Condition {
+	nc := g.copy()
+	nc.deprecated = true
+	nc.sunset = sunset
+	return nc
+}
@@ -0,0 +1,93 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+package xx_test
+
+import (
+	"github.com/orivil/morgine/xx"
+	"github.com/orivil/morgine/xx/xxtest"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newVersionMux() *xx.ServeMux {
+	mux, group := xxtest.NewMux("users")
+	sunset := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	group.Version("v1").Deprecate(&sunset).Handle("GET", "/users", nil, func(ctx *xx.Context) {
+		ctx.WriteString("v1")
+	})
+	group.Version("v2").Handle("GET", "/users", nil, func(ctx *xx.Context) {
+		ctx.WriteString("v2")
+	})
+	group.Handle("GET", "/status", nil, func(ctx *xx.Context) {
+		ctx.WriteString("status")
+	})
+	return mux
+}
+
+func TestServeMux_Version(t *testing.T) {
+	mux := newVersionMux()
+	mux.DefaultVersion = "v2"
+	cases := []struct {
+		path    string
+		header  http.Header
+		body    string
+		version string
+	}{
+		{path: "/v1/users", body: "v1", version: "v1"},
+		{path: "/v2/users", body: "v2", version: "v2"},
+		{path: "/users", header: http.Header{"Api-Version": {"v1"}}, body: "v1", version: "v1"},
+		{path: "/users", header: http.Header{"Api-Version": {"1"}}, body: "v1", version: "v1"},
+		{path: "/users", header: http.Header{"Accept": {"text/html, application/json; version=v1"}}, body: "v1", version: "v1"},
+		{path: "/users", body: "v2", version: "v2"},
+		{path: "/status", header: http.Header{"Api-Version": {"v1"}}, body: "status"},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest("GET", c.path, nil)
+		for key, values := range c.header {
+			req.Header[key] = values
+		}
+		w := xxtest.Serve(mux, req)
+		if got := w.Body.String(); got != c.body {
+			t.Errorf("%s %v need body: %s got: %s", c.path, c.header, c.body, got)
+		}
+		if got := w.Header().Get(xx.VersionHeader); got != c.version {
+			t.Errorf("%s %v need version: %s got: %s", c.path, c.header, c.version, got)
+		}
+	}
+}
+
+func TestServeMux_Deprecation(t *testing.T) {
+	mux := newVersionMux()
+	w := xxtest.Serve(mux, httptest.NewRequest("GET", "/v1/users", nil))
+	if got := w.Header().Get("Deprecation"); got != "true" {
+		t.Errorf("need Deprecation: true, got: %s", got)
+	}
+	if need, got := "Fri, 01 Jan 2021 00:00:00 GMT", w.Header().Get("Sunset"); got != need {
+		t.Errorf("need Sunset: %s, got: %s", need, got)
+	}
+	w = xxtest.Serve(mux, httptest.NewRequest("GET", "/v2/users", nil))
+	if got := w.Header().Get("Deprecation"); got != "" {
+		t.Errorf("need no Deprecation header, got: %s", got)
+	}
+}
+
+func TestApiDoc_Version(t *testing.T) {
+	doc := newVersionMux().ApiDoc()
+	v1 := doc.Version("v1")
+	var routes []string
+	for _, acts := range v1.Actions {
+		for _, act := range acts {
+			routes = append(routes, act.Route)
+			if act.Route == "/v1/users" && !act.Deprecated {
+				t.Errorf("need deprecated action: %s", act.Route)
+			}
+		}
+	}
+	if len(routes) != 2 {
+		t.Errorf("need 2 actions(/v1/users, /status), got: %v", routes)
+	}
+}
@@ -0,0 +1,32 @@
+// Copyright 2020 orivil.com. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found at https://mit-license.org.
+
+// xxtest 为 xx 及其扩展的测试提供公共的路由与请求工具:
+//
+//	mux, controller := xxtest.NewMux("users")
+//	controller.Handle("GET", "/users", nil, handle)
+//	res := xxtest.Serve(mux, httptest.NewRequest("GET", "/users", nil))
+package xxtest
+
+import (
+	"github.com/orivil/morgine/router"
+	"github.com/orivil/morgine/xx"
+	"net/http"
+	"net/http/httptest"
+)
+
+// 创建不打印请求日志的 ServeMux 及以 tag 为标签的控制器, middles 作用于该控制器的所有接口
+func NewMux(tag string, middles ...*xx.Handler) (*xx.ServeMux, *xx.Condition) {
+	mux := xx.NewServeMux(router.NewRouter())
+	mux.RequestLogger = nil
+	name := xx.NewTagName(tag)
+	return mux, mux.NewGroup(xx.ApiTags{{Name: name}}).Use(middles...).Controller(name)
+}
+
+// 由 mux 处理请求并返回响应记录
+func Serve(mux *xx.ServeMux, req *http.Request) *httptest.ResponseRecorder {
+	res := httptest.NewRecorder()
+	mux.ServeHTTP(res, req)
+	return res
+}